@@ -0,0 +1,75 @@
+// Package acl decides what a user may do with a note. The owner of a note
+// may do anything, the users the note is shared with may do what the role
+// they were given allows.
+package acl
+
+// Role is what a user is to a note.
+type Role string
+
+const (
+	// Owner is the role of the user who created the note, it can't be
+	// granted
+	Owner     Role = "owner"
+	Editor    Role = "editor"
+	Commenter Role = "commenter"
+	Viewer    Role = "viewer"
+)
+
+// Action is something done to a note.
+type Action string
+
+const (
+	Read    Action = "read"
+	Comment Action = "comment"
+	// Edit changes the fields of the note, its tags included
+	Edit Action = "edit"
+	// Trash moves the note to the trash, out of it and purges it
+	Trash Action = "trash"
+	// Share manages who the note is shared with
+	Share Action = "share"
+)
+
+var permissions = map[Role][]Action{
+	Owner:     {Read, Comment, Edit, Trash, Share},
+	Editor:    {Read, Comment, Edit},
+	Commenter: {Read, Comment},
+	Viewer:    {Read},
+}
+
+// Roles returns the roles a note can be shared with, the weakest first.
+func Roles() []Role {
+	return []Role{Viewer, Commenter, Editor}
+}
+
+// ParseRole returns the role named name, false unless it can be granted.
+func ParseRole(name string) (Role, bool) {
+	for _, role := range Roles() {
+		if string(role) == name {
+			return role, true
+		}
+	}
+	return "", false
+}
+
+// Can tells whether role allows action, the empty role allows nothing.
+func (r Role) Can(action Action) bool {
+	for _, a := range permissions[r] {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleOf returns the role of user on a note of owner, granted is the role
+// the note was shared with user with, empty when it wasn't. A granted role
+// that can't be granted is ignored.
+func RoleOf(user, owner uint, granted Role) Role {
+	if user == owner {
+		return Owner
+	}
+	if role, ok := ParseRole(string(granted)); ok {
+		return role
+	}
+	return ""
+}
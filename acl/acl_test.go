@@ -0,0 +1,59 @@
+package acl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRole_Can(t *testing.T) {
+	allowed := map[Role][]Action{
+		Owner:     {Read, Comment, Edit, Trash, Share},
+		Editor:    {Read, Comment, Edit},
+		Commenter: {Read, Comment},
+		Viewer:    {Read},
+		"":        {},
+		"admin":   {},
+	}
+	for role, actions := range allowed {
+		for _, action := range []Action{Read, Comment, Edit, Trash, Share, "unknown"} {
+			expect := false
+			for _, a := range actions {
+				expect = expect || a == action
+			}
+			assert.Equal(t, expect, role.Can(action), "%q %q", role, action)
+		}
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	for _, role := range Roles() {
+		parsed, ok := ParseRole(string(role))
+		assert.True(t, ok, role)
+		assert.Equal(t, role, parsed)
+	}
+	for _, name := range []string{"owner", "Editor", "", "admin"} {
+		_, ok := ParseRole(name)
+		assert.False(t, ok, name)
+	}
+}
+
+func TestRoleOf(t *testing.T) {
+	cases := []struct {
+		user    uint
+		owner   uint
+		granted Role
+		expect  Role
+	}{
+		{1, 1, "", Owner},
+		{1, 1, Viewer, Owner},
+		{2, 1, Viewer, Viewer},
+		{2, 1, Commenter, Commenter},
+		{2, 1, Editor, Editor},
+		{2, 1, "", ""},
+		{2, 1, Owner, ""},
+		{2, 1, "admin", ""},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expect, RoleOf(c.user, c.owner, c.granted), "%d %d %q", c.user, c.owner, c.granted)
+	}
+}
@@ -0,0 +1,125 @@
+// Package apperr holds the errors of the business layer. They carry a kind
+// telling what went wrong and a stable code identifying the error, the
+// transports map the kind to their own status codes and translate the code
+// with the i18n catalog.
+package apperr
+
+import "errors"
+
+type Kind int
+
+const (
+	Internal Kind = iota
+	BadRequest
+	NotFound
+	Conflict
+	PreconditionFailed
+	Validation
+	// Unsupported is a request in a format that isn't supported
+	Unsupported
+	// Unauthorized is a request without valid credentials
+	Unauthorized
+	// Forbidden is a request of a user who may not do what it asks
+	Forbidden
+)
+
+func (k Kind) String() string {
+	switch k {
+	case BadRequest:
+		return "bad request"
+	case NotFound:
+		return "not found"
+	case Conflict:
+		return "conflict"
+	case PreconditionFailed:
+		return "precondition failed"
+	case Validation:
+		return "validation"
+	case Unsupported:
+		return "unsupported"
+	case Unauthorized:
+		return "unauthorized"
+	case Forbidden:
+		return "forbidden"
+	}
+	return "internal"
+}
+
+// Sentinels for errors.Is, an *Error matches the sentinel of its kind.
+var (
+	ErrInternal           = &Error{Kind: Internal}
+	ErrBadRequest         = &Error{Kind: BadRequest}
+	ErrNotFound           = &Error{Kind: NotFound}
+	ErrConflict           = &Error{Kind: Conflict}
+	ErrPreconditionFailed = &Error{Kind: PreconditionFailed}
+	ErrValidation         = &Error{Kind: Validation}
+	ErrUnsupported        = &Error{Kind: Unsupported}
+	ErrUnauthorized       = &Error{Kind: Unauthorized}
+	ErrForbidden          = &Error{Kind: Forbidden}
+)
+
+type Error struct {
+	Kind Kind
+	// Code is one of the error codes of lib
+	Code string
+	// Fields maps the invalid fields to their code, Validation only
+	Fields map[string]string
+	// Err is the cause, it is never shown to users
+	Err error
+}
+
+func (e *Error) Error() string {
+	code := e.Code
+	if code == "" {
+		code = e.Kind.String()
+	}
+	if e.Err != nil {
+		return code + ": " + e.Err.Error()
+	}
+	return code
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the sentinel of the kind of e.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Kind == e.Kind && t.Code == "" && t.Err == nil && t.Fields == nil
+}
+
+func New(kind Kind, code string) error {
+	return &Error{Kind: kind, Code: code}
+}
+
+// Wrap keeps err as the cause, errors.Is and errors.As still see it.
+func Wrap(err error, kind Kind, code string) error {
+	return &Error{Kind: kind, Code: code, Err: err}
+}
+
+// NewValidation returns a Validation error, fields maps every invalid
+// field to its code.
+func NewValidation(code string, fields map[string]string) error {
+	return &Error{Kind: Validation, Code: code, Fields: fields}
+}
+
+// FromError returns domain errors as they are and wraps any other error
+// as Internal, nil stays nil.
+func FromError(err error) error {
+	var e *Error
+	if err == nil || errors.As(err, &e) {
+		return err
+	}
+	return &Error{Kind: Internal, Err: err}
+}
+
+// KindOf returns the kind of the first *Error in the chain of err, errors
+// that aren't domain errors are Internal.
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return Internal
+}
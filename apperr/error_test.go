@@ -0,0 +1,44 @@
+package apperr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestError_Is(t *testing.T) {
+	cause := errors.New("storage: note title already exists")
+	err := Wrap(cause, Conflict, "note_title_already_exists")
+
+	assert.True(t, errors.Is(err, ErrConflict))
+	assert.True(t, errors.Is(err, cause))
+	assert.False(t, errors.Is(err, ErrNotFound))
+	assert.True(t, errors.Is(fmt.Errorf("insert: %w", err), ErrConflict))
+	assert.False(t, errors.Is(err, New(Conflict, "another_code")))
+	assert.Equal(t, "note_title_already_exists: storage: note title already exists", err.Error())
+}
+
+func TestError_As(t *testing.T) {
+	err := fmt.Errorf("update: %w", NewValidation("note_invalid", map[string]string{"title": "note_title_required"}))
+
+	var e *Error
+	assert.True(t, errors.As(err, &e))
+	assert.Equal(t, Validation, e.Kind)
+	assert.Equal(t, "note_title_required", e.Fields["title"])
+	assert.Equal(t, Validation, KindOf(err))
+}
+
+func TestFromError(t *testing.T) {
+	assert.Nil(t, FromError(nil))
+
+	notFound := New(NotFound, "note_not_exist")
+	assert.Equal(t, notFound, FromError(notFound))
+
+	err := FromError(context.DeadlineExceeded)
+	assert.Equal(t, Internal, KindOf(err))
+	assert.True(t, errors.Is(err, ErrInternal))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, Internal, KindOf(errors.New("boom")))
+}
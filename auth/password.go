@@ -0,0 +1,88 @@
+// Package auth hashes passwords, signs the tokens of the API and carries
+// the identity of the caller in request contexts.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"strings"
+)
+
+// ErrPasswordMismatch is returned by CheckPassword for a wrong password.
+var ErrPasswordMismatch = errors.New("auth: password does not match")
+
+// ErrHashInvalid is returned by CheckPassword for a hash it can't read.
+var ErrHashInvalid = errors.New("auth: invalid password hash")
+
+// The hashes are Argon2id, written as
+// argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<key> with the salt
+// and the key in unpadded base64. The scheme and the parameters are part
+// of the hash, so they can change without invalidating the stored
+// passwords.
+const hashScheme = "argon2id"
+
+const (
+	saltLength = 16
+	keyLength  = 32
+)
+
+// hashParams are the costs of new hashes, memory is in KiB. It is a var
+// so tests can make hashing cheap.
+var hashParams = struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+}{memory: 64 * 1024, time: 1, threads: 4}
+
+var encoding = base64.RawStdEncoding
+
+// HashPassword returns the hash of password with a new random salt.
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	p := hashParams
+	key := argon2.IDKey([]byte(password), salt, p.time, p.memory, p.threads, keyLength)
+	return strings.Join([]string{
+		hashScheme,
+		fmt.Sprintf("v=%d", argon2.Version),
+		fmt.Sprintf("m=%d,t=%d,p=%d", p.memory, p.time, p.threads),
+		encoding.EncodeToString(salt),
+		encoding.EncodeToString(key),
+	}, "$"), nil
+}
+
+// CheckPassword returns nil when password is the one hash was made of,
+// ErrPasswordMismatch when it is not.
+func CheckPassword(hash, password string) error {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 5 || parts[0] != hashScheme || parts[1] != fmt.Sprintf("v=%d", argon2.Version) {
+		return ErrHashInvalid
+	}
+	var memory, time uint32
+	var threads uint8
+	n, err := fmt.Sscanf(parts[2], "m=%d,t=%d,p=%d", &memory, &time, &threads)
+	if err != nil || n != 3 || memory == 0 || time == 0 || threads == 0 ||
+		parts[2] != fmt.Sprintf("m=%d,t=%d,p=%d", memory, time, threads) {
+		return ErrHashInvalid
+	}
+	salt, err := encoding.DecodeString(parts[3])
+	if err != nil {
+		return ErrHashInvalid
+	}
+	key, err := encoding.DecodeString(parts[4])
+	if err != nil || len(key) == 0 {
+		return ErrHashInvalid
+	}
+
+	computed := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(computed, key) != 1 {
+		return ErrPasswordMismatch
+	}
+	return nil
+}
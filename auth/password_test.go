@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func init() {
+	hashParams.memory = 64
+}
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "argon2id$v=19$m=64,t=1,p=4$"), hash)
+	assert.NoError(t, CheckPassword(hash, "correct horse"))
+	assert.Equal(t, ErrPasswordMismatch, CheckPassword(hash, "correct horse "))
+	assert.Equal(t, ErrPasswordMismatch, CheckPassword(hash, ""))
+
+	again, err := HashPassword("correct horse")
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, again, "the salt is random")
+}
+
+func TestCheckPassword_Params(t *testing.T) {
+	hash, err := HashPassword("secret")
+	require.NoError(t, err)
+
+	hashParams.time = 2
+	defer func() { hashParams.time = 1 }()
+	assert.NoError(t, CheckPassword(hash, "secret"), "the parameters come from the hash")
+}
+
+func TestCheckPassword_Invalid(t *testing.T) {
+	for _, hash := range []string{
+		"",
+		"secret",
+		"pbkdf2-sha256$10$c2FsdA$a2V5",
+		"argon2i$v=19$m=64,t=1,p=4$c2FsdA$a2V5",
+		"argon2id$v=16$m=64,t=1,p=4$c2FsdA$a2V5",
+		"argon2id$v=19$m=0,t=1,p=4$c2FsdA$a2V5",
+		"argon2id$v=19$m=64,t=x,p=4$c2FsdA$a2V5",
+		"argon2id$v=19$m=64,t=1,p=4,x=1$c2FsdA$a2V5",
+		"argon2id$v=19$m=64,t=1,p=4$!!$a2V5",
+		"argon2id$v=19$m=64,t=1,p=4$c2FsdA$",
+		"argon2id$v=19$m=64,t=1,p=4$c2FsdA$a2V5$",
+	} {
+		assert.Equal(t, ErrHashInvalid, CheckPassword(hash, "secret"), hash)
+	}
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrTokenInvalid is returned by Parse for a token that is malformed,
+	// not signed by the key or of another type.
+	ErrTokenInvalid = errors.New("auth: invalid token")
+	// ErrTokenExpired is returned by Parse for a valid token past its
+	// expiry.
+	ErrTokenExpired = errors.New("auth: token expired")
+	// ErrKeyTooShort is returned by NewSigner for a key under MinKeyLength
+	// bytes.
+	ErrKeyTooShort = errors.New("auth: signing key too short")
+)
+
+// MinKeyLength is the length of the smallest signing key NewSigner takes,
+// the size of a SHA-256 hash.
+const MinKeyLength = 32
+
+// TokenType tells access tokens, sent with every request, from refresh
+// tokens, only accepted to issue new tokens.
+type TokenType string
+
+const (
+	AccessToken  TokenType = "access"
+	RefreshToken TokenType = "refresh"
+)
+
+// Claims are the JWT claims of the tokens. Subject is the public id of the
+// user and ID, the jti, a ULID unique to the token.
+type Claims struct {
+	Subject   string    `json:"sub"`
+	ID        string    `json:"jti"`
+	Type      TokenType `json:"token_use"`
+	IssuedAt  int64     `json:"iat"`
+	ExpiresAt int64     `json:"exp"`
+}
+
+// Expiry returns ExpiresAt as a time.
+func (c *Claims) Expiry() time.Time {
+	return time.Unix(c.ExpiresAt, 0)
+}
+
+// Signer signs and verifies JWTs with HMAC-SHA256, it only accepts tokens
+// whose header says HS256 so no other algorithm can be forced on it.
+type Signer struct {
+	key []byte
+	now func() time.Time
+}
+
+// NewSigner returns a Signer using key, which must be at least
+// MinKeyLength bytes long.
+func NewSigner(key []byte) (*Signer, error) {
+	if len(key) < MinKeyLength {
+		return nil, ErrKeyTooShort
+	}
+	return &Signer{key: append([]byte(nil), key...), now: time.Now}, nil
+}
+
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+}
+
+var tokenEncoding = base64.RawURLEncoding
+
+// encodedHeader is the header of every token, HS256 is the only algorithm.
+var encodedHeader = func() string {
+	data, _ := json.Marshal(header{Algorithm: "HS256", Type: "JWT"})
+	return tokenEncoding.EncodeToString(data)
+}()
+
+// Sign returns the compact form of a JWT holding claims.
+func (s *Signer) Sign(claims *Claims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := encodedHeader + "." + tokenEncoding.EncodeToString(payload)
+	return unsigned + "." + tokenEncoding.EncodeToString(s.signature(unsigned)), nil
+}
+
+// Parse verifies token and returns its claims. The token must be of type
+// typ and not be expired.
+func (s *Signer) Parse(token string, typ TokenType) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenInvalid
+	}
+
+	var h header
+	if !decodeSegment(parts[0], &h) || h.Algorithm != "HS256" {
+		return nil, ErrTokenInvalid
+	}
+	signature, err := tokenEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, s.signature(parts[0]+"."+parts[1])) {
+		return nil, ErrTokenInvalid
+	}
+
+	var claims Claims
+	if !decodeSegment(parts[1], &claims) || claims.Type != typ || claims.Subject == "" || claims.ID == "" {
+		return nil, ErrTokenInvalid
+	}
+	if !s.now().Before(claims.Expiry()) {
+		return nil, ErrTokenExpired
+	}
+	return &claims, nil
+}
+
+func (s *Signer) signature(unsigned string) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(unsigned))
+	return mac.Sum(nil)
+}
+
+func decodeSegment(segment string, v interface{}) bool {
+	data, err := tokenEncoding.DecodeString(segment)
+	return err == nil && json.Unmarshal(data, v) == nil
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+func newTestSigner(t *testing.T, now time.Time) *Signer {
+	signer, err := NewSigner(testKey)
+	require.NoError(t, err)
+	signer.now = func() time.Time { return now }
+	return signer
+}
+
+func testClaims(now time.Time, typ TokenType) *Claims {
+	return &Claims{
+		Subject:   "01D7EX2VQ3AHDK6N2QXKWB0001",
+		ID:        "01D7EX2VQ3AHDK6N2QXKWB0002",
+		Type:      typ,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(time.Minute).Unix(),
+	}
+}
+
+func TestNewSigner(t *testing.T) {
+	_, err := NewSigner(testKey[:MinKeyLength-1])
+	assert.Equal(t, ErrKeyTooShort, err)
+}
+
+func TestSigner_Parse(t *testing.T) {
+	now := time.Unix(1550000000, 0)
+	signer := newTestSigner(t, now)
+	claims := testClaims(now, AccessToken)
+	token, err := signer.Sign(claims)
+	require.NoError(t, err)
+	assert.Equal(t, 3, len(strings.Split(token, ".")))
+
+	parsed, err := signer.Parse(token, AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, claims, parsed)
+
+	_, err = signer.Parse(token, RefreshToken)
+	assert.Equal(t, ErrTokenInvalid, err, "another type")
+
+	signer.now = func() time.Time { return now.Add(time.Minute) }
+	_, err = signer.Parse(token, AccessToken)
+	assert.Equal(t, ErrTokenExpired, err)
+}
+
+func TestSigner_Parse_Invalid(t *testing.T) {
+	now := time.Unix(1550000000, 0)
+	signer := newTestSigner(t, now)
+	token, err := signer.Sign(testClaims(now, AccessToken))
+	require.NoError(t, err)
+	parts := strings.Split(token, ".")
+
+	other, err := NewSigner([]byte("another key of at least 32 bytes"))
+	require.NoError(t, err)
+	otherToken, err := other.Sign(testClaims(now, AccessToken))
+	require.NoError(t, err)
+
+	// a token claiming "none" with the signature dropped
+	none := tokenEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+	// an HS512 header still signed with the key
+	hs512 := tokenEncoding.EncodeToString([]byte(`{"alg":"HS512","typ":"JWT"}`))
+	mac := hmac.New(sha256.New, testKey)
+	mac.Write([]byte(hs512 + "." + parts[1]))
+	hs512Signature := tokenEncoding.EncodeToString(mac.Sum(nil))
+
+	noSubject := testClaims(now, AccessToken)
+	noSubject.Subject = ""
+	noSubjectToken, err := signer.Sign(noSubject)
+	require.NoError(t, err)
+
+	cases := map[string]string{
+		"empty":          "",
+		"two parts":      parts[0] + "." + parts[1],
+		"other key":      otherToken,
+		"tampered":       parts[0] + "." + tokenEncoding.EncodeToString([]byte(`{"sub":"x","jti":"y","token_use":"access","exp":9999999999}`)) + "." + parts[2],
+		"none":           none + "." + parts[1] + ".",
+		"other alg":      hs512 + "." + parts[1] + "." + hs512Signature,
+		"bad signature":  parts[0] + "." + parts[1] + ".!!",
+		"no subject":     noSubjectToken,
+		"padded payload": parts[0] + "." + parts[1] + "=." + parts[2],
+	}
+	for name, token := range cases {
+		_, err := signer.Parse(token, AccessToken)
+		assert.Equal(t, ErrTokenInvalid, err, name)
+	}
+}
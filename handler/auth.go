@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lyquocnam/go-note-learning/apperr"
+	"github.com/lyquocnam/go-note-learning/auth"
+	"github.com/lyquocnam/go-note-learning/lib"
+	"github.com/lyquocnam/go-note-learning/model"
+	"github.com/lyquocnam/go-note-learning/repo"
+	"net/http"
+	"strings"
+)
+
+type authHandler struct {
+	router   *gin.Engine
+	authRepo repo.AuthRepo
+}
+
+// NewAuthHandler registers the account routes, router must use
+// ErrorHandler for the errors to be written.
+func NewAuthHandler(router *gin.Engine, authRepo repo.AuthRepo) *authHandler {
+	handler := &authHandler{
+		router:   router,
+		authRepo: authRepo,
+	}
+
+	authGroup := handler.router.Group("/auth")
+	authGroup.POST("/register", handler.Register)
+	authGroup.POST("/login", handler.Login)
+	authGroup.POST("/refresh", handler.Refresh)
+	authGroup.POST("/logout", handler.Logout)
+
+	return handler
+}
+
+type AuthHandler interface {
+	Register(c *gin.Context)
+	Login(c *gin.Context)
+	Refresh(c *gin.Context)
+	Logout(c *gin.Context)
+}
+
+func (h *authHandler) Response(c *gin.Context, data interface{}) {
+	// tokens must not be kept by caches
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, lib.NewResponse(http.StatusOK, "", data))
+}
+
+// Register serves POST /auth/register, the new user is logged in.
+func (h *authHandler) Register(c *gin.Context) {
+	var request model.CredentialsRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	tokens, err := h.authRepo.Register(c.Request.Context(), &request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.Response(c, tokens)
+}
+
+// Login serves POST /auth/login with the email and the password in the
+// body.
+func (h *authHandler) Login(c *gin.Context) {
+	var request model.CredentialsRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	tokens, err := h.authRepo.Login(c.Request.Context(), &request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.Response(c, tokens)
+}
+
+// Refresh serves POST /auth/refresh, the refresh token of the body can't
+// be used again.
+func (h *authHandler) Refresh(c *gin.Context) {
+	var request model.RefreshRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	tokens, err := h.authRepo.Refresh(c.Request.Context(), &request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.Response(c, tokens)
+}
+
+// Logout serves POST /auth/logout, the access tokens already issued stay
+// valid until they expire.
+func (h *authHandler) Logout(c *gin.Context) {
+	var request model.RefreshRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	err := h.authRepo.Logout(c.Request.Context(), &request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.Response(c, nil)
+}
+
+func bindJSON(c *gin.Context, request interface{}) bool {
+	err := c.ShouldBindJSON(request)
+	if err != nil {
+		c.Error(apperr.Wrap(err, apperr.BadRequest, lib.RequestBodyInvalid))
+		return false
+	}
+	return true
+}
+
+// IdentityProvider tells who sent a request, it returns an apperr error
+// when the request doesn't carry a valid identity and may set the headers
+// of the answer, like WWW-Authenticate.
+type IdentityProvider func(c *gin.Context) (*auth.Identity, error)
+
+// Authenticate only lets the requests with a valid access token in their
+// Authorization header through, see Identify.
+func Authenticate(authRepo repo.AuthRepo) gin.HandlerFunc {
+	return Identify(BearerToken(authRepo))
+}
+
+// Identify only lets the requests provider finds an identity in through,
+// the identity of the caller is added to the context of the request for
+// auth.IdentityFrom and the note storages only show the notes of that
+// user.
+func Identify(provider IdentityProvider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity, err := provider(c)
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		c.Request = c.Request.WithContext(auth.WithIdentity(c.Request.Context(), identity))
+		c.Next()
+	}
+}
+
+// BearerToken identifies the caller by the access token of the
+// Authorization header.
+func BearerToken(authRepo repo.AuthRepo) IdentityProvider {
+	return func(c *gin.Context) (*auth.Identity, error) {
+		token, ok := bearerToken(c.GetHeader("Authorization"))
+		if !ok {
+			c.Header("WWW-Authenticate", "Bearer")
+			return nil, apperr.New(apperr.Unauthorized, lib.AuthTokenRequired)
+		}
+
+		identity, err := authRepo.Authenticate(c.Request.Context(), token)
+		if apperr.KindOf(err) == apperr.Unauthorized {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		}
+		return identity, err
+	}
+}
+
+// UserHeader identifies the caller by the public id of a user in the
+// header name. The header is trusted as is, it is meant for the servers
+// behind a proxy that authenticates the requests and sets it.
+func UserHeader(name string, authRepo repo.AuthRepo) IdentityProvider {
+	return func(c *gin.Context) (*auth.Identity, error) {
+		publicID := strings.TrimSpace(c.GetHeader(name))
+		if publicID == "" {
+			return nil, apperr.New(apperr.Unauthorized, lib.AuthUserRequired)
+		}
+		return authRepo.Identify(c.Request.Context(), publicID)
+	}
+}
+
+// bearerToken returns the token of an Authorization header using the
+// Bearer scheme, the scheme is case insensitive.
+func bearerToken(header string) (string, bool) {
+	const scheme = "bearer "
+	if len(header) <= len(scheme) || !strings.EqualFold(header[:len(scheme)], scheme) {
+		return "", false
+	}
+	token := strings.TrimSpace(header[len(scheme):])
+	return token, token != ""
+}
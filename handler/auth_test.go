@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/lyquocnam/go-note-learning/auth"
+	"github.com/lyquocnam/go-note-learning/lib"
+	"github.com/lyquocnam/go-note-learning/model"
+	"github.com/lyquocnam/go-note-learning/repo"
+	"github.com/lyquocnam/go-note-learning/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newAuthTestRouter serves the note and tag routes behind Authenticate.
+func newAuthTestRouter(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler(), Recover())
+	router.NoRoute(NotFound)
+
+	signer, err := auth.NewSigner([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+	userStorage := storage.NewUserMemoryStorage()
+	authRepo := repo.NewAuthRepo(userStorage, signer, time.Minute, time.Hour)
+	NewAuthHandler(router, authRepo)
+	authenticate := Authenticate(authRepo)
+	noteRepo := repo.NewNoteRepo(storage.NewNoteMemoryStorage(), userStorage)
+	NewNoteHandler(router, noteRepo, authenticate)
+	NewTagHandler(router, repo.NewTagRepo(noteRepo), authenticate)
+	return router
+}
+
+func serveWithToken(router *gin.Engine, method, path, body, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func decodeTokens(t *testing.T, w *httptest.ResponseRecorder) (*lib.Response, *model.AuthTokens) {
+	var tokens model.AuthTokens
+	res := &lib.Response{Data: &tokens}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), res))
+	return res, &tokens
+}
+
+func TestAuthHandler(t *testing.T) {
+	router := newAuthTestRouter(t)
+
+	w := serve(router, http.MethodPost, "/auth/register", `{"email":"ann@example.com","password":"correct horse"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.NotContains(t, w.Body.String(), "password", "the hash is never sent")
+	_, registered := decodeTokens(t, w)
+	assert.Equal(t, "ann@example.com", registered.User.Email)
+	assert.Equal(t, "Bearer", registered.TokenType)
+	assert.Equal(t, 60, registered.ExpiresIn)
+
+	w = serve(router, http.MethodPost, "/auth/register", `{"email":"ann@example.com","password":"correct horse"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	res, _ := decodeTokens(t, w)
+	assert.Equal(t, lib.UserEmailAlreadyExistError, res.ErrorCode)
+
+	w = serve(router, http.MethodPost, "/auth/register", `{"email":"ann","password":"short"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w = serve(router, http.MethodPost, "/auth/login", `{"email":"ann@example.com","password":"wrong horse"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	res, _ = decodeTokens(t, w)
+	assert.Equal(t, lib.AuthCredentialsInvalid, res.ErrorCode)
+
+	w = serve(router, http.MethodPost, "/auth/login", `{"email":"ann@example.com","password":"correct horse"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	_, login := decodeTokens(t, w)
+
+	w = serve(router, http.MethodPost, "/auth/refresh", `{"refresh_token":"`+login.RefreshToken+`"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	_, refreshed := decodeTokens(t, w)
+	assert.NotEqual(t, login.RefreshToken, refreshed.RefreshToken)
+
+	w = serve(router, http.MethodPost, "/auth/refresh", `{"refresh_token":"`+login.RefreshToken+`"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "a refresh token is used once")
+	res, _ = decodeTokens(t, w)
+	assert.Equal(t, lib.AuthRefreshTokenInvalid, res.ErrorCode)
+
+	w = serve(router, http.MethodPost, "/auth/logout", `{"refresh_token":"`+registered.RefreshToken+`"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = serve(router, http.MethodPost, "/auth/refresh", `{"refresh_token":"`+registered.RefreshToken+`"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = serve(router, http.MethodPost, "/auth/refresh", `{"refresh_token":`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAuthenticate(t *testing.T) {
+	router := newAuthTestRouter(t)
+	w := serve(router, http.MethodPost, "/auth/register", `{"email":"ann@example.com","password":"correct horse"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	_, tokens := decodeTokens(t, w)
+
+	for _, path := range []string{"/notes/", "/tags/"} {
+		w := serve(router, http.MethodGet, path, "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code, path)
+		assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"), path)
+		res, _ := decodeTokens(t, w)
+		assert.Equal(t, lib.AuthTokenRequired, res.ErrorCode, path)
+
+		w = serveWithToken(router, http.MethodGet, path, "", tokens.AccessToken)
+		assert.Equal(t, http.StatusOK, w.Code, path)
+	}
+
+	w = serveWithToken(router, http.MethodPost, "/notes/", `{"title":"Hello"}`, tokens.AccessToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	cases := map[string]string{
+		"refresh token": "Bearer " + tokens.RefreshToken,
+		"garbage":       "Bearer garbage",
+	}
+	for name, header := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/notes/", nil)
+		req.Header.Set("Authorization", header)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, name)
+		assert.Equal(t, `Bearer error="invalid_token"`, w.Header().Get("WWW-Authenticate"), name)
+		res, _ := decodeTokens(t, w)
+		assert.Equal(t, lib.AuthTokenInvalid, res.ErrorCode, name)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/notes/", nil)
+	req.Header.Set("Authorization", "bearer "+tokens.AccessToken)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "the scheme is case insensitive")
+}
+
+func TestUserHeader(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler(), Recover())
+	userStorage := storage.NewUserMemoryStorage()
+	signer, err := auth.NewSigner([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+	authRepo := repo.NewAuthRepo(userStorage, signer, time.Minute, time.Hour)
+	NewNoteHandler(router, repo.NewNoteRepo(storage.NewNoteMemoryStorage(), userStorage), Identify(UserHeader("X-User-ID", authRepo)))
+
+	ann, err := userStorage.InsertUser(context.Background(), &model.User{Email: "ann@example.com"})
+	require.NoError(t, err)
+	bob, err := userStorage.InsertUser(context.Background(), &model.User{Email: "bob@example.com"})
+	require.NoError(t, err)
+
+	serveAs := func(user, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-User-ID", user)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	w := serveAs(ann.PublicID, http.MethodPost, "/notes/", `{"title":"Hello"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	_, note := decodeNote(t, w)
+	w = serveAs(ann.PublicID, http.MethodGet, "/notes/"+note.PublicID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = serveAs(bob.PublicID, http.MethodGet, "/notes/"+note.PublicID, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = serveAs("", http.MethodGet, "/notes/", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	res, _ := decodeTokens(t, w)
+	assert.Equal(t, lib.AuthUserRequired, res.ErrorCode)
+	assert.Empty(t, w.Header().Get("WWW-Authenticate"))
+
+	w = serveAs(missingID, http.MethodGet, "/notes/", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	res, _ = decodeTokens(t, w)
+	assert.Equal(t, lib.AuthUserInvalid, res.ErrorCode)
+}
+
+func TestBearerToken(t *testing.T) {
+	cases := []struct {
+		header string
+		token  string
+		ok     bool
+	}{
+		{"Bearer abc", "abc", true},
+		{"BEARER  abc ", "abc", true},
+		{"Bearer ", "", false},
+		{"Bearer", "", false},
+		{"Basic YW5uOnB3", "", false},
+		{"", "", false},
+	}
+	for _, c := range cases {
+		token, ok := bearerToken(c.header)
+		assert.Equal(t, c.ok, ok, c.header)
+		assert.Equal(t, c.token, token, c.header)
+	}
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// etag is the entity tag of a note version.
+func etag(version uint) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// parseIfMatch returns the note versions an If-Match header accepts, none
+// when the header is empty or "*". The comparison is strong: weak tags and
+// tags that are not versions never match, ok is false when no tag of the
+// list can match or the list is malformed.
+func parseIfMatch(header string) (versions []uint, ok bool) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil, true
+	}
+	for header != "" {
+		weak := strings.HasPrefix(header, "W/")
+		if weak {
+			header = header[2:]
+		}
+		// an entity tag may contain commas, it ends at its closing quote
+		if !strings.HasPrefix(header, `"`) {
+			return nil, false
+		}
+		end := strings.IndexByte(header[1:], '"') + 1
+		if end == 0 {
+			return nil, false
+		}
+		value, err := strconv.ParseUint(header[1:end], 10, 32)
+		if !weak && err == nil && value != 0 {
+			versions = append(versions, uint(value))
+		}
+
+		header = strings.TrimLeft(header[end+1:], " \t")
+		if header == "" {
+			break
+		}
+		if header[0] != ',' {
+			return nil, false
+		}
+		header = strings.TrimLeft(header[1:], " \t")
+	}
+	return versions, len(versions) > 0
+}
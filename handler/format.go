@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"github.com/lyquocnam/go-note-learning/markdown"
+	"github.com/lyquocnam/go-note-learning/model"
+	"html"
+)
+
+const (
+	mimeMarkdown = "text/markdown"
+	mimeHTML     = "text/html"
+	mimeProblem  = "application/problem+json"
+)
+
+// noteMarkdown is the Markdown representation of a note, its title as a
+// heading followed by its content.
+func noteMarkdown(note *model.Note) string {
+	text := "# " + note.Title + "\n"
+	if note.Content != "" {
+		text += "\n" + note.Content
+	}
+	return text
+}
+
+// noteHTML renders a note as an HTML fragment that is safe to embed, the
+// title is escaped and the content sanitized by markdown.ToHTML.
+func noteHTML(note *model.Note) string {
+	return "<h1>" + html.EscapeString(note.Title) + "</h1>\n" + markdown.ToHTML(note.Content)
+}
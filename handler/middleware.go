@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/lyquocnam/go-note-learning/apperr"
+	"github.com/lyquocnam/go-note-learning/i18n"
+	"github.com/lyquocnam/go-note-learning/lib"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// Timeout bounds the context of every request, storages stop their work
+// once it expires. A zero timeout leaves requests unbounded.
+func Timeout(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// ErrorHandler writes the response of the error a handler added with
+// c.Error, apperr kinds become HTTP statuses. The error code is translated
+// in the language negotiated from Accept-Language. Internal errors are
+// hidden behind a generic code, gin's logger still prints them. Requests
+// the client canceled are not failures of the server, their errors are
+// made public so the logger leaves them out.
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		for _, err := range c.Errors {
+			if errors.Is(err.Err, context.Canceled) {
+				err.Type = gin.ErrorTypePublic
+			}
+		}
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		language := i18n.Negotiate(c.GetHeader("Accept-Language"))
+		res := errorResponse(c.Errors.Last().Err, language)
+		setLanguage(c, language)
+		writeError(c, res)
+	}
+}
+
+// Recover turns a panic of the next handlers into an internal error, it
+// must come after ErrorHandler for the error to be written.
+func Recover() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if r := recover(); r != nil {
+				if r == http.ErrAbortHandler {
+					panic(r)
+				}
+				log.Printf("panic serving %s %s: %v\n%s", c.Request.Method, c.Request.URL.Path, r, debug.Stack())
+				c.Error(fmt.Errorf("handler: panic: %v", r))
+				c.Abort()
+			}
+		}()
+		c.Next()
+	}
+}
+
+// NotFound answers the requests no route matches, it is meant for
+// gin.Engine.NoRoute.
+func NotFound(c *gin.Context) {
+	c.Error(apperr.New(apperr.NotFound, lib.RouteNotFound))
+}
+
+// writeError writes an error response as application/problem+json when
+// the client prefers it to application/json, in the usual envelope
+// otherwise.
+func writeError(c *gin.Context, res *lib.Response) {
+	if c.NegotiateFormat(gin.MIMEJSON, mimeProblem) == mimeProblem {
+		c.Header("Content-Type", mimeProblem)
+		c.JSON(res.Code, lib.NewProblem(res, c.Request.URL.RequestURI()))
+		return
+	}
+	c.JSON(res.Code, res)
+}
+
+// errorResponse is the response of err with its code translated in
+// language.
+func errorResponse(err error, language string) *lib.Response {
+	status, code := http.StatusInternalServerError, lib.InternalError
+	var e *apperr.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		status, code = http.StatusGatewayTimeout, lib.RequestTimeoutError
+	case errors.Is(err, context.Canceled):
+		status, code = statusClientClosedRequest, lib.RequestCanceledError
+	case errors.As(err, &e) && e.Kind != apperr.Internal:
+		status, code = errorStatus[e.Kind], e.Code
+	}
+
+	res := lib.NewErrorReponse(status, code, i18n.Translate(language, code))
+	if e != nil && len(e.Fields) > 0 {
+		res.Details = make(map[string]string, len(e.Fields))
+		for field, fieldCode := range e.Fields {
+			res.Details[field] = i18n.Translate(language, fieldCode)
+		}
+	}
+	return res
+}
+
+// setLanguage tells caches the response depends on Accept-Language.
+func setLanguage(c *gin.Context, language string) {
+	c.Header("Content-Language", language)
+	c.Header("Vary", "Accept-Language")
+}
+
+// statusClientClosedRequest is the status nginx logs for the requests the
+// client closed, the client never reads it.
+const statusClientClosedRequest = 499
+
+var errorStatus = map[apperr.Kind]int{
+	apperr.Internal:           http.StatusInternalServerError,
+	apperr.BadRequest:         http.StatusBadRequest,
+	apperr.NotFound:           http.StatusNotFound,
+	apperr.Conflict:           http.StatusConflict,
+	apperr.PreconditionFailed: http.StatusPreconditionFailed,
+	apperr.Validation:         http.StatusUnprocessableEntity,
+	apperr.Unsupported:        http.StatusUnsupportedMediaType,
+	apperr.Unauthorized:       http.StatusUnauthorized,
+	apperr.Forbidden:          http.StatusForbidden,
+}
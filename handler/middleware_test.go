@@ -0,0 +1,214 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/lyquocnam/go-note-learning/apperr"
+	"github.com/lyquocnam/go-note-learning/i18n"
+	"github.com/lyquocnam/go-note-learning/lib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestErrorHandler(t *testing.T) {
+	cases := []struct {
+		name      string
+		err       error
+		code      int
+		errorCode string
+		details   map[string]string
+	}{
+		{
+			name:      "case not found",
+			err:       apperr.New(apperr.NotFound, lib.NoteNotExistError),
+			code:      http.StatusNotFound,
+			errorCode: lib.NoteNotExistError,
+		},
+		{
+			name:      "case wrapped conflict",
+			err:       fmt.Errorf("insert: %w", apperr.New(apperr.Conflict, lib.NoteTitleAlreadyExistError)),
+			code:      http.StatusConflict,
+			errorCode: lib.NoteTitleAlreadyExistError,
+		},
+		{
+			name:      "case validation",
+			err:       apperr.NewValidation(lib.RequestQueryInvalid, map[string]string{"limit": lib.NoteListLimitRange}),
+			code:      http.StatusUnprocessableEntity,
+			errorCode: lib.RequestQueryInvalid,
+			details:   map[string]string{"limit": "Số lượng phải từ 1 - 100"},
+		},
+		{
+			name:      "case internal hides the cause",
+			err:       apperr.Wrap(errors.New("connection refused"), apperr.Internal, "connection refused"),
+			code:      http.StatusInternalServerError,
+			errorCode: lib.InternalError,
+		},
+		{
+			name:      "case plain error",
+			err:       errors.New("connection refused"),
+			code:      http.StatusInternalServerError,
+			errorCode: lib.InternalError,
+		},
+		{
+			name:      "case deadline",
+			err:       apperr.FromError(context.DeadlineExceeded),
+			code:      http.StatusGatewayTimeout,
+			errorCode: lib.RequestTimeoutError,
+		},
+		{
+			name:      "case canceled",
+			err:       fmt.Errorf("list: %w", apperr.FromError(context.Canceled)),
+			code:      499,
+			errorCode: lib.RequestCanceledError,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(ErrorHandler())
+			router.GET("/", func(ctx *gin.Context) {
+				ctx.Error(c.err)
+			})
+
+			w := serve(router, http.MethodGet, "/", "")
+			assert.Equal(t, c.code, w.Code)
+			res, _ := decodeNote(t, w)
+			assert.Equal(t, c.code, res.Code)
+			assert.Equal(t, c.errorCode, res.ErrorCode)
+			assert.Equal(t, i18n.Translate(i18n.DefaultLanguage, c.errorCode), res.Message)
+			assert.Equal(t, c.details, res.Details)
+		})
+	}
+}
+
+func TestErrorHandler_Language(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.GET("/", func(ctx *gin.Context) {
+		ctx.Error(apperr.NewValidation(lib.NoteInvalid, map[string]string{"title": lib.NoteTitleLength}))
+	})
+
+	cases := []struct {
+		acceptLanguage string
+		language       string
+		message        string
+		title          string
+	}{
+		{"", "vi", "Dữ liệu note không hợp lệ", "Tiêu đề phải từ 1 - 80 ký tự"},
+		{"en-US,en;q=0.9", "en", "The note is invalid", "The title must be 1 to 80 characters long"},
+		{"fr;q=0.9,vi;q=0.5", "vi", "Dữ liệu note không hợp lệ", "Tiêu đề phải từ 1 - 80 ký tự"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Language", c.acceptLanguage)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		res, _ := decodeNote(t, w)
+		assert.Equal(t, lib.NoteInvalid, res.ErrorCode, c.acceptLanguage)
+		assert.Equal(t, c.message, res.Message, c.acceptLanguage)
+		assert.Equal(t, map[string]string{"title": c.title}, res.Details, c.acceptLanguage)
+		assert.Equal(t, c.language, w.Header().Get("Content-Language"), c.acceptLanguage)
+	}
+}
+
+func TestErrorHandler_Problem(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.GET("/notes/", func(ctx *gin.Context) {
+		ctx.Error(apperr.NewValidation(lib.RequestQueryInvalid, map[string]string{"sort": lib.NoteListSortInvalid, "limit": lib.NoteListLimitRange}))
+	})
+
+	cases := []struct {
+		accept  string
+		problem bool
+	}{
+		{"", false},
+		{"application/json", false},
+		{"application/problem+json", true},
+		{"application/problem+json, application/json", true},
+		{"application/json, application/problem+json", false},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/notes/?limit=0&sort=size", nil)
+		req.Header.Set("Accept", c.accept)
+		req.Header.Set("Accept-Language", "en")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code, c.accept)
+
+		if !c.problem {
+			assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"), c.accept)
+			res, _ := decodeNote(t, w)
+			assert.Equal(t, lib.RequestQueryInvalid, res.ErrorCode, c.accept)
+			continue
+		}
+		assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"), c.accept)
+		var problem lib.Problem
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+		assert.Equal(t, lib.Problem{
+			Type:     "urn:note-learning:error:request_query_invalid",
+			Title:    "The query parameters are invalid",
+			Status:   http.StatusUnprocessableEntity,
+			Detail:   "limit: The limit must be between 1 and 100; sort: The sort order is invalid",
+			Instance: "/notes/?limit=0&sort=size",
+			Code:     lib.RequestQueryInvalid,
+			Fields:   map[string]string{"limit": "The limit must be between 1 and 100", "sort": "The sort order is invalid"},
+		}, problem, c.accept)
+	}
+}
+
+func TestErrorHandler_Canceled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var private string
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Next()
+		// what gin's logger prints
+		private = c.Errors.ByType(gin.ErrorTypePrivate).String()
+	}, ErrorHandler())
+	router.GET("/", func(ctx *gin.Context) {
+		ctx.Error(errors.New("connection refused"))
+		ctx.Error(apperr.FromError(context.Canceled))
+	})
+
+	w := serve(router, http.MethodGet, "/", "")
+	assert.Equal(t, 499, w.Code)
+	assert.Contains(t, private, "connection refused")
+	assert.NotContains(t, private, context.Canceled.Error())
+}
+
+func TestRecover(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler(), Recover())
+	router.GET("/", func(ctx *gin.Context) {
+		var notes map[uint]string
+		notes[1] = "nil map"
+	})
+
+	w := serve(router, http.MethodGet, "/", "")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	res, _ := decodeNote(t, w)
+	assert.Equal(t, lib.InternalError, res.ErrorCode)
+}
+
+func TestNotFound(t *testing.T) {
+	router := newTestRouter()
+	for _, path := range []string{"/missing", "/notes/1/missing"} {
+		w := serve(router, http.MethodGet, path, "")
+		assert.Equal(t, http.StatusNotFound, w.Code, path)
+		res, _ := decodeNote(t, w)
+		assert.Equal(t, http.StatusNotFound, res.Code, path)
+		assert.Equal(t, lib.RouteNotFound, res.ErrorCode, path)
+	}
+}
@@ -1,12 +1,16 @@
 package handler
 
 import (
+	validator "github.com/asaskevich/govalidator"
 	"github.com/gin-gonic/gin"
+	"github.com/lyquocnam/go-note-learning/apperr"
+	"github.com/lyquocnam/go-note-learning/i18n"
+	"github.com/lyquocnam/go-note-learning/jsonpatch"
 	"github.com/lyquocnam/go-note-learning/lib"
 	"github.com/lyquocnam/go-note-learning/model"
 	"github.com/lyquocnam/go-note-learning/repo"
+	"github.com/lyquocnam/go-note-learning/ulid"
 	"net/http"
-	"strconv"
 )
 
 type noteHandler struct {
@@ -14,115 +18,424 @@ type noteHandler struct {
 	noteRepo repo.NoteRepo
 }
 
-func NewNoteHandler(router *gin.Engine, noteRepo repo.NoteRepo) *noteHandler {
+// NewNoteHandler registers the note routes behind middleware, Authenticate
+// in production. router must use ErrorHandler for the errors to be written.
+func NewNoteHandler(router *gin.Engine, noteRepo repo.NoteRepo, middleware ...gin.HandlerFunc) *noteHandler {
 	handler := &noteHandler{
 		router:   router,
 		noteRepo: noteRepo,
 	}
 
-	notesGroup := handler.router.Group("/notes")
+	notesGroup := handler.router.Group("/notes", middleware...)
 	notesGroup.GET("/", handler.GetList)
 	notesGroup.GET("/:id", handler.Get)
+	notesGroup.GET("/:id/html", handler.GetHTML)
 
 	notesGroup.POST("/", handler.Add)
+	notesGroup.POST("/:id", handler.Batch)
 	notesGroup.PUT("/:id", handler.Update)
+	notesGroup.PATCH("/:id", handler.Patch)
 	notesGroup.DELETE("/:id", handler.Delete)
 
+	// GET /notes/trash and /notes/search are served by Get and POST
+	// /notes/batch by Batch, gin can't route a static segment next to :id
+	notesGroup.POST("/:id/restore", handler.Restore)
+	notesGroup.DELETE("/:id/purge", handler.Purge)
+	notesGroup.POST("/:id/tags", handler.AddTags)
+	notesGroup.DELETE("/:id/tags/:tag", handler.RemoveTag)
+	notesGroup.GET("/:id/shares", handler.GetShares)
+	notesGroup.POST("/:id/shares", handler.Share)
+	notesGroup.DELETE("/:id/shares/:user", handler.Unshare)
+	notesGroup.GET("/:id/comments", handler.GetComments)
+	notesGroup.POST("/:id/comments", handler.Comment)
+
 	return handler
 }
 
 type NoteHandler interface {
 	Get(c *gin.Context)
+	GetHTML(c *gin.Context)
 	GetList(c *gin.Context)
+	Search(c *gin.Context)
 	Add(c *gin.Context)
 	Update(c *gin.Context)
+	Patch(c *gin.Context)
 	Delete(c *gin.Context)
+	GetTrash(c *gin.Context)
+	Restore(c *gin.Context)
+	Purge(c *gin.Context)
+	Batch(c *gin.Context)
+	AddTags(c *gin.Context)
+	RemoveTag(c *gin.Context)
+	GetShares(c *gin.Context)
+	Share(c *gin.Context)
+	Unshare(c *gin.Context)
+	GetComments(c *gin.Context)
+	Comment(c *gin.Context)
 }
 
-func (h *noteHandler) Response(c *gin.Context, data interface{}, code int, err error) {
-	var message string
-	if err != nil {
-		message = err.Error()
-	}
-	c.JSON(code, lib.NewResponse(code, message, data))
+// Response writes a successful response, errors go through c.Error.
+func (h *noteHandler) Response(c *gin.Context, data interface{}) {
+	c.JSON(http.StatusOK, lib.NewResponse(http.StatusOK, "", data))
 }
 
 func (h *noteHandler) Get(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
+	switch c.Param("id") {
+	case "trash":
+		h.GetTrash(c)
+		return
+	case "search":
+		h.Search(c)
+		return
+	}
+
+	id, ok := h.bindID(c)
+	if !ok {
+		return
+	}
+
+	note, err := h.noteRepo.Get(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": err.Error(),
-		})
+		c.Error(err)
 		return
 	}
 
-	note, err := h.noteRepo.Get(uint(id))
-	if err != nil || note == nil {
-		h.Response(c, nil, 404, err)
+	c.Header("ETag", etag(note.Version))
+	c.Header("Vary", "Accept")
+	if c.NegotiateFormat(gin.MIMEJSON, mimeMarkdown) == mimeMarkdown {
+		c.Data(http.StatusOK, mimeMarkdown+"; charset=utf-8", []byte(noteMarkdown(note)))
+		return
+	}
+	h.Response(c, note)
+}
+
+// GetHTML serves GET /notes/:id/html, the note rendered as an HTML
+// fragment.
+func (h *noteHandler) GetHTML(c *gin.Context) {
+	id, ok := h.bindID(c)
+	if !ok {
+		return
+	}
+
+	note, err := h.noteRepo.Get(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	h.Response(c, note, 200, nil)
+	c.Header("ETag", etag(note.Version))
+	// the fragment has no scripts nor styles, a page opening it directly
+	// doesn't need any
+	c.Header("Content-Security-Policy", "default-src 'none'; img-src *")
+	c.Data(http.StatusOK, mimeHTML+"; charset=utf-8", []byte(noteHTML(note)))
 }
 
 func (h *noteHandler) GetList(c *gin.Context) {
-	notes, err := h.noteRepo.GetList()
+	request, ok := h.bindListRequest(c)
+	if !ok {
+		return
+	}
 
+	page, err := h.noteRepo.GetList(c.Request.Context(), request)
 	if err != nil {
-		c.JSON(http.StatusForbidden, gin.H{
-			"message": err.Error(),
-		})
+		c.Error(err)
+		return
+	}
+	h.Response(c, page)
+}
+
+// Search serves GET /notes/search?q=, the best matches first.
+func (h *noteHandler) Search(c *gin.Context) {
+	var request model.NoteSearchRequest
+	err := c.ShouldBindQuery(&request)
+	if err != nil {
+		c.Error(apperr.Wrap(err, apperr.BadRequest, lib.RequestQueryInvalid))
+		return
+	}
+	if _, err := request.Validate(); err != nil {
+		c.Error(validationError(err, lib.RequestQueryInvalid))
+		return
+	}
+
+	page, err := h.noteRepo.Search(c.Request.Context(), &request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.Response(c, page)
+}
+
+func (h *noteHandler) GetTrash(c *gin.Context) {
+	request, ok := h.bindListRequest(c)
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusOK, notes)
+	page, err := h.noteRepo.GetTrash(c.Request.Context(), request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.Response(c, page)
 }
 
 func (h *noteHandler) Add(c *gin.Context) {
 	var note model.NoteRequest
-	err := c.BindJSON(&note)
+	err := c.ShouldBindJSON(&note)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": err.Error(),
-		})
+		c.Error(apperr.Wrap(err, apperr.BadRequest, lib.RequestBodyInvalid))
 		return
 	}
 
-	result, code, err := h.noteRepo.Insert(&note)
-	h.Response(c, result, code, err)
+	result, err := h.noteRepo.Insert(c.Request.Context(), &note)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.Header("ETag", etag(result.Version))
+	h.Response(c, result)
 }
 
 func (h *noteHandler) Update(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": err.Error(),
-		})
+	id, ok := h.bindID(c)
+	if !ok {
 		return
 	}
 
 	var note model.NoteRequest
-	err = c.BindJSON(&note)
+	err := c.ShouldBindJSON(&note)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": err.Error(),
-		})
+		c.Error(apperr.Wrap(err, apperr.BadRequest, lib.RequestBodyInvalid))
+		return
+	}
+
+	versions, ok := parseIfMatch(c.GetHeader("If-Match"))
+	if !ok {
+		c.Error(apperr.New(apperr.PreconditionFailed, lib.NoteVersionMismatchError))
 		return
 	}
 
-	result, code, err := h.noteRepo.Update(uint(id), &note)
-	h.Response(c, result, code, err)
+	result, err := h.noteRepo.Update(c.Request.Context(), id, &note, versions)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.Header("ETag", etag(result.Version))
+	h.Response(c, result)
+}
+
+// Patch accepts application/merge-patch+json and application/json-patch+json.
+func (h *noteHandler) Patch(c *gin.Context) {
+	id, ok := h.bindID(c)
+	if !ok {
+		return
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		c.Error(apperr.Wrap(err, apperr.BadRequest, lib.RequestBodyInvalid))
+		return
+	}
+	var patch jsonpatch.Patcher
+	switch c.ContentType() {
+	case "application/merge-patch+json":
+		patch, err = jsonpatch.DecodeMergePatch(body)
+	case "application/json-patch+json":
+		patch, err = jsonpatch.DecodePatch(body)
+	default:
+		c.Header("Accept-Patch", "application/merge-patch+json, application/json-patch+json")
+		c.Error(apperr.New(apperr.Unsupported, lib.RequestContentTypeUnsupported))
+		return
+	}
+	if err != nil {
+		c.Error(apperr.Wrap(err, apperr.BadRequest, lib.RequestBodyInvalid))
+		return
+	}
+
+	versions, ok := parseIfMatch(c.GetHeader("If-Match"))
+	if !ok {
+		c.Error(apperr.New(apperr.PreconditionFailed, lib.NoteVersionMismatchError))
+		return
+	}
+
+	result, err := h.noteRepo.Patch(c.Request.Context(), id, patch, versions)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.Header("ETag", etag(result.Version))
+	h.Response(c, result)
 }
 
 func (h *noteHandler) Delete(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
+	id, ok := h.bindID(c)
+	if !ok {
+		return
+	}
+
+	deleted, err := h.noteRepo.Delete(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.Response(c, deleted)
+}
+
+func (h *noteHandler) Restore(c *gin.Context) {
+	id, ok := h.bindID(c)
+	if !ok {
+		return
+	}
+
+	note, err := h.noteRepo.Restore(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.Response(c, note)
+}
+
+func (h *noteHandler) Purge(c *gin.Context) {
+	id, ok := h.bindID(c)
+	if !ok {
+		return
+	}
+
+	purged, err := h.noteRepo.Purge(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.Response(c, purged)
+}
+
+// AddTags serves POST /notes/:id/tags, the tags the note already has are
+// left as they are.
+func (h *noteHandler) AddTags(c *gin.Context) {
+	id, ok := h.bindID(c)
+	if !ok {
+		return
+	}
+
+	var request model.NoteTagsRequest
+	err := c.ShouldBindJSON(&request)
+	if err != nil {
+		c.Error(apperr.Wrap(err, apperr.BadRequest, lib.RequestBodyInvalid))
+		return
+	}
+
+	versions, ok := parseIfMatch(c.GetHeader("If-Match"))
+	if !ok {
+		c.Error(apperr.New(apperr.PreconditionFailed, lib.NoteVersionMismatchError))
+		return
+	}
+
+	result, err := h.noteRepo.AddTags(c.Request.Context(), id, &request, versions)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.Header("ETag", etag(result.Version))
+	h.Response(c, result)
+}
+
+func (h *noteHandler) RemoveTag(c *gin.Context) {
+	id, ok := h.bindID(c)
+	if !ok {
+		return
+	}
+
+	versions, ok := parseIfMatch(c.GetHeader("If-Match"))
+	if !ok {
+		c.Error(apperr.New(apperr.PreconditionFailed, lib.NoteVersionMismatchError))
+		return
+	}
+
+	result, err := h.noteRepo.RemoveTag(c.Request.Context(), id, c.Param("tag"), versions)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.Header("ETag", etag(result.Version))
+	h.Response(c, result)
+}
+
+// Batch runs the operations of the body and answers with one response per
+// operation, in order. A failed atomic batch answers with the status of
+// the operation that failed.
+func (h *noteHandler) Batch(c *gin.Context) {
+	if c.Param("id") != "batch" {
+		// there is no POST /notes/:id
+		NotFound(c)
+		return
+	}
+
+	var request model.NoteBatchRequest
+	err := c.ShouldBindJSON(&request)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": err.Error(),
-		})
+		c.Error(apperr.Wrap(err, apperr.BadRequest, lib.RequestBodyInvalid))
 		return
 	}
 
-	note, code, err := h.noteRepo.Delete(uint(id))
-	h.Response(c, note, code, err)
+	results, err := h.noteRepo.Batch(c.Request.Context(), &request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	language := i18n.Negotiate(c.GetHeader("Accept-Language"))
+	items := make([]*lib.Response, len(results))
+	status := http.StatusOK
+	for i, result := range results {
+		if result.Err == nil {
+			items[i] = lib.NewResponse(http.StatusOK, "", result.Note)
+			continue
+		}
+		items[i] = errorResponse(result.Err, language)
+		if request.Atomic && status == http.StatusOK && items[i].ErrorCode != lib.NoteBatchAborted {
+			status = items[i].Code
+		}
+	}
+	setLanguage(c, language)
+
+	if status != http.StatusOK {
+		res := lib.NewErrorReponse(status, lib.NoteBatchAborted, i18n.Translate(language, lib.NoteBatchAborted))
+		res.Data = items
+		writeError(c, res)
+		return
+	}
+	h.Response(c, items)
+}
+
+// bindID reads the :id parameter, a ULID in either case, and returns it in
+// its canonical form. It adds the error itself when the id is invalid.
+func (h *noteHandler) bindID(c *gin.Context) (string, bool) {
+	id, err := ulid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(apperr.Wrap(err, apperr.BadRequest, lib.NoteIDInvalid))
+		return "", false
+	}
+	return id.String(), true
+}
+
+// bindListRequest reads the query string of the listings, it adds the
+// error itself when the query is invalid.
+func (h *noteHandler) bindListRequest(c *gin.Context) (*model.NoteListRequest, bool) {
+	var request model.NoteListRequest
+	err := c.ShouldBindQuery(&request)
+	if err != nil {
+		c.Error(apperr.Wrap(err, apperr.BadRequest, lib.RequestQueryInvalid))
+		return nil, false
+	}
+	if _, err := request.Validate(); err != nil {
+		c.Error(validationError(err, lib.RequestQueryInvalid))
+		return nil, false
+	}
+	return &request, true
+}
+
+// validationError turns the errors of govalidator into an apperr
+// Validation error listing every invalid field.
+func validationError(err error, code string) error {
+	return apperr.NewValidation(code, validator.ErrorsByField(err))
 }
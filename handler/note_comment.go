@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lyquocnam/go-note-learning/model"
+)
+
+// GetComments serves GET /notes/:id/comments, the comments of the note.
+func (h *noteHandler) GetComments(c *gin.Context) {
+	id, ok := h.bindID(c)
+	if !ok {
+		return
+	}
+
+	comments, err := h.noteRepo.GetComments(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.Response(c, comments)
+}
+
+// Comment serves POST /notes/:id/comments.
+func (h *noteHandler) Comment(c *gin.Context) {
+	id, ok := h.bindID(c)
+	if !ok {
+		return
+	}
+
+	var request model.CommentRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	comment, err := h.noteRepo.Comment(c.Request.Context(), id, &request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.Response(c, comment)
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/lyquocnam/go-note-learning/lib"
+	"github.com/lyquocnam/go-note-learning/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNoteHandler_Comment(t *testing.T) {
+	router, users := newShareRouter(t)
+	ids := addNotes(t, router, `{"title":"Hello"}`)
+	w := serve(router, http.MethodPost, "/notes/"+ids[0]+"/shares", `{"user_id":"`+users[1]+`","role":"viewer"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = serve(router, http.MethodPost, "/notes/"+ids[0]+"/shares", `{"user_id":"`+users[2]+`","role":"commenter"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = serveAs(router, 3, http.MethodPost, "/notes/"+ids[0]+"/comments", `{"body":"Looks good"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var comment model.Comment
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &lib.Response{Data: &comment}))
+	assert.Equal(t, ids[0], comment.NoteID)
+	assert.Equal(t, users[2], comment.AuthorPublicID)
+	assert.Equal(t, "Looks good", comment.Body)
+
+	// a viewer reads the comments but can't add one
+	w = serveAs(router, 2, http.MethodPost, "/notes/"+ids[0]+"/comments", `{"body":"Me too"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	res, _ := decodeNote(t, w)
+	assert.Equal(t, lib.NoteForbidden, res.ErrorCode)
+	w = serveAs(router, 2, http.MethodGet, "/notes/"+ids[0]+"/comments", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var comments []*model.Comment
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &lib.Response{Data: &comments}))
+	require.Len(t, comments, 1)
+	assert.Equal(t, "Looks good", comments[0].Body)
+
+	for _, body := range []string{`{}`, `{"body":""}`, `{"body":"` + strings.Repeat("a", 2001) + `"}`} {
+		w = serve(router, http.MethodPost, "/notes/"+ids[0]+"/comments", body)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code, body)
+		res, _ = decodeNote(t, w)
+		assert.Equal(t, lib.CommentInvalid, res.ErrorCode, body)
+		assert.Contains(t, res.Details, "body", body)
+	}
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lyquocnam/go-note-learning/model"
+)
+
+// GetShares serves GET /notes/:id/shares, the users the note is shared
+// with.
+func (h *noteHandler) GetShares(c *gin.Context) {
+	id, ok := h.bindID(c)
+	if !ok {
+		return
+	}
+
+	shares, err := h.noteRepo.GetShares(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.Response(c, shares)
+}
+
+// Share serves POST /notes/:id/shares, it invites the user of the body or
+// changes its role.
+func (h *noteHandler) Share(c *gin.Context) {
+	id, ok := h.bindID(c)
+	if !ok {
+		return
+	}
+
+	var request model.ShareRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	share, err := h.noteRepo.Share(c.Request.Context(), id, &request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.Response(c, share)
+}
+
+// Unshare serves DELETE /notes/:id/shares/:user, the user loses access to
+// the note.
+func (h *noteHandler) Unshare(c *gin.Context) {
+	id, ok := h.bindID(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.noteRepo.Unshare(c.Request.Context(), id, c.Param("user"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.Response(c, user)
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/lyquocnam/go-note-learning/auth"
+	"github.com/lyquocnam/go-note-learning/lib"
+	"github.com/lyquocnam/go-note-learning/model"
+	"github.com/lyquocnam/go-note-learning/repo"
+	"github.com/lyquocnam/go-note-learning/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newShareRouter is newTestRouter with the users 1, 2 and 3, it returns
+// their public ids. The identities carry them too.
+func newShareRouter(t *testing.T) (*gin.Engine, []string) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler(), Recover())
+	router.NoRoute(NotFound)
+	userStorage := storage.NewUserMemoryStorage()
+	var users []string
+	for _, email := range []string{"ann@example.com", "bob@example.com", "cat@example.com"} {
+		user, err := userStorage.InsertUser(context.Background(), &model.User{Email: email})
+		require.NoError(t, err)
+		users = append(users, user.PublicID)
+	}
+	identify := func(c *gin.Context) (*auth.Identity, error) {
+		identity, err := testUser(c)
+		if err == nil && identity.UserID <= uint(len(users)) {
+			identity.PublicID = users[identity.UserID-1]
+		}
+		return identity, err
+	}
+	NewNoteHandler(router, repo.NewNoteRepo(storage.NewNoteMemoryStorage(), userStorage), Identify(identify))
+	return router, users
+}
+
+func decodeShares(t *testing.T, w *httptest.ResponseRecorder) []*model.Share {
+	var shares []*model.Share
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &lib.Response{Data: &shares}))
+	return shares
+}
+
+func TestNoteHandler_Share(t *testing.T) {
+	router, users := newShareRouter(t)
+	ids := addNotes(t, router, `{"title":"Hello"}`, `{"title":"Private"}`)
+
+	w := serve(router, http.MethodPost, "/notes/"+ids[0]+"/shares", `{"user_id":"`+users[1]+`","role":"viewer"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var share model.Share
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &lib.Response{Data: &share}))
+	assert.Equal(t, ids[0], share.NoteID)
+	assert.Equal(t, users[1], share.UserPublicID)
+	assert.Equal(t, "viewer", string(share.Role))
+	w = serve(router, http.MethodPost, "/notes/"+ids[0]+"/shares", `{"user_id":"`+users[2]+`","role":"editor"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = serve(router, http.MethodGet, "/notes/"+ids[0]+"/shares", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	shares := decodeShares(t, w)
+	require.Len(t, shares, 2)
+	assert.Equal(t, users[1], shares[0].UserPublicID)
+	assert.Equal(t, "editor", string(shares[1].Role))
+	assert.NotContains(t, w.Body.String(), "owner_id")
+	w = serve(router, http.MethodGet, "/notes/"+ids[1]+"/shares", "")
+	assert.Equal(t, "[]", string(mustData(t, w)))
+
+	// the viewer reads the note and finds it among the shared ones
+	w = serveAs(router, 2, http.MethodGet, "/notes/"+ids[0], "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = serveAs(router, 2, http.MethodGet, "/notes/"+ids[1], "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = serveAs(router, 2, http.MethodGet, "/notes/?shared=with-me", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	page := decodePage(t, w)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, ids[0], page.Items[0].PublicID)
+	w = serveAs(router, 2, http.MethodGet, "/notes/?shared=others", "")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	res, _ := decodeNote(t, w)
+	assert.Contains(t, res.Details, "shared")
+
+	// only the owner changes the shares, only an editor the note
+	forbidden := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodPut, "/notes/" + ids[0], `{"title":"Stolen"}`},
+		{http.MethodDelete, "/notes/" + ids[0], ""},
+		{http.MethodGet, "/notes/" + ids[0] + "/shares", ""},
+		{http.MethodPost, "/notes/" + ids[0] + "/shares", `{"user_id":"` + users[1] + `","role":"editor"}`},
+		{http.MethodDelete, "/notes/" + ids[0] + "/shares/" + users[2], ""},
+	}
+	for _, r := range forbidden {
+		w = serveAs(router, 2, r.method, r.path, r.body)
+		assert.Equal(t, http.StatusForbidden, w.Code, r.method+" "+r.path)
+		res, _ := decodeNote(t, w)
+		assert.Equal(t, lib.NoteForbidden, res.ErrorCode, r.method+" "+r.path)
+	}
+	w = serveAs(router, 3, http.MethodPut, "/notes/"+ids[0], `{"title":"Edited"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, note := decodeNote(t, w)
+	assert.Equal(t, "Edited", note.Title)
+
+	w = serve(router, http.MethodDelete, "/notes/"+ids[0]+"/shares/"+users[1], "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = serveAs(router, 2, http.MethodGet, "/notes/"+ids[0], "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = serve(router, http.MethodDelete, "/notes/"+ids[0]+"/shares/"+users[1], "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	res, _ = decodeNote(t, w)
+	assert.Equal(t, lib.ShareNotExistError, res.ErrorCode)
+}
+
+func TestNoteHandler_ShareInvalid(t *testing.T) {
+	router, users := newShareRouter(t)
+	ids := addNotes(t, router, `{"title":"Hello"}`)
+
+	bodies := map[string]string{
+		`{}`: "role",
+		`{"user_id":"` + users[0] + `","role":"viewer"}`: "user_id",
+		`{"user_id":"` + users[1] + `","role":"owner"}`:  "role",
+		`{"user_id":"abc","role":"viewer"}`:              "user_id",
+	}
+	for body, field := range bodies {
+		w := serve(router, http.MethodPost, "/notes/"+ids[0]+"/shares", body)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code, body)
+		res, _ := decodeNote(t, w)
+		assert.Equal(t, lib.ShareInvalid, res.ErrorCode, body)
+		assert.Contains(t, res.Details, field, body)
+	}
+
+	w := serveAs(router, 2, http.MethodPost, "/notes/"+ids[0]+"/shares", `{"user_id":"`+users[2]+`","role":"viewer"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// mustData returns the raw data of a lib.Response.
+func mustData(t *testing.T, w *httptest.ResponseRecorder) json.RawMessage {
+	var data json.RawMessage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &lib.Response{Data: &data}))
+	return data
+}
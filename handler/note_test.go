@@ -0,0 +1,852 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/lyquocnam/go-note-learning/apperr"
+	"github.com/lyquocnam/go-note-learning/auth"
+	"github.com/lyquocnam/go-note-learning/lib"
+	"github.com/lyquocnam/go-note-learning/mocks"
+	"github.com/lyquocnam/go-note-learning/model"
+	"github.com/lyquocnam/go-note-learning/repo"
+	"github.com/lyquocnam/go-note-learning/storage"
+	"github.com/lyquocnam/go-note-learning/ulid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func newTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler(), Recover())
+	router.NoRoute(NotFound)
+	noteRepo := repo.NewNoteRepo(storage.NewNoteMemoryStorage(), storage.NewUserMemoryStorage())
+	identify := Identify(testUser)
+	NewNoteHandler(router, noteRepo, identify)
+	NewTagHandler(router, repo.NewTagRepo(noteRepo), identify)
+	return router
+}
+
+// testUser identifies the caller by the id in the X-Test-User header, the
+// requests without one are sent by the user 1.
+func testUser(c *gin.Context) (*auth.Identity, error) {
+	id, err := strconv.ParseUint(c.GetHeader("X-Test-User"), 10, 32)
+	if err != nil {
+		id = 1
+	}
+	return &auth.Identity{UserID: uint(id)}, nil
+}
+
+func serve(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// decodeNote reads a lib.Response whose data is a single note.
+func decodeNote(t *testing.T, w *httptest.ResponseRecorder) (*lib.Response, *model.Note) {
+	var note model.Note
+	res := &lib.Response{Data: &note}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), res))
+	return res, &note
+}
+
+// missingID is a valid id no note of the tests gets.
+const missingID = "00000000000000000000000000"
+
+// addNotes creates a note for every body and returns their ids.
+func addNotes(t *testing.T, router *gin.Engine, bodies ...string) []string {
+	ids := make([]string, len(bodies))
+	for i, body := range bodies {
+		w := serve(router, http.MethodPost, "/notes/", body)
+		require.Equal(t, http.StatusOK, w.Code, body)
+		_, note := decodeNote(t, w)
+		ids[i] = note.PublicID
+	}
+	return ids
+}
+
+func TestNoteHandler_Add(t *testing.T) {
+	router := newTestRouter()
+
+	w := serve(router, http.MethodPost, "/notes/", `{"title":"Hello","is_completed":true}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	res, note := decodeNote(t, w)
+	assert.Equal(t, http.StatusOK, res.Code)
+	_, err := ulid.Parse(note.PublicID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello", note.Title)
+	assert.True(t, note.IsCompleted)
+
+	w = serve(router, http.MethodPost, "/notes/", `{"title":"Hello"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	res, _ = decodeNote(t, w)
+	assert.Equal(t, lib.NoteTitleAlreadyExistError, res.ErrorCode)
+
+	w = serve(router, http.MethodPost, "/notes/", `{}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	res, _ = decodeNote(t, w)
+	assert.Equal(t, lib.NoteInvalid, res.ErrorCode)
+	assert.Equal(t, "Dữ liệu note không hợp lệ", res.Message)
+	assert.Equal(t, map[string]string{"title": "Tiêu đề không được trống"}, res.Details)
+
+	w = serve(router, http.MethodPost, "/notes/", `not json`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestNoteHandler_AddConcurrent(t *testing.T) {
+	router := newTestRouter()
+
+	var wg sync.WaitGroup
+	codes := make(chan int, 50)
+	for i := 0; i < cap(codes); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- serve(router, http.MethodPost, "/notes/", `{"title":"Hello"}`).Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	created := 0
+	for code := range codes {
+		if code == http.StatusOK {
+			created++
+			continue
+		}
+		assert.Equal(t, http.StatusConflict, code)
+	}
+	assert.Equal(t, 1, created)
+}
+
+func TestNoteHandler_RenameConflict(t *testing.T) {
+	router := newTestRouter()
+	ids := addNotes(t, router, `{"title":"Hello"}`, `{"title":"World"}`)
+
+	w := serve(router, http.MethodPut, "/notes/"+ids[1], `{"title":"Hello"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	res, _ := decodeNote(t, w)
+	assert.Equal(t, lib.NoteTitleAlreadyExistError, res.ErrorCode)
+}
+
+func TestNoteHandler_Get(t *testing.T) {
+	router := newTestRouter()
+	ids := addNotes(t, router, `{"title":"Hello"}`)
+
+	w := serve(router, http.MethodGet, "/notes/"+ids[0], "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, note := decodeNote(t, w)
+	assert.Equal(t, "Hello", note.Title)
+	assert.Equal(t, ids[0], note.PublicID)
+	assert.NotContains(t, w.Body.String(), `"id":1`)
+
+	// ids are case insensitive
+	w = serve(router, http.MethodGet, "/notes/"+strings.ToLower(ids[0]), "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = serve(router, http.MethodGet, "/notes/"+missingID, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	for _, id := range []string{"abc", "1", ids[0] + "0", ids[0][1:], "8" + ids[0][1:], ids[0][:25] + "U"} {
+		w = serve(router, http.MethodGet, "/notes/"+id, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, id)
+		res, _ := decodeNote(t, w)
+		assert.Equal(t, lib.NoteIDInvalid, res.ErrorCode, id)
+	}
+}
+
+func TestNoteHandler_GetMarkdown(t *testing.T) {
+	router := newTestRouter()
+	ids := addNotes(t, router, `{"title":"Hello","content":"Some *text*\n"}`)
+	get := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/notes/"+ids[0], nil)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("text/markdown")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/markdown; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+	assert.Equal(t, "# Hello\n\nSome *text*\n", w.Body.String())
+
+	for _, accept := range []string{"", "*/*", "application/json", "application/json, text/markdown"} {
+		w = get(accept)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"), accept)
+		_, note := decodeNote(t, w)
+		assert.Equal(t, "Some *text*\n", note.Content)
+	}
+}
+
+func TestNoteHandler_GetHTML(t *testing.T) {
+	router := newTestRouter()
+	ids := addNotes(t, router, `{"title":"<b>Hello</b>","content":"Some *text*\n\n<script>x()</script> [link](javascript:x())"}`)
+
+	w := serve(router, http.MethodGet, "/notes/"+ids[0]+"/html", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "<h1>&lt;b&gt;Hello&lt;/b&gt;</h1>\n<p>Some <em>text</em></p>\n<p>&lt;script&gt;x()&lt;/script&gt; link</p>\n", w.Body.String())
+
+	w = serve(router, http.MethodGet, "/notes/"+missingID+"/html", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+
+	w = serve(router, http.MethodPost, "/notes/", `{"title":"Long","content":"`+strings.Repeat("a", 20001)+`"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	res, _ := decodeNote(t, w)
+	assert.Equal(t, map[string]string{"content": "Nội dung tối đa 20000 ký tự"}, res.Details)
+}
+
+// decodePage reads a lib.Response whose data is a model.NotePage.
+func decodePage(t *testing.T, w *httptest.ResponseRecorder) *model.NotePage {
+	var page model.NotePage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &lib.Response{Data: &page}))
+	return &page
+}
+
+func TestNoteHandler_GetList(t *testing.T) {
+	router := newTestRouter()
+	serve(router, http.MethodPost, "/notes/", `{"title":"b"}`)
+	serve(router, http.MethodPost, "/notes/", `{"title":"a","is_completed":true}`)
+	serve(router, http.MethodPost, "/notes/", `{"title":"c"}`)
+
+	w := serve(router, http.MethodGet, "/notes/", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	page := decodePage(t, w)
+	assert.Equal(t, 3, page.Total)
+	assert.Empty(t, page.NextCursor)
+	require.Len(t, page.Items, 3)
+	assert.Equal(t, "b", page.Items[0].Title)
+
+	w = serve(router, http.MethodGet, "/notes/?is_completed=false&sort=-title", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	page = decodePage(t, w)
+	assert.Equal(t, 2, page.Total)
+	require.Len(t, page.Items, 2)
+	assert.Equal(t, "c", page.Items[0].Title)
+	assert.Equal(t, "b", page.Items[1].Title)
+
+	w = serve(router, http.MethodGet, "/notes/?title=A", "")
+	page = decodePage(t, w)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "a", page.Items[0].Title)
+
+	var titles []string
+	path := "/notes/?limit=2&sort=title"
+	for {
+		w = serve(router, http.MethodGet, path, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		page = decodePage(t, w)
+		for _, note := range page.Items {
+			titles = append(titles, note.Title)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		path = "/notes/?limit=2&sort=title&cursor=" + page.NextCursor
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, titles)
+
+	w = serve(router, http.MethodGet, "/notes/?limit=2&offset=2", "")
+	page = decodePage(t, w)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "c", page.Items[0].Title)
+
+	for _, query := range []string{"limit=abc", "is_completed=maybe", "cursor=abc"} {
+		w = serve(router, http.MethodGet, "/notes/?"+query, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+	for query, field := range map[string]string{"limit=-1": "limit", "limit=101": "limit", "sort=size": "sort"} {
+		w = serve(router, http.MethodGet, "/notes/?"+query, "")
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code, query)
+		res, _ := decodeNote(t, w)
+		assert.Len(t, res.Details, 1, query)
+		assert.Contains(t, res.Details, field, query)
+	}
+}
+
+func TestNoteHandler_Tags(t *testing.T) {
+	router := newTestRouter()
+	ids := addNotes(t, router, `{"title":"a","tags":["Work"]}`, `{"title":"b","tags":["home","work"]}`, `{"title":"c"}`)
+
+	w := serve(router, http.MethodGet, "/notes/"+ids[0], "")
+	_, note := decodeNote(t, w)
+	assert.Equal(t, []string{"work"}, note.Tags)
+	w = serve(router, http.MethodGet, "/notes/"+ids[2], "")
+	assert.Contains(t, w.Body.String(), `"tags":[]`)
+
+	w = serve(router, http.MethodPost, "/notes/"+ids[2]+"/tags", `{"tags":["home","urgent"]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+	_, note = decodeNote(t, w)
+	assert.Equal(t, []string{"home", "urgent"}, note.Tags)
+
+	titles := func(query string) []string {
+		w := serve(router, http.MethodGet, "/notes/?"+query, "")
+		require.Equal(t, http.StatusOK, w.Code, query)
+		var titles []string
+		for _, note := range decodePage(t, w).Items {
+			titles = append(titles, note.Title)
+		}
+		return titles
+	}
+	assert.Equal(t, []string{"b"}, titles("tag=home&tag=work"))
+	assert.Equal(t, []string{"a", "b", "c"}, titles("tag=home&tag=work&tag_mode=any"))
+	assert.Equal(t, []string{"b", "c"}, titles("tag=HOME"))
+
+	w = serve(router, http.MethodDelete, "/notes/"+ids[2]+"/tags/Urgent", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, note = decodeNote(t, w)
+	assert.Equal(t, []string{"home"}, note.Tags)
+
+	w = serve(router, http.MethodDelete, "/notes/"+ids[2]+"/tags/urgent", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	res, _ := decodeNote(t, w)
+	assert.Equal(t, lib.TagNotExistError, res.ErrorCode)
+
+	w = serve(router, http.MethodPost, "/notes/"+ids[2]+"/tags", `{"tags":["a/b"]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	res, _ = decodeNote(t, w)
+	assert.Contains(t, res.Details, "tags")
+
+	w = serve(router, http.MethodGet, "/notes/?tag_mode=some", "")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	req := httptest.NewRequest(http.MethodPost, "/notes/"+ids[2]+"/tags", strings.NewReader(`{"tags":["later"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+}
+
+func TestNoteHandler_Update(t *testing.T) {
+	router := newTestRouter()
+	ids := addNotes(t, router, `{"title":"Hello"}`)
+
+	w := serve(router, http.MethodPut, "/notes/"+ids[0], `{"title":"World","is_completed":true}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, note := decodeNote(t, w)
+	assert.Equal(t, "World", note.Title)
+	assert.True(t, note.IsCompleted)
+
+	// PUT replaces the note, a missing field is reset
+	w = serve(router, http.MethodPut, "/notes/"+ids[0], `{"title":"Hello"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, note = decodeNote(t, w)
+	assert.Equal(t, "Hello", note.Title)
+	assert.False(t, note.IsCompleted)
+
+	w = serve(router, http.MethodPut, "/notes/"+ids[0], `{"is_completed":true}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w = serve(router, http.MethodPut, "/notes/"+missingID, `{"title":"Hello"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = serve(router, http.MethodPut, "/notes/"+ids[0], `not json`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	for _, title := range []string{"", strings.Repeat("a", 500)} {
+		w = serve(router, http.MethodPut, "/notes/"+ids[0], `{"title":"`+title+`"}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		res, _ := decodeNote(t, w)
+		assert.Contains(t, res.Details, "title")
+	}
+	w = serve(router, http.MethodGet, "/notes/"+ids[0], "")
+	_, note = decodeNote(t, w)
+	assert.Equal(t, "Hello", note.Title)
+}
+
+func TestNoteHandler_Patch(t *testing.T) {
+	router := newTestRouter()
+	ids := addNotes(t, router, `{"title":"Hello","is_completed":true}`, `{"title":"World"}`)
+
+	patch := func(path, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	const mergePatch, jsonPatch = "application/merge-patch+json", "application/json-patch+json"
+
+	w := patch("/notes/"+ids[0], mergePatch, `{"title":"Hi"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+	_, note := decodeNote(t, w)
+	assert.Equal(t, "Hi", note.Title)
+	assert.True(t, note.IsCompleted)
+
+	// null removes the member, is_completed goes back to false
+	w = patch("/notes/"+ids[0], mergePatch, `{"is_completed":null}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, note = decodeNote(t, w)
+	assert.Equal(t, "Hi", note.Title)
+	assert.False(t, note.IsCompleted)
+
+	w = patch("/notes/"+ids[0], jsonPatch, `[
+		{"op":"test","path":"/title","value":"Hi"},
+		{"op":"replace","path":"/title","value":"Hello"},
+		{"op":"add","path":"/is_completed","value":true}
+	]`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, note = decodeNote(t, w)
+	assert.Equal(t, "Hello", note.Title)
+	assert.True(t, note.IsCompleted)
+	assert.Equal(t, uint(4), note.Version)
+
+	w = patch("/notes/"+ids[0], jsonPatch, `[{"op":"test","path":"/title","value":"Hi"},{"op":"replace","path":"/title","value":"Bye"}]`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	res, _ := decodeNote(t, w)
+	assert.Equal(t, lib.NotePatchTestFailed, res.ErrorCode)
+
+	cases := []struct {
+		name        string
+		contentType string
+		body        string
+		code        int
+		errorCode   string
+		details     []string
+	}{
+		{"case null title", mergePatch, `{"title":null}`, http.StatusUnprocessableEntity, lib.NoteInvalid, []string{"title"}},
+		{"case read only fields", mergePatch, `{"id":5,"version":null,"color":"red"}`, http.StatusUnprocessableEntity, lib.NoteInvalid, []string{"id", "version", "color"}},
+		{"case duplicate title", mergePatch, `{"title":"World"}`, http.StatusConflict, lib.NoteTitleAlreadyExistError, nil},
+		{"case wrong type", mergePatch, `{"title":5}`, http.StatusUnprocessableEntity, lib.NotePatchInvalid, nil},
+		{"case not an object", mergePatch, `"Hello"`, http.StatusUnprocessableEntity, lib.NotePatchInvalid, nil},
+		{"case missing path", jsonPatch, `[{"op":"remove","path":"/color"}]`, http.StatusUnprocessableEntity, lib.NotePatchInvalid, nil},
+		{"case malformed json patch", jsonPatch, `[{"op":"rename","path":"/title"}]`, http.StatusBadRequest, lib.RequestBodyInvalid, nil},
+		{"case malformed merge patch", mergePatch, `{"title":`, http.StatusBadRequest, lib.RequestBodyInvalid, nil},
+		{"case plain json", "application/json", `{"title":"Bye"}`, http.StatusUnsupportedMediaType, lib.RequestContentTypeUnsupported, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := patch("/notes/"+ids[0], c.contentType, c.body)
+			assert.Equal(t, c.code, w.Code)
+			res, _ := decodeNote(t, w)
+			assert.Equal(t, c.errorCode, res.ErrorCode)
+			assert.Len(t, res.Details, len(c.details))
+			for _, field := range c.details {
+				assert.Contains(t, res.Details, field)
+			}
+		})
+	}
+
+	w = serve(router, http.MethodGet, "/notes/"+ids[0], "")
+	_, note = decodeNote(t, w)
+	assert.Equal(t, "Hello", note.Title)
+	assert.Equal(t, uint(4), note.Version)
+
+	w = patch("/notes/"+missingID, mergePatch, `{"title":"Bye"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestNoteHandler_Delete(t *testing.T) {
+	router := newTestRouter()
+	ids := addNotes(t, router, `{"title":"Hello"}`)
+
+	w := serve(router, http.MethodDelete, "/notes/"+ids[0], "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = serve(router, http.MethodGet, "/notes/"+ids[0], "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = serve(router, http.MethodDelete, "/notes/"+ids[0], "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// serveAs serves a request of the user id, see testUser.
+func serveAs(router *gin.Engine, id uint, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-User", strconv.FormatUint(uint64(id), 10))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestNoteHandler_OtherUsers(t *testing.T) {
+	router := newTestRouter()
+	ids := addNotes(t, router, `{"title":"Hello","tags":["work"]}`, `{"title":"Trashed"}`)
+	serve(router, http.MethodDelete, "/notes/"+ids[1], "")
+
+	requests := []struct {
+		method      string
+		path        string
+		body        string
+		contentType string
+	}{
+		{http.MethodGet, "/notes/" + ids[0], "", ""},
+		{http.MethodGet, "/notes/" + ids[0] + "/html", "", ""},
+		{http.MethodPut, "/notes/" + ids[0], `{"title":"Stolen"}`, "application/json"},
+		{http.MethodPatch, "/notes/" + ids[0], `{"title":"Stolen"}`, "application/merge-patch+json"},
+		{http.MethodDelete, "/notes/" + ids[0], "", ""},
+		{http.MethodPost, "/notes/" + ids[0] + "/tags", `{"tags":["stolen"]}`, "application/json"},
+		{http.MethodDelete, "/notes/" + ids[0] + "/tags/work", "", ""},
+		{http.MethodPost, "/notes/" + ids[1] + "/restore", "", ""},
+		{http.MethodDelete, "/notes/" + ids[1] + "/purge", "", ""},
+		{http.MethodPatch, "/tags/work", `{"name":"stolen"}`, "application/json"},
+	}
+	for _, r := range requests {
+		req := httptest.NewRequest(r.method, r.path, strings.NewReader(r.body))
+		req.Header.Set("Content-Type", r.contentType)
+		req.Header.Set("X-Test-User", "2")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code, r.method+" "+r.path)
+	}
+
+	w := serveAs(router, 2, http.MethodGet, "/notes/", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, decodePage(t, w).Items)
+	w = serveAs(router, 2, http.MethodGet, "/notes/trash", "")
+	assert.Empty(t, decodePage(t, w).Items)
+	w = serveAs(router, 2, http.MethodGet, "/tags/", "")
+	assert.Empty(t, decodeTags(t, w))
+
+	// titles are only unique among the notes of a user
+	w = serveAs(router, 2, http.MethodPost, "/notes/", `{"title":"Hello"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = serve(router, http.MethodGet, "/notes/"+ids[0], "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, note := decodeNote(t, w)
+	assert.Equal(t, "Hello", note.Title)
+	assert.Equal(t, []string{"work"}, note.Tags)
+	assert.Equal(t, uint(1), note.Version)
+	w = serve(router, http.MethodGet, "/notes/", "")
+	assert.Len(t, decodePage(t, w).Items, 1)
+}
+
+func TestNoteHandler_Trash(t *testing.T) {
+	router := newTestRouter()
+	ids := addNotes(t, router, `{"title":"Hello"}`, `{"title":"World"}`)
+	serve(router, http.MethodDelete, "/notes/"+ids[0], "")
+
+	w := serve(router, http.MethodGet, "/notes/trash", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	page := decodePage(t, w)
+	assert.Equal(t, 1, page.Total)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "Hello", page.Items[0].Title)
+	assert.NotNil(t, page.Items[0].DeletedAt)
+
+	// the title is free again until the note is restored
+	third := addNotes(t, router, `{"title":"Hello"}`)[0]
+	w = serve(router, http.MethodPost, "/notes/"+ids[0]+"/restore", "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	serve(router, http.MethodDelete, "/notes/"+third, "")
+	w = serve(router, http.MethodDelete, "/notes/"+third+"/purge", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = serve(router, http.MethodPost, "/notes/"+ids[0]+"/restore", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, note := decodeNote(t, w)
+	assert.Equal(t, "Hello", note.Title)
+	assert.Nil(t, note.DeletedAt)
+	w = serve(router, http.MethodGet, "/notes/"+ids[0], "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = serve(router, http.MethodPost, "/notes/"+ids[0]+"/restore", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = serve(router, http.MethodDelete, "/notes/"+ids[1]+"/purge", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = serve(router, http.MethodDelete, "/notes/"+third+"/purge", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = serve(router, http.MethodPost, "/notes/abc/restore", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestNoteHandler_UpdateIfMatch(t *testing.T) {
+	router := newTestRouter()
+	w := serve(router, http.MethodPost, "/notes/", `{"title":"Hello"}`)
+	assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+	_, note := decodeNote(t, w)
+	path := "/notes/" + note.PublicID
+
+	w = serve(router, http.MethodGet, path, "")
+	tag := w.Header().Get("ETag")
+	assert.Equal(t, `"1"`, tag)
+
+	update := func(ifMatch, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", ifMatch)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w = update(tag, `{"title":"first"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+	_, note = decodeNote(t, w)
+	assert.Equal(t, uint(2), note.Version)
+
+	// a second client still holding the first version
+	w = update(tag, `{"title":"second"}`)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	res, _ := decodeNote(t, w)
+	assert.Equal(t, lib.NoteVersionMismatchError, res.ErrorCode)
+
+	for _, ifMatch := range []string{`W/"2"`, `W/"1", W/"2"`, `2`, `"abc"`, `"1", "3"`, `"2" "3"`} {
+		w = update(ifMatch, `{"title":"second"}`)
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code, ifMatch)
+	}
+
+	// a list passes when one of its strong tags is the current one
+	w = update(`"1", W/"3", "a,b", "2"`, `{"title":"second"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+
+	w = update("*", `{"title":"third"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = serve(router, http.MethodGet, path, "")
+	_, note = decodeNote(t, w)
+	assert.Equal(t, "third", note.Title)
+	assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+}
+
+func TestParseIfMatch(t *testing.T) {
+	cases := []struct {
+		header   string
+		versions []uint
+		ok       bool
+	}{
+		{"", nil, true},
+		{" * ", nil, true},
+		{`"2"`, []uint{2}, true},
+		{`"1","2" , "3"`, []uint{1, 2, 3}, true},
+		{`W/"1", "2"`, []uint{2}, true},
+		{`"a,b", "2"`, []uint{2}, true},
+		{`W/"2"`, nil, false},
+		{`"0"`, nil, false},
+		{`"abc"`, nil, false},
+		{`2`, nil, false},
+		{`"2`, nil, false},
+		{`"2" "3"`, nil, false},
+		{`"2",`, []uint{2}, true},
+		{`*, "2"`, nil, false},
+	}
+	for _, c := range cases {
+		versions, ok := parseIfMatch(c.header)
+		assert.Equal(t, c.ok, ok, c.header)
+		assert.Equal(t, c.versions, versions, c.header)
+	}
+}
+
+func TestNoteHandler_Batch(t *testing.T) {
+	router := newTestRouter()
+	ids := addNotes(t, router, `{"title":"Hello"}`, `{"title":"World"}`)
+
+	decodeItems := func(w *httptest.ResponseRecorder) (*lib.Response, []*lib.Response) {
+		var items []*lib.Response
+		res := &lib.Response{Data: &items}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), res))
+		return res, items
+	}
+
+	w := serve(router, http.MethodPost, "/notes/batch", `{"atomic":true,"operations":[
+		{"op":"create","note":{"title":"New"}},
+		{"op":"update","id":"`+ids[0]+`","version":1,"note":{"title":"World"}},
+		{"op":"delete","id":"`+ids[1]+`"}]}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	res, items := decodeItems(w)
+	assert.Equal(t, lib.NoteBatchAborted, res.ErrorCode)
+	require.Len(t, items, 3)
+	assert.Equal(t, lib.NoteBatchAborted, items[0].ErrorCode)
+	assert.Equal(t, http.StatusConflict, items[1].Code)
+	assert.Equal(t, lib.NoteTitleAlreadyExistError, items[1].ErrorCode)
+	assert.Equal(t, "Tên note đã tồn tại", items[1].Message)
+	assert.Equal(t, lib.NoteBatchAborted, items[2].ErrorCode)
+	w = serve(router, http.MethodGet, "/notes/", "")
+	assert.Equal(t, 2, decodePage(t, w).Total)
+
+	w = serve(router, http.MethodPost, "/notes/batch", `{"operations":[
+		{"op":"create","note":{"title":"New"}},
+		{"op":"update","id":"`+ids[0]+`","version":5,"note":{"title":"Renamed"}},
+		{"op":"update","id":"`+ids[1]+`","note":{}},
+		{"op":"delete","id":"`+ids[1]+`"},
+		{"op":"delete","id":"1"}]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, items = decodeItems(w)
+	require.Len(t, items, 5)
+	assert.Equal(t, http.StatusOK, items[0].Code)
+	assert.Equal(t, "New", items[0].Data.(map[string]interface{})["title"])
+	assert.Equal(t, http.StatusPreconditionFailed, items[1].Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, items[2].Code)
+	assert.Equal(t, map[string]string{"title": "Tiêu đề không được trống"}, items[2].Details)
+	assert.Equal(t, http.StatusBadRequest, items[3].Code)
+	assert.Equal(t, lib.NoteBatchDuplicateNote, items[3].ErrorCode)
+	assert.Equal(t, http.StatusBadRequest, items[4].Code)
+	assert.Equal(t, lib.NoteIDInvalid, items[4].ErrorCode)
+	w = serve(router, http.MethodGet, "/notes/", "")
+	assert.Equal(t, 3, decodePage(t, w).Total)
+
+	w = serve(router, http.MethodPost, "/notes/batch", `{"operations":[]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	w = serve(router, http.MethodPost, "/notes/batch", `not json`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = serve(router, http.MethodPost, "/notes/"+ids[0], `{}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestNoteHandler_Search(t *testing.T) {
+	router := newTestRouter()
+	serve(router, http.MethodPost, "/notes/", `{"title":"Du lịch Hà Nội"}`)
+	serve(router, http.MethodPost, "/notes/", `{"title":"Mua sữa"}`)
+
+	w := serve(router, http.MethodGet, "/notes/search?q=ha+noi", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var page model.NoteSearchPage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &lib.Response{Data: &page}))
+	assert.Equal(t, 1, page.Total)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "Du lịch Hà Nội", page.Items[0].Note.Title)
+	assert.Equal(t, "Du lịch <mark>Hà</mark> <mark>Nội</mark>", page.Items[0].Snippet)
+
+	w = serve(router, http.MethodGet, "/notes/search?q=paris", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"items":[]`)
+
+	w = serve(router, http.MethodGet, "/notes/search", "")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	res, _ := decodeNote(t, w)
+	assert.Equal(t, map[string]string{"q": "Từ khóa tìm kiếm không được trống"}, res.Details)
+	w = serve(router, http.MethodGet, "/notes/search?q=ha&limit=500", "")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+// TestNoteHandler_Envelope checks that every route answers with a
+// lib.Response, or with problem details when they are asked for.
+func TestNoteHandler_Envelope(t *testing.T) {
+	router := newTestRouter()
+	ids := addNotes(t, router, `{"title":"a","tags":["x"]}`, `{"title":"b"}`, `{"title":"c","tags":["y"]}`, `{"title":"d"}`)
+	serve(router, http.MethodDelete, "/notes/"+ids[1], "")
+
+	request := func(method, path, contentType, body, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	cases := []struct {
+		method      string
+		path        string
+		contentType string
+		body        string
+		status      int
+	}{
+		{http.MethodGet, "/notes/", "", "", http.StatusOK},
+		{http.MethodGet, "/notes/" + ids[0], "", "", http.StatusOK},
+		{http.MethodGet, "/notes/trash", "", "", http.StatusOK},
+		{http.MethodGet, "/notes/search?q=a", "", "", http.StatusOK},
+		{http.MethodGet, "/tags/", "", "", http.StatusOK},
+		{http.MethodPost, "/notes/", "application/json", `{"title":"h"}`, http.StatusOK},
+		{http.MethodPut, "/notes/" + ids[3], "application/json", `{"title":"e"}`, http.StatusOK},
+		{http.MethodPatch, "/notes/" + ids[3], "application/merge-patch+json", `{"content":"text"}`, http.StatusOK},
+		{http.MethodPost, "/notes/" + ids[3] + "/tags", "application/json", `{"tags":["z"]}`, http.StatusOK},
+		{http.MethodDelete, "/notes/" + ids[3] + "/tags/z", "", "", http.StatusOK},
+		{http.MethodPatch, "/tags/x", "application/json", `{"name":"w"}`, http.StatusOK},
+		{http.MethodPost, "/tags/w/merge", "application/json", `{"name":"y"}`, http.StatusOK},
+		{http.MethodPost, "/notes/batch", "application/json", `{"operations":[{"op":"create","note":{"title":"f"}}]}`, http.StatusOK},
+		{http.MethodPost, "/notes/" + ids[1] + "/restore", "", "", http.StatusOK},
+		{http.MethodDelete, "/notes/" + ids[3], "", "", http.StatusOK},
+		{http.MethodDelete, "/notes/" + ids[3] + "/purge", "", "", http.StatusOK},
+
+		{http.MethodGet, "/notes/abc", "", "", http.StatusBadRequest},
+		{http.MethodGet, "/notes/" + missingID, "", "", http.StatusNotFound},
+		{http.MethodGet, "/notes/" + missingID + "/html", "", "", http.StatusNotFound},
+		{http.MethodGet, "/notes/?limit=101", "", "", http.StatusUnprocessableEntity},
+		{http.MethodGet, "/notes/trash?sort=size", "", "", http.StatusUnprocessableEntity},
+		{http.MethodGet, "/notes/search", "", "", http.StatusUnprocessableEntity},
+		{http.MethodPost, "/notes/", "application/json", `not json`, http.StatusBadRequest},
+		{http.MethodPost, "/notes/", "application/json", `{"title":"a"}`, http.StatusConflict},
+		{http.MethodPut, "/notes/" + missingID, "application/json", `{"title":"g"}`, http.StatusNotFound},
+		{http.MethodPatch, "/notes/" + ids[0], "application/json", `{"title":"g"}`, http.StatusUnsupportedMediaType},
+		{http.MethodPost, "/notes/" + ids[0], "application/json", `{}`, http.StatusNotFound},
+		{http.MethodPost, "/notes/batch", "application/json", `{"operations":[]}`, http.StatusUnprocessableEntity},
+		{http.MethodPost, "/notes/" + ids[0] + "/tags", "application/json", `{"tags":[]}`, http.StatusUnprocessableEntity},
+		{http.MethodDelete, "/notes/" + ids[0] + "/tags/missing", "", "", http.StatusNotFound},
+		{http.MethodPost, "/notes/" + ids[0] + "/restore", "", "", http.StatusNotFound},
+		{http.MethodDelete, "/notes/" + ids[0] + "/purge", "", "", http.StatusNotFound},
+		{http.MethodDelete, "/notes/" + missingID, "", "", http.StatusNotFound},
+		{http.MethodPatch, "/tags/missing", "application/json", `{"name":"q"}`, http.StatusNotFound},
+		{http.MethodPost, "/tags/y/merge", "application/json", `{"name":"missing"}`, http.StatusNotFound},
+		{http.MethodGet, "/missing", "", "", http.StatusNotFound},
+	}
+	for _, c := range cases {
+		name := c.method + " " + c.path
+		w := request(c.method, c.path, c.contentType, c.body, "")
+		require.Equal(t, c.status, w.Code, name)
+		assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"), name)
+		var res map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res), name)
+		assert.Equal(t, float64(c.status), res["code"], name)
+		if c.status == http.StatusOK {
+			assert.Contains(t, res, "data", name)
+			assert.NotContains(t, res, "error", name)
+			continue
+		}
+		assert.NotEmpty(t, res["error"], name)
+		assert.NotEmpty(t, res["message"], name)
+
+		w = request(c.method, c.path, c.contentType, c.body, "application/problem+json")
+		require.Equal(t, c.status, w.Code, name)
+		assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"), name)
+		var problem lib.Problem
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem), name)
+		assert.Equal(t, c.status, problem.Status, name)
+		assert.Equal(t, lib.ProblemTypePrefix+problem.Code, problem.Type, name)
+		assert.Equal(t, res["error"], problem.Code, name)
+		assert.Equal(t, res["message"], problem.Title, name)
+		assert.Equal(t, c.path, problem.Instance, name)
+	}
+}
+
+func TestNoteHandler_StorageFailure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler())
+	noteRepo := &mocks.NoteRepo{}
+	NewNoteHandler(router, noteRepo)
+
+	failure := apperr.FromError(errors.New("connection refused"))
+	const failing = "01D7EX2VQ3AHDK6N2QXKWBS4JZ"
+	noteRepo.On("Get", mock.Anything, failing).Return(nil, failure)
+	noteRepo.On("Get", mock.Anything, missingID).Return(nil, apperr.New(apperr.NotFound, lib.NoteNotExistError))
+	noteRepo.On("GetList", mock.Anything, mock.Anything).Return(nil, failure)
+
+	cases := []struct {
+		path      string
+		status    int
+		errorCode string
+	}{
+		{"/notes/" + failing, http.StatusInternalServerError, lib.InternalError},
+		{"/notes/" + failing + "/html", http.StatusInternalServerError, lib.InternalError},
+		{"/notes/" + missingID, http.StatusNotFound, lib.NoteNotExistError},
+		{"/notes/", http.StatusInternalServerError, lib.InternalError},
+	}
+	for _, c := range cases {
+		w := serve(router, http.MethodGet, c.path, "")
+		assert.Equal(t, c.status, w.Code, c.path)
+		res, _ := decodeNote(t, w)
+		assert.Equal(t, c.errorCode, res.ErrorCode, c.path)
+		assert.NotContains(t, w.Body.String(), "connection refused", c.path)
+	}
+}
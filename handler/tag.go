@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lyquocnam/go-note-learning/apperr"
+	"github.com/lyquocnam/go-note-learning/lib"
+	"github.com/lyquocnam/go-note-learning/model"
+	"github.com/lyquocnam/go-note-learning/repo"
+	"net/http"
+)
+
+type tagHandler struct {
+	router  *gin.Engine
+	tagRepo repo.TagRepo
+}
+
+// NewTagHandler registers the tag routes behind middleware like
+// NewNoteHandler. router must use ErrorHandler for the errors to be
+// written.
+func NewTagHandler(router *gin.Engine, tagRepo repo.TagRepo, middleware ...gin.HandlerFunc) *tagHandler {
+	handler := &tagHandler{
+		router:  router,
+		tagRepo: tagRepo,
+	}
+
+	tagsGroup := handler.router.Group("/tags", middleware...)
+	tagsGroup.GET("/", handler.GetList)
+	tagsGroup.PATCH("/:name", handler.Rename)
+	tagsGroup.POST("/:name/merge", handler.Merge)
+
+	return handler
+}
+
+type TagHandler interface {
+	GetList(c *gin.Context)
+	Rename(c *gin.Context)
+	Merge(c *gin.Context)
+}
+
+func (h *tagHandler) Response(c *gin.Context, data interface{}) {
+	c.JSON(http.StatusOK, lib.NewResponse(http.StatusOK, "", data))
+}
+
+// GetList serves GET /tags/, every tag with how many notes have it.
+func (h *tagHandler) GetList(c *gin.Context) {
+	tags, err := h.tagRepo.GetList(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.Response(c, tags)
+}
+
+// Rename serves PATCH /tags/:name with the new name in the body.
+func (h *tagHandler) Rename(c *gin.Context) {
+	request, ok := h.bindRequest(c)
+	if !ok {
+		return
+	}
+
+	tag, err := h.tagRepo.Rename(c.Request.Context(), c.Param("name"), request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.Response(c, tag)
+}
+
+// Merge serves POST /tags/:name/merge with the tag to merge into in the
+// body.
+func (h *tagHandler) Merge(c *gin.Context) {
+	request, ok := h.bindRequest(c)
+	if !ok {
+		return
+	}
+
+	tag, err := h.tagRepo.Merge(c.Request.Context(), c.Param("name"), request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.Response(c, tag)
+}
+
+func (h *tagHandler) bindRequest(c *gin.Context) (*model.TagRequest, bool) {
+	var request model.TagRequest
+	err := c.ShouldBindJSON(&request)
+	if err != nil {
+		c.Error(apperr.Wrap(err, apperr.BadRequest, lib.RequestBodyInvalid))
+		return nil, false
+	}
+	return &request, true
+}
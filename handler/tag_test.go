@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/lyquocnam/go-note-learning/lib"
+	"github.com/lyquocnam/go-note-learning/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func decodeTags(t *testing.T, w *httptest.ResponseRecorder) []*model.Tag {
+	var tags []*model.Tag
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &lib.Response{Data: &tags}))
+	return tags
+}
+
+func TestTagHandler(t *testing.T) {
+	router := newTestRouter()
+	ids := addNotes(t, router, `{"title":"a","tags":["work"]}`, `{"title":"b","tags":["home","work"]}`, `{"title":"c","tags":["job"]}`)
+
+	w := serve(router, http.MethodGet, "/tags/", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []*model.Tag{{Name: "home", Count: 1}, {Name: "job", Count: 1}, {Name: "work", Count: 2}}, decodeTags(t, w))
+
+	w = serve(router, http.MethodPatch, "/tags/home", `{"name":"job"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	res, _ := decodeNote(t, w)
+	assert.Equal(t, lib.TagAlreadyExistError, res.ErrorCode)
+
+	w = serve(router, http.MethodPatch, "/tags/home", `{"name":"House"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var tag model.Tag
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &lib.Response{Data: &tag}))
+	assert.Equal(t, model.Tag{Name: "house", Count: 1}, tag)
+
+	w = serve(router, http.MethodPost, "/tags/work/merge", `{"name":"job"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &lib.Response{Data: &tag}))
+	assert.Equal(t, model.Tag{Name: "job", Count: 3}, tag)
+
+	w = serve(router, http.MethodGet, "/tags/", "")
+	assert.Equal(t, []*model.Tag{{Name: "house", Count: 1}, {Name: "job", Count: 3}}, decodeTags(t, w))
+	w = serve(router, http.MethodGet, "/notes/"+ids[1], "")
+	_, note := decodeNote(t, w)
+	assert.Equal(t, []string{"house", "job"}, note.Tags)
+	assert.Equal(t, uint(3), note.Version)
+
+	w = serve(router, http.MethodPost, "/tags/missing/merge", `{"name":"job"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = serve(router, http.MethodPatch, "/tags/job", `{}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	w = serve(router, http.MethodPatch, "/tags/job", `not json`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
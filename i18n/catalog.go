@@ -0,0 +1,124 @@
+// Package i18n translates the error and validation codes of lib into the
+// language of the client. Every language is a bundle of messages keyed by
+// code, more languages are added with Register.
+package i18n
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultLanguage is used when the client accepts none of the bundles, and
+// for the codes a bundle misses.
+const DefaultLanguage = "vi"
+
+var (
+	mu      sync.RWMutex
+	bundles = make(map[string]map[string]string)
+)
+
+// Register adds messages to the bundle of language, replacing the messages
+// it already has for the same codes.
+func Register(language string, messages map[string]string) {
+	language = strings.ToLower(language)
+
+	mu.Lock()
+	defer mu.Unlock()
+	bundle, ok := bundles[language]
+	if !ok {
+		bundle = make(map[string]string, len(messages))
+		bundles[language] = bundle
+	}
+	for code, message := range messages {
+		bundle[code] = message
+	}
+}
+
+// Languages returns the languages that have a bundle, sorted.
+func Languages() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	languages := make([]string, 0, len(bundles))
+	for language := range bundles {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// Translate returns the message of code in language. It falls back on
+// DefaultLanguage, then on the code itself.
+func Translate(language, code string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	if message, ok := bundles[strings.ToLower(language)][code]; ok {
+		return message
+	}
+	if message, ok := bundles[DefaultLanguage][code]; ok {
+		return message
+	}
+	return code
+}
+
+// Negotiate picks the bundle matching an Accept-Language header best. A
+// region falls back on its language, "en-US" matches "en".
+func Negotiate(acceptLanguage string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, tag := range parseAcceptLanguage(acceptLanguage) {
+		if tag == "*" {
+			break
+		}
+		if _, ok := bundles[tag]; ok {
+			return tag
+		}
+		if i := strings.IndexByte(tag, '-'); i > 0 {
+			if _, ok := bundles[tag[:i]]; ok {
+				return tag[:i]
+			}
+		}
+	}
+	return DefaultLanguage
+}
+
+// parseAcceptLanguage returns the language tags of header, lowercased and
+// ordered by quality. Tags with a zero or invalid quality are dropped.
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag     string
+		quality float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		tag := strings.ToLower(strings.TrimSpace(params[0]))
+		if tag == "" {
+			continue
+		}
+		quality := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(param[2:], 64)
+				if err != nil || q < 0 || q > 1 {
+					q = 0
+				}
+				quality = q
+			}
+		}
+		if quality > 0 {
+			tags = append(tags, weighted{tag, quality})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].quality > tags[j].quality
+	})
+
+	result := make([]string, len(tags))
+	for i, tag := range tags {
+		result[i] = tag.tag
+	}
+	return result
+}
@@ -0,0 +1,53 @@
+package i18n
+
+import (
+	"github.com/lyquocnam/go-note-learning/lib"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBundles(t *testing.T) {
+	// every language translates the same codes
+	for _, language := range Languages() {
+		assert.Equal(t, len(bundles[DefaultLanguage]), len(bundles[language]), language)
+		for code := range bundles[DefaultLanguage] {
+			assert.Contains(t, bundles[language], code, language)
+		}
+	}
+	assert.Equal(t, []string{"en", "vi"}, Languages())
+}
+
+func TestTranslate(t *testing.T) {
+	assert.Equal(t, "The note does not exist", Translate("en", lib.NoteNotExistError))
+	assert.Equal(t, "The note does not exist", Translate("EN", lib.NoteNotExistError))
+	assert.Equal(t, "Note không tồn tại", Translate("vi", lib.NoteNotExistError))
+	assert.Equal(t, "Note không tồn tại", Translate("fr", lib.NoteNotExistError))
+	assert.Equal(t, "unknown_code", Translate("en", "unknown_code"))
+
+	Register("en", map[string]string{"test_only": "Only in English"})
+	defer delete(bundles["en"], "test_only")
+	assert.Equal(t, "Only in English", Translate("en", "test_only"))
+	assert.Equal(t, "test_only", Translate("vi", "test_only"))
+}
+
+func TestNegotiate(t *testing.T) {
+	cases := []struct {
+		header string
+		expect string
+	}{
+		{"", DefaultLanguage},
+		{"en", "en"},
+		{"EN-us", "en"},
+		{"vi-VN,vi;q=0.9,en;q=0.8", "vi"},
+		{"fr-FR,fr;q=0.9,en;q=0.8", "en"},
+		{"en;q=0.5,vi;q=0.8", "vi"},
+		{"vi;q=0,en;q=0.1", "en"},
+		{"en;q=abc,vi;q=0.2", "vi"},
+		{"fr, de", DefaultLanguage},
+		{"*", DefaultLanguage},
+		{" en-GB ; q=0.7 , ja", "en"},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expect, Negotiate(c.header), c.header)
+	}
+}
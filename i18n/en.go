@@ -0,0 +1,77 @@
+package i18n
+
+import "github.com/lyquocnam/go-note-learning/lib"
+
+func init() {
+	Register("en", map[string]string{
+		lib.NoteTitleAlreadyExistError:    "A note with this title already exists",
+		lib.NoteNotExistError:             "The note does not exist",
+		lib.NoteListCursorInvalid:         "The pagination cursor is invalid",
+		lib.NoteListCursorWithOffset:      "A pagination cursor can't be used with an offset",
+		lib.NoteNotInTrashError:           "The note is not in the trash",
+		lib.NoteVersionMismatchError:      "The note has changed, please reload it",
+		lib.NoteModifiedConcurrentlyError: "The note is being updated by someone else, please try again",
+		lib.InternalError:                 "Something went wrong, please try again later",
+		lib.RequestTimeoutError:           "The request took too long, please try again later",
+		lib.RequestCanceledError:          "The request was canceled",
+		lib.NoteIDInvalid:                 "The note id is invalid",
+		lib.RequestBodyInvalid:            "The request body is invalid",
+		lib.RequestQueryInvalid:           "The query parameters are invalid",
+		lib.NoteInvalid:                   "The note is invalid",
+		lib.NotePatchInvalid:              "The patch can't be applied to the note",
+		lib.NotePatchTestFailed:           "The note does not pass the tests of the patch",
+		lib.RequestContentTypeUnsupported: "The content type of the request is not supported",
+		lib.NoteBatchInvalid:              "The batch is invalid",
+		lib.NoteBatchOpInvalid:            "The operation must be create, update or delete",
+		lib.NoteBatchDuplicateNote:        "A note can only be changed once in a batch",
+		lib.NoteBatchAborted:              "The batch was cancelled because another operation failed",
+		lib.TagNotExistError:              "The tag does not exist",
+		lib.TagAlreadyExistError:          "A tag with this name already exists",
+		lib.TagInvalid:                    "The tag is invalid",
+		lib.RouteNotFound:                 "There is nothing at this address",
+		lib.UserInvalid:                   "The account is invalid",
+		lib.UserEmailAlreadyExistError:    "An account with this email already exists",
+		lib.AuthCredentialsInvalid:        "The email or the password is wrong",
+		lib.AuthTokenRequired:             "Please sign in to continue",
+		lib.AuthTokenInvalid:              "The access token is invalid",
+		lib.AuthTokenExpired:              "The access token has expired, please refresh it",
+		lib.AuthRefreshTokenInvalid:       "The session has ended, please sign in again",
+		lib.AuthUserRequired:              "The user of the request is missing",
+		lib.AuthUserInvalid:               "The user of the request does not exist",
+		lib.NoteForbidden:                 "You are not allowed to do this with the note",
+		lib.ShareNotExistError:            "The note is not shared with this user",
+		lib.ShareInvalid:                  "The share is invalid",
+		lib.CommentInvalid:                "The comment is invalid",
+
+		lib.NoteTitleRequired:        "The title is required",
+		lib.NoteTitleLength:          "The title must be 1 to 80 characters long",
+		lib.NoteTitleInvalid:         "The title must be a single line without leading or trailing spaces",
+		lib.NoteContentLength:        "The content must be at most 20000 characters long",
+		lib.NoteListLimitRange:       "The limit must be between 1 and 100",
+		lib.NoteListOffsetRange:      "The offset is invalid",
+		lib.NoteListSortInvalid:      "The sort order is invalid",
+		lib.NoteBatchSize:            "A batch must have 1 to 100 operations",
+		lib.NoteSearchQueryRequired:  "The search query is required",
+		lib.NoteSearchQueryLength:    "The search query must be 1 to 200 characters long",
+		lib.NoteTagRequired:          "A tag is required",
+		lib.NoteTagLength:            "A tag must be 1 to 50 characters long",
+		lib.NoteTagInvalid:           "A tag can't contain a slash or a comma",
+		lib.NoteTagCount:             "A note can have at most 20 tags",
+		lib.NoteListTagModeInvalid:   "The tag mode must be all or any",
+		lib.NoteFieldReadOnly:        "This field can't be changed",
+		lib.NoteFieldUnknown:         "This field does not exist",
+		lib.UserEmailRequired:        "The email is required",
+		lib.UserEmailInvalid:         "The email is invalid",
+		lib.UserPasswordRequired:     "The password is required",
+		lib.UserPasswordLength:       "The password must be 8 to 128 characters long",
+		lib.AuthRefreshTokenRequired: "The refresh token is required",
+		lib.NoteListSharedInvalid:    "The shared filter must be with-me",
+		lib.ShareUserRequired:        "The user is required",
+		lib.ShareUserNotExist:        "The user does not exist",
+		lib.ShareUserOwner:           "A note can't be shared with its owner",
+		lib.ShareRoleRequired:        "The role is required",
+		lib.ShareRoleInvalid:         "The role must be viewer, commenter or editor",
+		lib.CommentBodyRequired:      "The comment can't be empty",
+		lib.CommentBodyLength:        "A comment must be 1 to 2000 characters long",
+	})
+}
@@ -0,0 +1,77 @@
+package i18n
+
+import "github.com/lyquocnam/go-note-learning/lib"
+
+func init() {
+	Register("vi", map[string]string{
+		lib.NoteTitleAlreadyExistError:    "Tên note đã tồn tại",
+		lib.NoteNotExistError:             "Note không tồn tại",
+		lib.NoteListCursorInvalid:         "Con trỏ phân trang không hợp lệ",
+		lib.NoteListCursorWithOffset:      "Không thể dùng con trỏ phân trang cùng với offset",
+		lib.NoteNotInTrashError:           "Note không có trong thùng rác",
+		lib.NoteVersionMismatchError:      "Note đã bị thay đổi, vui lòng tải lại",
+		lib.NoteModifiedConcurrentlyError: "Note đang được cập nhật bởi người khác, vui lòng thử lại",
+		lib.InternalError:                 "Hệ thống đang gặp sự cố, vui lòng thử lại sau",
+		lib.RequestTimeoutError:           "Yêu cầu xử lý quá lâu, vui lòng thử lại sau",
+		lib.RequestCanceledError:          "Yêu cầu đã bị hủy",
+		lib.NoteIDInvalid:                 "Mã note không hợp lệ",
+		lib.RequestBodyInvalid:            "Dữ liệu gửi lên không hợp lệ",
+		lib.RequestQueryInvalid:           "Tham số truy vấn không hợp lệ",
+		lib.NoteInvalid:                   "Dữ liệu note không hợp lệ",
+		lib.NotePatchInvalid:              "Không thể áp dụng bản vá cho note",
+		lib.NotePatchTestFailed:           "Note không thỏa điều kiện kiểm tra của bản vá",
+		lib.RequestContentTypeUnsupported: "Định dạng dữ liệu gửi lên không được hỗ trợ",
+		lib.NoteBatchInvalid:              "Lô thao tác không hợp lệ",
+		lib.NoteBatchOpInvalid:            "Thao tác phải là create, update hoặc delete",
+		lib.NoteBatchDuplicateNote:        "Mỗi note chỉ được thay đổi một lần trong một lô",
+		lib.NoteBatchAborted:              "Lô thao tác đã bị hủy do một thao tác khác thất bại",
+		lib.TagNotExistError:              "Nhãn không tồn tại",
+		lib.TagAlreadyExistError:          "Tên nhãn đã tồn tại",
+		lib.TagInvalid:                    "Nhãn không hợp lệ",
+		lib.RouteNotFound:                 "Địa chỉ này không tồn tại",
+		lib.UserInvalid:                   "Dữ liệu tài khoản không hợp lệ",
+		lib.UserEmailAlreadyExistError:    "Email này đã được đăng ký",
+		lib.AuthCredentialsInvalid:        "Email hoặc mật khẩu không đúng",
+		lib.AuthTokenRequired:             "Vui lòng đăng nhập để tiếp tục",
+		lib.AuthTokenInvalid:              "Mã truy cập không hợp lệ",
+		lib.AuthTokenExpired:              "Mã truy cập đã hết hạn, vui lòng làm mới",
+		lib.AuthRefreshTokenInvalid:       "Phiên đăng nhập đã kết thúc, vui lòng đăng nhập lại",
+		lib.AuthUserRequired:              "Thiếu người dùng của yêu cầu",
+		lib.AuthUserInvalid:               "Người dùng của yêu cầu không tồn tại",
+		lib.NoteForbidden:                 "Bạn không được phép làm điều này với note",
+		lib.ShareNotExistError:            "Note không được chia sẻ với người dùng này",
+		lib.ShareInvalid:                  "Chia sẻ không hợp lệ",
+		lib.CommentInvalid:                "Bình luận không hợp lệ",
+
+		lib.NoteTitleRequired:        "Tiêu đề không được trống",
+		lib.NoteTitleLength:          "Tiêu đề phải từ 1 - 80 ký tự",
+		lib.NoteTitleInvalid:         "Tiêu đề phải trên một dòng, không có khoảng trắng ở đầu và cuối",
+		lib.NoteContentLength:        "Nội dung tối đa 20000 ký tự",
+		lib.NoteListLimitRange:       "Số lượng phải từ 1 - 100",
+		lib.NoteListOffsetRange:      "Vị trí bắt đầu không hợp lệ",
+		lib.NoteListSortInvalid:      "Kiểu sắp xếp không hợp lệ",
+		lib.NoteBatchSize:            "Một lô phải có từ 1 - 100 thao tác",
+		lib.NoteSearchQueryRequired:  "Từ khóa tìm kiếm không được trống",
+		lib.NoteTagRequired:          "Nhãn không được trống",
+		lib.NoteTagLength:            "Nhãn phải từ 1 - 50 ký tự",
+		lib.NoteTagInvalid:           "Nhãn không được chứa dấu gạch chéo hoặc dấu phẩy",
+		lib.NoteTagCount:             "Một note có tối đa 20 nhãn",
+		lib.NoteListTagModeInvalid:   "Chế độ lọc nhãn phải là all hoặc any",
+		lib.NoteSearchQueryLength:    "Từ khóa tìm kiếm phải từ 1 - 200 ký tự",
+		lib.NoteFieldReadOnly:        "Trường này không được thay đổi",
+		lib.NoteFieldUnknown:         "Trường này không tồn tại",
+		lib.UserEmailRequired:        "Email không được trống",
+		lib.UserEmailInvalid:         "Email không hợp lệ",
+		lib.UserPasswordRequired:     "Mật khẩu không được trống",
+		lib.UserPasswordLength:       "Mật khẩu phải từ 8 - 128 ký tự",
+		lib.AuthRefreshTokenRequired: "Mã làm mới không được trống",
+		lib.NoteListSharedInvalid:    "Bộ lọc chia sẻ phải là with-me",
+		lib.ShareUserRequired:        "Người dùng là bắt buộc",
+		lib.ShareUserNotExist:        "Người dùng không tồn tại",
+		lib.ShareUserOwner:           "Không thể chia sẻ note với chủ sở hữu của nó",
+		lib.ShareRoleRequired:        "Vai trò là bắt buộc",
+		lib.ShareRoleInvalid:         "Vai trò phải là viewer, commenter hoặc editor",
+		lib.CommentBodyRequired:      "Bình luận không được trống",
+		lib.CommentBodyLength:        "Bình luận phải từ 1 - 2000 ký tự",
+	})
+}
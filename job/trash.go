@@ -0,0 +1,34 @@
+package job
+
+import (
+	"context"
+	"github.com/lyquocnam/go-note-learning/repo"
+	"github.com/lyquocnam/go-note-learning/storage"
+	"log"
+	"time"
+)
+
+// PurgeTrash deletes for good, every interval, the notes of every user
+// that stayed in the trash longer than retention. It returns when ctx is
+// done.
+func PurgeTrash(ctx context.Context, noteRepo repo.NoteRepo, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	purgeCtx := storage.AllOwners(ctx)
+	for {
+		count, err := noteRepo.PurgeTrash(purgeCtx, time.Now().Add(-retention))
+		if err != nil && ctx.Err() == nil {
+			log.Printf("purge trash: %v", err)
+		}
+		if count > 0 {
+			log.Printf("purge trash: %d notes purged", count)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
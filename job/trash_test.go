@@ -0,0 +1,46 @@
+package job
+
+import (
+	"context"
+	"github.com/lyquocnam/go-note-learning/mocks"
+	"github.com/lyquocnam/go-note-learning/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"time"
+)
+
+func TestPurgeTrash(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	noteRepo := &mocks.NoteRepo{}
+	calls := make(chan time.Time, 10)
+	noteRepo.On("PurgeTrash", mock.Anything, mock.AnythingOfType("time.Time")).Return(1, nil).Run(func(args mock.Arguments) {
+		// the job runs for every user
+		_, err := storage.NewNoteMemoryStorage().Count(args.Get(0).(context.Context), storage.NoteFilter{})
+		assert.NoError(t, err)
+		calls <- args.Get(1).(time.Time)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		PurgeTrash(ctx, noteRepo, time.Hour, 10*time.Millisecond)
+		close(done)
+	}()
+
+	// runs right away then on every tick
+	for i := 0; i < 2; i++ {
+		select {
+		case before := <-calls:
+			assert.WithinDuration(t, time.Now().Add(-time.Hour), before, time.Second)
+		case <-time.After(time.Second):
+			t.Fatal("trash was not purged")
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("PurgeTrash did not return after cancel")
+	}
+}
@@ -0,0 +1,356 @@
+// Package jsonpatch applies JSON Merge Patch (RFC 7396) and JSON Patch
+// (RFC 6902) documents to JSON values.
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidPatch is returned for a patch that can't be decoded.
+	ErrInvalidPatch = errors.New("jsonpatch: invalid patch")
+	// ErrTestFailed is returned when a test operation doesn't match.
+	ErrTestFailed = errors.New("jsonpatch: test operation failed")
+	// ErrPath is returned when an operation targets a location that does not
+	// exist or can't be modified.
+	ErrPath = errors.New("jsonpatch: invalid path")
+)
+
+// Patcher is a patch document of either format.
+type Patcher interface {
+	Apply(doc []byte) ([]byte, error)
+}
+
+// MergePatch is an RFC 7396 document: objects are merged recursively, null
+// removes a member and any other value replaces the target.
+type MergePatch json.RawMessage
+
+// DecodeMergePatch checks data is a JSON document.
+func DecodeMergePatch(data []byte) (MergePatch, error) {
+	if !json.Valid(data) {
+		return nil, ErrInvalidPatch
+	}
+	return MergePatch(data), nil
+}
+
+func (p MergePatch) Apply(doc []byte) ([]byte, error) {
+	var target, patch interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(p, &patch); err != nil {
+		return nil, ErrInvalidPatch
+	}
+	return json.Marshal(mergePatch(target, patch))
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+			continue
+		}
+		targetObject[name] = mergePatch(targetObject[name], value)
+	}
+	return targetObject
+}
+
+// Operation is one operation of an RFC 6902 document.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  *string         `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"` // empty when absent, null is a value
+}
+
+// Patch is an RFC 6902 document, its operations are applied in order and
+// the patch fails as a whole when one of them fails.
+type Patch []Operation
+
+// DecodePatch parses an RFC 6902 document and checks every operation has
+// the members its op requires.
+func DecodePatch(data []byte) (Patch, error) {
+	var patch Patch
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return nil, ErrInvalidPatch
+	}
+	for i, op := range patch {
+		switch op.Op {
+		case "add", "replace", "test":
+			if len(op.Value) == 0 {
+				return nil, fmt.Errorf("%w: operation %d has no value", ErrInvalidPatch, i)
+			}
+		case "move", "copy":
+			if op.From == nil {
+				return nil, fmt.Errorf("%w: operation %d has no from", ErrInvalidPatch, i)
+			}
+			if _, err := parsePointer(*op.From); err != nil {
+				return nil, fmt.Errorf("%w: operation %d: %v", ErrInvalidPatch, i, err)
+			}
+		case "remove":
+		default:
+			return nil, fmt.Errorf("%w: operation %d has an unknown op %q", ErrInvalidPatch, i, op.Op)
+		}
+		if _, err := parsePointer(op.Path); err != nil {
+			return nil, fmt.Errorf("%w: operation %d: %v", ErrInvalidPatch, i, err)
+		}
+	}
+	return patch, nil
+}
+
+func (p Patch) Apply(doc []byte) ([]byte, error) {
+	var root interface{}
+	if err := json.Unmarshal(doc, &root); err != nil {
+		return nil, err
+	}
+
+	var err error
+	for i, op := range p {
+		root, err = op.apply(root)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+	return json.Marshal(root)
+}
+
+func (op Operation) apply(root interface{}) (interface{}, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil || ((op.Op == "move" || op.Op == "copy") && op.From == nil) {
+		return nil, ErrInvalidPatch
+	}
+
+	switch op.Op {
+	case "add":
+		value, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		return add(root, path, value)
+	case "remove":
+		root, _, err = remove(root, path)
+		return root, err
+	case "replace":
+		value, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return value, nil
+		}
+		if root, _, err = remove(root, path); err != nil {
+			return nil, err
+		}
+		return add(root, path, value)
+	case "move":
+		from, _ := parsePointer(*op.From)
+		if len(path) > len(from) && isPrefix(from, path) {
+			return nil, fmt.Errorf("%w: %q can't be moved into itself", ErrPath, *op.From)
+		}
+		root, value, err := remove(root, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(root, path, value)
+	case "copy":
+		from, _ := parsePointer(*op.From)
+		value, err := get(root, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(root, path, deepCopy(value))
+	case "test":
+		expected, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		actual, err := get(root, path)
+		if err != nil {
+			return nil, ErrTestFailed
+		}
+		if !reflect.DeepEqual(expected, actual) {
+			return nil, ErrTestFailed
+		}
+		return root, nil
+	}
+	return nil, ErrInvalidPatch
+}
+
+func (op Operation) value() (interface{}, error) {
+	var value interface{}
+	if json.Unmarshal(op.Value, &value) != nil {
+		return nil, ErrInvalidPatch
+	}
+	return value, nil
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into its unescaped tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("pointer %q does not start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+func isPrefix(prefix, path []string) bool {
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func get(node interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch value := node.(type) {
+		case map[string]interface{}:
+			child, ok := value[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: member %q does not exist", ErrPath, token)
+			}
+			node = child
+		case []interface{}:
+			i, err := arrayIndex(token, len(value)-1)
+			if err != nil {
+				return nil, err
+			}
+			node = value[i]
+		default:
+			return nil, fmt.Errorf("%w: %q is not in a container", ErrPath, token)
+		}
+	}
+	return node, nil
+}
+
+// add sets the value at path and returns the new root, arrays are
+// reallocated so the parent has to be updated too.
+func add(root interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := get(root, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	token := path[len(path)-1]
+	switch container := parent.(type) {
+	case map[string]interface{}:
+		container[token] = value
+		return root, nil
+	case []interface{}:
+		i := len(container)
+		if token != "-" {
+			if i, err = arrayIndex(token, len(container)); err != nil {
+				return nil, err
+			}
+		}
+		grown := make([]interface{}, 0, len(container)+1)
+		grown = append(grown, container[:i]...)
+		grown = append(grown, value)
+		grown = append(grown, container[i:]...)
+		return replaceAt(root, path[:len(path)-1], grown)
+	}
+	return nil, fmt.Errorf("%w: %q is not in a container", ErrPath, token)
+}
+
+// remove deletes the value at path, it returns the new root and the value.
+func remove(root interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("%w: the root can't be removed", ErrPath)
+	}
+	parent, err := get(root, path[:len(path)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	token := path[len(path)-1]
+	switch container := parent.(type) {
+	case map[string]interface{}:
+		value, ok := container[token]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: member %q does not exist", ErrPath, token)
+		}
+		delete(container, token)
+		return root, value, nil
+	case []interface{}:
+		i, err := arrayIndex(token, len(container)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		value := container[i]
+		shrunk := append(append(make([]interface{}, 0, len(container)-1), container[:i]...), container[i+1:]...)
+		root, err = replaceAt(root, path[:len(path)-1], shrunk)
+		return root, value, err
+	}
+	return nil, nil, fmt.Errorf("%w: %q is not in a container", ErrPath, token)
+}
+
+// replaceAt stores value at an existing path.
+func replaceAt(root interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := get(root, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	switch container := parent.(type) {
+	case map[string]interface{}:
+		container[path[len(path)-1]] = value
+	case []interface{}:
+		i, _ := arrayIndex(path[len(path)-1], len(container)-1)
+		container[i] = value
+	}
+	return root, nil
+}
+
+// arrayIndex parses an array index no greater than max, leading zeros are
+// not allowed.
+func arrayIndex(token string, max int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (len(token) > 1 && token[0] == '0') || token[0] == '+' {
+		return 0, fmt.Errorf("%w: %q is not an array index", ErrPath, token)
+	}
+	if i > max {
+		return 0, fmt.Errorf("%w: index %d is out of range", ErrPath, i)
+	}
+	return i, nil
+}
+
+func deepCopy(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(value))
+		for name, child := range value {
+			object[name] = deepCopy(child)
+		}
+		return object
+	case []interface{}:
+		array := make([]interface{}, len(value))
+		for i, child := range value {
+			array[i] = deepCopy(child)
+		}
+		return array
+	}
+	return value
+}
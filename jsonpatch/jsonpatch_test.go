@@ -0,0 +1,223 @@
+package jsonpatch
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// the examples of RFC 7396 appendix A
+func TestMergePatch_Apply(t *testing.T) {
+	cases := []struct {
+		doc    string
+		patch  string
+		expect string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, c := range cases {
+		patch, err := DecodeMergePatch([]byte(c.patch))
+		require.NoError(t, err, c.patch)
+		actual, err := patch.Apply([]byte(c.doc))
+		require.NoError(t, err, c.patch)
+		assert.JSONEq(t, c.expect, string(actual), c.patch)
+	}
+
+	_, err := DecodeMergePatch([]byte(`{"a":`))
+	assert.True(t, errors.Is(err, ErrInvalidPatch))
+}
+
+// mostly the examples of RFC 6902 appendix A
+func TestPatch_Apply(t *testing.T) {
+	cases := []struct {
+		name   string
+		doc    string
+		patch  string
+		expect string
+		err    error
+	}{
+		{
+			name:   "case add an object member",
+			doc:    `{"foo":"bar"}`,
+			patch:  `[{"op":"add","path":"/baz","value":"qux"}]`,
+			expect: `{"baz":"qux","foo":"bar"}`,
+		},
+		{
+			name:   "case add an array element",
+			doc:    `{"foo":["bar","baz"]}`,
+			patch:  `[{"op":"add","path":"/foo/1","value":"qux"}]`,
+			expect: `{"foo":["bar","qux","baz"]}`,
+		},
+		{
+			name:   "case add at the end of an array",
+			doc:    `{"foo":["bar"]}`,
+			patch:  `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`,
+			expect: `{"foo":["bar",["abc","def"]]}`,
+		},
+		{
+			name:   "case remove an object member",
+			doc:    `{"baz":"qux","foo":"bar"}`,
+			patch:  `[{"op":"remove","path":"/baz"}]`,
+			expect: `{"foo":"bar"}`,
+		},
+		{
+			name:   "case remove an array element",
+			doc:    `{"foo":["bar","qux","baz"]}`,
+			patch:  `[{"op":"remove","path":"/foo/1"}]`,
+			expect: `{"foo":["bar","baz"]}`,
+		},
+		{
+			name:   "case replace a value",
+			doc:    `{"baz":"qux","foo":"bar"}`,
+			patch:  `[{"op":"replace","path":"/baz","value":"boo"}]`,
+			expect: `{"baz":"boo","foo":"bar"}`,
+		},
+		{
+			name:   "case move a value",
+			doc:    `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			patch:  `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			expect: `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`,
+		},
+		{
+			name:   "case move an array element",
+			doc:    `{"foo":["all","grass","cows","eat"]}`,
+			patch:  `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			expect: `{"foo":["all","cows","eat","grass"]}`,
+		},
+		{
+			name:   "case copy a value",
+			doc:    `{"foo":{"bar":1}}`,
+			patch:  `[{"op":"copy","from":"/foo","path":"/baz"},{"op":"replace","path":"/baz/bar","value":2}]`,
+			expect: `{"foo":{"bar":1},"baz":{"bar":2}}`,
+		},
+		{
+			name:   "case test succeeds",
+			doc:    `{"baz":"qux","foo":["a",2,"c"]}`,
+			patch:  `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+			expect: `{"baz":"qux","foo":["a",2,"c"]}`,
+		},
+		{
+			name:  "case test fails",
+			doc:   `{"baz":"qux"}`,
+			patch: `[{"op":"test","path":"/baz","value":"bar"}]`,
+			err:   ErrTestFailed,
+		},
+		{
+			name:  "case failed test discards the previous operations",
+			doc:   `{"baz":"qux"}`,
+			patch: `[{"op":"replace","path":"/baz","value":"bar"},{"op":"test","path":"/baz","value":"qux"}]`,
+			err:   ErrTestFailed,
+		},
+		{
+			name:   "case add a nested member",
+			doc:    `{"foo":"bar"}`,
+			patch:  `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`,
+			expect: `{"foo":"bar","child":{"grandchild":{}}}`,
+		},
+		{
+			name:  "case add to a nonexistent target",
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/baz/bat","value":"qux"}]`,
+			err:   ErrPath,
+		},
+		{
+			name:   "case escaped pointer",
+			doc:    `{"/":9,"~1":10}`,
+			patch:  `[{"op":"test","path":"/~01","value":10},{"op":"remove","path":"/~1"}]`,
+			expect: `{"~1":10}`,
+		},
+		{
+			name:  "case comparing strings and numbers",
+			doc:   `{"/":9,"~1":10}`,
+			patch: `[{"op":"test","path":"/~01","value":"10"}]`,
+			err:   ErrTestFailed,
+		},
+		{
+			name:   "case add an array value",
+			doc:    `{"foo":["bar"]}`,
+			patch:  `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`,
+			expect: `{"foo":["bar",["abc","def"]]}`,
+		},
+		{
+			name:  "case index out of range",
+			doc:   `{"foo":["bar"]}`,
+			patch: `[{"op":"add","path":"/foo/2","value":"baz"}]`,
+			err:   ErrPath,
+		},
+		{
+			name:  "case index with leading zero",
+			doc:   `{"foo":["bar","baz"]}`,
+			patch: `[{"op":"remove","path":"/foo/01"}]`,
+			err:   ErrPath,
+		},
+		{
+			name:  "case move into itself",
+			doc:   `{"foo":{"bar":1}}`,
+			patch: `[{"op":"move","from":"/foo","path":"/foo/bar"}]`,
+			err:   ErrPath,
+		},
+		{
+			name:  "case remove a missing member",
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"remove","path":"/baz"}]`,
+			err:   ErrPath,
+		},
+		{
+			name:   "case replace the root",
+			doc:    `{"foo":"bar"}`,
+			patch:  `[{"op":"replace","path":"","value":{"baz":1}}]`,
+			expect: `{"baz":1}`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			patch, err := DecodePatch([]byte(c.patch))
+			require.NoError(t, err)
+			actual, err := patch.Apply([]byte(c.doc))
+			if c.err != nil {
+				assert.True(t, errors.Is(err, c.err), err)
+				return
+			}
+			require.NoError(t, err)
+			assert.JSONEq(t, c.expect, string(actual))
+		})
+	}
+}
+
+func TestDecodePatch(t *testing.T) {
+	for _, patch := range []string{
+		`{"op":"add","path":"/a","value":1}`,
+		`[{"op":"add","path":"/a"}]`,
+		`[{"op":"test","path":"/a"}]`,
+		`[{"op":"move","path":"/a"}]`,
+		`[{"op":"copy","from":"a","path":"/a"}]`,
+		`[{"op":"remove","path":"a"}]`,
+		`[{"op":"delete","path":"/a"}]`,
+		`[{"path":"/a"}]`,
+		`not json`,
+	} {
+		_, err := DecodePatch([]byte(patch))
+		assert.True(t, errors.Is(err, ErrInvalidPatch), patch)
+	}
+
+	patch, err := DecodePatch([]byte(`[{"op":"add","path":"/a","value":null}]`))
+	require.NoError(t, err)
+	actual, err := patch.Apply([]byte(`{}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"a":null}`, string(actual))
+}
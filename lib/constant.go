@@ -1,5 +1,76 @@
 package lib
 
-const NoteTitleAlreadyExistError = "Tên note đã tồn tại"
-const NoteNotExistError = "Note không tồn tại"
-const NoteTitleRequired = "Tên note không được trống"
+// Error codes, they are stable and returned to clients next to the message
+// the i18n catalog translates them into.
+const NoteTitleAlreadyExistError = "note_title_already_exists"
+const NoteNotExistError = "note_not_exist"
+const NoteListCursorInvalid = "note_list_cursor_invalid"
+const NoteListCursorWithOffset = "note_list_cursor_with_offset"
+const NoteNotInTrashError = "note_not_in_trash"
+const NoteVersionMismatchError = "note_version_mismatch"
+const NoteModifiedConcurrentlyError = "note_modified_concurrently"
+const InternalError = "internal_error"
+const RequestTimeoutError = "request_timeout"
+const RequestCanceledError = "request_canceled"
+const NoteIDInvalid = "note_id_invalid"
+const RequestBodyInvalid = "request_body_invalid"
+const RequestQueryInvalid = "request_query_invalid"
+const NoteInvalid = "note_invalid"
+const NotePatchInvalid = "note_patch_invalid"
+const NotePatchTestFailed = "note_patch_test_failed"
+const RequestContentTypeUnsupported = "request_content_type_unsupported"
+const NoteBatchInvalid = "note_batch_invalid"
+const NoteBatchOpInvalid = "note_batch_op_invalid"
+const NoteBatchDuplicateNote = "note_batch_duplicate_note"
+const NoteBatchAborted = "note_batch_aborted"
+const TagNotExistError = "tag_not_exist"
+const TagAlreadyExistError = "tag_already_exists"
+const TagInvalid = "tag_invalid"
+const RouteNotFound = "route_not_found"
+const UserInvalid = "user_invalid"
+const UserEmailAlreadyExistError = "user_email_already_exists"
+const AuthCredentialsInvalid = "auth_credentials_invalid"
+const AuthTokenRequired = "auth_token_required"
+const AuthTokenInvalid = "auth_token_invalid"
+const AuthTokenExpired = "auth_token_expired"
+const AuthRefreshTokenInvalid = "auth_refresh_token_invalid"
+const AuthUserRequired = "auth_user_required"
+const AuthUserInvalid = "auth_user_invalid"
+const NoteForbidden = "note_forbidden"
+const ShareNotExistError = "share_not_exist"
+const ShareInvalid = "share_invalid"
+const CommentInvalid = "comment_invalid"
+
+// Validation codes, they are used in the valid tags of model.
+const NoteTitleRequired = "note_title_required"
+const NoteTitleLength = "note_title_length"
+const NoteTitleInvalid = "note_title_invalid"
+const NoteContentLength = "note_content_length"
+const NoteListLimitRange = "note_list_limit_range"
+const NoteListOffsetRange = "note_list_offset_range"
+const NoteListSortInvalid = "note_list_sort_invalid"
+const NoteBatchSize = "note_batch_size"
+const NoteSearchQueryRequired = "note_search_query_required"
+const NoteSearchQueryLength = "note_search_query_length"
+const NoteTagRequired = "note_tag_required"
+const NoteTagLength = "note_tag_length"
+const NoteTagInvalid = "note_tag_invalid"
+const NoteTagCount = "note_tag_count"
+const NoteListTagModeInvalid = "note_list_tag_mode_invalid"
+const UserEmailRequired = "user_email_required"
+const UserEmailInvalid = "user_email_invalid"
+const UserPasswordRequired = "user_password_required"
+const UserPasswordLength = "user_password_length"
+const AuthRefreshTokenRequired = "auth_refresh_token_required"
+const NoteListSharedInvalid = "note_list_shared_invalid"
+const ShareUserRequired = "share_user_required"
+const ShareUserNotExist = "share_user_not_exist"
+const ShareUserOwner = "share_user_owner"
+const ShareRoleRequired = "share_role_required"
+const ShareRoleInvalid = "share_role_invalid"
+const CommentBodyRequired = "comment_body_required"
+const CommentBodyLength = "comment_body_length"
+
+// Validation codes of the fields a patch can't change.
+const NoteFieldReadOnly = "note_field_read_only"
+const NoteFieldUnknown = "note_field_unknown"
@@ -0,0 +1,51 @@
+package lib
+
+import (
+	"sort"
+	"strings"
+)
+
+// ProblemTypePrefix starts the type of every problem, the error code
+// follows it.
+const ProblemTypePrefix = "urn:note-learning:error:"
+
+// Problem is the RFC 7807 form of an error Response, sent as
+// application/problem+json. Code, Fields and Data are extension members
+// holding the error code, the invalid fields and the data of the Response.
+type Problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Code     string            `json:"code"`
+	Fields   map[string]string `json:"fields,omitempty"`
+	Data     interface{}       `json:"data,omitempty"`
+}
+
+// NewProblem converts an error Response, instance identifies the request
+// that failed. Detail lists the messages of the invalid fields.
+func NewProblem(res *Response, instance string) *Problem {
+	problem := &Problem{
+		Type:     ProblemTypePrefix + res.ErrorCode,
+		Title:    res.Message,
+		Status:   res.Code,
+		Instance: instance,
+		Code:     res.ErrorCode,
+		Fields:   res.Details,
+		Data:     res.Data,
+	}
+	if len(res.Details) > 0 {
+		fields := make([]string, 0, len(res.Details))
+		for field := range res.Details {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		details := make([]string, len(fields))
+		for i, field := range fields {
+			details[i] = field + ": " + res.Details[field]
+		}
+		problem.Detail = strings.Join(details, "; ")
+	}
+	return problem
+}
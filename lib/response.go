@@ -1,13 +1,13 @@
 package lib
 
 type Response struct {
-	Code    int         `json:"code"`
-	Message string      `json:"message,omitempty"`
-	Data    interface{} `json:"data,omitempty"`
-}
-
-func (r *Response) Error() string {
-	return r.Message
+	Code int `json:"code"`
+	// ErrorCode is one of the error codes of this package, Message is its
+	// translation
+	ErrorCode string            `json:"error,omitempty"`
+	Message   string            `json:"message,omitempty"`
+	Data      interface{}       `json:"data,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
 }
 
 func NewResponse(code int, message string, data interface{}) *Response {
@@ -18,9 +18,10 @@ func NewResponse(code int, message string, data interface{}) *Response {
 	}
 }
 
-func NewErrorReponse(code int, message string) *Response {
+func NewErrorReponse(code int, errorCode, message string) *Response {
 	return &Response{
-		Code:    code,
-		Message: message,
+		Code:      code,
+		ErrorCode: errorCode,
+		Message:   message,
 	}
 }
@@ -1,16 +1,23 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/postgres"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
 	"github.com/joho/godotenv"
+	"github.com/lyquocnam/go-note-learning/auth"
 	"github.com/lyquocnam/go-note-learning/handler"
-	"github.com/lyquocnam/go-note-learning/model"
+	"github.com/lyquocnam/go-note-learning/job"
 	"github.com/lyquocnam/go-note-learning/repo"
 	"github.com/lyquocnam/go-note-learning/storage"
+	bolt "go.etcd.io/bbolt"
 	"log"
 	"os"
+	"path/filepath"
+	"time"
 )
 
 func main() {
@@ -18,21 +25,156 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
-	db, err := gorm.Open("postgres", os.Getenv("DATABASE_URL"))
+	noteStorage, userStorage, closeStorage, err := newStorage(os.Getenv("DATABASE_DRIVER"), os.Getenv("DATABASE_URL"))
 	if err != nil {
 		panic(err)
 	}
-	defer db.Close()
+	defer closeStorage()
 
-	db.LogMode(true)
-	db.AutoMigrate(model.Note{})
+	signer, err := auth.NewSigner([]byte(os.Getenv("JWT_SECRET")))
+	if err != nil {
+		panic(fmt.Errorf("JWT_SECRET must be at least %d bytes long: %v", auth.MinKeyLength, err))
+	}
+	accessTTL, err := parseDuration(os.Getenv("ACCESS_TOKEN_TTL"), 15*time.Minute)
+	if err != nil {
+		panic(err)
+	}
+	refreshTTL, err := parseDuration(os.Getenv("REFRESH_TOKEN_TTL"), 30*24*time.Hour)
+	if err != nil {
+		panic(err)
+	}
+
+	timeout, err := parseDuration(os.Getenv("REQUEST_TIMEOUT"), 0)
+	if err != nil {
+		panic(err)
+	}
+	retention, err := parseDuration(os.Getenv("TRASH_RETENTION"), 30*24*time.Hour)
+	if err != nil {
+		panic(err)
+	}
+	purgeInterval, err := parseDuration(os.Getenv("TRASH_PURGE_INTERVAL"), time.Hour)
+	if err != nil {
+		panic(err)
+	}
 
 	gin.SetMode(os.Getenv("GIN_MODE"))
 	engine := gin.Default()
+	engine.Use(handler.Timeout(timeout), handler.ErrorHandler(), handler.Recover())
+	engine.NoRoute(handler.NotFound)
 
-	noteStorage := storage.NewNotePostgresStorage(db)
-	noteRepo := repo.NewNoteRepo(noteStorage)
-	handler.NewNoteHandler(engine, noteRepo)
+	authRepo := repo.NewAuthRepo(userStorage, signer, accessTTL, refreshTTL)
+	if adopter, ok := noteStorage.(storage.NoteAdopter); ok {
+		owner, count, err := authRepo.AdoptNotes(context.Background(), adopter, os.Getenv("NOTES_OWNER"))
+		if err != nil {
+			panic(fmt.Errorf("NOTES_OWNER: %v", err))
+		}
+		if count > 0 {
+			log.Printf("%d notes stored before accounts existed now belong to %s", count, owner.Email)
+		}
+	}
+	handler.NewAuthHandler(engine, authRepo)
+	authenticate, err := identify(os.Getenv("AUTH_MODE"), os.Getenv("AUTH_HEADER"), authRepo)
+	if err != nil {
+		panic(err)
+	}
+
+	noteRepo := repo.NewNoteRepo(noteStorage, userStorage)
+	handler.NewNoteHandler(engine, noteRepo, authenticate)
+	handler.NewTagHandler(engine, repo.NewTagRepo(noteRepo), authenticate)
+
+	if purgeInterval > 0 {
+		go job.PurgeTrash(context.Background(), noteRepo, retention, purgeInterval)
+	}
 
 	log.Fatal(engine.Run(":8080"))
 }
+
+// accountStorage keeps the users and who the notes are shared with, every
+// user storage does.
+type accountStorage interface {
+	storage.UserStorage
+	storage.ShareStorage
+}
+
+// newStorage opens the note and user storages selected by
+// DATABASE_DRIVER, postgres is used when it is empty.
+func newStorage(driver, url string) (storage.NoteStorage, accountStorage, func(), error) {
+	switch driver {
+	case "", "postgres":
+		return newGormStorage("postgres", url)
+	case "sqlite", "sqlite3":
+		return newGormStorage("sqlite3", url)
+	case "bolt":
+		db, err := bolt.Open(url, 0600, &bolt.Options{Timeout: time.Second})
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		noteStorage, err := storage.NewNoteBoltStorage(db)
+		if err != nil {
+			db.Close()
+			return nil, nil, nil, err
+		}
+		userStorage, err := storage.NewUserBoltStorage(db)
+		if err != nil {
+			db.Close()
+			return nil, nil, nil, err
+		}
+		return noteStorage, userStorage, func() { db.Close() }, nil
+	case "markdown":
+		noteStorage, err := storage.NewNoteMarkdownStorage(url)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		userStorage, err := storage.NewUserFileStorage(filepath.Join(url, ".users.yaml"))
+		return noteStorage, userStorage, func() {}, err
+	case "memory":
+		return storage.NewNoteMemoryStorage(), storage.NewUserMemoryStorage(), func() {}, nil
+	default:
+		return nil, nil, nil, fmt.Errorf("unsupported DATABASE_DRIVER %q", driver)
+	}
+}
+
+func newGormStorage(dialect, url string) (storage.NoteStorage, accountStorage, func(), error) {
+	db, err := gorm.Open(dialect, url)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	db.LogMode(true)
+	err = storage.MigrateGorm(db)
+	if err != nil {
+		db.Close()
+		return nil, nil, nil, err
+	}
+	userStorage := storage.NewUserGormStorage(db).LogMode(true)
+	if dialect == "postgres" {
+		noteStorage := storage.NewNotePostgresStorage(db)
+		noteStorage.LogMode(true)
+		return noteStorage, userStorage, func() { db.Close() }, nil
+	}
+	return storage.NewNoteGormStorage(db).LogMode(true), userStorage, func() { db.Close() }, nil
+}
+
+// identify returns the middleware telling who sent a request with the
+// provider selected by AUTH_MODE, the access tokens when it is empty.
+func identify(mode, header string, authRepo repo.AuthRepo) (gin.HandlerFunc, error) {
+	switch mode {
+	case "", "token":
+		return handler.Authenticate(authRepo), nil
+	case "header":
+		if header == "" {
+			header = "X-User-ID"
+		}
+		return handler.Identify(handler.UserHeader(header, authRepo)), nil
+	default:
+		return nil, fmt.Errorf("unsupported AUTH_MODE %q", mode)
+	}
+}
+
+// parseDuration parses settings like REQUEST_TIMEOUT (e.g. "5s"),
+// fallback is used when value is empty.
+func parseDuration(value string, fallback time.Duration) (time.Duration, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return time.ParseDuration(value)
+}
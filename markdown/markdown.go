@@ -0,0 +1,598 @@
+// Package markdown renders the common subset of CommonMark to HTML that is
+// safe to embed in a page: raw HTML is escaped and links and images only
+// keep http, https, mailto and relative URLs.
+//
+// Supported are ATX headings, paragraphs, hard line breaks, block quotes,
+// bullet and ordered lists, fenced code blocks, thematic breaks, code
+// spans, emphasis, links, images and autolinks.
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	headingPattern  = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))??(?:[ \t]+#+)?[ \t]*$`)
+	breakPattern    = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	fencePattern    = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ \t]*([^ \t`]*)")
+	quotePattern    = regexp.MustCompile(`^ {0,3}> ?`)
+	listPattern     = regexp.MustCompile(`^( {0,3})([-*+]|\d{1,9}[.)])(?:( {1,4})|[ \t]*$)`)
+	autolinkPattern = regexp.MustCompile(`^<([a-zA-Z][a-zA-Z0-9+.-]{1,31}:[^<>\s]*)>`)
+	blockEndPattern = regexp.MustCompile(`(?:</(?:ul|ol|pre|blockquote|h[1-6])>|<hr />)\n$`)
+	tagPattern      = regexp.MustCompile(`<[^>]*>`)
+)
+
+// ToHTML renders source, every block is followed by a newline.
+func ToHTML(source string) string {
+	source = strings.Replace(source, "\r\n", "\n", -1)
+	source = strings.Replace(source, "\r", "\n", -1)
+	lines := strings.Split(source, "\n")
+	for i, line := range lines {
+		lines[i] = expandTabs(line)
+	}
+
+	var b strings.Builder
+	renderBlocks(&b, lines, false)
+	return b.String()
+}
+
+// expandTabs replaces the tabs of the indentation of line by spaces, tab
+// stops are 4 columns apart.
+func expandTabs(line string) string {
+	var b strings.Builder
+	for i, r := range line {
+		switch r {
+		case ' ':
+			b.WriteByte(' ')
+		case '\t':
+			b.WriteString(strings.Repeat(" ", 4-b.Len()%4))
+		default:
+			return b.String() + line[i:]
+		}
+	}
+	return b.String()
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// renderBlocks renders lines as a sequence of blocks, the paragraphs of a
+// tight list item are not wrapped in <p>.
+func renderBlocks(b *strings.Builder, lines []string, tight bool) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case isBlank(line):
+			i++
+		case fencePattern.MatchString(line):
+			i = renderFence(b, lines, i)
+		case headingPattern.MatchString(line):
+			m := headingPattern.FindStringSubmatch(line)
+			level := strconv.Itoa(len(m[1]))
+			b.WriteString("<h" + level + ">")
+			renderInline(b, strings.TrimSpace(m[2]))
+			b.WriteString("</h" + level + ">\n")
+			i++
+		case breakPattern.MatchString(line):
+			b.WriteString("<hr />\n")
+			i++
+		case quotePattern.MatchString(line):
+			i = renderQuote(b, lines, i)
+		case listPattern.MatchString(line):
+			i = renderList(b, lines, i)
+		default:
+			i = renderParagraph(b, lines, i, tight)
+		}
+	}
+}
+
+func renderFence(b *strings.Builder, lines []string, i int) int {
+	m := fencePattern.FindStringSubmatch(lines[i])
+	indent, marker, info := len(m[1]), m[2], m[3]
+
+	b.WriteString("<pre><code")
+	if info != "" {
+		b.WriteString(` class="language-` + html.EscapeString(info) + `"`)
+	}
+	b.WriteString(">")
+	for i++; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimLeft(line, " ")
+		if len(line)-len(trimmed) <= 3 && strings.HasPrefix(trimmed, marker) &&
+			strings.Trim(trimmed, marker[:1]+" \t") == "" {
+			i++
+			break
+		}
+		// the content loses up to the indentation of the opening fence
+		for n := 0; n < indent && strings.HasPrefix(line, " "); n++ {
+			line = line[1:]
+		}
+		b.WriteString(html.EscapeString(line) + "\n")
+	}
+	b.WriteString("</code></pre>\n")
+	return i
+}
+
+func renderQuote(b *strings.Builder, lines []string, i int) int {
+	var quoted []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if loc := quotePattern.FindStringIndex(line); loc != nil {
+			quoted = append(quoted, line[loc[1]:])
+			continue
+		}
+		// a lazy continuation of the quoted paragraph
+		if isBlank(line) || len(quoted) == 0 || isBlank(quoted[len(quoted)-1]) || interrupts(line) {
+			break
+		}
+		quoted = append(quoted, line)
+	}
+
+	b.WriteString("<blockquote>\n")
+	renderBlocks(b, quoted, false)
+	b.WriteString("</blockquote>\n")
+	return i
+}
+
+// listItem is a list item with the indentation of its content removed.
+type listItem struct {
+	lines []string
+}
+
+func renderList(b *strings.Builder, lines []string, i int) int {
+	first := listPattern.FindStringSubmatch(lines[i])
+	ordered := unicode.IsDigit(rune(first[2][0]))
+	delimiter := first[2][len(first[2])-1:]
+
+	var items []*listItem
+	var item *listItem
+	contentIndent := 0
+	tight := true
+	blank := false
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if isBlank(line) {
+			blank = true
+			item.lines = append(item.lines, "")
+			continue
+		}
+
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if indent >= contentIndent && item != nil {
+			item.lines = append(item.lines, line[contentIndent:])
+			if blank {
+				tight = false
+			}
+			blank = false
+			continue
+		}
+
+		m := listPattern.FindStringSubmatch(line)
+		if m != nil && !breakPattern.MatchString(line) && sameList(m[2], ordered, delimiter) {
+			if blank && item != nil {
+				tight = false
+			}
+			item = &listItem{}
+			items = append(items, item)
+			contentIndent = len(m[0])
+			if m[3] == "" {
+				// an empty first line, the content starts on the next ones
+				contentIndent = len(m[1]) + len(m[2]) + 1
+			}
+			item.lines = append(item.lines, line[len(m[0]):])
+			blank = false
+			continue
+		}
+
+		// a lazy continuation of the paragraph of the item
+		if blank || interrupts(line) {
+			break
+		}
+		item.lines = append(item.lines, strings.TrimLeft(line, " "))
+	}
+
+	if ordered {
+		start, _ := strconv.Atoi(first[2][:len(first[2])-1])
+		if start != 1 {
+			b.WriteString(`<ol start="` + strconv.Itoa(start) + `">` + "\n")
+		} else {
+			b.WriteString("<ol>\n")
+		}
+	} else {
+		b.WriteString("<ul>\n")
+	}
+	for _, item := range items {
+		var content strings.Builder
+		renderBlocks(&content, item.lines, tight)
+		rendered := content.String()
+		b.WriteString("<li>")
+		// the text of a tight item sits right inside <li>, blocks go on
+		// their own lines
+		if rendered != "" && (!tight || startsBlock(item.lines)) {
+			b.WriteString("\n")
+		}
+		if tight && !blockEndPattern.MatchString(rendered) {
+			rendered = strings.TrimSuffix(rendered, "\n")
+		}
+		b.WriteString(rendered)
+		b.WriteString("</li>\n")
+	}
+	if ordered {
+		b.WriteString("</ol>\n")
+	} else {
+		b.WriteString("</ul>\n")
+	}
+	return i
+}
+
+// startsBlock tells whether the first line of lines starts another block
+// than a paragraph.
+func startsBlock(lines []string) bool {
+	for _, line := range lines {
+		if !isBlank(line) {
+			return fencePattern.MatchString(line) || headingPattern.MatchString(line) ||
+				breakPattern.MatchString(line) || quotePattern.MatchString(line) || listPattern.MatchString(line)
+		}
+	}
+	return false
+}
+
+// sameList tells whether a list marker continues a list of the given kind.
+func sameList(marker string, ordered bool, delimiter string) bool {
+	if unicode.IsDigit(rune(marker[0])) != ordered {
+		return false
+	}
+	return marker[len(marker)-1:] == delimiter
+}
+
+// interrupts tells whether line starts a block that ends a paragraph.
+func interrupts(line string) bool {
+	if fencePattern.MatchString(line) || headingPattern.MatchString(line) ||
+		breakPattern.MatchString(line) || quotePattern.MatchString(line) {
+		return true
+	}
+	// only a bullet or a list starting at 1 interrupts a paragraph, a line
+	// starting with a number rarely is a list
+	m := listPattern.FindStringSubmatch(line)
+	if m == nil || m[3] == "" {
+		return false
+	}
+	return !unicode.IsDigit(rune(m[2][0])) || m[2][:len(m[2])-1] == "1"
+}
+
+func renderParagraph(b *strings.Builder, lines []string, i int, tight bool) int {
+	var text []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if isBlank(line) || len(text) > 0 && interrupts(line) {
+			break
+		}
+		text = append(text, strings.TrimLeft(line, " "))
+	}
+	for j := range text {
+		// two trailing spaces are a hard line break, like a backslash
+		if j < len(text)-1 && strings.HasSuffix(text[j], "  ") {
+			text[j] = strings.TrimRight(text[j], " ") + "\\"
+		} else {
+			text[j] = strings.TrimRight(text[j], " ")
+		}
+	}
+
+	if !tight {
+		b.WriteString("<p>")
+	}
+	renderInline(b, strings.Join(text, "\n"))
+	if !tight {
+		b.WriteString("</p>")
+	}
+	b.WriteString("\n")
+	return i
+}
+
+// renderInline renders the spans of the text of a block.
+func renderInline(b *strings.Builder, text string) {
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\\' && i+1 < len(text) && text[i+1] == '\n':
+			b.WriteString("<br />\n")
+			i += 2
+		case c == '\\' && i+1 < len(text) && isPunct(text[i+1]):
+			b.WriteString(html.EscapeString(text[i+1 : i+2]))
+			i += 2
+		case c == '`':
+			i = renderCode(b, text, i)
+		case c == '*' || c == '_':
+			i = renderEmphasis(b, text, i)
+		case c == '!' && i+1 < len(text) && text[i+1] == '[':
+			i = renderLink(b, text, i, true)
+		case c == '[':
+			i = renderLink(b, text, i, false)
+		case c == '<' && autolinkPattern.MatchString(text[i:]):
+			m := autolinkPattern.FindStringSubmatch(text[i:])
+			if href, ok := safeURL(m[1]); ok {
+				b.WriteString(`<a href="` + html.EscapeString(href) + `" rel="nofollow">` + html.EscapeString(m[1]) + "</a>")
+			} else {
+				b.WriteString(html.EscapeString(m[0]))
+			}
+			i += len(m[0])
+		default:
+			_, size := utf8.DecodeRuneInString(text[i:])
+			b.WriteString(html.EscapeString(text[i : i+size]))
+			i += size
+		}
+	}
+}
+
+func isPunct(c byte) bool {
+	return c < utf8.RuneSelf && unicode.IsPunct(rune(c)) || strings.IndexByte("$+<=>^`|~", c) >= 0
+}
+
+// renderCode renders the code span starting at text[i], a backtick string
+// without a closing one of the same length is literal.
+func renderCode(b *strings.Builder, text string, i int) int {
+	n := 0
+	for i+n < len(text) && text[i+n] == '`' {
+		n++
+	}
+	marker := text[i : i+n]
+	for j := i + n; j < len(text); {
+		k := strings.Index(text[j:], marker)
+		if k < 0 {
+			break
+		}
+		end := j + k
+		if end+n < len(text) && text[end+n] == '`' {
+			// a longer backtick string
+			for end < len(text) && text[end] == '`' {
+				end++
+			}
+			j = end
+			continue
+		}
+		code := strings.Replace(text[i+n:end], "\n", " ", -1)
+		if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.TrimSpace(code) != "" {
+			code = code[1 : len(code)-1]
+		}
+		b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+		return end + n
+	}
+	b.WriteString(marker)
+	return i + n
+}
+
+// renderEmphasis renders the emphasis opened at text[i], a delimiter run
+// followed by a space or without a closing run is literal.
+func renderEmphasis(b *strings.Builder, text string, i int) int {
+	c := text[i]
+	n := 0
+	for i+n < len(text) && text[i+n] == c {
+		n++
+	}
+	if n > 2 {
+		n = 2
+	}
+	for ; n > 0; n-- {
+		if end, ok := closeEmphasis(text, i, n); ok {
+			tag := "em"
+			if n == 2 {
+				tag = "strong"
+			}
+			b.WriteString("<" + tag + ">")
+			renderInline(b, text[i+n:end])
+			b.WriteString("</" + tag + ">")
+			return end + n
+		}
+	}
+	b.WriteByte(c)
+	return i + 1
+}
+
+// closeEmphasis finds the run of n delimiters closing the one at text[i].
+// An underscore can't open or close inside a word.
+func closeEmphasis(text string, i, n int) (int, bool) {
+	c := text[i]
+	start := i + n
+	if start >= len(text) || isSpace(text[start]) {
+		return 0, false
+	}
+	if c == '_' && i > 0 && isWordByte(text[i-1]) {
+		return 0, false
+	}
+	for j := start; j < len(text); j++ {
+		switch text[j] {
+		case '`':
+			// delimiters inside a code span don't count
+			var code strings.Builder
+			if next := renderCode(&code, text, j); strings.HasPrefix(code.String(), "<code>") {
+				j = next - 1
+			}
+			continue
+		case '\\':
+			j++
+			continue
+		case c:
+		default:
+			continue
+		}
+
+		run := j
+		for run < len(text) && text[run] == c {
+			run++
+		}
+		closes := run-j == n && j > start && !isSpace(text[j-1]) &&
+			!(c == '_' && run < len(text) && isWordByte(text[run]))
+		if closes {
+			return j, true
+		}
+		// a run of another length closes or opens something else
+		j = run - 1
+	}
+	return 0, false
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+func isWordByte(c byte) bool {
+	return c >= utf8.RuneSelf || c == '_' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
+
+// renderLink renders the inline link or image starting at text[i], a
+// bracket that doesn't start one is literal. Unsafe destinations are
+// dropped, the link text or the image description stays.
+func renderLink(b *strings.Builder, text string, i int, image bool) int {
+	open := i + 1
+	if image {
+		open++
+	}
+	close := matchingBracket(text, open-1)
+	if close < 0 || close+1 >= len(text) || text[close+1] != '(' {
+		b.WriteString(html.EscapeString(text[i:open]))
+		return open
+	}
+	dest, title, end, ok := parseDestination(text, close+2)
+	if !ok {
+		b.WriteString(html.EscapeString(text[i:open]))
+		return open
+	}
+
+	label := text[open:close]
+	href, safe := safeURL(dest)
+	switch {
+	case image && safe:
+		b.WriteString(`<img src="` + html.EscapeString(href) + `" alt="` + html.EscapeString(plainText(label)) + `"`)
+		if title != "" {
+			b.WriteString(` title="` + html.EscapeString(title) + `"`)
+		}
+		b.WriteString(" />")
+	case image:
+		b.WriteString(html.EscapeString(plainText(label)))
+	case safe:
+		b.WriteString(`<a href="` + html.EscapeString(href) + `"`)
+		if title != "" {
+			b.WriteString(` title="` + html.EscapeString(title) + `"`)
+		}
+		b.WriteString(` rel="nofollow">`)
+		renderInline(b, label)
+		b.WriteString("</a>")
+	default:
+		renderInline(b, label)
+	}
+	return end
+}
+
+// matchingBracket returns the index of the ] closing the [ at text[i].
+func matchingBracket(text string, i int) int {
+	depth := 0
+	for j := i; j < len(text); j++ {
+		switch text[j] {
+		case '\\':
+			j++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+// parseDestination parses `url "title")` from text[i], end is the index
+// after the closing parenthesis.
+func parseDestination(text string, i int) (dest, title string, end int, ok bool) {
+	skipSpaces := func() {
+		for i < len(text) && isSpace(text[i]) {
+			i++
+		}
+	}
+	skipSpaces()
+	if i < len(text) && text[i] == '<' {
+		j := strings.IndexAny(text[i:], ">\n")
+		if j < 0 || text[i+j] != '>' {
+			return "", "", 0, false
+		}
+		dest = text[i+1 : i+j]
+		i += j + 1
+	} else {
+		start, depth := i, 0
+		for ; i < len(text) && !isSpace(text[i]); i++ {
+			if text[i] == '(' {
+				depth++
+			} else if text[i] == ')' {
+				if depth == 0 {
+					break
+				}
+				depth--
+			}
+		}
+		dest = text[start:i]
+	}
+
+	skipSpaces()
+	if i < len(text) && (text[i] == '"' || text[i] == '\'') {
+		quote := text[i]
+		j := strings.IndexByte(text[i+1:], quote)
+		if j < 0 {
+			return "", "", 0, false
+		}
+		title = text[i+1 : i+1+j]
+		i += j + 2
+		skipSpaces()
+	}
+	if i >= len(text) || text[i] != ')' {
+		return "", "", 0, false
+	}
+	return unescape(dest), unescape(title), i + 1, true
+}
+
+// unescape removes the backslashes escaping punctuation.
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && isPunct(s[i+1]) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// plainText is the text of the spans of an image description.
+func plainText(label string) string {
+	var b strings.Builder
+	renderInline(&b, label)
+	text := tagPattern.ReplaceAllString(b.String(), "")
+	return html.UnescapeString(text)
+}
+
+// safeURL returns the destination of a link when it is relative or uses
+// http, https or mailto. Browsers ignore some characters in schemes, such
+// URLs don't parse.
+func safeURL(raw string) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "", "http", "https", "mailto":
+	default:
+		return "", false
+	}
+	if u.Scheme == "" && strings.Contains(strings.SplitN(raw, "/", 2)[0], ":") {
+		return "", false
+	}
+	return raw, true
+}
@@ -0,0 +1,72 @@
+package markdown
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestToHTML(t *testing.T) {
+	cases := []struct {
+		name     string
+		source   string
+		expected string
+	}{
+		{"empty", "", ""},
+		{"paragraphs", "one\ntwo\n\nthree", "<p>one\ntwo</p>\n<p>three</p>\n"},
+		{"hard break", "one  \ntwo\\\nthree", "<p>one<br />\ntwo<br />\nthree</p>\n"},
+		{"headings", "# One\n### Three ###\n#not", "<h1>One</h1>\n<h3>Three</h3>\n<p>#not</p>\n"},
+		{"thematic break", "a\n\n***\n- - -", "<p>a</p>\n<hr />\n<hr />\n"},
+		{"emphasis", "*em* **strong** _em_ __strong__", "<p><em>em</em> <strong>strong</strong> <em>em</em> <strong>strong</strong></p>\n"},
+		{"nested emphasis", "*a **b** c*", "<p><em>a <strong>b</strong> c</em></p>\n"},
+		{"literal delimiters", "2 * 3 * 4 and snake_case_name", "<p>2 * 3 * 4 and snake_case_name</p>\n"},
+		{"escapes", `\*not em\* \# \\`, "<p>*not em* # \\</p>\n"},
+		{"code span", "use `a < b` and `` ` ``", "<p>use <code>a &lt; b</code> and <code>`</code></p>\n"},
+		{"emphasis around code", "*`*`*", "<p><em><code>*</code></em></p>\n"},
+		{"fenced code", "```go\nif a < b {\n}\n```\nafter", "<pre><code class=\"language-go\">if a &lt; b {\n}\n</code></pre>\n<p>after</p>\n"},
+		{"unclosed fence", "~~~\n# not a heading", "<pre><code># not a heading\n</code></pre>\n"},
+		{"block quote", "> # Quote\n> text\nlazy\n\nafter", "<blockquote>\n<h1>Quote</h1>\n<p>text\nlazy</p>\n</blockquote>\n<p>after</p>\n"},
+		{"tight list", "- one\n- *two*\n- three", "<ul>\n<li>one</li>\n<li><em>two</em></li>\n<li>three</li>\n</ul>\n"},
+		{"loose list", "1. one\n\n2. two", "<ol>\n<li>\n<p>one</p>\n</li>\n<li>\n<p>two</p>\n</li>\n</ol>\n"},
+		{"ordered list start", "3) three\n4) four", "<ol start=\"3\">\n<li>three</li>\n<li>four</li>\n</ol>\n"},
+		{"nested list", "- one\n  - a\n  - b\n- two", "<ul>\n<li>one\n<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n</li>\n<li>two</li>\n</ul>\n"},
+		{"list after paragraph", "text\n- one", "<p>text</p>\n<ul>\n<li>one</li>\n</ul>\n"},
+		{"number in paragraph", "The year\n2019. was good", "<p>The year\n2019. was good</p>\n"},
+		{"lists of different markers", "- one\n+ two", "<ul>\n<li>one</li>\n</ul>\n<ul>\n<li>two</li>\n</ul>\n"},
+		{"link", `[the *site*](https://example.com/a_b "Title")`, "<p><a href=\"https://example.com/a_b\" title=\"Title\" rel=\"nofollow\">the <em>site</em></a></p>\n"},
+		{"relative link", "[up](../notes?a=1&b=2)", "<p><a href=\"../notes?a=1&amp;b=2\" rel=\"nofollow\">up</a></p>\n"},
+		{"image", `![a *cat*](/cat.png)`, "<p><img src=\"/cat.png\" alt=\"a cat\" /></p>\n"},
+		{"autolink", "<https://example.com?a=1>", "<p><a href=\"https://example.com?a=1\" rel=\"nofollow\">https://example.com?a=1</a></p>\n"},
+		{"not a link", "[a] (b) [c", "<p>[a] (b) [c</p>\n"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, ToHTML(c.source))
+		})
+	}
+}
+
+func TestToHTML_Sanitizes(t *testing.T) {
+	cases := []struct {
+		name     string
+		source   string
+		expected string
+	}{
+		{"raw html", `<script>alert("x")</script>`, "<p>&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt;</p>\n"},
+		{"html block", "<div onclick=\"x()\">\n\nhi", "<p>&lt;div onclick=&#34;x()&#34;&gt;</p>\n<p>hi</p>\n"},
+		{"javascript link", "[click](javascript:alert(1))", "<p>click</p>\n"},
+		{"javascript link case", "[click](JaVaScRiPt:alert(1))", "<p>click</p>\n"},
+		{"javascript link tab", "[click](java\tscript:alert(1))", "<p>[click](java\tscript:alert(1))</p>\n"},
+		{"javascript link entity", "[click](&#106;avascript:alert(1))", "<p>click</p>\n"},
+		{"data image", "![x](data:text/html;base64,PHNjcmlwdD4=)", "<p>x</p>\n"},
+		{"javascript autolink", "<javascript:alert(1)>", "<p>&lt;javascript:alert(1)&gt;</p>\n"},
+		{"attribute injection", `[x](https://a.com/" onmouseover="y)`, "<p>[x](https://a.com/&#34; onmouseover=&#34;y)</p>\n"},
+		{"quoted title", `[x](/a 'it"s')`, "<p><a href=\"/a\" title=\"it&#34;s\" rel=\"nofollow\">x</a></p>\n"},
+		{"fence info", "```\"><script>\n```", "<pre><code class=\"language-&#34;&gt;&lt;script&gt;\"></code></pre>\n"},
+		{"mailto", "[me](mailto:me@example.com)", "<p><a href=\"mailto:me@example.com\" rel=\"nofollow\">me</a></p>\n"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, ToHTML(c.source))
+		})
+	}
+}
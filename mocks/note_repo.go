@@ -2,56 +2,121 @@
 
 package mocks
 
+import context "context"
+import jsonpatch "github.com/lyquocnam/go-note-learning/jsonpatch"
 import mock "github.com/stretchr/testify/mock"
 import model "github.com/lyquocnam/go-note-learning/model"
+import time "time"
 
 // NoteRepo is an autogenerated mock type for the NoteRepo type
 type NoteRepo struct {
 	mock.Mock
 }
 
-// Delete provides a mock function with given fields: id
-func (_m *NoteRepo) Delete(id uint) (uint, int, error) {
-	ret := _m.Called(id)
+// AddTags provides a mock function with given fields: ctx, id, request, versions
+func (_m *NoteRepo) AddTags(ctx context.Context, id string, request *model.NoteTagsRequest, versions []uint) (*model.Note, error) {
+	ret := _m.Called(ctx, id, request, versions)
 
-	var r0 uint
-	if rf, ok := ret.Get(0).(func(uint) uint); ok {
-		r0 = rf(id)
+	var r0 *model.Note
+	if rf, ok := ret.Get(0).(func(context.Context, string, *model.NoteTagsRequest, []uint) *model.Note); ok {
+		r0 = rf(ctx, id, request, versions)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Note)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, *model.NoteTagsRequest, []uint) error); ok {
+		r1 = rf(ctx, id, request, versions)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Batch provides a mock function with given fields: ctx, request
+func (_m *NoteRepo) Batch(ctx context.Context, request *model.NoteBatchRequest) ([]*model.NoteBatchResult, error) {
+	ret := _m.Called(ctx, request)
+
+	var r0 []*model.NoteBatchResult
+	if rf, ok := ret.Get(0).(func(context.Context, *model.NoteBatchRequest) []*model.NoteBatchResult); ok {
+		r0 = rf(ctx, request)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.NoteBatchResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *model.NoteBatchRequest) error); ok {
+		r1 = rf(ctx, request)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Comment provides a mock function with given fields: ctx, id, request
+func (_m *NoteRepo) Comment(ctx context.Context, id string, request *model.CommentRequest) (*model.Comment, error) {
+	ret := _m.Called(ctx, id, request)
+
+	var r0 *model.Comment
+	if rf, ok := ret.Get(0).(func(context.Context, string, *model.CommentRequest) *model.Comment); ok {
+		r0 = rf(ctx, id, request)
 	} else {
-		r0 = ret.Get(0).(uint)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Comment)
+		}
 	}
 
-	var r1 int
-	if rf, ok := ret.Get(1).(func(uint) int); ok {
-		r1 = rf(id)
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, *model.CommentRequest) error); ok {
+		r1 = rf(ctx, id, request)
 	} else {
-		r1 = ret.Get(1).(int)
+		r1 = ret.Error(1)
 	}
 
-	var r2 error
-	if rf, ok := ret.Get(2).(func(uint) error); ok {
-		r2 = rf(id)
+	return r0, r1
+}
+
+// Delete provides a mock function with given fields: ctx, id
+func (_m *NoteRepo) Delete(ctx context.Context, id string) (string, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
+		r0 = rf(ctx, id)
 	} else {
-		r2 = ret.Error(2)
+		r0 = ret.Get(0).(string)
 	}
 
-	return r0, r1, r2
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
-// Exist provides a mock function with given fields: id
-func (_m *NoteRepo) Exist(id uint) (bool, error) {
-	ret := _m.Called(id)
+// Exist provides a mock function with given fields: ctx, id
+func (_m *NoteRepo) Exist(ctx context.Context, id string) (bool, error) {
+	ret := _m.Called(ctx, id)
 
 	var r0 bool
-	if rf, ok := ret.Get(0).(func(uint) bool); ok {
-		r0 = rf(id)
+	if rf, ok := ret.Get(0).(func(context.Context, string) bool); ok {
+		r0 = rf(ctx, id)
 	} else {
 		r0 = ret.Get(0).(bool)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(uint) error); ok {
-		r1 = rf(id)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -59,20 +124,20 @@ func (_m *NoteRepo) Exist(id uint) (bool, error) {
 	return r0, r1
 }
 
-// ExistByTitle provides a mock function with given fields: title
-func (_m *NoteRepo) ExistByTitle(title string) (bool, error) {
-	ret := _m.Called(title)
+// ExistByTitle provides a mock function with given fields: ctx, title
+func (_m *NoteRepo) ExistByTitle(ctx context.Context, title string) (bool, error) {
+	ret := _m.Called(ctx, title)
 
 	var r0 bool
-	if rf, ok := ret.Get(0).(func(string) bool); ok {
-		r0 = rf(title)
+	if rf, ok := ret.Get(0).(func(context.Context, string) bool); ok {
+		r0 = rf(ctx, title)
 	} else {
 		r0 = ret.Get(0).(bool)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(title)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, title)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -80,13 +145,13 @@ func (_m *NoteRepo) ExistByTitle(title string) (bool, error) {
 	return r0, r1
 }
 
-// Get provides a mock function with given fields: id
-func (_m *NoteRepo) Get(id uint) (*model.Note, error) {
-	ret := _m.Called(id)
+// Get provides a mock function with given fields: ctx, id
+func (_m *NoteRepo) Get(ctx context.Context, id string) (*model.Note, error) {
+	ret := _m.Called(ctx, id)
 
 	var r0 *model.Note
-	if rf, ok := ret.Get(0).(func(uint) *model.Note); ok {
-		r0 = rf(id)
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.Note); ok {
+		r0 = rf(ctx, id)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*model.Note)
@@ -94,8 +159,100 @@ func (_m *NoteRepo) Get(id uint) (*model.Note, error) {
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(uint) error); ok {
-		r1 = rf(id)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetComments provides a mock function with given fields: ctx, id
+func (_m *NoteRepo) GetComments(ctx context.Context, id string) ([]*model.Comment, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 []*model.Comment
+	if rf, ok := ret.Get(0).(func(context.Context, string) []*model.Comment); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.Comment)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetList provides a mock function with given fields: ctx, request
+func (_m *NoteRepo) GetList(ctx context.Context, request *model.NoteListRequest) (*model.NotePage, error) {
+	ret := _m.Called(ctx, request)
+
+	var r0 *model.NotePage
+	if rf, ok := ret.Get(0).(func(context.Context, *model.NoteListRequest) *model.NotePage); ok {
+		r0 = rf(ctx, request)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.NotePage)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *model.NoteListRequest) error); ok {
+		r1 = rf(ctx, request)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetShares provides a mock function with given fields: ctx, id
+func (_m *NoteRepo) GetShares(ctx context.Context, id string) ([]*model.Share, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 []*model.Share
+	if rf, ok := ret.Get(0).(func(context.Context, string) []*model.Share); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.Share)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetTrash provides a mock function with given fields: ctx, request
+func (_m *NoteRepo) GetTrash(ctx context.Context, request *model.NoteListRequest) (*model.NotePage, error) {
+	ret := _m.Called(ctx, request)
+
+	var r0 *model.NotePage
+	if rf, ok := ret.Get(0).(func(context.Context, *model.NoteListRequest) *model.NotePage); ok {
+		r0 = rf(ctx, request)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.NotePage)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *model.NoteListRequest) error); ok {
+		r1 = rf(ctx, request)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -103,22 +260,22 @@ func (_m *NoteRepo) Get(id uint) (*model.Note, error) {
 	return r0, r1
 }
 
-// GetList provides a mock function with given fields:
-func (_m *NoteRepo) GetList() ([]*model.Note, error) {
-	ret := _m.Called()
+// Insert provides a mock function with given fields: ctx, note
+func (_m *NoteRepo) Insert(ctx context.Context, note *model.NoteRequest) (*model.Note, error) {
+	ret := _m.Called(ctx, note)
 
-	var r0 []*model.Note
-	if rf, ok := ret.Get(0).(func() []*model.Note); ok {
-		r0 = rf()
+	var r0 *model.Note
+	if rf, ok := ret.Get(0).(func(context.Context, *model.NoteRequest) *model.Note); ok {
+		r0 = rf(ctx, note)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]*model.Note)
+			r0 = ret.Get(0).(*model.Note)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func() error); ok {
-		r1 = rf()
+	if rf, ok := ret.Get(1).(func(context.Context, *model.NoteRequest) error); ok {
+		r1 = rf(ctx, note)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -126,62 +283,203 @@ func (_m *NoteRepo) GetList() ([]*model.Note, error) {
 	return r0, r1
 }
 
-// Insert provides a mock function with given fields: note
-func (_m *NoteRepo) Insert(note *model.NoteRequest) (*model.Note, int, error) {
-	ret := _m.Called(note)
+// Patch provides a mock function with given fields: ctx, id, patch, versions
+func (_m *NoteRepo) Patch(ctx context.Context, id string, patch jsonpatch.Patcher, versions []uint) (*model.Note, error) {
+	ret := _m.Called(ctx, id, patch, versions)
 
 	var r0 *model.Note
-	if rf, ok := ret.Get(0).(func(*model.NoteRequest) *model.Note); ok {
-		r0 = rf(note)
+	if rf, ok := ret.Get(0).(func(context.Context, string, jsonpatch.Patcher, []uint) *model.Note); ok {
+		r0 = rf(ctx, id, patch, versions)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*model.Note)
 		}
 	}
 
-	var r1 int
-	if rf, ok := ret.Get(1).(func(*model.NoteRequest) int); ok {
-		r1 = rf(note)
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, jsonpatch.Patcher, []uint) error); ok {
+		r1 = rf(ctx, id, patch, versions)
 	} else {
-		r1 = ret.Get(1).(int)
+		r1 = ret.Error(1)
 	}
 
-	var r2 error
-	if rf, ok := ret.Get(2).(func(*model.NoteRequest) error); ok {
-		r2 = rf(note)
+	return r0, r1
+}
+
+// Purge provides a mock function with given fields: ctx, id
+func (_m *NoteRepo) Purge(ctx context.Context, id string) (string, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PurgeTrash provides a mock function with given fields: ctx, before
+func (_m *NoteRepo) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	ret := _m.Called(ctx, before)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) int); ok {
+		r0 = rf(ctx, before)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, before)
 	} else {
-		r2 = ret.Error(2)
+		r1 = ret.Error(1)
 	}
 
-	return r0, r1, r2
+	return r0, r1
 }
 
-// Update provides a mock function with given fields: id, request
-func (_m *NoteRepo) Update(id uint, request *model.NoteRequest) (*model.Note, int, error) {
-	ret := _m.Called(id, request)
+// RemoveTag provides a mock function with given fields: ctx, id, tag, versions
+func (_m *NoteRepo) RemoveTag(ctx context.Context, id string, tag string, versions []uint) (*model.Note, error) {
+	ret := _m.Called(ctx, id, tag, versions)
 
 	var r0 *model.Note
-	if rf, ok := ret.Get(0).(func(uint, *model.NoteRequest) *model.Note); ok {
-		r0 = rf(id, request)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, []uint) *model.Note); ok {
+		r0 = rf(ctx, id, tag, versions)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*model.Note)
 		}
 	}
 
-	var r1 int
-	if rf, ok := ret.Get(1).(func(uint, *model.NoteRequest) int); ok {
-		r1 = rf(id, request)
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, []uint) error); ok {
+		r1 = rf(ctx, id, tag, versions)
 	} else {
-		r1 = ret.Get(1).(int)
+		r1 = ret.Error(1)
 	}
 
-	var r2 error
-	if rf, ok := ret.Get(2).(func(uint, *model.NoteRequest) error); ok {
-		r2 = rf(id, request)
+	return r0, r1
+}
+
+// Restore provides a mock function with given fields: ctx, id
+func (_m *NoteRepo) Restore(ctx context.Context, id string) (*model.Note, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *model.Note
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.Note); ok {
+		r0 = rf(ctx, id)
 	} else {
-		r2 = ret.Error(2)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Note)
+		}
 	}
 
-	return r0, r1, r2
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Search provides a mock function with given fields: ctx, request
+func (_m *NoteRepo) Search(ctx context.Context, request *model.NoteSearchRequest) (*model.NoteSearchPage, error) {
+	ret := _m.Called(ctx, request)
+
+	var r0 *model.NoteSearchPage
+	if rf, ok := ret.Get(0).(func(context.Context, *model.NoteSearchRequest) *model.NoteSearchPage); ok {
+		r0 = rf(ctx, request)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.NoteSearchPage)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *model.NoteSearchRequest) error); ok {
+		r1 = rf(ctx, request)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Share provides a mock function with given fields: ctx, id, request
+func (_m *NoteRepo) Share(ctx context.Context, id string, request *model.ShareRequest) (*model.Share, error) {
+	ret := _m.Called(ctx, id, request)
+
+	var r0 *model.Share
+	if rf, ok := ret.Get(0).(func(context.Context, string, *model.ShareRequest) *model.Share); ok {
+		r0 = rf(ctx, id, request)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Share)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, *model.ShareRequest) error); ok {
+		r1 = rf(ctx, id, request)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Unshare provides a mock function with given fields: ctx, id, userID
+func (_m *NoteRepo) Unshare(ctx context.Context, id string, userID string) (string, error) {
+	ret := _m.Called(ctx, id, userID)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) string); ok {
+		r0 = rf(ctx, id, userID)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, id, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Update provides a mock function with given fields: ctx, id, request, versions
+func (_m *NoteRepo) Update(ctx context.Context, id string, request *model.NoteRequest, versions []uint) (*model.Note, error) {
+	ret := _m.Called(ctx, id, request, versions)
+
+	var r0 *model.Note
+	if rf, ok := ret.Get(0).(func(context.Context, string, *model.NoteRequest, []uint) *model.Note); ok {
+		r0 = rf(ctx, id, request, versions)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Note)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, *model.NoteRequest, []uint) error); ok {
+		r1 = rf(ctx, id, request, versions)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
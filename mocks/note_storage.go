@@ -2,31 +2,53 @@
 
 package mocks
 
+import context "context"
 import mock "github.com/stretchr/testify/mock"
 import model "github.com/lyquocnam/go-note-learning/model"
+import storage "github.com/lyquocnam/go-note-learning/storage"
 
 // NoteStorage is an autogenerated mock type for the NoteStorage type
 type NoteStorage struct {
 	mock.Mock
 }
 
-// Count provides a mock function with given fields: where, args
-func (_m *NoteStorage) Count(where interface{}, args ...interface{}) (int, error) {
-	var _ca []interface{}
-	_ca = append(_ca, where)
-	_ca = append(_ca, args...)
-	ret := _m.Called(_ca...)
+// Batch provides a mock function with given fields: ctx, ops, atomic
+func (_m *NoteStorage) Batch(ctx context.Context, ops []storage.NoteOp, atomic bool) ([]storage.NoteOpResult, error) {
+	ret := _m.Called(ctx, ops, atomic)
+
+	var r0 []storage.NoteOpResult
+	if rf, ok := ret.Get(0).(func(context.Context, []storage.NoteOp, bool) []storage.NoteOpResult); ok {
+		r0 = rf(ctx, ops, atomic)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]storage.NoteOpResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []storage.NoteOp, bool) error); ok {
+		r1 = rf(ctx, ops, atomic)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Count provides a mock function with given fields: ctx, filter
+func (_m *NoteStorage) Count(ctx context.Context, filter storage.NoteFilter) (int, error) {
+	ret := _m.Called(ctx, filter)
 
 	var r0 int
-	if rf, ok := ret.Get(0).(func(interface{}, ...interface{}) int); ok {
-		r0 = rf(where, args...)
+	if rf, ok := ret.Get(0).(func(context.Context, storage.NoteFilter) int); ok {
+		r0 = rf(ctx, filter)
 	} else {
 		r0 = ret.Get(0).(int)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(interface{}, ...interface{}) error); ok {
-		r1 = rf(where, args...)
+	if rf, ok := ret.Get(1).(func(context.Context, storage.NoteFilter) error); ok {
+		r1 = rf(ctx, filter)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -34,13 +56,13 @@ func (_m *NoteStorage) Count(where interface{}, args ...interface{}) (int, error
 	return r0, r1
 }
 
-// Delete provides a mock function with given fields: note
-func (_m *NoteStorage) Delete(note *model.Note) error {
-	ret := _m.Called(note)
+// Delete provides a mock function with given fields: ctx, note
+func (_m *NoteStorage) Delete(ctx context.Context, note *model.Note) error {
+	ret := _m.Called(ctx, note)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(*model.Note) error); ok {
-		r0 = rf(note)
+	if rf, ok := ret.Get(0).(func(context.Context, *model.Note) error); ok {
+		r0 = rf(ctx, note)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -48,13 +70,13 @@ func (_m *NoteStorage) Delete(note *model.Note) error {
 	return r0
 }
 
-// Get provides a mock function with given fields: id
-func (_m *NoteStorage) Get(id uint) (*model.Note, error) {
-	ret := _m.Called(id)
+// Find provides a mock function with given fields: ctx, filter
+func (_m *NoteStorage) Find(ctx context.Context, filter storage.NoteFilter) (*model.Note, error) {
+	ret := _m.Called(ctx, filter)
 
 	var r0 *model.Note
-	if rf, ok := ret.Get(0).(func(uint) *model.Note); ok {
-		r0 = rf(id)
+	if rf, ok := ret.Get(0).(func(context.Context, storage.NoteFilter) *model.Note); ok {
+		r0 = rf(ctx, filter)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*model.Note)
@@ -62,8 +84,8 @@ func (_m *NoteStorage) Get(id uint) (*model.Note, error) {
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(uint) error); ok {
-		r1 = rf(id)
+	if rf, ok := ret.Get(1).(func(context.Context, storage.NoteFilter) error); ok {
+		r1 = rf(ctx, filter)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -71,13 +93,13 @@ func (_m *NoteStorage) Get(id uint) (*model.Note, error) {
 	return r0, r1
 }
 
-// GetByTitle provides a mock function with given fields: title
-func (_m *NoteStorage) GetByTitle(title string) (*model.Note, error) {
-	ret := _m.Called(title)
+// Get provides a mock function with given fields: ctx, id
+func (_m *NoteStorage) Get(ctx context.Context, id uint) (*model.Note, error) {
+	ret := _m.Called(ctx, id)
 
 	var r0 *model.Note
-	if rf, ok := ret.Get(0).(func(string) *model.Note); ok {
-		r0 = rf(title)
+	if rf, ok := ret.Get(0).(func(context.Context, uint) *model.Note); ok {
+		r0 = rf(ctx, id)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*model.Note)
@@ -85,8 +107,8 @@ func (_m *NoteStorage) GetByTitle(title string) (*model.Note, error) {
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(title)
+	if rf, ok := ret.Get(1).(func(context.Context, uint) error); ok {
+		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -94,13 +116,13 @@ func (_m *NoteStorage) GetByTitle(title string) (*model.Note, error) {
 	return r0, r1
 }
 
-// GetList provides a mock function with given fields:
-func (_m *NoteStorage) GetList() ([]*model.Note, error) {
-	ret := _m.Called()
+// GetList provides a mock function with given fields: ctx, filter, opts
+func (_m *NoteStorage) GetList(ctx context.Context, filter storage.NoteFilter, opts storage.NoteListOptions) ([]*model.Note, error) {
+	ret := _m.Called(ctx, filter, opts)
 
 	var r0 []*model.Note
-	if rf, ok := ret.Get(0).(func() []*model.Note); ok {
-		r0 = rf()
+	if rf, ok := ret.Get(0).(func(context.Context, storage.NoteFilter, storage.NoteListOptions) []*model.Note); ok {
+		r0 = rf(ctx, filter, opts)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]*model.Note)
@@ -108,8 +130,8 @@ func (_m *NoteStorage) GetList() ([]*model.Note, error) {
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func() error); ok {
-		r1 = rf()
+	if rf, ok := ret.Get(1).(func(context.Context, storage.NoteFilter, storage.NoteListOptions) error); ok {
+		r1 = rf(ctx, filter, opts)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -117,13 +139,13 @@ func (_m *NoteStorage) GetList() ([]*model.Note, error) {
 	return r0, r1
 }
 
-// Insert provides a mock function with given fields: note
-func (_m *NoteStorage) Insert(note *model.Note) (*model.Note, error) {
-	ret := _m.Called(note)
+// Insert provides a mock function with given fields: ctx, note
+func (_m *NoteStorage) Insert(ctx context.Context, note *model.Note) (*model.Note, error) {
+	ret := _m.Called(ctx, note)
 
 	var r0 *model.Note
-	if rf, ok := ret.Get(0).(func(*model.Note) *model.Note); ok {
-		r0 = rf(note)
+	if rf, ok := ret.Get(0).(func(context.Context, *model.Note) *model.Note); ok {
+		r0 = rf(ctx, note)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*model.Note)
@@ -131,8 +153,8 @@ func (_m *NoteStorage) Insert(note *model.Note) (*model.Note, error) {
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(*model.Note) error); ok {
-		r1 = rf(note)
+	if rf, ok := ret.Get(1).(func(context.Context, *model.Note) error); ok {
+		r1 = rf(ctx, note)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -140,13 +162,85 @@ func (_m *NoteStorage) Insert(note *model.Note) (*model.Note, error) {
 	return r0, r1
 }
 
-// Update provides a mock function with given fields: id, note
-func (_m *NoteStorage) Update(id uint, note *model.Note) (*model.Note, error) {
-	ret := _m.Called(id, note)
+// Purge provides a mock function with given fields: ctx, note
+func (_m *NoteStorage) Purge(ctx context.Context, note *model.Note) error {
+	ret := _m.Called(ctx, note)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.Note) error); ok {
+		r0 = rf(ctx, note)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RenameTag provides a mock function with given fields: ctx, from, to
+func (_m *NoteStorage) RenameTag(ctx context.Context, from string, to string) (int, error) {
+	ret := _m.Called(ctx, from, to)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) int); ok {
+		r0 = rf(ctx, from, to)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, from, to)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Restore provides a mock function with given fields: ctx, note
+func (_m *NoteStorage) Restore(ctx context.Context, note *model.Note) error {
+	ret := _m.Called(ctx, note)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.Note) error); ok {
+		r0 = rf(ctx, note)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Tags provides a mock function with given fields: ctx
+func (_m *NoteStorage) Tags(ctx context.Context) ([]*model.Tag, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []*model.Tag
+	if rf, ok := ret.Get(0).(func(context.Context) []*model.Tag); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.Tag)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Update provides a mock function with given fields: ctx, id, note
+func (_m *NoteStorage) Update(ctx context.Context, id uint, note *model.Note) (*model.Note, error) {
+	ret := _m.Called(ctx, id, note)
 
 	var r0 *model.Note
-	if rf, ok := ret.Get(0).(func(uint, *model.Note) *model.Note); ok {
-		r0 = rf(id, note)
+	if rf, ok := ret.Get(0).(func(context.Context, uint, *model.Note) *model.Note); ok {
+		r0 = rf(ctx, id, note)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*model.Note)
@@ -154,11 +248,25 @@ func (_m *NoteStorage) Update(id uint, note *model.Note) (*model.Note, error) {
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(uint, *model.Note) error); ok {
-		r1 = rf(id, note)
+	if rf, ok := ret.Get(1).(func(context.Context, uint, *model.Note) error); ok {
+		r1 = rf(ctx, id, note)
 	} else {
 		r1 = ret.Error(1)
 	}
 
 	return r0, r1
 }
+
+// WithTx provides a mock function with given fields: ctx, fn
+func (_m *NoteStorage) WithTx(ctx context.Context, fn func(s storage.NoteStorage) error) error {
+	ret := _m.Called(ctx, fn)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, func(s storage.NoteStorage) error) error); ok {
+		r0 = rf(ctx, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
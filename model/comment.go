@@ -0,0 +1,29 @@
+package model
+
+import (
+	validator "github.com/asaskevich/govalidator"
+	"time"
+)
+
+// Comment is a comment left on a note by its owner or by a user the note
+// is shared with as commenter or editor.
+type Comment struct {
+	ID uint `gorm:"primary_key" json:"-"`
+	// NoteID is the public id of the note
+	NoteID string `gorm:"type:char(26);not null;index" json:"note_id"`
+	// AuthorID is the user who wrote the comment, AuthorPublicID its
+	// public id
+	AuthorID       uint      `gorm:"not null" json:"-"`
+	AuthorPublicID string    `gorm:"type:char(26);not null" json:"author_id"`
+	Body           string    `gorm:"type:text;not null" json:"body"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// CommentRequest is the body of POST /notes/:id/comments.
+type CommentRequest struct {
+	Body *string `json:"body" valid:"required~comment_body_required,runelength(1|2000)~comment_body_length"`
+}
+
+func (r *CommentRequest) Validate() (bool, error) {
+	return validator.ValidateStruct(r)
+}
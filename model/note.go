@@ -1,19 +1,56 @@
 package model
 
 import (
+	"encoding/json"
 	validator "github.com/asaskevich/govalidator"
+	"strings"
 	"time"
+	"unicode"
 )
 
 type Note struct {
-	ID          uint       `gorm:"primary_key" json:"id"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
-	DeletedAt   *time.Time `json:"deleted_at"`
-	Title       string     `gorm:"unique" json:"title" valid:"required~Tiêu đề không được trống,runelength(1|80)~Tiêu đề phải từ 1 - 80 ký tự"`
-	IsCompleted bool       `json:"is_completed" valid:"required~Trạng thái hoàn tất không được rỗng"`
+	// ID is the key of the note inside the storage, it is never exposed
+	ID uint `gorm:"primary_key" json:"-" bson:"_id"`
+	// PublicID is the id of the note in the API, a ULID the storage assigns
+	// on insert
+	PublicID string `gorm:"type:char(26);unique_index" json:"id" bson:"public_id"`
+	// OwnerID is the id of the user the note belongs to, the storages only
+	// let that user see it
+	OwnerID     uint       `gorm:"not null;default:0" json:"-" bson:"owner_id"`
+	CreatedAt   time.Time  `json:"created_at" bson:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" bson:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at" bson:"deleted_at"`
+	Title       string     `json:"title" bson:"title" valid:"required~note_title_required,runelength(1|80)~note_title_length,note_title~note_title_invalid"`
+	IsCompleted bool       `json:"is_completed" bson:"is_completed"`
+	// Content is the Markdown source of the body of the note
+	Content string `gorm:"type:text;not null;default:''" json:"content" bson:"content" valid:"runelength(0|20000)~note_content_length"`
+	// Tags are normalized by NormalizeTags and checked by Validate, the gorm
+	// storage keeps them in the tags and note_tags tables
+	Tags []string `gorm:"-" json:"tags" bson:"tags,omitempty"`
+	// Version is incremented by every update, it guards against lost updates
+	Version uint `gorm:"not null;default:0" json:"version" bson:"version"`
 }
 
 func (n *Note) Validate() (bool, error) {
-	return validator.ValidateStruct(n)
+	ok, err := validator.ValidateStruct(n)
+	return validateTags("tags", n.Tags, MaxNoteTags, ok, err)
+}
+
+func init() {
+	// a title is written as a single Markdown heading by the Markdown
+	// storage, it has no line break and no space around it
+	validator.TagMap["note_title"] = validator.Validator(isTitle)
+}
+
+func isTitle(title string) bool {
+	return strings.TrimSpace(title) == title && strings.IndexFunc(title, unicode.IsControl) < 0
+}
+
+// MarshalJSON writes a note without tags with an empty list of tags.
+func (n Note) MarshalJSON() ([]byte, error) {
+	type note Note
+	if n.Tags == nil {
+		n.Tags = []string{}
+	}
+	return json.Marshal(note(n))
 }
@@ -0,0 +1,32 @@
+package model
+
+// Operations of a NoteBatchOperation.
+const (
+	NoteBatchCreate = "create"
+	NoteBatchUpdate = "update"
+	NoteBatchDelete = "delete"
+)
+
+// NoteBatchRequest is the body of POST /notes/batch. An atomic batch is
+// applied entirely or not at all, otherwise every operation succeeds or
+// fails on its own.
+type NoteBatchRequest struct {
+	Atomic     bool                  `json:"atomic"`
+	Operations []*NoteBatchOperation `json:"operations"`
+}
+
+// NoteBatchOperation creates a note, replaces the note ID like PUT does or
+// moves it to the trash. Version works like If-Match, 0 skips the check.
+type NoteBatchOperation struct {
+	Op      string       `json:"op"`
+	ID      string       `json:"id,omitempty"`
+	Version uint         `json:"version,omitempty"`
+	Note    *NoteRequest `json:"note,omitempty"`
+}
+
+// NoteBatchResult is the outcome of the operation at the same index, Err
+// is an apperr error.
+type NoteBatchResult struct {
+	Note *Note
+	Err  error
+}
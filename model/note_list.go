@@ -0,0 +1,51 @@
+package model
+
+import (
+	validator "github.com/asaskevich/govalidator"
+	"strings"
+)
+
+// NoteListRequest is the query string of GET /notes/.
+type NoteListRequest struct {
+	Limit       int    `form:"limit,default=20" json:"limit" valid:"range(1|100)~note_list_limit_range"`
+	Offset      int    `form:"offset" json:"offset" valid:"range(0|1000000)~note_list_offset_range"`
+	Cursor      string `form:"cursor" json:"cursor"`
+	Title       string `form:"title" json:"title" valid:"runelength(1|80)~note_title_length"`
+	IsCompleted *bool  `form:"is_completed" json:"is_completed"`
+	Sort        string `form:"sort" json:"sort" valid:"in(id|-id|created_at|-created_at|updated_at|-updated_at|title|-title)~note_list_sort_invalid"`
+	// Tags keeps the notes having every tag, or one of them when TagMode is
+	// any
+	Tags    []string `form:"tag" json:"tag"`
+	TagMode string   `form:"tag_mode" json:"tag_mode" valid:"in(all|any)~note_list_tag_mode_invalid"`
+	// Shared lists the notes other users shared with the caller instead of
+	// its own when it is with-me
+	Shared string `form:"shared" json:"shared" valid:"in(with-me)~note_list_shared_invalid"`
+}
+
+const (
+	TagModeAll = "all"
+	TagModeAny = "any"
+)
+
+const SharedWithMe = "with-me"
+
+func (r *NoteListRequest) Validate() (bool, error) {
+	ok, err := validator.ValidateStruct(r)
+	return validateTags("tag", r.Tags, 0, ok, err)
+}
+
+// SortField splits Sort into the field and the direction, a leading "-"
+// sorts descending.
+func (r *NoteListRequest) SortField() (field string, desc bool) {
+	if strings.HasPrefix(r.Sort, "-") {
+		return r.Sort[1:], true
+	}
+	return r.Sort, false
+}
+
+// NotePage is one page of GET /notes/. NextCursor is empty on the last page.
+type NotePage struct {
+	Items      []*Note `json:"items"`
+	Total      int     `json:"total"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
@@ -3,10 +3,13 @@ package model
 import validator "github.com/asaskevich/govalidator"
 
 type NoteRequest struct {
-	Title       *string `gorm:"unique" json:"title" valid:"required~Tiêu đề không được trống,runelength(1|80)~Tiêu đề phải từ 1 - 80 ký tự"`
-	IsCompleted *bool   `json:"is_completed" valid:"required~Trạng thái hoàn tất không được rỗng"`
+	Title       *string  `json:"title" valid:"required~note_title_required,runelength(1|80)~note_title_length,note_title~note_title_invalid"`
+	Content     *string  `json:"content" valid:"runelength(0|20000)~note_content_length"`
+	IsCompleted *bool    `json:"is_completed"`
+	Tags        []string `json:"tags"`
 }
 
 func (n *NoteRequest) Validate() (bool, error) {
-	return validator.ValidateStruct(n)
+	ok, err := validator.ValidateStruct(n)
+	return validateTags("tags", n.Tags, MaxNoteTags, ok, err)
 }
@@ -0,0 +1,118 @@
+package model
+
+import (
+	validator "github.com/asaskevich/govalidator"
+	"github.com/lyquocnam/go-note-learning/lib"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestNoteRequest_Validate(t *testing.T) {
+	title := func(value string) *string {
+		return &value
+	}
+	completed := false
+	cases := []struct {
+		name    string
+		request NoteRequest
+		fields  map[string]string
+	}{
+		{
+			name:    "case title only",
+			request: NoteRequest{Title: title("Hello")},
+		},
+		{
+			name:    "case title and status",
+			request: NoteRequest{Title: title("Hello"), IsCompleted: &completed},
+		},
+		{
+			name:    "case missing title",
+			request: NoteRequest{IsCompleted: &completed},
+			fields:  map[string]string{"title": lib.NoteTitleRequired},
+		},
+		{
+			name:    "case empty title",
+			request: NoteRequest{Title: title("")},
+			fields:  map[string]string{"title": lib.NoteTitleRequired},
+		},
+		{
+			name:    "case 80 vietnamese characters",
+			request: NoteRequest{Title: title(strings.Repeat("ữ", 80))},
+		},
+		{
+			name:    "case title with a line break",
+			request: NoteRequest{Title: title("Hello\r\nWorld")},
+			fields:  map[string]string{"title": lib.NoteTitleInvalid},
+		},
+		{
+			name:    "case 81 vietnamese characters",
+			request: NoteRequest{Title: title(strings.Repeat("ữ", 81))},
+			fields:  map[string]string{"title": lib.NoteTitleLength},
+		},
+		{
+			// 375 bytes, 125 runes
+			name:    "case 125 cjk characters",
+			request: NoteRequest{Title: title(strings.Repeat("漢字", 62) + "漢")},
+			fields:  map[string]string{"title": lib.NoteTitleLength},
+		},
+		{
+			name:    "case markdown content",
+			request: NoteRequest{Title: title("Hello"), Content: title("# Hello\n\n" + strings.Repeat("ữ", 19991))},
+		},
+		{
+			name:    "case content too long",
+			request: NoteRequest{Title: title("Hello"), Content: title(strings.Repeat("ữ", 20001))},
+			fields:  map[string]string{"content": lib.NoteContentLength},
+		},
+		{
+			name:    "case tags",
+			request: NoteRequest{Title: title("Hello"), Tags: []string{"work", "đi chợ"}},
+		},
+		{
+			name:    "case tag with a slash",
+			request: NoteRequest{Title: title("Hello"), Tags: []string{"work", "a/b"}},
+			fields:  map[string]string{"tags": lib.NoteTagInvalid},
+		},
+		{
+			name:    "case tag too long",
+			request: NoteRequest{Title: title("Hello"), Tags: []string{strings.Repeat("ữ", 51)}},
+			fields:  map[string]string{"tags": lib.NoteTagLength},
+		},
+		{
+			name:    "case too many tags",
+			request: NoteRequest{Title: title("Hello"), Tags: strings.Split(strings.Repeat("tag,", MaxNoteTags)+"tag", ",")},
+			fields:  map[string]string{"tags": lib.NoteTagCount},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ok, err := c.request.Validate()
+			assert.Equal(t, c.fields == nil, ok)
+			if c.fields == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, c.fields, validator.ErrorsByField(err))
+		})
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	assert.Equal(t, []string{"home", "work"}, NormalizeTags([]string{" Work", "home", "WORK", ""}))
+	assert.Nil(t, NormalizeTags([]string{" "}))
+}
+
+func TestNoteTagsRequest_Validate(t *testing.T) {
+	ok, err := (&NoteTagsRequest{}).Validate()
+	assert.False(t, ok)
+	assert.Equal(t, map[string]string{"tags": lib.NoteTagRequired}, validator.ErrorsByField(err))
+
+	ok, err = (&NoteTagsRequest{Tags: []string{"work", ""}}).Validate()
+	assert.False(t, ok)
+	assert.Equal(t, map[string]string{"tags": lib.NoteTagLength}, validator.ErrorsByField(err))
+
+	ok, err = (&NoteTagsRequest{Tags: []string{"work"}}).Validate()
+	assert.True(t, ok)
+	assert.NoError(t, err)
+}
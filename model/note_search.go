@@ -0,0 +1,29 @@
+package model
+
+import validator "github.com/asaskevich/govalidator"
+
+// NoteSearchRequest is the query string of GET /notes/search.
+type NoteSearchRequest struct {
+	Q      string `form:"q" json:"q" valid:"required~note_search_query_required,runelength(1|200)~note_search_query_length"`
+	Limit  int    `form:"limit,default=20" json:"limit" valid:"range(1|100)~note_list_limit_range"`
+	Offset int    `form:"offset" json:"offset" valid:"range(0|1000000)~note_list_offset_range"`
+}
+
+func (r *NoteSearchRequest) Validate() (bool, error) {
+	return validator.ValidateStruct(r)
+}
+
+// NoteSearchResult is a note matching a search. Snippet is the text around
+// the matches, the matched words are between <mark> and </mark> and the
+// text is not HTML escaped.
+type NoteSearchResult struct {
+	Note    *Note   `json:"note"`
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+// NoteSearchPage is one page of GET /notes/search, best matches first.
+type NoteSearchPage struct {
+	Items []*NoteSearchResult `json:"items"`
+	Total int                 `json:"total"`
+}
@@ -0,0 +1,83 @@
+package model
+
+import (
+	validator "github.com/asaskevich/govalidator"
+	"github.com/lyquocnam/go-note-learning/lib"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestNote_Validate(t *testing.T) {
+	cases := []struct {
+		name   string
+		title  string
+		fields map[string]string
+	}{
+		{
+			name:  "case ascii title",
+			title: "Hello",
+		},
+		{
+			name:   "case empty title",
+			title:  "",
+			fields: map[string]string{"title": lib.NoteTitleRequired},
+		},
+		{
+			name:  "case 80 ascii characters",
+			title: strings.Repeat("a", 80),
+		},
+		{
+			name:   "case 81 ascii characters",
+			title:  strings.Repeat("a", 81),
+			fields: map[string]string{"title": lib.NoteTitleLength},
+		},
+		{
+			// 160 bytes, the limit is on runes
+			name:  "case 80 vietnamese characters",
+			title: strings.Repeat("ệ", 80),
+		},
+		{
+			name:   "case 81 vietnamese characters",
+			title:  strings.Repeat("ệ", 81),
+			fields: map[string]string{"title": lib.NoteTitleLength},
+		},
+		{
+			name:  "case 80 emoji",
+			title: strings.Repeat("📝", 80),
+		},
+		{
+			name:  "case inner spaces",
+			title: "Hello  World",
+		},
+		{
+			name:   "case line break",
+			title:  "Hello\nWorld",
+			fields: map[string]string{"title": lib.NoteTitleInvalid},
+		},
+		{
+			name:   "case surrounding spaces",
+			title:  " Hello ",
+			fields: map[string]string{"title": lib.NoteTitleInvalid},
+		},
+		{
+			name:   "case blank title",
+			title:  "   ",
+			fields: map[string]string{"title": lib.NoteTitleInvalid},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for _, isCompleted := range []bool{false, true} {
+				note := Note{Title: c.title, IsCompleted: isCompleted}
+				ok, err := note.Validate()
+				assert.Equal(t, c.fields == nil, ok)
+				if c.fields == nil {
+					assert.NoError(t, err)
+					continue
+				}
+				assert.Equal(t, c.fields, validator.ErrorsByField(err))
+			}
+		})
+	}
+}
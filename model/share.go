@@ -0,0 +1,33 @@
+package model
+
+import (
+	validator "github.com/asaskevich/govalidator"
+	"github.com/lyquocnam/go-note-learning/acl"
+	"time"
+)
+
+// Share gives a user a role on the note of another user.
+type Share struct {
+	ID uint `gorm:"primary_key" json:"-"`
+	// NoteID is the public id of the note, OwnerID the user it belongs to
+	NoteID  string `gorm:"type:char(26);not null;unique_index:uix_shares_note_user" json:"note_id"`
+	OwnerID uint   `gorm:"not null" json:"-"`
+	// UserID is the user the note is shared with, UserPublicID its public
+	// id
+	UserID       uint      `gorm:"not null;unique_index:uix_shares_note_user;index" json:"-"`
+	UserPublicID string    `gorm:"type:char(26);not null" json:"user_id"`
+	Role         acl.Role  `gorm:"not null" json:"role"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// ShareRequest is the body of POST /notes/:id/shares, sharing the note
+// with a user it is already shared with changes the role.
+type ShareRequest struct {
+	UserID *string `json:"user_id" valid:"required~share_user_required"`
+	Role   *string `json:"role" valid:"required~share_role_required,in(viewer|commenter|editor)~share_role_invalid"`
+}
+
+func (r *ShareRequest) Validate() (bool, error) {
+	return validator.ValidateStruct(r)
+}
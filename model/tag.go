@@ -0,0 +1,107 @@
+package model
+
+import (
+	"errors"
+	validator "github.com/asaskevich/govalidator"
+	"github.com/lyquocnam/go-note-learning/lib"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxNoteTags is how many tags a note can have.
+const MaxNoteTags = 20
+
+// Tag labels notes, it is identified by its name. Count is how many notes
+// outside of the trash have it.
+type Tag struct {
+	ID    uint   `gorm:"primary_key" json:"-" bson:"-"`
+	Name  string `gorm:"type:varchar(50);not null;unique_index" json:"name" bson:"_id"`
+	Count int    `gorm:"-" json:"count" bson:"count"`
+}
+
+// TagRequest is the body of the tag rename and merge, Name is the new
+// name or the tag to merge into.
+type TagRequest struct {
+	Name *string `json:"name" valid:"required~note_tag_required,runelength(1|50)~note_tag_length,note_tag~note_tag_invalid"`
+}
+
+func (r *TagRequest) Validate() (bool, error) {
+	return validator.ValidateStruct(r)
+}
+
+// NoteTagsRequest is the body of POST /notes/:id/tags.
+type NoteTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+func (r *NoteTagsRequest) Validate() (bool, error) {
+	if len(r.Tags) == 0 {
+		return false, validator.Errors{tagsError("tags", lib.NoteTagRequired)}
+	}
+	return validateTags("tags", r.Tags, MaxNoteTags, true, nil)
+}
+
+func init() {
+	// a tag is used in paths and query strings, it has no slash, comma or
+	// control character
+	validator.TagMap["note_tag"] = validator.Validator(isTag)
+}
+
+func isTag(name string) bool {
+	return strings.IndexFunc(name, func(r rune) bool {
+		return r == '/' || r == ',' || unicode.IsControl(r)
+	}) < 0
+}
+
+// NormalizeTag is the stored form of a tag name, tags are case insensitive.
+func NormalizeTag(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// NormalizeTags normalizes names and sorts them without duplicates, it
+// returns nil when there is no tag.
+func NormalizeTags(names []string) []string {
+	var tags []string
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		tag := NormalizeTag(name)
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// validateTags adds an error on field to the result of ValidateStruct when
+// a tag is invalid or when there are more than max tags, 0 is no limit.
+// The tags are not checked by valid struct tags, govalidator only runs
+// them on the first element of a slice.
+func validateTags(field string, tags []string, max int, ok bool, err error) (bool, error) {
+	code := ""
+	for _, tag := range tags {
+		if length := utf8.RuneCountInString(tag); length < 1 || length > 50 {
+			code = lib.NoteTagLength
+		} else if !isTag(tag) {
+			code = lib.NoteTagInvalid
+		}
+		if code != "" {
+			break
+		}
+	}
+	if code == "" && max > 0 && len(tags) > max {
+		code = lib.NoteTagCount
+	}
+	if code == "" {
+		return ok, err
+	}
+	errs, _ := err.(validator.Errors)
+	return false, append(errs, tagsError(field, code))
+}
+
+func tagsError(field, code string) validator.Error {
+	return validator.Error{Name: field, Err: errors.New(code), CustomErrorMessageExists: true}
+}
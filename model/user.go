@@ -0,0 +1,78 @@
+package model
+
+import (
+	validator "github.com/asaskevich/govalidator"
+	"strings"
+	"time"
+)
+
+type User struct {
+	// ID is the key of the user inside the storage, it is never exposed
+	ID uint `gorm:"primary_key" json:"-"`
+	// PublicID is the id of the user in the API and the subject of its
+	// tokens, a ULID the storage assigns on insert
+	PublicID  string    `gorm:"type:char(26);unique_index" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Email is normalized by NormalizeEmail, it identifies the user on login
+	Email string `gorm:"type:varchar(254);not null;unique_index" json:"email"`
+	// PasswordHash is made by auth.HashPassword
+	PasswordHash string `gorm:"not null" json:"-"`
+}
+
+// RefreshToken records a refresh token the API issued, so it can be
+// rotated and revoked. Only its claims are kept, not the token itself.
+type RefreshToken struct {
+	// ID is the jti of the token
+	ID     string `gorm:"type:char(26);primary_key"`
+	UserID uint   `gorm:"not null;index"`
+	// FamilyID is the ID of the first token of the login it comes from,
+	// every token a refresh rotates into keeps it
+	FamilyID  string `gorm:"type:char(26);not null;index"`
+	CreatedAt time.Time
+	ExpiresAt time.Time `gorm:"not null"`
+	// RevokedAt is set once the token was used or revoked, a revoked token
+	// is never accepted again
+	RevokedAt *time.Time
+}
+
+// Revoked reports whether the token was used or revoked.
+func (t *RefreshToken) Revoked() bool {
+	return t.RevokedAt != nil
+}
+
+// CredentialsRequest is the body of POST /auth/register and /auth/login.
+type CredentialsRequest struct {
+	Email    *string `json:"email" valid:"required~user_email_required,email~user_email_invalid,runelength(3|254)~user_email_invalid"`
+	Password *string `json:"password" valid:"required~user_password_required,runelength(8|128)~user_password_length"`
+}
+
+func (r *CredentialsRequest) Validate() (bool, error) {
+	return validator.ValidateStruct(r)
+}
+
+// RefreshRequest is the body of POST /auth/refresh and /auth/logout.
+type RefreshRequest struct {
+	RefreshToken *string `json:"refresh_token" valid:"required~auth_refresh_token_required"`
+}
+
+func (r *RefreshRequest) Validate() (bool, error) {
+	return validator.ValidateStruct(r)
+}
+
+// AuthTokens is the answer of register, login and refresh.
+type AuthTokens struct {
+	User         *User  `json:"user"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	// TokenType is always Bearer, ExpiresIn is the number of seconds the
+	// access token is valid for
+	TokenType string `json:"token_type"`
+	ExpiresIn int    `json:"expires_in"`
+}
+
+// NormalizeEmail is the stored form of an email, emails are case
+// insensitive.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
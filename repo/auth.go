@@ -0,0 +1,301 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	validator "github.com/asaskevich/govalidator"
+	"github.com/lyquocnam/go-note-learning/apperr"
+	"github.com/lyquocnam/go-note-learning/auth"
+	"github.com/lyquocnam/go-note-learning/lib"
+	"github.com/lyquocnam/go-note-learning/model"
+	"github.com/lyquocnam/go-note-learning/storage"
+	"github.com/lyquocnam/go-note-learning/ulid"
+	"sync"
+	"time"
+)
+
+type authRepo struct {
+	userStorage storage.UserStorage
+	signer      *auth.Signer
+	accessTTL   time.Duration
+	refreshTTL  time.Duration
+	// adopter gets the notes stored before accounts existed to notesOwner,
+	// the email of their owner or empty for the first user, when that user
+	// registers
+	adopter    storage.NoteAdopter
+	notesOwner string
+}
+
+// NewAuthRepo issues tokens signed by signer, access tokens are valid for
+// accessTTL and refresh tokens for refreshTTL.
+func NewAuthRepo(userStorage storage.UserStorage, signer *auth.Signer, accessTTL, refreshTTL time.Duration) *authRepo {
+	return &authRepo{
+		userStorage: userStorage,
+		signer:      signer,
+		accessTTL:   accessTTL,
+		refreshTTL:  refreshTTL,
+	}
+}
+
+// AdoptNotes gives the notes stored before accounts existed to the user
+// whose email is owner, or to the first user when owner is empty. When
+// there is no user yet, Register gives them the notes. It fails when owner
+// is not a user while there are users.
+func (r *authRepo) AdoptNotes(ctx context.Context, adopter storage.NoteAdopter, owner string) (*model.User, int, error) {
+	r.adopter, r.notesOwner = adopter, model.NormalizeEmail(owner)
+	user, err := r.userStorage.FirstUser(ctx)
+	if err != nil || user == nil {
+		return nil, 0, err
+	}
+	if r.notesOwner != "" {
+		user, err = r.userStorage.FindUserByEmail(ctx, r.notesOwner)
+		if err != nil {
+			return nil, 0, err
+		}
+		if user == nil {
+			return nil, 0, fmt.Errorf("repo: the owner of the notes %s is not a user", r.notesOwner)
+		}
+	}
+	count, err := adopter.AdoptNotes(ctx, user.ID)
+	return user, count, err
+}
+
+// AuthRepo returns apperr errors like NoteRepo, credentials and tokens that
+// are not accepted are apperr.Unauthorized errors.
+type AuthRepo interface {
+	// Register creates a user and logs it in.
+	Register(ctx context.Context, request *model.CredentialsRequest) (*model.AuthTokens, error)
+	Login(ctx context.Context, request *model.CredentialsRequest) (*model.AuthTokens, error)
+	// Refresh rotates the refresh token of request: it is revoked and a new
+	// pair of tokens is issued. A refresh token used twice was stolen or
+	// leaked, every token rotated from the same login is then revoked.
+	Refresh(ctx context.Context, request *model.RefreshRequest) (*model.AuthTokens, error)
+	// Logout revokes the refresh token of request with every token rotated
+	// from the same login.
+	Logout(ctx context.Context, request *model.RefreshRequest) error
+	// Authenticate returns the identity of the user accessToken was issued
+	// to.
+	Authenticate(ctx context.Context, accessToken string) (*auth.Identity, error)
+	// Identify returns the identity of the user whose public id is
+	// publicID, for the requests a trusted proxy already authenticated.
+	Identify(ctx context.Context, publicID string) (*auth.Identity, error)
+}
+
+func (r *authRepo) Register(ctx context.Context, request *model.CredentialsRequest) (*model.AuthTokens, error) {
+	if _, err := request.Validate(); err != nil {
+		return nil, apperr.NewValidation(lib.UserInvalid, validator.ErrorsByField(err))
+	}
+
+	hash, err := auth.HashPassword(*request.Password)
+	if err != nil {
+		return nil, apperr.FromError(err)
+	}
+	user, err := r.userStorage.InsertUser(ctx, &model.User{
+		Email:        model.NormalizeEmail(*request.Email),
+		PasswordHash: hash,
+	})
+	if err == storage.ErrDuplicateEmail {
+		return nil, apperr.Wrap(err, apperr.Conflict, lib.UserEmailAlreadyExistError)
+	}
+	if err != nil {
+		return nil, apperr.FromError(err)
+	}
+	if _, err := r.adoptOnRegister(ctx, user); err != nil {
+		return nil, apperr.FromError(err)
+	}
+	return r.issue(ctx, user, "")
+}
+
+// adoptOnRegister gives the notes stored before accounts existed to the
+// user who just registered when it is the owner AdoptNotes was waiting
+// for, so they don't wait for a restart.
+func (r *authRepo) adoptOnRegister(ctx context.Context, user *model.User) (int, error) {
+	if r.adopter == nil {
+		return 0, nil
+	}
+	if r.notesOwner != "" {
+		if user.Email != r.notesOwner {
+			return 0, nil
+		}
+		return r.adopter.AdoptNotes(ctx, user.ID)
+	}
+	first, err := r.userStorage.FirstUser(ctx)
+	if err != nil || first == nil || first.ID != user.ID {
+		return 0, err
+	}
+	return r.adopter.AdoptNotes(ctx, user.ID)
+}
+
+func (r *authRepo) Login(ctx context.Context, request *model.CredentialsRequest) (*model.AuthTokens, error) {
+	if request.Email == nil || request.Password == nil {
+		return nil, apperr.New(apperr.Unauthorized, lib.AuthCredentialsInvalid)
+	}
+
+	user, err := r.userStorage.FindUserByEmail(ctx, model.NormalizeEmail(*request.Email))
+	if err != nil {
+		return nil, apperr.FromError(err)
+	}
+	hash := unknownUserHash()
+	if user != nil {
+		hash = user.PasswordHash
+	}
+	// the password is checked even without a user, so the time of the
+	// answer doesn't tell which emails have an account
+	err = auth.CheckPassword(hash, *request.Password)
+	if user == nil || err == auth.ErrPasswordMismatch {
+		return nil, apperr.New(apperr.Unauthorized, lib.AuthCredentialsInvalid)
+	}
+	if err != nil {
+		return nil, apperr.FromError(err)
+	}
+	return r.issue(ctx, user, "")
+}
+
+func (r *authRepo) Refresh(ctx context.Context, request *model.RefreshRequest) (*model.AuthTokens, error) {
+	token, user, err := r.refreshToken(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	revoked, err := r.userStorage.RevokeRefreshToken(ctx, token.ID, time.Now())
+	if err != nil {
+		return nil, apperr.FromError(err)
+	}
+	if !revoked {
+		if err := r.userStorage.RevokeRefreshTokenFamily(ctx, token.FamilyID, time.Now()); err != nil {
+			return nil, apperr.FromError(err)
+		}
+		return nil, apperr.New(apperr.Unauthorized, lib.AuthRefreshTokenInvalid)
+	}
+	return r.issue(ctx, user, token.FamilyID)
+}
+
+func (r *authRepo) Logout(ctx context.Context, request *model.RefreshRequest) error {
+	token, _, err := r.refreshToken(ctx, request)
+	if err != nil {
+		return err
+	}
+	return apperr.FromError(r.userStorage.RevokeRefreshTokenFamily(ctx, token.FamilyID, time.Now()))
+}
+
+func (r *authRepo) Authenticate(ctx context.Context, accessToken string) (*auth.Identity, error) {
+	claims, err := r.signer.Parse(accessToken, auth.AccessToken)
+	switch err {
+	case nil:
+	case auth.ErrTokenExpired:
+		return nil, apperr.Wrap(err, apperr.Unauthorized, lib.AuthTokenExpired)
+	default:
+		return nil, apperr.Wrap(err, apperr.Unauthorized, lib.AuthTokenInvalid)
+	}
+
+	return r.identity(ctx, claims.Subject, lib.AuthTokenInvalid)
+}
+
+func (r *authRepo) Identify(ctx context.Context, publicID string) (*auth.Identity, error) {
+	return r.identity(ctx, publicID, lib.AuthUserInvalid)
+}
+
+// identity returns the identity of the user publicID, code is the code of
+// the error when there is no such user.
+func (r *authRepo) identity(ctx context.Context, publicID string, code string) (*auth.Identity, error) {
+	user, err := r.userStorage.FindUserByPublicID(ctx, publicID)
+	if err != nil {
+		return nil, apperr.FromError(err)
+	}
+	if user == nil {
+		return nil, apperr.New(apperr.Unauthorized, code)
+	}
+	return &auth.Identity{UserID: user.ID, PublicID: user.PublicID}, nil
+}
+
+// refreshToken returns the stored token of the refresh token of request
+// and its user, the token may already be revoked.
+func (r *authRepo) refreshToken(ctx context.Context, request *model.RefreshRequest) (*model.RefreshToken, *model.User, error) {
+	if _, err := request.Validate(); err != nil {
+		return nil, nil, apperr.NewValidation(lib.RequestBodyInvalid, validator.ErrorsByField(err))
+	}
+	claims, err := r.signer.Parse(*request.RefreshToken, auth.RefreshToken)
+	if err != nil {
+		return nil, nil, apperr.Wrap(err, apperr.Unauthorized, lib.AuthRefreshTokenInvalid)
+	}
+
+	token, err := r.userStorage.GetRefreshToken(ctx, claims.ID)
+	if err != nil {
+		return nil, nil, apperr.FromError(err)
+	}
+	if token == nil {
+		return nil, nil, apperr.New(apperr.Unauthorized, lib.AuthRefreshTokenInvalid)
+	}
+	user, err := r.userStorage.GetUser(ctx, token.UserID)
+	if err != nil {
+		return nil, nil, apperr.FromError(err)
+	}
+	if user == nil || user.PublicID != claims.Subject {
+		return nil, nil, apperr.New(apperr.Unauthorized, lib.AuthRefreshTokenInvalid)
+	}
+	return token, user, nil
+}
+
+// issue signs a pair of tokens for user and stores the refresh token in
+// familyID, an empty familyID starts a new family.
+func (r *authRepo) issue(ctx context.Context, user *model.User, familyID string) (*model.AuthTokens, error) {
+	now := time.Now()
+	access := &auth.Claims{
+		Subject:   user.PublicID,
+		ID:        ulid.New().String(),
+		Type:      auth.AccessToken,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(r.accessTTL).Unix(),
+	}
+	refresh := &auth.Claims{
+		Subject:   user.PublicID,
+		ID:        ulid.New().String(),
+		Type:      auth.RefreshToken,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(r.refreshTTL).Unix(),
+	}
+	if familyID == "" {
+		familyID = refresh.ID
+	}
+
+	accessToken, err := r.signer.Sign(access)
+	if err != nil {
+		return nil, apperr.FromError(err)
+	}
+	refreshToken, err := r.signer.Sign(refresh)
+	if err != nil {
+		return nil, apperr.FromError(err)
+	}
+	err = r.userStorage.InsertRefreshToken(ctx, &model.RefreshToken{
+		ID:        refresh.ID,
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		CreatedAt: now,
+		ExpiresAt: refresh.Expiry(),
+	})
+	if err != nil {
+		return nil, apperr.FromError(err)
+	}
+
+	return &model.AuthTokens{
+		User:         user,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(r.accessTTL / time.Second),
+	}, nil
+}
+
+var (
+	unknownUserOnce sync.Once
+	unknownUser     string
+)
+
+// unknownUserHash is a hash no password matches, Login checks passwords
+// against it for emails without a user.
+func unknownUserHash() string {
+	unknownUserOnce.Do(func() {
+		unknownUser, _ = auth.HashPassword(ulid.New().String())
+	})
+	return unknownUser
+}
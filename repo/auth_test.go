@@ -0,0 +1,287 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"github.com/lyquocnam/go-note-learning/apperr"
+	"github.com/lyquocnam/go-note-learning/auth"
+	"github.com/lyquocnam/go-note-learning/lib"
+	"github.com/lyquocnam/go-note-learning/mocks"
+	"github.com/lyquocnam/go-note-learning/model"
+	"github.com/lyquocnam/go-note-learning/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestAuthRepo(t *testing.T, userStorage storage.UserStorage) *authRepo {
+	signer, err := auth.NewSigner([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+	return NewAuthRepo(userStorage, signer, 15*time.Minute, time.Hour)
+}
+
+func credentials(email, password string) *model.CredentialsRequest {
+	return &model.CredentialsRequest{Email: &email, Password: &password}
+}
+
+func refresh(token string) *model.RefreshRequest {
+	return &model.RefreshRequest{RefreshToken: &token}
+}
+
+func TestAuthRepo_Register(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestAuthRepo(t, storage.NewUserMemoryStorage())
+
+	tokens, err := repo.Register(ctx, credentials("Ann@Example.com", "correct horse"))
+	require.NoError(t, err)
+	assert.Equal(t, "ann@example.com", tokens.User.Email)
+	assert.Equal(t, "Bearer", tokens.TokenType)
+	assert.Equal(t, 900, tokens.ExpiresIn)
+
+	identity, err := repo.Authenticate(ctx, tokens.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, tokens.User.ID, identity.UserID)
+	assert.Equal(t, tokens.User.PublicID, identity.PublicID)
+
+	_, err = repo.Register(ctx, credentials("ann@example.COM", "another password"))
+	assert.Equal(t, apperr.Wrap(storage.ErrDuplicateEmail, apperr.Conflict, lib.UserEmailAlreadyExistError), err)
+
+	_, err = repo.Register(ctx, credentials("not an email", "short"))
+	assert.Equal(t, apperr.NewValidation(lib.UserInvalid, map[string]string{
+		"email":    lib.UserEmailInvalid,
+		"password": lib.UserPasswordLength,
+	}), err)
+	_, err = repo.Register(ctx, &model.CredentialsRequest{})
+	assert.Equal(t, apperr.NewValidation(lib.UserInvalid, map[string]string{
+		"email":    lib.UserEmailRequired,
+		"password": lib.UserPasswordRequired,
+	}), err)
+}
+
+func TestAuthRepo_Login(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestAuthRepo(t, storage.NewUserMemoryStorage())
+	registered, err := repo.Register(ctx, credentials("ann@example.com", "correct horse"))
+	require.NoError(t, err)
+
+	tokens, err := repo.Login(ctx, credentials("ANN@example.com", "correct horse"))
+	require.NoError(t, err)
+	assert.Equal(t, registered.User.PublicID, tokens.User.PublicID)
+	assert.NotEqual(t, registered.RefreshToken, tokens.RefreshToken)
+
+	invalid := apperr.New(apperr.Unauthorized, lib.AuthCredentialsInvalid)
+	for name, request := range map[string]*model.CredentialsRequest{
+		"wrong password": credentials("ann@example.com", "wrong horse"),
+		"unknown email":  credentials("bob@example.com", "correct horse"),
+		"no password":    {Email: credentials("ann@example.com", "").Email},
+		"nothing":        {},
+	} {
+		_, err := repo.Login(ctx, request)
+		assert.Equal(t, invalid, err, name)
+	}
+}
+
+func TestAuthRepo_Refresh(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestAuthRepo(t, storage.NewUserMemoryStorage())
+	login, err := repo.Register(ctx, credentials("ann@example.com", "correct horse"))
+	require.NoError(t, err)
+
+	rotated, err := repo.Refresh(ctx, refresh(login.RefreshToken))
+	require.NoError(t, err)
+	assert.Equal(t, login.User.PublicID, rotated.User.PublicID)
+	assert.NotEqual(t, login.RefreshToken, rotated.RefreshToken)
+	_, err = repo.Authenticate(ctx, rotated.AccessToken)
+	assert.NoError(t, err)
+
+	// another login is not affected by the reuse below
+	other, err := repo.Login(ctx, credentials("ann@example.com", "correct horse"))
+	require.NoError(t, err)
+
+	// the first token was used already, its whole family is revoked
+	invalid := apperr.New(apperr.Unauthorized, lib.AuthRefreshTokenInvalid)
+	_, err = repo.Refresh(ctx, refresh(login.RefreshToken))
+	assert.Equal(t, invalid, err)
+	_, err = repo.Refresh(ctx, refresh(rotated.RefreshToken))
+	assert.Equal(t, invalid, err, "rotated from the reused token")
+	_, err = repo.Refresh(ctx, refresh(other.RefreshToken))
+	assert.NoError(t, err)
+
+	_, err = repo.Refresh(ctx, refresh(rotated.AccessToken))
+	assert.Equal(t, apperr.Wrap(auth.ErrTokenInvalid, apperr.Unauthorized, lib.AuthRefreshTokenInvalid), err, "access token")
+	_, err = repo.Refresh(ctx, &model.RefreshRequest{})
+	assert.Equal(t, apperr.NewValidation(lib.RequestBodyInvalid, map[string]string{
+		"refresh_token": lib.AuthRefreshTokenRequired,
+	}), err)
+}
+
+func TestAuthRepo_Refresh_Unknown(t *testing.T) {
+	ctx := context.Background()
+	issuer := newTestAuthRepo(t, storage.NewUserMemoryStorage())
+	tokens, err := issuer.Register(ctx, credentials("ann@example.com", "correct horse"))
+	require.NoError(t, err)
+
+	// signed with the same key but never stored
+	repo := newTestAuthRepo(t, storage.NewUserMemoryStorage())
+	_, err = repo.Refresh(ctx, refresh(tokens.RefreshToken))
+	assert.Equal(t, apperr.New(apperr.Unauthorized, lib.AuthRefreshTokenInvalid), err)
+	_, err = repo.Authenticate(ctx, tokens.AccessToken)
+	assert.Equal(t, apperr.New(apperr.Unauthorized, lib.AuthTokenInvalid), err, "unknown user")
+}
+
+func TestAuthRepo_Logout(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestAuthRepo(t, storage.NewUserMemoryStorage())
+	login, err := repo.Register(ctx, credentials("ann@example.com", "correct horse"))
+	require.NoError(t, err)
+	rotated, err := repo.Refresh(ctx, refresh(login.RefreshToken))
+	require.NoError(t, err)
+
+	require.NoError(t, repo.Logout(ctx, refresh(login.RefreshToken)))
+	_, err = repo.Refresh(ctx, refresh(rotated.RefreshToken))
+	assert.Equal(t, apperr.New(apperr.Unauthorized, lib.AuthRefreshTokenInvalid), err)
+	assert.NoError(t, repo.Logout(ctx, refresh(rotated.RefreshToken)), "logging out twice")
+}
+
+func TestAuthRepo_Authenticate(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestAuthRepo(t, storage.NewUserMemoryStorage())
+	tokens, err := repo.Register(ctx, credentials("ann@example.com", "correct horse"))
+	require.NoError(t, err)
+
+	_, err = repo.Authenticate(ctx, tokens.RefreshToken)
+	assert.Equal(t, apperr.Wrap(auth.ErrTokenInvalid, apperr.Unauthorized, lib.AuthTokenInvalid), err, "refresh token")
+	_, err = repo.Authenticate(ctx, "garbage")
+	assert.Equal(t, apperr.Wrap(auth.ErrTokenInvalid, apperr.Unauthorized, lib.AuthTokenInvalid), err)
+
+	expiring := NewAuthRepo(storage.NewUserMemoryStorage(), repo.signer, -time.Second, time.Hour)
+	tokens, err = expiring.Register(ctx, credentials("ann@example.com", "correct horse"))
+	require.NoError(t, err)
+	_, err = expiring.Authenticate(ctx, tokens.AccessToken)
+	assert.Equal(t, apperr.Wrap(auth.ErrTokenExpired, apperr.Unauthorized, lib.AuthTokenExpired), err)
+}
+
+func TestAuthRepo_Identify(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestAuthRepo(t, storage.NewUserMemoryStorage())
+	tokens, err := repo.Register(ctx, credentials("ann@example.com", "correct horse"))
+	require.NoError(t, err)
+
+	identity, err := repo.Identify(ctx, tokens.User.PublicID)
+	require.NoError(t, err)
+	assert.Equal(t, &auth.Identity{UserID: tokens.User.ID, PublicID: tokens.User.PublicID}, identity)
+
+	_, err = repo.Identify(ctx, "01D7EX2VQ3AHDK6N2QXKWBS4JZ")
+	assert.Equal(t, apperr.New(apperr.Unauthorized, lib.AuthUserInvalid), err)
+}
+
+func TestAuthRepo_StorageFailure(t *testing.T) {
+	ctx := context.Background()
+	failure := errors.New("failure")
+	userStorage := &mocks.UserStorage{}
+	userStorage.On("FindUserByEmail", ctx, "ann@example.com").Return(nil, failure)
+	userStorage.On("InsertUser", ctx, mock.Anything).Return(nil, failure)
+	repo := newTestAuthRepo(t, userStorage)
+
+	_, err := repo.Login(ctx, credentials("ann@example.com", "correct horse"))
+	assert.Equal(t, apperr.Wrap(failure, apperr.Internal, ""), err)
+	_, err = repo.Register(ctx, credentials("ann@example.com", "correct horse"))
+	assert.Equal(t, apperr.Wrap(failure, apperr.Internal, ""), err)
+}
+
+func newAdopter(t *testing.T, titles ...string) (storage.NoteStorage, func()) {
+	dir, err := ioutil.TempDir("", "notes")
+	require.NoError(t, err)
+	db, err := bolt.Open(filepath.Join(dir, "notes.db"), 0600, nil)
+	require.NoError(t, err)
+	s, err := storage.NewNoteBoltStorage(db)
+	require.NoError(t, err)
+	for _, title := range titles {
+		// notes stored before accounts existed
+		_, err := s.Insert(storage.AllOwners(context.Background()), &model.Note{Title: title})
+		require.NoError(t, err)
+	}
+	return s, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func ownedTitles(t *testing.T, s storage.NoteStorage, userStorage storage.UserStorage, email string) []string {
+	user, err := userStorage.FindUserByEmail(context.Background(), email)
+	require.NoError(t, err)
+	require.NotNil(t, user)
+	notes, err := s.GetList(userContext(user.ID), storage.NoteFilter{}, storage.NoteListOptions{})
+	require.NoError(t, err)
+	titles := []string{}
+	for _, note := range notes {
+		titles = append(titles, note.Title)
+	}
+	return titles
+}
+
+func TestAuthRepo_AdoptNotes(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("register after upgrade, no restart", func(t *testing.T) {
+		s, closeFn := newAdopter(t, "Hello", "World")
+		defer closeFn()
+		userStorage := storage.NewUserMemoryStorage()
+		repo := newTestAuthRepo(t, userStorage)
+
+		owner, count, err := repo.AdoptNotes(ctx, s.(storage.NoteAdopter), "")
+		require.NoError(t, err)
+		assert.Nil(t, owner)
+		assert.Equal(t, 0, count)
+
+		_, err = repo.Register(ctx, credentials("ann@example.com", "correct horse"))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Hello", "World"}, ownedTitles(t, s, userStorage, "ann@example.com"))
+		_, err = repo.Register(ctx, credentials("bob@example.com", "correct horse"))
+		require.NoError(t, err)
+		assert.Equal(t, []string{}, ownedTitles(t, s, userStorage, "bob@example.com"))
+	})
+
+	t.Run("configured owner registers later", func(t *testing.T) {
+		s, closeFn := newAdopter(t, "Hello")
+		defer closeFn()
+		userStorage := storage.NewUserMemoryStorage()
+		repo := newTestAuthRepo(t, userStorage)
+
+		_, _, err := repo.AdoptNotes(ctx, s.(storage.NoteAdopter), " Boss@Example.com")
+		require.NoError(t, err)
+		_, err = repo.Register(ctx, credentials("ann@example.com", "correct horse"))
+		require.NoError(t, err)
+		assert.Equal(t, []string{}, ownedTitles(t, s, userStorage, "ann@example.com"))
+		_, err = repo.Register(ctx, credentials("boss@example.com", "correct horse"))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Hello"}, ownedTitles(t, s, userStorage, "boss@example.com"))
+	})
+
+	t.Run("existing users", func(t *testing.T) {
+		s, closeFn := newAdopter(t, "Hello")
+		defer closeFn()
+		userStorage := storage.NewUserMemoryStorage()
+		for _, email := range []string{"ann@example.com", "bob@example.com"} {
+			_, err := userStorage.InsertUser(ctx, &model.User{Email: email, PasswordHash: "hash"})
+			require.NoError(t, err)
+		}
+		repo := newTestAuthRepo(t, userStorage)
+
+		_, _, err := repo.AdoptNotes(ctx, s.(storage.NoteAdopter), "boss@example.com")
+		assert.EqualError(t, err, "repo: the owner of the notes boss@example.com is not a user")
+
+		owner, count, err := repo.AdoptNotes(ctx, s.(storage.NoteAdopter), "")
+		require.NoError(t, err)
+		require.NotNil(t, owner)
+		assert.Equal(t, "ann@example.com", owner.Email)
+		assert.Equal(t, 1, count)
+		assert.Equal(t, []string{"Hello"}, ownedTitles(t, s, userStorage, "ann@example.com"))
+	})
+}
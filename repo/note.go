@@ -1,111 +1,593 @@
 package repo
 
 import (
-	"errors"
+	"context"
+	validator "github.com/asaskevich/govalidator"
+	"github.com/lyquocnam/go-note-learning/acl"
+	"github.com/lyquocnam/go-note-learning/apperr"
+	"github.com/lyquocnam/go-note-learning/jsonpatch"
 	"github.com/lyquocnam/go-note-learning/lib"
 	"github.com/lyquocnam/go-note-learning/model"
 	"github.com/lyquocnam/go-note-learning/storage"
-	"net/http"
+	"github.com/lyquocnam/go-note-learning/ulid"
+	"time"
 )
 
 type noteRepo struct {
-	noteStorage storage.NoteStorage
+	noteStorage  storage.NoteStorage
+	noteSearcher storage.NoteSearcher
+	shareStorage storage.ShareStorage
 }
 
-func NewNoteRepo(noteStorage storage.NoteStorage) *noteRepo {
-	return &noteRepo{noteStorage: noteStorage}
+// NewNoteRepo searches notes with the storage when it implements
+// storage.NoteSearcher, with an in-memory index otherwise. shareStorage
+// tells who the notes are shared with.
+func NewNoteRepo(noteStorage storage.NoteStorage, shareStorage storage.ShareStorage) *noteRepo {
+	searcher, ok := noteStorage.(storage.NoteSearcher)
+	if !ok {
+		index := storage.NewNoteSearchIndex(noteStorage)
+		noteStorage, searcher = index, index
+	}
+	return &noteRepo{noteStorage: noteStorage, noteSearcher: searcher, shareStorage: shareStorage}
 }
 
+// NoteRepo returns apperr errors, a note that does not exist is an
+// apperr.NotFound error rather than a nil note. Notes are identified by
+// their public id.
+//
+// The calls act on the notes of the user of the context and on the notes
+// other users shared with it, as far as the role they gave allows. Search,
+// the trash, the batches and the tags only cover the notes of the user.
 type NoteRepo interface {
-	Get(id uint) (*model.Note, error)
-	GetList() ([]*model.Note, error)
-	ExistByTitle(title string) (bool, error)
-	Exist(id uint) (bool, error)
-	Insert(note *model.NoteRequest) (*model.Note, int, error)
-	Update(id uint, request *model.NoteRequest) (*model.Note, int, error)
-	Delete(id uint) (uint, int, error)
+	Get(ctx context.Context, id string) (*model.Note, error)
+	// GetList lists the notes shared with the user instead of its own when
+	// request.Shared is model.SharedWithMe.
+	GetList(ctx context.Context, request *model.NoteListRequest) (*model.NotePage, error)
+	Search(ctx context.Context, request *model.NoteSearchRequest) (*model.NoteSearchPage, error)
+	ExistByTitle(ctx context.Context, title string) (bool, error)
+	Exist(ctx context.Context, id string) (bool, error)
+	Insert(ctx context.Context, note *model.NoteRequest) (*model.Note, error)
+	// Update and Patch only apply when the current version of the note is
+	// one of versions, no versions skips the check.
+	Update(ctx context.Context, id string, request *model.NoteRequest, versions []uint) (*model.Note, error)
+	Patch(ctx context.Context, id string, patch jsonpatch.Patcher, versions []uint) (*model.Note, error)
+	// AddTags and RemoveTag check versions like Update does.
+	AddTags(ctx context.Context, id string, request *model.NoteTagsRequest, versions []uint) (*model.Note, error)
+	RemoveTag(ctx context.Context, id string, tag string, versions []uint) (*model.Note, error)
+	Delete(ctx context.Context, id string) (string, error)
+	GetTrash(ctx context.Context, request *model.NoteListRequest) (*model.NotePage, error)
+	Restore(ctx context.Context, id string) (*model.Note, error)
+	Purge(ctx context.Context, id string) (string, error)
+	PurgeTrash(ctx context.Context, before time.Time) (int, error)
+	// Batch returns one result per operation, the error is only set when
+	// the batch as a whole is invalid or could not run.
+	Batch(ctx context.Context, request *model.NoteBatchRequest) ([]*model.NoteBatchResult, error)
+	// GetShares, Share and Unshare manage who the note is shared with, only
+	// its owner may.
+	GetShares(ctx context.Context, id string) ([]*model.Share, error)
+	Share(ctx context.Context, id string, request *model.ShareRequest) (*model.Share, error)
+	Unshare(ctx context.Context, id string, userID string) (string, error)
+	// GetComments lists the comments of the note, the oldest first, to
+	// the users who can read it. Comment needs the commenter role at
+	// least.
+	GetComments(ctx context.Context, id string) ([]*model.Comment, error)
+	Comment(ctx context.Context, id string, request *model.CommentRequest) (*model.Comment, error)
 }
 
-func (r *noteRepo) Get(id uint) (*model.Note, error) {
-	return r.noteStorage.Get(id)
+func (r *noteRepo) Get(ctx context.Context, id string) (*model.Note, error) {
+	ctx, err := r.access(ctx, id, acl.Read)
+	if err != nil {
+		return nil, err
+	}
+	return getNote(ctx, r.noteStorage, id)
 }
 
-func (r *noteRepo) GetList() ([]*model.Note, error) {
-	return r.noteStorage.GetList()
+func getNote(ctx context.Context, s storage.NoteStorage, id string) (*model.Note, error) {
+	note, err := s.Find(ctx, storage.NoteFilter{}.WithPublicID(id))
+	if err != nil {
+		return nil, apperr.FromError(err)
+	}
+	if note == nil {
+		return nil, apperr.New(apperr.NotFound, lib.NoteNotExistError)
+	}
+	return note, nil
 }
 
-func (r *noteRepo) ExistByTitle(title string) (bool, error) {
-	count, err := r.noteStorage.Count("title = ?", title)
-	return count > 0, err
+const defaultNoteListLimit = 20
+
+func (r *noteRepo) GetList(ctx context.Context, request *model.NoteListRequest) (*model.NotePage, error) {
+	if request.Shared == model.SharedWithMe {
+		return r.sharedWithMe(ctx, request)
+	}
+	return r.list(ctx, storage.NoteFilter{}, request)
 }
 
-func (r *noteRepo) Exist(id uint) (bool, error) {
-	count, err := r.noteStorage.Count("id = ?", id)
-	return count > 0, err
+// GetTrash lists the deleted notes that were not purged yet.
+func (r *noteRepo) GetTrash(ctx context.Context, request *model.NoteListRequest) (*model.NotePage, error) {
+	return r.list(ctx, storage.NoteFilter{}.InTrash(), request)
 }
 
-func (r *noteRepo) Insert(request *model.NoteRequest) (*model.Note, int, error) {
-	if request.Title == nil {
-		return nil, http.StatusBadRequest, errors.New(lib.NoteTitleRequired)
+func (r *noteRepo) list(ctx context.Context, filter storage.NoteFilter, request *model.NoteListRequest) (*model.NotePage, error) {
+	if request.Title != "" {
+		filter = filter.WithTitleContains(request.Title)
+	}
+	if request.IsCompleted != nil {
+		filter = filter.WithCompleted(*request.IsCompleted)
+	}
+	if tags := model.NormalizeTags(request.Tags); len(tags) > 0 {
+		if request.TagMode == model.TagModeAny {
+			filter = filter.WithAnyTag(tags...)
+		} else {
+			filter = filter.WithTags(tags...)
+		}
+	}
+
+	opts := storage.NoteListOptions{Limit: request.Limit, Offset: request.Offset}
+	if opts.Limit <= 0 {
+		opts.Limit = defaultNoteListLimit
+	}
+	opts.SortBy, opts.Desc = request.SortField()
+	if opts.SortBy == "" {
+		opts.SortBy = storage.NoteSortID
+	}
+	if request.Cursor != "" {
+		if request.Offset > 0 {
+			return nil, apperr.New(apperr.BadRequest, lib.NoteListCursorWithOffset)
+		}
+		cursor, err := storage.DecodeNoteCursor(request.Cursor)
+		// a cursor only makes sense for the ordering it was issued for
+		if err != nil || cursor.SortBy != opts.SortBy || cursor.Desc != opts.Desc {
+			return nil, apperr.New(apperr.BadRequest, lib.NoteListCursorInvalid)
+		}
+		opts.After = cursor
 	}
 
-	note, err := r.noteStorage.GetByTitle(*request.Title)
+	total, err := r.noteStorage.Count(ctx, filter)
 	if err != nil {
-		return nil, 500, err
+		return nil, apperr.FromError(err)
 	}
-	if note != nil {
-		return nil, http.StatusConflict, errors.New(lib.NoteTitleAlreadyExistError)
+
+	// one extra note tells whether there is a next page
+	limit := opts.Limit
+	opts.Limit++
+	notes, err := r.noteStorage.GetList(ctx, filter, opts)
+	if err != nil {
+		return nil, apperr.FromError(err)
 	}
 
-	note = &model.Note{
-		Title: *request.Title,
+	page := &model.NotePage{Items: notes, Total: total}
+	if len(notes) > limit {
+		page.Items = notes[:limit]
+		opts.Limit = limit
+		page.NextCursor = storage.NewNoteCursor(page.Items[limit-1], opts).Encode()
 	}
-	if request.IsCompleted != nil {
-		note.IsCompleted = *request.IsCompleted
+	if page.Items == nil {
+		page.Items = []*model.Note{}
 	}
+	return page, nil
+}
 
-	result, err := r.noteStorage.Insert(note)
+// Search finds the notes outside of the trash containing every word of the
+// query, accents aside, the best matches first.
+func (r *noteRepo) Search(ctx context.Context, request *model.NoteSearchRequest) (*model.NoteSearchPage, error) {
+	opts := storage.NoteSearchOptions{Limit: request.Limit, Offset: request.Offset}
+	if opts.Limit <= 0 {
+		opts.Limit = defaultNoteListLimit
+	}
+	results, total, err := r.noteSearcher.Search(ctx, request.Q, opts)
 	if err != nil {
-		return nil, 500, err
+		return nil, apperr.FromError(err)
 	}
-	return result, 200, nil
+	if results == nil {
+		results = []*model.NoteSearchResult{}
+	}
+	return &model.NoteSearchPage{Items: results, Total: total}, nil
 }
 
-func (r *noteRepo) Update(id uint, request *model.NoteRequest) (*model.Note, int, error) {
-	note, err := r.noteStorage.Get(id)
-	// exist, err := u.noteRepo.Exist(id)
+func (r *noteRepo) ExistByTitle(ctx context.Context, title string) (bool, error) {
+	count, err := r.noteStorage.Count(ctx, storage.NoteFilter{}.WithTitle(title))
+	return count > 0, apperr.FromError(err)
+}
+
+func (r *noteRepo) Exist(ctx context.Context, id string) (bool, error) {
+	ctx, err := r.access(ctx, id, acl.Read)
 	if err != nil {
-		return nil, 500, err
+		return false, err
 	}
-	if note == nil {
-		return nil, http.StatusNotFound, errors.New(lib.NoteNotExistError)
+	count, err := r.noteStorage.Count(ctx, storage.NoteFilter{}.WithPublicID(id))
+	return count > 0, apperr.FromError(err)
+}
+
+func (r *noteRepo) Insert(ctx context.Context, request *model.NoteRequest) (*model.Note, error) {
+	if _, err := request.Validate(); err != nil {
+		return nil, validationError(err)
 	}
 
-	if request.Title != nil {
-		note.Title = *request.Title
+	note := &model.Note{}
+	replaceNote(note, request)
+
+	// the storage enforces unique titles, checking first would race with
+	// concurrent inserts
+	result, err := r.noteStorage.Insert(ctx, note)
+	if err != nil {
+		return nil, storageError(err)
 	}
-	if request.IsCompleted != nil {
-		note.IsCompleted = *request.IsCompleted
+	return result, nil
+}
+
+// Update replaces the note, the fields the request leaves out are reset.
+func (r *noteRepo) Update(ctx context.Context, id string, request *model.NoteRequest, versions []uint) (*model.Note, error) {
+	if _, err := request.Validate(); err != nil {
+		return nil, validationError(err)
+	}
+	return r.update(ctx, id, versions, func(note *model.Note) error {
+		replaceNote(note, request)
+		return nil
+	})
+}
+
+func replaceNote(note *model.Note, request *model.NoteRequest) {
+	note.Title = *request.Title
+	note.IsCompleted = request.IsCompleted != nil && *request.IsCompleted
+	note.Content = ""
+	if request.Content != nil {
+		note.Content = *request.Content
+	}
+	note.Tags = model.NormalizeTags(request.Tags)
+}
+
+// Patch applies a JSON Merge Patch or a JSON Patch to the JSON of the note.
+func (r *noteRepo) Patch(ctx context.Context, id string, patch jsonpatch.Patcher, versions []uint) (*model.Note, error) {
+	return r.update(ctx, id, versions, func(note *model.Note) error {
+		return applyNotePatch(note, patch)
+	})
+}
+
+// AddTags adds the tags of request the note does not have yet.
+func (r *noteRepo) AddTags(ctx context.Context, id string, request *model.NoteTagsRequest, versions []uint) (*model.Note, error) {
+	if _, err := request.Validate(); err != nil {
+		return nil, validationError(err)
+	}
+	return r.update(ctx, id, versions, func(note *model.Note) error {
+		note.Tags = model.NormalizeTags(append(note.Tags, request.Tags...))
+		return nil
+	})
+}
+
+func (r *noteRepo) RemoveTag(ctx context.Context, id string, tag string, versions []uint) (*model.Note, error) {
+	tag = model.NormalizeTag(tag)
+	return r.update(ctx, id, versions, func(note *model.Note) error {
+		tags := make([]string, 0, len(note.Tags))
+		for _, t := range note.Tags {
+			if t != tag {
+				tags = append(tags, t)
+			}
+		}
+		if len(tags) == len(note.Tags) {
+			return apperr.New(apperr.NotFound, lib.TagNotExistError)
+		}
+		note.Tags = model.NormalizeTags(tags)
+		return nil
+	})
+}
+
+func (r *noteRepo) update(ctx context.Context, id string, versions []uint, apply func(note *model.Note) error) (*model.Note, error) {
+	ctx, err := r.access(ctx, id, acl.Edit)
+	if err != nil {
+		return nil, err
 	}
+	var result *model.Note
+	err = r.withTx(ctx, func(tx storage.NoteStorage) error {
+		note, err := getNote(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if len(versions) > 0 && !hasVersion(versions, note.Version) {
+			return apperr.New(apperr.PreconditionFailed, lib.NoteVersionMismatchError)
+		}
+
+		if err := apply(note); err != nil {
+			return err
+		}
+		if _, err := note.Validate(); err != nil {
+			return validationError(err)
+		}
 
-	result, err := r.noteStorage.Update(id, note)
+		result, err = tx.Update(ctx, note.ID, note)
+		if err == storage.ErrVersionConflict && len(versions) > 0 {
+			// a storage without isolation let the note change since Get
+			return apperr.Wrap(err, apperr.PreconditionFailed, lib.NoteVersionMismatchError)
+		}
+		if err != nil {
+			return storageError(err)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, 500, err
+		return nil, err
+	}
+	return result, nil
+}
+
+func hasVersion(versions []uint, version uint) bool {
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
 	}
-	return result, 200, nil
+	return false
 }
 
-func (r *noteRepo) Delete(id uint) (uint, int, error) {
-	note, err := r.noteStorage.Get(id)
+func (r *noteRepo) Delete(ctx context.Context, id string) (string, error) {
+	ctx, err := r.access(ctx, id, acl.Trash)
 	if err != nil {
-		return 0, http.StatusInternalServerError, err
+		return "", err
 	}
-	if note == nil {
-		return 0, http.StatusNotFound, errors.New(lib.NoteNotExistError)
+	err = r.withTx(ctx, func(tx storage.NoteStorage) error {
+		note, err := getNote(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		return apperr.FromError(tx.Delete(ctx, note))
+	})
+	if err != nil {
+		return "", err
 	}
-	err = r.noteStorage.Delete(note)
+	return id, nil
+}
+
+func (r *noteRepo) Restore(ctx context.Context, id string) (*model.Note, error) {
+	ctx, err := r.access(ctx, id, acl.Trash)
+	if err != nil {
+		return nil, err
+	}
+	var note *model.Note
+	err = r.withTx(ctx, func(tx storage.NoteStorage) error {
+		var err error
+		note, err = getTrashed(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		// fails when the title was reused while the note was in the trash
+		if err := tx.Restore(ctx, note); err != nil {
+			return storageError(err)
+		}
+		return nil
+	})
 	if err != nil {
-		return 0, http.StatusInternalServerError, err
+		return nil, err
+	}
+	return note, nil
+}
+
+// Purge deletes a note of the trash, its shares and its comments for good.
+func (r *noteRepo) Purge(ctx context.Context, id string) (string, error) {
+	ctx, err := r.access(ctx, id, acl.Trash)
+	if err != nil {
+		return "", err
+	}
+	err = r.withTx(ctx, func(tx storage.NoteStorage) error {
+		note, err := getTrashed(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		return apperr.FromError(tx.Purge(ctx, note))
+	})
+	if err != nil {
+		return "", err
+	}
+	return id, r.deleteSharing(ctx, id)
+}
+
+// PurgeTrash deletes for good the notes moved to the trash before before
+// with their shares and comments, it returns how many notes were purged.
+// Either all of them are or none is.
+func (r *noteRepo) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	var purged []string
+	err := r.withTx(ctx, func(tx storage.NoteStorage) error {
+		notes, err := tx.GetList(ctx, storage.NoteFilter{}.WithDeletedBefore(before), storage.NoteListOptions{})
+		if err != nil {
+			return apperr.FromError(err)
+		}
+		// the unit of work may be retried
+		purged = purged[:0]
+		for _, note := range notes {
+			if err := tx.Purge(ctx, note); err != nil {
+				return apperr.FromError(err)
+			}
+			purged = append(purged, note.PublicID)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range purged {
+		if err := r.deleteSharing(ctx, id); err != nil {
+			return len(purged), err
+		}
+	}
+	return len(purged), nil
+}
+
+// deleteSharing deletes the shares and the comments of a purged note.
+func (r *noteRepo) deleteSharing(ctx context.Context, id string) error {
+	if err := r.shareStorage.DeleteShares(ctx, id); err != nil {
+		return apperr.FromError(err)
+	}
+	return apperr.FromError(r.shareStorage.DeleteComments(ctx, id))
+}
+
+const maxNoteBatchSize = 100
+
+// Batch prepares every operation like Insert, Update and Delete do, then
+// runs them in a single NoteStorage.Batch. An operation that can't be
+// prepared, like the update of a missing note, never reaches the storage
+// and aborts the others of an atomic batch. An atomic batch is prepared
+// and run in one unit of work. Operations see the notes as they were
+// before the batch, so a note may only appear once.
+func (r *noteRepo) Batch(ctx context.Context, request *model.NoteBatchRequest) ([]*model.NoteBatchResult, error) {
+	if len(request.Operations) == 0 || len(request.Operations) > maxNoteBatchSize {
+		return nil, apperr.NewValidation(lib.NoteBatchInvalid, map[string]string{"operations": lib.NoteBatchSize})
+	}
+	if !request.Atomic {
+		results, _, err := r.batch(ctx, r.noteStorage, request)
+		return results, err
+	}
+
+	// the error of the failed operation rolls the unit of work back, the
+	// storage retries it when it is a serialization failure
+	var results []*model.NoteBatchResult
+	var failure error
+	err := r.noteStorage.WithTx(ctx, func(tx storage.NoteStorage) error {
+		var err error
+		results, failure, err = r.batch(ctx, tx, request)
+		if err != nil {
+			return err
+		}
+		return failure
+	})
+	if err != nil && failure == nil {
+		return nil, apperr.FromError(err)
+	}
+	return results, nil
+}
+
+// batch runs the operations of request on s, failure is the error of the
+// first operation that failed.
+func (r *noteRepo) batch(ctx context.Context, s storage.NoteStorage, request *model.NoteBatchRequest) (results []*model.NoteBatchResult, failure error, err error) {
+	results = make([]*model.NoteBatchResult, len(request.Operations))
+	var ops []storage.NoteOp
+	var indexes []int // the index in results of every op
+	seen := make(map[string]bool)
+	for i, operation := range request.Operations {
+		results[i] = &model.NoteBatchResult{}
+		op, err := batchOp(ctx, s, operation, seen)
+		if err != nil {
+			results[i].Err = err
+			if failure == nil {
+				failure = err
+			}
+			continue
+		}
+		ops = append(ops, op)
+		indexes = append(indexes, i)
+	}
+	if failure != nil && request.Atomic {
+		for _, i := range indexes {
+			results[i].Err = batchError(storage.ErrBatchAborted, request.Operations[i])
+		}
+		return results, failure, nil
+	}
+	if len(ops) == 0 {
+		return results, failure, nil
+	}
+
+	opResults, err := s.Batch(ctx, ops, request.Atomic)
+	if err != nil {
+		return nil, nil, apperr.FromError(err)
+	}
+	for j, result := range opResults {
+		i := indexes[j]
+		if result.Err != nil {
+			if failure == nil && result.Err != storage.ErrBatchAborted {
+				failure = result.Err
+			}
+			results[i].Err = batchError(result.Err, request.Operations[i])
+			continue
+		}
+		results[i].Note = result.Note
+	}
+	return results, failure, nil
+}
+
+func batchOp(ctx context.Context, s storage.NoteStorage, operation *model.NoteBatchOperation, seen map[string]bool) (storage.NoteOp, error) {
+	if operation == nil {
+		return storage.NoteOp{}, apperr.New(apperr.BadRequest, lib.NoteBatchOpInvalid)
+	}
+	request := operation.Note
+	if request == nil {
+		request = &model.NoteRequest{}
+	}
+
+	switch operation.Op {
+	case model.NoteBatchCreate:
+		if _, err := request.Validate(); err != nil {
+			return storage.NoteOp{}, validationError(err)
+		}
+		note := &model.Note{}
+		replaceNote(note, request)
+		return storage.NoteOp{Kind: storage.NoteOpInsert, Note: note}, nil
+	case model.NoteBatchUpdate, model.NoteBatchDelete:
+	default:
+		return storage.NoteOp{}, apperr.New(apperr.BadRequest, lib.NoteBatchOpInvalid)
+	}
+
+	// the id comes from the body, the handler only checks the ids of paths
+	id, err := ulid.Parse(operation.ID)
+	if err != nil {
+		return storage.NoteOp{}, apperr.Wrap(err, apperr.BadRequest, lib.NoteIDInvalid)
+	}
+	if seen[id.String()] {
+		return storage.NoteOp{}, apperr.New(apperr.BadRequest, lib.NoteBatchDuplicateNote)
+	}
+	seen[id.String()] = true
+	note, err := getNote(ctx, s, id.String())
+	if err != nil {
+		return storage.NoteOp{}, err
+	}
+	if operation.Version != 0 && note.Version != operation.Version {
+		return storage.NoteOp{}, apperr.New(apperr.PreconditionFailed, lib.NoteVersionMismatchError)
+	}
+
+	if operation.Op == model.NoteBatchDelete {
+		return storage.NoteOp{Kind: storage.NoteOpDelete, ID: note.ID, Note: note}, nil
+	}
+	if _, err := request.Validate(); err != nil {
+		return storage.NoteOp{}, validationError(err)
+	}
+	replaceNote(note, request)
+	return storage.NoteOp{Kind: storage.NoteOpUpdate, ID: note.ID, Note: note}, nil
+}
+
+func batchError(err error, operation *model.NoteBatchOperation) error {
+	switch {
+	case err == storage.ErrBatchAborted:
+		return apperr.Wrap(err, apperr.Conflict, lib.NoteBatchAborted)
+	case err == storage.ErrVersionConflict && operation.Version != 0:
+		return apperr.Wrap(err, apperr.PreconditionFailed, lib.NoteVersionMismatchError)
+	}
+	return storageError(err)
+}
+
+func (r *noteRepo) withTx(ctx context.Context, fn func(tx storage.NoteStorage) error) error {
+	return apperr.FromError(r.noteStorage.WithTx(ctx, fn))
+}
+
+func getTrashed(ctx context.Context, s storage.NoteStorage, id string) (*model.Note, error) {
+	note, err := s.Find(ctx, storage.NoteFilter{}.WithPublicID(id).InTrash())
+	if err != nil {
+		return nil, apperr.FromError(err)
+	}
+	if note == nil {
+		return nil, apperr.New(apperr.NotFound, lib.NoteNotInTrashError)
+	}
+	return note, nil
+}
+
+// validationError lists every field govalidator rejected with its message.
+func validationError(err error) error {
+	return apperr.NewValidation(lib.NoteInvalid, validator.ErrorsByField(err))
+}
+
+// storageError translates the errors of the storage writes.
+func storageError(err error) error {
+	switch err {
+	case storage.ErrDuplicateTitle:
+		return apperr.Wrap(err, apperr.Conflict, lib.NoteTitleAlreadyExistError)
+	case storage.ErrVersionConflict:
+		return apperr.Wrap(err, apperr.Conflict, lib.NoteModifiedConcurrentlyError)
 	}
-	return note.ID, 200, nil
+	return apperr.FromError(err)
 }
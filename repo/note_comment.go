@@ -0,0 +1,61 @@
+package repo
+
+import (
+	"context"
+	validator "github.com/asaskevich/govalidator"
+	"github.com/lyquocnam/go-note-learning/acl"
+	"github.com/lyquocnam/go-note-learning/apperr"
+	"github.com/lyquocnam/go-note-learning/auth"
+	"github.com/lyquocnam/go-note-learning/lib"
+	"github.com/lyquocnam/go-note-learning/model"
+	"github.com/lyquocnam/go-note-learning/storage"
+)
+
+func (r *noteRepo) GetComments(ctx context.Context, id string) ([]*model.Comment, error) {
+	if _, err := r.commentedNote(ctx, id, acl.Read); err != nil {
+		return nil, err
+	}
+	comments, err := r.shareStorage.GetComments(ctx, id)
+	if err != nil {
+		return nil, apperr.FromError(err)
+	}
+	if comments == nil {
+		comments = []*model.Comment{}
+	}
+	return comments, nil
+}
+
+// Comment leaves a comment on the note, the owner and the users it is
+// shared with as commenter or editor may.
+func (r *noteRepo) Comment(ctx context.Context, id string, request *model.CommentRequest) (*model.Comment, error) {
+	if _, err := request.Validate(); err != nil {
+		return nil, apperr.NewValidation(lib.CommentInvalid, validator.ErrorsByField(err))
+	}
+	identity, ok := auth.IdentityFrom(ctx)
+	if !ok {
+		return nil, apperr.FromError(storage.ErrNoOwner)
+	}
+	note, err := r.commentedNote(ctx, id, acl.Comment)
+	if err != nil {
+		return nil, err
+	}
+
+	comment, err := r.shareStorage.InsertComment(ctx, &model.Comment{
+		NoteID:         note.PublicID,
+		AuthorID:       identity.UserID,
+		AuthorPublicID: identity.PublicID,
+		Body:           *request.Body,
+	})
+	if err != nil {
+		return nil, apperr.FromError(err)
+	}
+	return comment, nil
+}
+
+func (r *noteRepo) commentedNote(ctx context.Context, id string, action acl.Action) (*model.Note, error) {
+	ctx, err := r.access(ctx, id, action)
+	if err != nil {
+		return nil, err
+	}
+	return getNote(ctx, r.noteStorage, id)
+}
@@ -0,0 +1,97 @@
+package repo
+
+import (
+	"context"
+	"github.com/lyquocnam/go-note-learning/acl"
+	"github.com/lyquocnam/go-note-learning/apperr"
+	"github.com/lyquocnam/go-note-learning/auth"
+	"github.com/lyquocnam/go-note-learning/lib"
+	"github.com/lyquocnam/go-note-learning/model"
+	"github.com/lyquocnam/go-note-learning/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func commentRequest(body string) *model.CommentRequest {
+	return &model.CommentRequest{Body: &body}
+}
+
+func TestNoteRepo_Comment(t *testing.T) {
+	users := storage.NewUserMemoryStorage()
+	var accounts []*model.User
+	var contexts []context.Context
+	for _, email := range []string{"ann@example.com", "bob@example.com", "cat@example.com", "dan@example.com", "eve@example.com"} {
+		user, err := users.InsertUser(context.Background(), &model.User{Email: email})
+		require.NoError(t, err)
+		accounts = append(accounts, user)
+		contexts = append(contexts, auth.WithIdentity(context.Background(), &auth.Identity{UserID: user.ID, PublicID: user.PublicID}))
+	}
+	ann, viewer, commenter, editor, stranger := contexts[0], contexts[1], contexts[2], contexts[3], contexts[4]
+	repo := NewNoteRepo(storage.NewNoteMemoryStorage(), users)
+
+	title := "Hello"
+	note, err := repo.Insert(ann, &model.NoteRequest{Title: &title})
+	require.NoError(t, err)
+	for i, role := range []acl.Role{acl.Viewer, acl.Commenter, acl.Editor} {
+		_, err = repo.Share(ann, note.PublicID, shareRequest(accounts[i+1], role))
+		require.NoError(t, err)
+	}
+
+	comment, err := repo.Comment(commenter, note.PublicID, commentRequest("Looks good"))
+	require.NoError(t, err)
+	assert.Equal(t, note.PublicID, comment.NoteID)
+	assert.Equal(t, accounts[2].PublicID, comment.AuthorPublicID)
+	assert.Equal(t, "Looks good", comment.Body)
+	_, err = repo.Comment(editor, note.PublicID, commentRequest("Fixed"))
+	require.NoError(t, err)
+	_, err = repo.Comment(ann, note.PublicID, commentRequest("Thanks"))
+	require.NoError(t, err)
+
+	// the comment role is what a viewer lacks
+	_, err = repo.Comment(viewer, note.PublicID, commentRequest("Me too"))
+	assert.Equal(t, apperr.New(apperr.Forbidden, lib.NoteForbidden), err)
+	notFound := apperr.New(apperr.NotFound, lib.NoteNotExistError)
+	_, err = repo.Comment(stranger, note.PublicID, commentRequest("Hi"))
+	assert.Equal(t, notFound, err)
+	_, err = repo.GetComments(stranger, note.PublicID)
+	assert.Equal(t, notFound, err)
+
+	comments, err := repo.GetComments(viewer, note.PublicID)
+	require.NoError(t, err)
+	require.Len(t, comments, 3)
+	assert.Equal(t, "Looks good", comments[0].Body)
+	assert.Equal(t, "Fixed", comments[1].Body)
+	assert.Equal(t, accounts[0].PublicID, comments[2].AuthorPublicID)
+
+	cases := map[string]*model.CommentRequest{
+		"missing": {},
+		"empty":   commentRequest(""),
+		"long":    commentRequest(strings.Repeat("a", 2001)),
+	}
+	fields := map[string]map[string]string{
+		"missing": {"body": lib.CommentBodyRequired},
+		"empty":   {"body": lib.CommentBodyRequired},
+		"long":    {"body": lib.CommentBodyLength},
+	}
+	for name, request := range cases {
+		_, err := repo.Comment(ann, note.PublicID, request)
+		assert.Equal(t, apperr.NewValidation(lib.CommentInvalid, fields[name]), err, name)
+	}
+
+	// purging the note drops its comments
+	_, err = repo.Delete(ann, note.PublicID)
+	require.NoError(t, err)
+	_, err = repo.Purge(ann, note.PublicID)
+	require.NoError(t, err)
+	comments, err = users.GetComments(context.Background(), note.PublicID)
+	require.NoError(t, err)
+	assert.Empty(t, comments)
+
+	other, err := repo.Insert(ann, &model.NoteRequest{Title: &title})
+	require.NoError(t, err)
+	comments, err = repo.GetComments(ann, other.PublicID)
+	require.NoError(t, err)
+	assert.Equal(t, []*model.Comment{}, comments)
+}
@@ -0,0 +1,77 @@
+package repo
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/lyquocnam/go-note-learning/apperr"
+	"github.com/lyquocnam/go-note-learning/jsonpatch"
+	"github.com/lyquocnam/go-note-learning/lib"
+	"github.com/lyquocnam/go-note-learning/model"
+	"reflect"
+)
+
+// notePatchFields are the members of the JSON of a note a patch may
+// change, the others are read only.
+var notePatchFields = map[string]bool{
+	"title":        true,
+	"is_completed": true,
+	"content":      true,
+	"tags":         true,
+}
+
+// applyNotePatch applies patch to the JSON of note and copies the fields
+// it changed back into note. A member removed by the patch is reset.
+func applyNotePatch(note *model.Note, patch jsonpatch.Patcher) error {
+	doc, err := json.Marshal(note)
+	if err != nil {
+		return apperr.FromError(err)
+	}
+	patched, err := patch.Apply(doc)
+	if errors.Is(err, jsonpatch.ErrTestFailed) {
+		return apperr.Wrap(err, apperr.Conflict, lib.NotePatchTestFailed)
+	}
+	if err != nil {
+		return apperr.Wrap(err, apperr.Validation, lib.NotePatchInvalid)
+	}
+
+	var before, after map[string]interface{}
+	if err := json.Unmarshal(doc, &before); err != nil {
+		return apperr.FromError(err)
+	}
+	if err := json.Unmarshal(patched, &after); err != nil {
+		// the patch replaced the note with something else than an object
+		return apperr.Wrap(err, apperr.Validation, lib.NotePatchInvalid)
+	}
+
+	fields := make(map[string]string)
+	for name, value := range after {
+		if _, ok := before[name]; !ok {
+			fields[name] = lib.NoteFieldUnknown
+		} else if !notePatchFields[name] && !reflect.DeepEqual(before[name], value) {
+			fields[name] = lib.NoteFieldReadOnly
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok && !notePatchFields[name] {
+			fields[name] = lib.NoteFieldReadOnly
+		}
+	}
+	if len(fields) > 0 {
+		return apperr.NewValidation(lib.NoteInvalid, fields)
+	}
+
+	var result struct {
+		Title       string   `json:"title"`
+		IsCompleted bool     `json:"is_completed"`
+		Content     string   `json:"content"`
+		Tags        []string `json:"tags"`
+	}
+	if err := json.Unmarshal(patched, &result); err != nil {
+		return apperr.Wrap(err, apperr.Validation, lib.NotePatchInvalid)
+	}
+	note.Title = result.Title
+	note.IsCompleted = result.IsCompleted
+	note.Content = result.Content
+	note.Tags = model.NormalizeTags(result.Tags)
+	return nil
+}
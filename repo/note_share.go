@@ -0,0 +1,140 @@
+package repo
+
+import (
+	"context"
+	validator "github.com/asaskevich/govalidator"
+	"github.com/lyquocnam/go-note-learning/acl"
+	"github.com/lyquocnam/go-note-learning/apperr"
+	"github.com/lyquocnam/go-note-learning/auth"
+	"github.com/lyquocnam/go-note-learning/lib"
+	"github.com/lyquocnam/go-note-learning/model"
+	"github.com/lyquocnam/go-note-learning/storage"
+	"github.com/lyquocnam/go-note-learning/ulid"
+)
+
+// access returns the context to run the storage calls on the note id
+// with. A note shared with the user of ctx is reached as its owner once
+// the role of the user allows action, an apperr.Forbidden error otherwise.
+// The other notes are left to the storage, it only shows the notes of the
+// user.
+func (r *noteRepo) access(ctx context.Context, id string, action acl.Action) (context.Context, error) {
+	identity, ok := auth.IdentityFrom(ctx)
+	if !ok {
+		return ctx, nil
+	}
+	share, err := r.shareStorage.FindShare(ctx, id, identity.UserID)
+	if err != nil {
+		return nil, apperr.FromError(err)
+	}
+	if share == nil {
+		return ctx, nil
+	}
+	if !acl.RoleOf(identity.UserID, share.OwnerID, share.Role).Can(action) {
+		return nil, apperr.New(apperr.Forbidden, lib.NoteForbidden)
+	}
+	return storage.AsOwner(ctx, share.OwnerID), nil
+}
+
+// sharedWithMe lists the notes other users shared with the user of ctx.
+func (r *noteRepo) sharedWithMe(ctx context.Context, request *model.NoteListRequest) (*model.NotePage, error) {
+	identity, ok := auth.IdentityFrom(ctx)
+	if !ok {
+		return nil, apperr.FromError(storage.ErrNoOwner)
+	}
+	shares, err := r.shareStorage.GetSharesWith(ctx, identity.UserID)
+	if err != nil {
+		return nil, apperr.FromError(err)
+	}
+	ids := make([]string, len(shares))
+	for i, share := range shares {
+		ids[i] = share.NoteID
+	}
+	// the filter restricts the notes of every user to the shared ones
+	return r.list(storage.AllOwners(ctx), storage.NoteFilter{}.WithPublicIDs(ids...), request)
+}
+
+func (r *noteRepo) GetShares(ctx context.Context, id string) ([]*model.Share, error) {
+	if _, err := r.sharedNote(ctx, id); err != nil {
+		return nil, err
+	}
+	shares, err := r.shareStorage.GetShares(ctx, id)
+	if err != nil {
+		return nil, apperr.FromError(err)
+	}
+	if shares == nil {
+		shares = []*model.Share{}
+	}
+	return shares, nil
+}
+
+// Share shares the note with the user of request, or changes the role of
+// the user when the note is already shared with it.
+func (r *noteRepo) Share(ctx context.Context, id string, request *model.ShareRequest) (*model.Share, error) {
+	if _, err := request.Validate(); err != nil {
+		return nil, apperr.NewValidation(lib.ShareInvalid, validator.ErrorsByField(err))
+	}
+	note, err := r.sharedNote(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := ulid.Parse(*request.UserID)
+	if err != nil {
+		return nil, apperr.NewValidation(lib.ShareInvalid, map[string]string{"user_id": lib.ShareUserNotExist})
+	}
+	role, _ := acl.ParseRole(*request.Role)
+
+	share, err := r.shareStorage.PutShare(ctx, &model.Share{
+		NoteID:       note.PublicID,
+		OwnerID:      note.OwnerID,
+		UserPublicID: userID.String(),
+		Role:         role,
+	})
+	switch err {
+	case nil:
+		return share, nil
+	case storage.ErrUserNotExist:
+		return nil, apperr.NewValidation(lib.ShareInvalid, map[string]string{"user_id": lib.ShareUserNotExist})
+	case storage.ErrShareWithOwner:
+		return nil, apperr.NewValidation(lib.ShareInvalid, map[string]string{"user_id": lib.ShareUserOwner})
+	}
+	return nil, apperr.FromError(err)
+}
+
+// Unshare stops sharing the note with the user whose public id is userID.
+func (r *noteRepo) Unshare(ctx context.Context, id string, userID string) (string, error) {
+	if _, err := r.sharedNote(ctx, id); err != nil {
+		return "", err
+	}
+	shares, err := r.shareStorage.GetShares(ctx, id)
+	if err != nil {
+		return "", apperr.FromError(err)
+	}
+	notShared := apperr.New(apperr.NotFound, lib.ShareNotExistError)
+	user, err := ulid.Parse(userID)
+	if err != nil {
+		return "", notShared
+	}
+	for _, share := range shares {
+		if share.UserPublicID != user.String() {
+			continue
+		}
+		deleted, err := r.shareStorage.DeleteShare(ctx, id, share.UserID)
+		if err != nil {
+			return "", apperr.FromError(err)
+		}
+		if deleted {
+			return share.UserPublicID, nil
+		}
+	}
+	return "", notShared
+}
+
+// sharedNote returns the note id whose shares are managed by the user of
+// ctx.
+func (r *noteRepo) sharedNote(ctx context.Context, id string) (*model.Note, error) {
+	ctx, err := r.access(ctx, id, acl.Share)
+	if err != nil {
+		return nil, err
+	}
+	return getNote(ctx, r.noteStorage, id)
+}
@@ -0,0 +1,197 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"github.com/lyquocnam/go-note-learning/acl"
+	"github.com/lyquocnam/go-note-learning/apperr"
+	"github.com/lyquocnam/go-note-learning/jsonpatch"
+	"github.com/lyquocnam/go-note-learning/lib"
+	"github.com/lyquocnam/go-note-learning/mocks"
+	"github.com/lyquocnam/go-note-learning/model"
+	"github.com/lyquocnam/go-note-learning/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func shareRequest(user *model.User, role acl.Role) *model.ShareRequest {
+	userID, name := user.PublicID, string(role)
+	return &model.ShareRequest{UserID: &userID, Role: &name}
+}
+
+func TestNoteRepo_Share(t *testing.T) {
+	users := storage.NewUserMemoryStorage()
+	var accounts []*model.User
+	for _, email := range []string{"ann@example.com", "bob@example.com", "cat@example.com"} {
+		user, err := users.InsertUser(context.Background(), &model.User{Email: email})
+		require.NoError(t, err)
+		accounts = append(accounts, user)
+	}
+	ann, bob, cat := userContext(accounts[0].ID), userContext(accounts[1].ID), userContext(accounts[2].ID)
+	repo := NewNoteRepo(storage.NewNoteMemoryStorage(), users)
+
+	title, other := "Hello", "World"
+	note, err := repo.Insert(ann, &model.NoteRequest{Title: &title, Tags: []string{"work"}})
+	require.NoError(t, err)
+	_, err = repo.Insert(ann, &model.NoteRequest{Title: &other})
+	require.NoError(t, err)
+
+	share, err := repo.Share(ann, note.PublicID, shareRequest(accounts[1], acl.Viewer))
+	require.NoError(t, err)
+	assert.Equal(t, note.PublicID, share.NoteID)
+	assert.Equal(t, accounts[1].PublicID, share.UserPublicID)
+	assert.Equal(t, acl.Viewer, share.Role)
+	lower := strings.ToLower(accounts[2].PublicID)
+	editor := string(acl.Editor)
+	_, err = repo.Share(ann, note.PublicID, &model.ShareRequest{UserID: &lower, Role: &editor})
+	require.NoError(t, err, "user ids are read in either case")
+
+	shares, err := repo.GetShares(ann, note.PublicID)
+	require.NoError(t, err)
+	require.Len(t, shares, 2)
+	assert.Equal(t, accounts[1].PublicID, shares[0].UserPublicID)
+	assert.Equal(t, accounts[2].PublicID, shares[1].UserPublicID)
+
+	// a viewer reads
+	got, err := repo.Get(bob, note.PublicID)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", got.Title)
+	exists, err := repo.Exist(bob, note.PublicID)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	page, err := repo.GetList(bob, &model.NoteListRequest{Shared: model.SharedWithMe})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, note.PublicID, page.Items[0].PublicID)
+	page, err = repo.GetList(bob, &model.NoteListRequest{})
+	require.NoError(t, err)
+	assert.Empty(t, page.Items, "the shared notes are not the notes of the user")
+
+	// but does nothing else
+	forbidden := apperr.New(apperr.Forbidden, lib.NoteForbidden)
+	stolen := "Stolen"
+	_, err = repo.Update(bob, note.PublicID, &model.NoteRequest{Title: &stolen}, nil)
+	assert.Equal(t, forbidden, err)
+	_, err = repo.Patch(bob, note.PublicID, jsonpatch.MergePatch(`{"title":"Stolen"}`), nil)
+	assert.Equal(t, forbidden, err)
+	_, err = repo.AddTags(bob, note.PublicID, &model.NoteTagsRequest{Tags: []string{"stolen"}}, nil)
+	assert.Equal(t, forbidden, err)
+	_, err = repo.RemoveTag(bob, note.PublicID, "work", nil)
+	assert.Equal(t, forbidden, err)
+	_, err = repo.Delete(bob, note.PublicID)
+	assert.Equal(t, forbidden, err)
+	_, err = repo.GetShares(bob, note.PublicID)
+	assert.Equal(t, forbidden, err)
+	_, err = repo.Share(bob, note.PublicID, shareRequest(accounts[1], acl.Editor))
+	assert.Equal(t, forbidden, err)
+	_, err = repo.Unshare(bob, note.PublicID, accounts[1].PublicID)
+	assert.Equal(t, forbidden, err)
+
+	// an editor edits, the title stays unique among the notes of the owner
+	content := "shared"
+	updated, err := repo.Update(cat, note.PublicID, &model.NoteRequest{Title: &title, Content: &content}, []uint{note.Version})
+	require.NoError(t, err)
+	assert.Equal(t, "shared", updated.Content)
+	_, err = repo.Update(cat, note.PublicID, &model.NoteRequest{Title: &other}, nil)
+	assert.Equal(t, apperr.Wrap(storage.ErrDuplicateTitle, apperr.Conflict, lib.NoteTitleAlreadyExistError), err)
+	_, err = repo.Insert(cat, &model.NoteRequest{Title: &other})
+	assert.NoError(t, err)
+	_, err = repo.Delete(cat, note.PublicID)
+	assert.Equal(t, forbidden, err)
+	got, err = repo.Get(ann, note.PublicID)
+	require.NoError(t, err)
+	assert.Equal(t, "shared", got.Content)
+
+	// sharing again changes the role
+	_, err = repo.Share(ann, note.PublicID, shareRequest(accounts[1], acl.Editor))
+	require.NoError(t, err)
+	_, err = repo.AddTags(bob, note.PublicID, &model.NoteTagsRequest{Tags: []string{"bob"}}, nil)
+	assert.NoError(t, err)
+
+	// revoked
+	user, err := repo.Unshare(ann, note.PublicID, strings.ToLower(accounts[1].PublicID))
+	require.NoError(t, err)
+	assert.Equal(t, accounts[1].PublicID, user)
+	notFound := apperr.New(apperr.NotFound, lib.NoteNotExistError)
+	_, err = repo.Get(bob, note.PublicID)
+	assert.Equal(t, notFound, err)
+	_, err = repo.Unshare(ann, note.PublicID, accounts[1].PublicID)
+	assert.Equal(t, apperr.New(apperr.NotFound, lib.ShareNotExistError), err)
+	_, err = repo.Unshare(ann, note.PublicID, "abc")
+	assert.Equal(t, apperr.New(apperr.NotFound, lib.ShareNotExistError), err)
+	page, err = repo.GetList(bob, &model.NoteListRequest{Shared: model.SharedWithMe})
+	require.NoError(t, err)
+	assert.Empty(t, page.Items)
+
+	// the trash of the owner hides the note, purging it drops the shares
+	_, err = repo.Delete(ann, note.PublicID)
+	require.NoError(t, err)
+	_, err = repo.Get(cat, note.PublicID)
+	assert.Equal(t, notFound, err)
+	_, err = repo.Restore(cat, note.PublicID)
+	assert.Equal(t, forbidden, err)
+	_, err = repo.Purge(ann, note.PublicID)
+	require.NoError(t, err)
+	shares, err = users.GetSharesWith(context.Background(), accounts[2].ID)
+	require.NoError(t, err)
+	assert.Empty(t, shares)
+}
+
+func TestNoteRepo_ShareInvalid(t *testing.T) {
+	users := storage.NewUserMemoryStorage()
+	owner, err := users.InsertUser(context.Background(), &model.User{Email: "ann@example.com"})
+	require.NoError(t, err)
+	ann := userContext(owner.ID)
+	repo := NewNoteRepo(storage.NewNoteMemoryStorage(), users)
+	title := "Hello"
+	note, err := repo.Insert(ann, &model.NoteRequest{Title: &title})
+	require.NoError(t, err)
+
+	missing, garbage, owned, admin := publicID(42), "abc", owner.PublicID, "admin"
+	viewer := string(acl.Viewer)
+	cases := map[string]struct {
+		request *model.ShareRequest
+		fields  map[string]string
+	}{
+		"empty":        {&model.ShareRequest{}, map[string]string{"user_id": lib.ShareUserRequired, "role": lib.ShareRoleRequired}},
+		"role":         {&model.ShareRequest{UserID: &missing, Role: &admin}, map[string]string{"role": lib.ShareRoleInvalid}},
+		"missing user": {&model.ShareRequest{UserID: &missing, Role: &viewer}, map[string]string{"user_id": lib.ShareUserNotExist}},
+		"invalid user": {&model.ShareRequest{UserID: &garbage, Role: &viewer}, map[string]string{"user_id": lib.ShareUserNotExist}},
+		"owner":        {&model.ShareRequest{UserID: &owned, Role: &viewer}, map[string]string{"user_id": lib.ShareUserOwner}},
+	}
+	for name, c := range cases {
+		_, err := repo.Share(ann, note.PublicID, c.request)
+		assert.Equal(t, apperr.NewValidation(lib.ShareInvalid, c.fields), err, name)
+	}
+
+	_, err = repo.Share(ann, publicID(42), shareRequest(owner, acl.Viewer))
+	assert.Equal(t, apperr.New(apperr.NotFound, lib.NoteNotExistError), err)
+	_, err = repo.GetShares(ann, publicID(42))
+	assert.Equal(t, apperr.New(apperr.NotFound, lib.NoteNotExistError), err)
+	shares, err := repo.GetShares(ann, note.PublicID)
+	require.NoError(t, err)
+	assert.Equal(t, []*model.Share{}, shares)
+}
+
+func TestNoteRepo_ShareStorageFailure(t *testing.T) {
+	failure := errors.New("connection refused")
+	shareStorage := &mocks.ShareStorage{}
+	shareStorage.On("FindShare", mock.Anything, mock.Anything, mock.Anything).Return(nil, failure)
+	shareStorage.On("GetSharesWith", mock.Anything, mock.Anything).Return(nil, failure)
+	repo := NewNoteRepo(storage.NewNoteMemoryStorage(), shareStorage)
+	ctx := userContext(1)
+
+	_, err := repo.Get(ctx, publicID(1))
+	assert.Equal(t, apperr.FromError(failure), err)
+	title := "Hello"
+	_, err = repo.Update(ctx, publicID(1), &model.NoteRequest{Title: &title}, nil)
+	assert.Equal(t, apperr.FromError(failure), err)
+	_, err = repo.GetList(ctx, &model.NoteListRequest{Shared: model.SharedWithMe})
+	assert.Equal(t, apperr.FromError(failure), err)
+	_, err = repo.PurgeTrash(storage.AllOwners(context.Background()), time.Now())
+	assert.NoError(t, err, "there was nothing to purge")
+}
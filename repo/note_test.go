@@ -1,48 +1,94 @@
 package repo
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"github.com/lyquocnam/go-note-learning/apperr"
+	"github.com/lyquocnam/go-note-learning/auth"
+	"github.com/lyquocnam/go-note-learning/jsonpatch"
 	"github.com/lyquocnam/go-note-learning/lib"
 	"github.com/lyquocnam/go-note-learning/mocks"
 	"github.com/lyquocnam/go-note-learning/model"
+	"github.com/lyquocnam/go-note-learning/storage"
+	"github.com/lyquocnam/go-note-learning/ulid"
 	"github.com/stretchr/testify/assert"
-	"net/http"
+	"github.com/stretchr/testify/mock"
+	"strings"
 	"testing"
+	"time"
 )
 
+// mockStorage is a mocks.NoteStorage that also searches, NewNoteRepo
+// doesn't put it behind a search index.
+type mockStorage struct {
+	*mocks.NoteStorage
+}
+
+func (m *mockStorage) Search(ctx context.Context, query string, opts storage.NoteSearchOptions) ([]*model.NoteSearchResult, int, error) {
+	ret := m.Called(ctx, query, opts)
+	results, _ := ret.Get(0).([]*model.NoteSearchResult)
+	return results, ret.Int(1), ret.Error(2)
+}
+
+// publicID is the public id the tests give to the note id.
+func publicID(id uint) string {
+	return fmt.Sprintf("01D7EX2VQ3AHDK6N2QXKWB%04d", id)
+}
+
+func byPublicID(id uint) storage.NoteFilter {
+	return storage.NoteFilter{}.WithPublicID(publicID(id))
+}
+
+// newMockStorage returns a mock storage running its units of work on
+// itself.
+func newMockStorage() *mockStorage {
+	m := &mockStorage{NoteStorage: &mocks.NoteStorage{}}
+	m.On("WithTx", mock.Anything, mock.Anything).Return(func(ctx context.Context, fn func(storage.NoteStorage) error) error {
+		return fn(m)
+	})
+	return m
+}
+
 func TestNoteRepo_Get(t *testing.T) {
+	ctx := context.Background()
 	note := model.Note{
 		ID:          1,
 		Title:       "Hello",
 		IsCompleted: true,
 	}
+	canNotGetError := errors.New("can not get data")
 	cases := []struct {
-		name   string
-		expect *model.Note
-		err    error
+		name      string
+		getResult *model.Note
+		getErr    error
+		expect    *model.Note
+		err       error
 	}{
 		{
-			name:   "case get note ok",
-			expect: &note,
-			err:    nil,
+			name:      "case get note ok",
+			getResult: &note,
+			expect:    &note,
+			err:       nil,
 		},
 		{
 			name:   "case get note not found",
 			expect: nil,
-			err:    errors.New(lib.NoteNotExistError),
+			err:    apperr.New(apperr.NotFound, lib.NoteNotExistError),
 		},
 		{
 			name:   "case can not get",
+			getErr: canNotGetError,
 			expect: nil,
-			err:    errors.New("can not get data"),
+			err:    apperr.Wrap(canNotGetError, apperr.Internal, ""),
 		},
 	}
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			mockStorage := &mocks.NoteStorage{}
-			repo := NewNoteRepo(mockStorage)
-			mockStorage.On("Get", note.ID).Return(c.expect, c.err)
-			actual, err := repo.Get(note.ID)
+			mockStorage := newMockStorage()
+			repo := NewNoteRepo(mockStorage, storage.NewUserMemoryStorage())
+			mockStorage.On("Find", ctx, byPublicID(note.ID)).Return(c.getResult, c.getErr)
+			actual, err := repo.Get(ctx, publicID(note.ID))
 			assert.Equal(t, c.err, err)
 			assert.Equal(t, c.expect, actual)
 		})
@@ -51,6 +97,7 @@ func TestNoteRepo_Get(t *testing.T) {
 }
 
 func TestNoteRepo_GetList(t *testing.T) {
+	ctx := context.Background()
 	notes := make([]*model.Note, 0)
 	notes = append(notes, &model.Note{
 		ID:          1,
@@ -62,35 +109,99 @@ func TestNoteRepo_GetList(t *testing.T) {
 		Title:       "Hello 2",
 		IsCompleted: false,
 	})
+	completed := true
+	canNotGetError := errors.New("can not get data")
+	nextCursor := storage.NewNoteCursor(notes[0], storage.NoteListOptions{SortBy: storage.NoteSortTitle, Desc: true}).Encode()
 	cases := []struct {
-		name   string
-		expect []*model.Note
-		err    error
+		name    string
+		request model.NoteListRequest
+		filter  storage.NoteFilter
+		opts    storage.NoteListOptions
+		notes   []*model.Note
+		expect  *model.NotePage
+		listErr error
+		err     error
 	}{
 		{
-			name:   "case get note ok",
-			expect: notes,
-			err:    nil,
+			name:    "case get note ok",
+			request: model.NoteListRequest{Limit: 10},
+			filter:  storage.NoteFilter{},
+			opts:    storage.NoteListOptions{SortBy: storage.NoteSortID, Limit: 11},
+			notes:   notes,
+			expect:  &model.NotePage{Items: notes, Total: 2},
 		},
 		{
-			name:   "case can not get data",
-			expect: nil,
-			err:    errors.New("can not get data"),
+			name:    "case next page",
+			request: model.NoteListRequest{Limit: 1, Title: "hello", IsCompleted: &completed, Sort: "-title"},
+			filter:  storage.NoteFilter{}.WithTitleContains("hello").WithCompleted(true),
+			opts:    storage.NoteListOptions{SortBy: storage.NoteSortTitle, Desc: true, Limit: 2},
+			notes:   notes,
+			expect:  &model.NotePage{Items: notes[:1], Total: 2, NextCursor: nextCursor},
+		},
+		{
+			name:    "case every tag",
+			request: model.NoteListRequest{Limit: 10, Tags: []string{"Work", "home", "work"}},
+			filter:  storage.NoteFilter{}.WithTags("home", "work"),
+			opts:    storage.NoteListOptions{SortBy: storage.NoteSortID, Limit: 11},
+			notes:   notes,
+			expect:  &model.NotePage{Items: notes, Total: 2},
+		},
+		{
+			name:    "case any tag",
+			request: model.NoteListRequest{Limit: 10, Tags: []string{"work", "home"}, TagMode: model.TagModeAny},
+			filter:  storage.NoteFilter{}.WithAnyTag("home", "work"),
+			opts:    storage.NoteListOptions{SortBy: storage.NoteSortID, Limit: 11},
+			notes:   notes,
+			expect:  &model.NotePage{Items: notes, Total: 2},
+		},
+		{
+			name:    "case can not get data",
+			request: model.NoteListRequest{},
+			filter:  storage.NoteFilter{},
+			opts:    storage.NoteListOptions{SortBy: storage.NoteSortID, Limit: 21},
+			listErr: canNotGetError,
+			err:     apperr.Wrap(canNotGetError, apperr.Internal, ""),
 		},
 	}
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			mockStorage := &mocks.NoteStorage{}
-			repo := NewNoteRepo(mockStorage)
-			mockStorage.On("GetList").Return(c.expect, c.err)
-			actual, err := repo.GetList()
+			mockStorage := newMockStorage()
+			repo := NewNoteRepo(mockStorage, storage.NewUserMemoryStorage())
+			mockStorage.On("Count", ctx, c.filter).Return(2, nil)
+			mockStorage.On("GetList", ctx, c.filter, c.opts).Return(c.notes, c.listErr)
+			actual, err := repo.GetList(ctx, &c.request)
 			assert.Equal(t, c.err, err)
 			assert.Equal(t, c.expect, actual)
 		})
 	}
 }
 
+func TestNoteRepo_GetListCursor(t *testing.T) {
+	ctx := context.Background()
+	note := &model.Note{ID: 3, PublicID: publicID(3), Title: "Hello"}
+	cursor := storage.NewNoteCursor(note, storage.NoteListOptions{SortBy: storage.NoteSortTitle})
+
+	mockStorage := newMockStorage()
+	repo := NewNoteRepo(mockStorage, storage.NewUserMemoryStorage())
+	opts := storage.NoteListOptions{SortBy: storage.NoteSortTitle, Limit: 21, After: cursor}
+	mockStorage.On("Count", ctx, storage.NoteFilter{}).Return(1, nil)
+	mockStorage.On("GetList", ctx, storage.NoteFilter{}, opts).Return([]*model.Note{}, nil)
+	page, err := repo.GetList(ctx, &model.NoteListRequest{Sort: "title", Cursor: cursor.Encode()})
+	assert.NoError(t, err)
+	assert.Equal(t, &model.NotePage{Items: []*model.Note{}, Total: 1}, page)
+
+	for _, request := range []model.NoteListRequest{
+		{Sort: "-title", Cursor: cursor.Encode()},
+		{Sort: "title", Cursor: "not a cursor"},
+		{Sort: "title", Cursor: cursor.Encode(), Offset: 5},
+	} {
+		_, err := repo.GetList(ctx, &request)
+		assert.True(t, errors.Is(err, apperr.ErrBadRequest))
+	}
+}
+
 func TestNoteRepo_ExistByTitle(t *testing.T) {
+	ctx := context.Background()
 	note := model.Note{
 		ID:          1,
 		Title:       "Hello",
@@ -117,17 +228,18 @@ func TestNoteRepo_ExistByTitle(t *testing.T) {
 	}
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			mockStorage := &mocks.NoteStorage{}
-			repo := NewNoteRepo(mockStorage)
-			mockStorage.On("Count", "title = ?", note.Title).Return(c.count, c.err)
-			actual, err := repo.ExistByTitle(note.Title)
-			assert.Equal(t, c.err, err)
+			mockStorage := newMockStorage()
+			repo := NewNoteRepo(mockStorage, storage.NewUserMemoryStorage())
+			mockStorage.On("Count", ctx, storage.NoteFilter{}.WithTitle(note.Title)).Return(c.count, c.err)
+			actual, err := repo.ExistByTitle(ctx, note.Title)
+			assert.Equal(t, apperr.FromError(c.err), err)
 			assert.Equal(t, c.expect, actual)
 		})
 	}
 }
 
 func TestNoteRepo_Exist(t *testing.T) {
+	ctx := context.Background()
 	note := model.Note{
 		ID:          1,
 		Title:       "Hello",
@@ -154,17 +266,18 @@ func TestNoteRepo_Exist(t *testing.T) {
 	}
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			mockStorage := &mocks.NoteStorage{}
-			repo := NewNoteRepo(mockStorage)
-			mockStorage.On("Count", "id = ?", note.ID).Return(c.count, c.err)
-			actual, err := repo.Exist(note.ID)
-			assert.Equal(t, c.err, err)
+			mockStorage := newMockStorage()
+			repo := NewNoteRepo(mockStorage, storage.NewUserMemoryStorage())
+			mockStorage.On("Count", ctx, byPublicID(note.ID)).Return(c.count, c.err)
+			actual, err := repo.Exist(ctx, publicID(note.ID))
+			assert.Equal(t, apperr.FromError(c.err), err)
 			assert.Equal(t, c.expect, actual)
 		})
 	}
 }
 
 func TestNoteRepo_Insert(t *testing.T) {
+	ctx := context.Background()
 	isCompleted := false
 	note := model.Note{
 		ID:          1,
@@ -178,36 +291,31 @@ func TestNoteRepo_Insert(t *testing.T) {
 		Title:       &note.Title,
 		IsCompleted: &isCompleted,
 	}
+	canNotInsertError := errors.New("can not insert note")
 	cases := []struct {
-		name             string
-		expect           *model.Note
-		request          model.NoteRequest
-		beforeInsert     *model.Note
-		afterInsert      *model.Note
-		code             int
-		err              error
-		getByTitleResult *model.Note
-		getByTitleErr    error
+		name         string
+		expect       *model.Note
+		request      model.NoteRequest
+		beforeInsert *model.Note
+		afterInsert  *model.Note
+		err          error
+		insertErr    error
 	}{
 		{
-			name:             "case 1: get note ok",
-			expect:           &note,
-			beforeInsert:     &before,
-			afterInsert:      &note,
-			request:          request,
-			code:             200,
-			err:              nil,
-			getByTitleResult: nil,
-			getByTitleErr:    nil,
+			name:         "case 1: get note ok",
+			expect:       &note,
+			beforeInsert: &before,
+			afterInsert:  &note,
+			request:      request,
+			err:          nil,
 		},
 		{
-			name:             "case 2: title has already exist",
-			expect:           nil,
-			request:          request,
-			code:             http.StatusConflict,
-			err:              errors.New(lib.NoteTitleAlreadyExistError),
-			getByTitleResult: &note,
-			getByTitleErr:    nil,
+			name:         "case 2: title has already exist",
+			expect:       nil,
+			request:      request,
+			beforeInsert: &before,
+			err:          apperr.Wrap(storage.ErrDuplicateTitle, apperr.Conflict, lib.NoteTitleAlreadyExistError),
+			insertErr:    storage.ErrDuplicateTitle,
 		},
 		{
 			name:   "case 3: title is not valid",
@@ -215,40 +323,24 @@ func TestNoteRepo_Insert(t *testing.T) {
 			request: model.NoteRequest{
 				Title: nil,
 			},
-			code:             http.StatusBadRequest,
-			err:              errors.New(lib.NoteTitleRequired),
-			getByTitleResult: nil,
-			getByTitleErr:    nil,
+			err: apperr.NewValidation(lib.NoteInvalid, map[string]string{"title": lib.NoteTitleRequired}),
 		},
 		{
-			name:             "case 4: getByTitle has error",
-			expect:           nil,
-			request:          request,
-			code:             http.StatusInternalServerError,
-			err:              errors.New(""),
-			getByTitleResult: nil,
-			getByTitleErr:    errors.New(""),
-		},
-		{
-			name:             "case 5: Can not insert to db",
-			expect:           nil,
-			request:          request,
-			beforeInsert:     &before,
-			afterInsert:      &note,
-			code:             http.StatusInternalServerError,
-			err:              errors.New(""),
-			getByTitleResult: nil,
-			getByTitleErr:    nil,
+			name:         "case 4: Can not insert to db",
+			expect:       nil,
+			request:      request,
+			beforeInsert: &before,
+			afterInsert:  &note,
+			err:          apperr.Wrap(canNotInsertError, apperr.Internal, ""),
+			insertErr:    canNotInsertError,
 		},
 	}
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			mockStorage := &mocks.NoteStorage{}
-			repo := NewNoteRepo(mockStorage)
-			mockStorage.On("GetByTitle", note.Title).Return(c.getByTitleResult, c.getByTitleErr)
-			mockStorage.On("Insert", c.beforeInsert).Return(c.afterInsert, c.err)
-			actual, code, err := repo.Insert(&c.request)
-			assert.Equal(t, c.code, code)
+			mockStorage := newMockStorage()
+			repo := NewNoteRepo(mockStorage, storage.NewUserMemoryStorage())
+			mockStorage.On("Insert", ctx, c.beforeInsert).Return(c.afterInsert, c.insertErr)
+			actual, err := repo.Insert(ctx, &c.request)
 			assert.Equal(t, c.err, err)
 			assert.Equal(t, c.expect, actual)
 		})
@@ -256,6 +348,7 @@ func TestNoteRepo_Insert(t *testing.T) {
 }
 
 func TestNoteRepo_Update(t *testing.T) {
+	ctx := context.Background()
 	isCompleted := false
 	var noteId uint = 1
 	note := model.Note{
@@ -272,13 +365,14 @@ func TestNoteRepo_Update(t *testing.T) {
 		IsCompleted: &isCompleted,
 	}
 	canNotGetNoteError := errors.New("can not get note")
+	canNotUpdateNoteError := errors.New("can not update note")
 	cases := []struct {
 		name         string
 		expect       *model.Note
 		request      model.NoteRequest
 		beforeUpdate *model.Note
 		afterUpdate  *model.Note
-		code         int
+		updateErr    error
 		err          error
 		getResult    *model.Note
 		getErr       error
@@ -289,7 +383,6 @@ func TestNoteRepo_Update(t *testing.T) {
 			beforeUpdate: &before,
 			afterUpdate:  &note,
 			request:      request,
-			code:         200,
 			err:          nil,
 			getResult:    &note,
 			getErr:       nil,
@@ -300,8 +393,7 @@ func TestNoteRepo_Update(t *testing.T) {
 			beforeUpdate: &before,
 			afterUpdate:  &note,
 			request:      request,
-			code:         500,
-			err:          canNotGetNoteError,
+			err:          apperr.Wrap(canNotGetNoteError, apperr.Internal, ""),
 			getResult:    nil,
 			getErr:       canNotGetNoteError,
 		},
@@ -311,8 +403,7 @@ func TestNoteRepo_Update(t *testing.T) {
 			beforeUpdate: &before,
 			afterUpdate:  &note,
 			request:      request,
-			code:         404,
-			err:          errors.New(lib.NoteNotExistError),
+			err:          apperr.New(apperr.NotFound, lib.NoteNotExistError),
 			getResult:    nil,
 			getErr:       nil,
 		},
@@ -322,27 +413,270 @@ func TestNoteRepo_Update(t *testing.T) {
 			beforeUpdate: &before,
 			afterUpdate:  &note,
 			request:      request,
-			code:         500,
-			err:          errors.New("can not update note"),
+			updateErr:    canNotUpdateNoteError,
+			err:          apperr.Wrap(canNotUpdateNoteError, apperr.Internal, ""),
 			getResult:    &note,
 			getErr:       nil,
 		},
 	}
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			mockStorage := &mocks.NoteStorage{}
-			repo := NewNoteRepo(mockStorage)
-			mockStorage.On("Get", noteId).Return(c.getResult, c.getErr)
-			mockStorage.On("Update", noteId, c.beforeUpdate).Return(c.afterUpdate, c.err)
-			actual, code, err := repo.Update(noteId, &c.request)
-			assert.Equal(t, c.code, code)
+			mockStorage := newMockStorage()
+			repo := NewNoteRepo(mockStorage, storage.NewUserMemoryStorage())
+			mockStorage.On("Find", ctx, byPublicID(noteId)).Return(c.getResult, c.getErr)
+			mockStorage.On("Update", ctx, noteId, c.beforeUpdate).Return(c.afterUpdate, c.updateErr)
+			actual, err := repo.Update(ctx, publicID(noteId), &c.request, nil)
 			assert.Equal(t, c.err, err)
 			assert.Equal(t, c.expect, actual)
 		})
 	}
 }
 
+func TestNoteRepo_UpdateVersion(t *testing.T) {
+	ctx := context.Background()
+	title := "World"
+	request := model.NoteRequest{Title: &title}
+	cases := []struct {
+		name      string
+		versions  []uint
+		updateErr error
+		err       error
+	}{
+		{
+			name:     "case version matches",
+			versions: []uint{2},
+		},
+		{
+			name:     "case stale version",
+			versions: []uint{1},
+			err:      apperr.New(apperr.PreconditionFailed, lib.NoteVersionMismatchError),
+		},
+		{
+			name:     "case one of the versions matches",
+			versions: []uint{1, 2},
+		},
+		{
+			name:     "case none of the versions matches",
+			versions: []uint{1, 3},
+			err:      apperr.New(apperr.PreconditionFailed, lib.NoteVersionMismatchError),
+		},
+		{
+			name:      "case changed before the update with if match",
+			versions:  []uint{2},
+			updateErr: storage.ErrVersionConflict,
+			err:       apperr.Wrap(storage.ErrVersionConflict, apperr.PreconditionFailed, lib.NoteVersionMismatchError),
+		},
+		{
+			name:      "case changed before the update without if match",
+			updateErr: storage.ErrVersionConflict,
+			err:       apperr.Wrap(storage.ErrVersionConflict, apperr.Conflict, lib.NoteModifiedConcurrentlyError),
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			note := &model.Note{ID: 1, Title: "Hello", Version: 2}
+			mockStorage := newMockStorage()
+			repo := NewNoteRepo(mockStorage, storage.NewUserMemoryStorage())
+			mockStorage.On("Find", ctx, byPublicID(note.ID)).Return(note, nil)
+			mockStorage.On("Update", ctx, note.ID, note).Return(note, c.updateErr)
+			_, err := repo.Update(ctx, publicID(note.ID), &request, c.versions)
+			assert.Equal(t, c.err, err)
+		})
+	}
+}
+
+func TestNoteRepo_UpdateDuplicateTitle(t *testing.T) {
+	ctx := context.Background()
+	title := "World"
+	note := &model.Note{ID: 1, Title: "Hello", Version: 1}
+
+	mockStorage := newMockStorage()
+	repo := NewNoteRepo(mockStorage, storage.NewUserMemoryStorage())
+	mockStorage.On("Find", ctx, byPublicID(note.ID)).Return(note, nil)
+	mockStorage.On("Update", ctx, note.ID, note).Return(note, storage.ErrDuplicateTitle)
+	actual, err := repo.Update(ctx, publicID(note.ID), &model.NoteRequest{Title: &title}, nil)
+	assert.Nil(t, actual)
+	assert.True(t, errors.Is(err, apperr.ErrConflict))
+	assert.True(t, errors.Is(err, storage.ErrDuplicateTitle))
+	assert.Equal(t, lib.NoteTitleAlreadyExistError, err.(*apperr.Error).Code)
+}
+
+func TestNoteRepo_UpdateUnitOfWork(t *testing.T) {
+	ctx := context.Background()
+	title := "World"
+	note := &model.Note{ID: 1, Title: "Hello", Version: 1}
+
+	tx := &mocks.NoteStorage{}
+	tx.On("Find", ctx, byPublicID(note.ID)).Return(note, nil)
+	tx.On("Update", ctx, note.ID, note).Return(note, storage.ErrDuplicateTitle)
+	mockStorage := &mockStorage{NoteStorage: &mocks.NoteStorage{}}
+	var txErr error
+	mockStorage.On("WithTx", ctx, mock.Anything).Return(func(ctx context.Context, fn func(storage.NoteStorage) error) error {
+		txErr = fn(tx)
+		return txErr
+	})
+	repo := NewNoteRepo(mockStorage, storage.NewUserMemoryStorage())
+	actual, err := repo.Update(ctx, publicID(note.ID), &model.NoteRequest{Title: &title}, nil)
+	assert.Nil(t, actual)
+	// the error of the unit of work rolls it back
+	assert.Equal(t, txErr, err)
+	assert.True(t, errors.Is(err, storage.ErrDuplicateTitle))
+	mockStorage.AssertNotCalled(t, "Find", ctx, byPublicID(note.ID))
+	tx.AssertExpectations(t)
+}
+
+func TestNoteRepo_UpdateInvalid(t *testing.T) {
+	ctx := context.Background()
+	empty := ""
+	note := &model.Note{ID: 1, Title: "Hello", Version: 1}
+
+	mockStorage := newMockStorage()
+	repo := NewNoteRepo(mockStorage, storage.NewUserMemoryStorage())
+	mockStorage.On("Find", ctx, byPublicID(note.ID)).Return(note, nil)
+	actual, err := repo.Update(ctx, publicID(note.ID), &model.NoteRequest{Title: &empty}, nil)
+	assert.Nil(t, actual)
+	assert.Equal(t, apperr.NewValidation(lib.NoteInvalid, map[string]string{"title": lib.NoteTitleRequired}), err)
+}
+
+func TestNoteRepo_Patch(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		name     string
+		patch    jsonpatch.Patcher
+		versions []uint
+		expect   *model.Note
+		err      error
+	}{
+		{
+			name:   "case merge patch",
+			patch:  jsonpatch.MergePatch(`{"title":"World","is_completed":null}`),
+			expect: &model.Note{ID: 1, Title: "World", Version: 2},
+		},
+		{
+			name:     "case json patch",
+			patch:    jsonpatch.Patch{{Op: "replace", Path: "/title", Value: []byte(`"World"`)}},
+			versions: []uint{2},
+			expect:   &model.Note{ID: 1, Title: "World", IsCompleted: true, Version: 2},
+		},
+		{
+			name:     "case stale version",
+			patch:    jsonpatch.MergePatch(`{"title":"World"}`),
+			versions: []uint{1},
+			err:      apperr.New(apperr.PreconditionFailed, lib.NoteVersionMismatchError),
+		},
+		{
+			name:  "case failed test",
+			patch: jsonpatch.Patch{{Op: "test", Path: "/title", Value: []byte(`"World"`)}},
+			err:   apperr.Wrap(fmt.Errorf("operation 0: %w", jsonpatch.ErrTestFailed), apperr.Conflict, lib.NotePatchTestFailed),
+		},
+		{
+			name:  "case read only field",
+			patch: jsonpatch.MergePatch(`{"id":2}`),
+			err:   apperr.NewValidation(lib.NoteInvalid, map[string]string{"id": lib.NoteFieldReadOnly}),
+		},
+		{
+			name:     "case content",
+			patch:    jsonpatch.Patch{{Op: "replace", Path: "/content", Value: []byte(`"Some *text*"`)}},
+			versions: []uint{2},
+			expect:   &model.Note{ID: 1, Title: "Hello", IsCompleted: true, Content: "Some *text*", Version: 2},
+		},
+		{
+			name:   "case tags",
+			patch:  jsonpatch.MergePatch(`{"tags":["Work","home"]}`),
+			expect: &model.Note{ID: 1, Title: "Hello", IsCompleted: true, Tags: []string{"home", "work"}, Version: 2},
+		},
+		{
+			name:  "case empty title",
+			patch: jsonpatch.MergePatch(`{"title":""}`),
+			err:   apperr.NewValidation(lib.NoteInvalid, map[string]string{"title": lib.NoteTitleRequired}),
+		},
+		{
+			name:  "case content too long",
+			patch: jsonpatch.MergePatch(`{"content":"` + strings.Repeat("ữ", 20001) + `"}`),
+			err:   apperr.NewValidation(lib.NoteInvalid, map[string]string{"content": lib.NoteContentLength}),
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			note := &model.Note{ID: 1, Title: "Hello", IsCompleted: true, Version: 2}
+			mockStorage := newMockStorage()
+			repo := NewNoteRepo(mockStorage, storage.NewUserMemoryStorage())
+			mockStorage.On("Find", ctx, byPublicID(note.ID)).Return(note, nil)
+			mockStorage.On("Update", ctx, note.ID, note).Return(note, nil)
+			actual, err := repo.Patch(ctx, publicID(note.ID), c.patch, c.versions)
+			assert.Equal(t, c.err, err)
+			assert.Equal(t, c.expect, actual)
+		})
+	}
+}
+
+func TestNoteRepo_AddTags(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		name     string
+		tags     []string
+		versions []uint
+		expect   []string
+		err      error
+	}{
+		{
+			name:     "case new tags",
+			tags:     []string{"Urgent", "work"},
+			versions: []uint{2},
+			expect:   []string{"home", "urgent", "work"},
+		},
+		{
+			name: "case invalid tag",
+			tags: []string{"a/b"},
+			err:  apperr.NewValidation(lib.NoteInvalid, map[string]string{"tags": lib.NoteTagInvalid}),
+		},
+		{
+			name: "case too many tags",
+			tags: strings.Split("a,b,c,d,e,f,g,h,i,j,k,l,m,n,o,p,q,r,s", ","),
+			err:  apperr.NewValidation(lib.NoteInvalid, map[string]string{"tags": lib.NoteTagCount}),
+		},
+		{
+			name:     "case stale version",
+			tags:     []string{"urgent"},
+			versions: []uint{1},
+			err:      apperr.New(apperr.PreconditionFailed, lib.NoteVersionMismatchError),
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			note := &model.Note{ID: 1, Title: "Hello", Tags: []string{"home", "work"}, Version: 2}
+			mockStorage := newMockStorage()
+			repo := NewNoteRepo(mockStorage, storage.NewUserMemoryStorage())
+			mockStorage.On("Find", ctx, byPublicID(note.ID)).Return(note, nil)
+			mockStorage.On("Update", ctx, note.ID, note).Return(note, nil)
+			actual, err := repo.AddTags(ctx, publicID(note.ID), &model.NoteTagsRequest{Tags: c.tags}, c.versions)
+			assert.Equal(t, c.err, err)
+			if c.err == nil {
+				assert.Equal(t, c.expect, actual.Tags)
+			}
+		})
+	}
+}
+
+func TestNoteRepo_RemoveTag(t *testing.T) {
+	ctx := context.Background()
+	note := &model.Note{ID: 1, Title: "Hello", Tags: []string{"home", "work"}, Version: 2}
+
+	mockStorage := newMockStorage()
+	repo := NewNoteRepo(mockStorage, storage.NewUserMemoryStorage())
+	mockStorage.On("Find", ctx, byPublicID(note.ID)).Return(note, nil)
+	mockStorage.On("Update", ctx, note.ID, note).Return(note, nil)
+	actual, err := repo.RemoveTag(ctx, publicID(note.ID), "Work", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"home"}, actual.Tags)
+
+	_, err = repo.RemoveTag(ctx, publicID(note.ID), "missing", nil)
+	assert.Equal(t, apperr.New(apperr.NotFound, lib.TagNotExistError), err)
+	mockStorage.AssertNumberOfCalls(t, "Update", 1)
+}
+
 func TestNoteRepo_Delete(t *testing.T) {
+	ctx := context.Background()
 	isCompleted := false
 	var noteId uint = 1
 	note := model.Note{
@@ -350,28 +684,28 @@ func TestNoteRepo_Delete(t *testing.T) {
 		Title:       "Hello",
 		IsCompleted: true,
 	}
-	var noteIdZero uint = 0
+	deletedID, noteIdZero := publicID(noteId), ""
 	request := model.NoteRequest{
 		Title:       &note.Title,
 		IsCompleted: &isCompleted,
 	}
 	canNotGetNoteError := errors.New("can not get note")
+	canNotDeleteNoteError := errors.New("can not delete note")
 	cases := []struct {
 		name         string
-		expect       *uint
+		expect       *string
 		request      model.NoteRequest
 		beforeDelete *model.Note
-		code         int
+		deleteErr    error
 		err          error
 		getResult    *model.Note
 		getErr       error
 	}{
 		{
 			name:         "case 1: get note ok",
-			expect:       &noteId,
+			expect:       &deletedID,
 			beforeDelete: &note,
 			request:      request,
-			code:         200,
 			err:          nil,
 			getResult:    &note,
 			getErr:       nil,
@@ -380,8 +714,7 @@ func TestNoteRepo_Delete(t *testing.T) {
 			name:      "case 2: can not get note",
 			expect:    &noteIdZero,
 			request:   request,
-			code:      500,
-			err:       canNotGetNoteError,
+			err:       apperr.Wrap(canNotGetNoteError, apperr.Internal, ""),
 			getResult: nil,
 			getErr:    canNotGetNoteError,
 		},
@@ -389,8 +722,7 @@ func TestNoteRepo_Delete(t *testing.T) {
 			name:      "case 3: note not exist",
 			expect:    &noteIdZero,
 			request:   request,
-			code:      404,
-			err:       errors.New(lib.NoteNotExistError),
+			err:       apperr.New(apperr.NotFound, lib.NoteNotExistError),
 			getResult: nil,
 			getErr:    nil,
 		},
@@ -399,22 +731,353 @@ func TestNoteRepo_Delete(t *testing.T) {
 			expect:       &noteIdZero,
 			beforeDelete: &note,
 			request:      request,
-			code:         500,
-			err:          errors.New("can not delete note"),
+			deleteErr:    canNotDeleteNoteError,
+			err:          apperr.Wrap(canNotDeleteNoteError, apperr.Internal, ""),
 			getResult:    &note,
 			getErr:       nil,
 		},
 	}
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			mockStorage := &mocks.NoteStorage{}
-			repo := NewNoteRepo(mockStorage)
-			mockStorage.On("Get", noteId).Return(c.getResult, c.getErr)
-			mockStorage.On("Delete", c.beforeDelete).Return(c.err)
-			actual, code, err := repo.Delete(noteId)
-			assert.Equal(t, c.code, code)
+			mockStorage := newMockStorage()
+			repo := NewNoteRepo(mockStorage, storage.NewUserMemoryStorage())
+			mockStorage.On("Find", ctx, byPublicID(noteId)).Return(c.getResult, c.getErr)
+			mockStorage.On("Delete", ctx, c.beforeDelete).Return(c.deleteErr)
+			actual, err := repo.Delete(ctx, publicID(noteId))
 			assert.Equal(t, c.err, err)
 			assert.Equal(t, *c.expect, actual)
 		})
 	}
 }
+
+func TestNoteRepo_Restore(t *testing.T) {
+	ctx := context.Background()
+	deletedAt := time.Now()
+	note := model.Note{
+		ID:        1,
+		Title:     "Hello",
+		DeletedAt: &deletedAt,
+	}
+	inTrash := byPublicID(note.ID).InTrash()
+	canNotRestoreNoteError := errors.New("can not restore note")
+	cases := []struct {
+		name       string
+		trashed    *model.Note
+		restoreErr error
+		expect     *model.Note
+		err        error
+	}{
+		{
+			name:    "case restore ok",
+			trashed: &note,
+			expect:  &note,
+		},
+		{
+			name: "case note not in trash",
+			err:  apperr.New(apperr.NotFound, lib.NoteNotInTrashError),
+		},
+		{
+			name:       "case title was reused",
+			trashed:    &note,
+			restoreErr: storage.ErrDuplicateTitle,
+			err:        apperr.Wrap(storage.ErrDuplicateTitle, apperr.Conflict, lib.NoteTitleAlreadyExistError),
+		},
+		{
+			name:       "case can not restore note",
+			trashed:    &note,
+			restoreErr: canNotRestoreNoteError,
+			err:        apperr.Wrap(canNotRestoreNoteError, apperr.Internal, ""),
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mockStorage := newMockStorage()
+			repo := NewNoteRepo(mockStorage, storage.NewUserMemoryStorage())
+			mockStorage.On("Find", ctx, inTrash).Return(c.trashed, nil)
+			mockStorage.On("Restore", ctx, c.trashed).Return(c.restoreErr)
+			actual, err := repo.Restore(ctx, publicID(note.ID))
+			assert.Equal(t, c.err, err)
+			assert.Equal(t, c.expect, actual)
+		})
+	}
+}
+
+func TestNoteRepo_Purge(t *testing.T) {
+	ctx := context.Background()
+	deletedAt := time.Now()
+	note := model.Note{ID: 1, Title: "Hello", DeletedAt: &deletedAt}
+	inTrash := byPublicID(note.ID).InTrash()
+
+	mockStorage := newMockStorage()
+	repo := NewNoteRepo(mockStorage, storage.NewUserMemoryStorage())
+	mockStorage.On("Find", ctx, inTrash).Return(&note, nil).Once()
+	mockStorage.On("Purge", ctx, &note).Return(nil).Once()
+	id, err := repo.Purge(ctx, publicID(note.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, publicID(note.ID), id)
+
+	mockStorage.On("Find", ctx, inTrash).Return(nil, nil).Once()
+	_, err = repo.Purge(ctx, publicID(note.ID))
+	assert.Equal(t, apperr.New(apperr.NotFound, lib.NoteNotInTrashError), err)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestNoteRepo_PurgeTrash(t *testing.T) {
+	ctx := context.Background()
+	before := time.Now()
+	notes := []*model.Note{{ID: 1}, {ID: 2}}
+	filter := storage.NoteFilter{}.WithDeletedBefore(before)
+
+	mockStorage := newMockStorage()
+	repo := NewNoteRepo(mockStorage, storage.NewUserMemoryStorage())
+	mockStorage.On("GetList", ctx, filter, storage.NoteListOptions{}).Return(notes, nil)
+	mockStorage.On("Purge", ctx, notes[0]).Return(nil)
+	mockStorage.On("Purge", ctx, notes[1]).Return(errors.New("can not purge note"))
+	count, err := repo.PurgeTrash(ctx, before)
+	assert.Error(t, err)
+	assert.Zero(t, count)
+}
+
+func TestNoteRepo_Batch(t *testing.T) {
+	ctx := context.Background()
+	title, empty := "World", ""
+	note := &model.Note{ID: 1, Title: "Hello", Version: 2}
+	other := &model.Note{ID: 2, Title: "Other", Version: 1}
+	cases := []struct {
+		name       string
+		atomic     bool
+		operations []*model.NoteBatchOperation
+		ops        []storage.NoteOp
+		opResults  []storage.NoteOpResult
+		expect     []*model.NoteBatchResult
+		err        error
+	}{
+		{
+			name: "case best effort",
+			operations: []*model.NoteBatchOperation{
+				{Op: model.NoteBatchCreate, Note: &model.NoteRequest{Title: &title}},
+				{Op: model.NoteBatchUpdate, ID: publicID(1), Version: 2, Note: &model.NoteRequest{Title: &title}},
+				{Op: model.NoteBatchDelete, ID: publicID(3)},
+				{Op: model.NoteBatchDelete, ID: publicID(2)},
+			},
+			ops: []storage.NoteOp{
+				{Kind: storage.NoteOpInsert, Note: &model.Note{Title: "World"}},
+				{Kind: storage.NoteOpUpdate, ID: 1, Note: &model.Note{ID: 1, Title: "World", Version: 2}},
+				{Kind: storage.NoteOpDelete, ID: 2, Note: other},
+			},
+			opResults: []storage.NoteOpResult{
+				{Note: &model.Note{ID: 3, Title: "World", Version: 1}},
+				{Err: storage.ErrDuplicateTitle},
+				{Note: other},
+			},
+			expect: []*model.NoteBatchResult{
+				{Note: &model.Note{ID: 3, Title: "World", Version: 1}},
+				{Err: apperr.Wrap(storage.ErrDuplicateTitle, apperr.Conflict, lib.NoteTitleAlreadyExistError)},
+				{Err: apperr.New(apperr.NotFound, lib.NoteNotExistError)},
+				{Note: other},
+			},
+		},
+		{
+			name:   "case atomic with an invalid operation",
+			atomic: true,
+			operations: []*model.NoteBatchOperation{
+				{Op: model.NoteBatchDelete, ID: publicID(2)},
+				{Op: model.NoteBatchUpdate, ID: publicID(1), Note: &model.NoteRequest{Title: &empty}},
+				{Op: "move", ID: publicID(1)},
+				{Op: model.NoteBatchDelete, ID: "2"},
+			},
+			expect: []*model.NoteBatchResult{
+				{Err: apperr.Wrap(storage.ErrBatchAborted, apperr.Conflict, lib.NoteBatchAborted)},
+				{Err: apperr.NewValidation(lib.NoteInvalid, map[string]string{"title": lib.NoteTitleRequired})},
+				{Err: apperr.New(apperr.BadRequest, lib.NoteBatchOpInvalid)},
+				{Err: apperr.Wrap(ulid.ErrInvalid, apperr.BadRequest, lib.NoteIDInvalid)},
+			},
+		},
+		{
+			name:   "case atomic rolled back by the storage",
+			atomic: true,
+			operations: []*model.NoteBatchOperation{
+				{Op: model.NoteBatchDelete, ID: publicID(2)},
+				{Op: model.NoteBatchUpdate, ID: publicID(1), Version: 2, Note: &model.NoteRequest{Title: &title}},
+			},
+			ops: []storage.NoteOp{
+				{Kind: storage.NoteOpDelete, ID: 2, Note: other},
+				{Kind: storage.NoteOpUpdate, ID: 1, Note: &model.Note{ID: 1, Title: "World", Version: 2}},
+			},
+			opResults: []storage.NoteOpResult{
+				{Err: storage.ErrBatchAborted},
+				{Err: storage.ErrVersionConflict},
+			},
+			expect: []*model.NoteBatchResult{
+				{Err: apperr.Wrap(storage.ErrBatchAborted, apperr.Conflict, lib.NoteBatchAborted)},
+				{Err: apperr.Wrap(storage.ErrVersionConflict, apperr.PreconditionFailed, lib.NoteVersionMismatchError)},
+			},
+		},
+		{
+			name: "case same note twice",
+			operations: []*model.NoteBatchOperation{
+				{Op: model.NoteBatchDelete, ID: publicID(2)},
+				{Op: model.NoteBatchDelete, ID: publicID(2)},
+			},
+			ops:       []storage.NoteOp{{Kind: storage.NoteOpDelete, ID: 2, Note: other}},
+			opResults: []storage.NoteOpResult{{Note: other}},
+			expect: []*model.NoteBatchResult{
+				{Note: other},
+				{Err: apperr.New(apperr.BadRequest, lib.NoteBatchDuplicateNote)},
+			},
+		},
+		{
+			name: "case empty batch",
+			err:  apperr.NewValidation(lib.NoteBatchInvalid, map[string]string{"operations": lib.NoteBatchSize}),
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mockStorage := newMockStorage()
+			repo := NewNoteRepo(mockStorage, storage.NewUserMemoryStorage())
+			mockStorage.On("Find", ctx, byPublicID(1)).Return(func(context.Context, storage.NoteFilter) *model.Note {
+				clone := *note
+				return &clone
+			}, nil)
+			mockStorage.On("Find", ctx, byPublicID(2)).Return(other, nil)
+			mockStorage.On("Find", ctx, byPublicID(3)).Return(nil, nil)
+			mockStorage.On("Batch", ctx, c.ops, c.atomic).Return(c.opResults, nil)
+			actual, err := repo.Batch(ctx, &model.NoteBatchRequest{Atomic: c.atomic, Operations: c.operations})
+			assert.Equal(t, c.err, err)
+			if c.err == nil {
+				assert.Equal(t, c.expect, actual)
+			}
+			if c.ops == nil {
+				mockStorage.AssertNotCalled(t, "Batch", ctx, c.ops, c.atomic)
+			}
+		})
+	}
+}
+
+func TestNoteRepo_Search(t *testing.T) {
+	ctx := context.Background()
+	results := []*model.NoteSearchResult{
+		{Note: &model.Note{ID: 1, Title: "Hà Nội"}, Rank: 0.5, Snippet: "<mark>Hà</mark> Nội"},
+	}
+	cases := []struct {
+		name      string
+		request   *model.NoteSearchRequest
+		opts      storage.NoteSearchOptions
+		results   []*model.NoteSearchResult
+		searchErr error
+		expect    *model.NoteSearchPage
+		err       error
+	}{
+		{
+			name:    "case default limit",
+			request: &model.NoteSearchRequest{Q: "ha"},
+			opts:    storage.NoteSearchOptions{Limit: 20},
+			results: results,
+			expect:  &model.NoteSearchPage{Items: results, Total: 1},
+		},
+		{
+			name:    "case no match",
+			request: &model.NoteSearchRequest{Q: "paris", Limit: 5, Offset: 10},
+			opts:    storage.NoteSearchOptions{Limit: 5, Offset: 10},
+			expect:  &model.NoteSearchPage{Items: []*model.NoteSearchResult{}},
+		},
+		{
+			name:      "case can not search",
+			request:   &model.NoteSearchRequest{Q: "ha"},
+			opts:      storage.NoteSearchOptions{Limit: 20},
+			searchErr: errors.New("can not search"),
+			err:       apperr.Wrap(errors.New("can not search"), apperr.Internal, ""),
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mockStorage := newMockStorage()
+			repo := NewNoteRepo(mockStorage, storage.NewUserMemoryStorage())
+			mockStorage.On("Search", ctx, c.request.Q, c.opts).Return(c.results, len(c.results), c.searchErr)
+			actual, err := repo.Search(ctx, c.request)
+			assert.Equal(t, c.err, err)
+			assert.Equal(t, c.expect, actual)
+		})
+	}
+}
+
+// userContext is the context of the requests of the user id.
+func userContext(id uint) context.Context {
+	return auth.WithIdentity(context.Background(), &auth.Identity{UserID: id})
+}
+
+func TestNoteRepo_SearchIndex(t *testing.T) {
+	ctx := userContext(1)
+	repo := NewNoteRepo(storage.NewNoteMemoryStorage(), storage.NewUserMemoryStorage())
+	title := "Đi chợ Hà Nội"
+	_, err := repo.Insert(ctx, &model.NoteRequest{Title: &title})
+	assert.NoError(t, err)
+
+	page, err := repo.Search(ctx, &model.NoteSearchRequest{Q: "cho ha noi"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, page.Total)
+}
+
+func TestNoteRepo_OtherUsers(t *testing.T) {
+	ann, bob := userContext(1), userContext(2)
+	repo := NewNoteRepo(storage.NewNoteMemoryStorage(), storage.NewUserMemoryStorage())
+	title := "Hello"
+	note, err := repo.Insert(ann, &model.NoteRequest{Title: &title, Tags: []string{"work"}})
+	assert.NoError(t, err)
+	trashedTitle, stolen := "Trashed", "Stolen"
+	trashed, err := repo.Insert(ann, &model.NoteRequest{Title: &trashedTitle})
+	assert.NoError(t, err)
+	_, err = repo.Delete(ann, trashed.PublicID)
+	assert.NoError(t, err)
+
+	notFound := apperr.New(apperr.NotFound, lib.NoteNotExistError)
+	_, err = repo.Get(bob, note.PublicID)
+	assert.Equal(t, notFound, err)
+	_, err = repo.Update(bob, note.PublicID, &model.NoteRequest{Title: &stolen}, nil)
+	assert.Equal(t, notFound, err)
+	_, err = repo.Patch(bob, note.PublicID, jsonpatch.MergePatch(`{"title":"Stolen"}`), nil)
+	assert.Equal(t, notFound, err)
+	_, err = repo.AddTags(bob, note.PublicID, &model.NoteTagsRequest{Tags: []string{"stolen"}}, nil)
+	assert.Equal(t, notFound, err)
+	_, err = repo.Delete(bob, note.PublicID)
+	assert.Equal(t, notFound, err)
+	notInTrash := apperr.New(apperr.NotFound, lib.NoteNotInTrashError)
+	_, err = repo.Restore(bob, trashed.PublicID)
+	assert.Equal(t, notInTrash, err)
+	_, err = repo.Purge(bob, trashed.PublicID)
+	assert.Equal(t, notInTrash, err)
+
+	page, err := repo.GetList(bob, &model.NoteListRequest{})
+	assert.NoError(t, err)
+	assert.Empty(t, page.Items)
+	assert.Zero(t, page.Total)
+	page, err = repo.GetTrash(bob, &model.NoteListRequest{})
+	assert.NoError(t, err)
+	assert.Empty(t, page.Items)
+	exists, err := repo.Exist(bob, note.PublicID)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	exists, err = repo.ExistByTitle(bob, title)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	results, err := repo.Batch(bob, &model.NoteBatchRequest{Operations: []*model.NoteBatchOperation{
+		{Op: model.NoteBatchDelete, ID: note.PublicID},
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, apperr.NotFound, apperr.KindOf(results[0].Err))
+	search, err := repo.Search(bob, &model.NoteSearchRequest{Q: "hello"})
+	assert.NoError(t, err)
+	assert.Zero(t, search.Total)
+
+	// titles are only unique among the notes of a user
+	_, err = repo.Insert(bob, &model.NoteRequest{Title: &title})
+	assert.NoError(t, err)
+
+	got, err := repo.Get(ann, note.PublicID)
+	assert.NoError(t, err)
+	assert.Equal(t, note.Version, got.Version)
+	assert.Equal(t, []string{"work"}, got.Tags)
+	page, err = repo.GetTrash(ann, &model.NoteListRequest{})
+	assert.NoError(t, err)
+	assert.Len(t, page.Items, 1)
+}
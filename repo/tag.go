@@ -0,0 +1,103 @@
+package repo
+
+import (
+	"context"
+	validator "github.com/asaskevich/govalidator"
+	"github.com/lyquocnam/go-note-learning/apperr"
+	"github.com/lyquocnam/go-note-learning/lib"
+	"github.com/lyquocnam/go-note-learning/model"
+	"github.com/lyquocnam/go-note-learning/storage"
+)
+
+type tagRepo struct {
+	noteStorage storage.NoteStorage
+}
+
+// NewTagRepo works on the notes of noteRepo through the same storage, so
+// its search index sees the renamed tags.
+func NewTagRepo(noteRepo *noteRepo) *tagRepo {
+	return &tagRepo{noteStorage: noteRepo.noteStorage}
+}
+
+// TagRepo returns apperr errors like NoteRepo. A tag exists as long as a
+// note has it, the notes in the trash included.
+type TagRepo interface {
+	GetList(ctx context.Context) ([]*model.Tag, error)
+	// Rename gives the tag name the name of request, no other tag may have
+	// it yet.
+	Rename(ctx context.Context, name string, request *model.TagRequest) (*model.Tag, error)
+	// Merge moves the notes of the tag name to the existing tag of request,
+	// name no longer exists afterwards.
+	Merge(ctx context.Context, name string, request *model.TagRequest) (*model.Tag, error)
+}
+
+func (r *tagRepo) GetList(ctx context.Context) ([]*model.Tag, error) {
+	tags, err := r.noteStorage.Tags(ctx)
+	if err != nil {
+		return nil, apperr.FromError(err)
+	}
+	if tags == nil {
+		tags = []*model.Tag{}
+	}
+	return tags, nil
+}
+
+func (r *tagRepo) Rename(ctx context.Context, name string, request *model.TagRequest) (*model.Tag, error) {
+	return r.rename(ctx, name, request, false)
+}
+
+func (r *tagRepo) Merge(ctx context.Context, name string, request *model.TagRequest) (*model.Tag, error) {
+	return r.rename(ctx, name, request, true)
+}
+
+func (r *tagRepo) rename(ctx context.Context, name string, request *model.TagRequest, merge bool) (*model.Tag, error) {
+	if _, err := request.Validate(); err != nil {
+		return nil, apperr.NewValidation(lib.TagInvalid, validator.ErrorsByField(err))
+	}
+	from, to := model.NormalizeTag(name), model.NormalizeTag(*request.Name)
+	if to == "" {
+		return nil, apperr.NewValidation(lib.TagInvalid, map[string]string{"name": lib.NoteTagRequired})
+	}
+
+	var tag *model.Tag
+	err := apperr.FromError(r.noteStorage.WithTx(ctx, func(tx storage.NoteStorage) error {
+		exists, err := tagExists(ctx, tx, from)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return apperr.New(apperr.NotFound, lib.TagNotExistError)
+		}
+		if from != to {
+			exists, err := tagExists(ctx, tx, to)
+			if err != nil {
+				return err
+			}
+			if exists != merge {
+				if merge {
+					return apperr.New(apperr.NotFound, lib.TagNotExistError)
+				}
+				return apperr.New(apperr.Conflict, lib.TagAlreadyExistError)
+			}
+			if _, err := tx.RenameTag(ctx, from, to); err != nil {
+				return apperr.FromError(err)
+			}
+		}
+
+		count, err := tx.Count(ctx, storage.NoteFilter{}.WithTags(to))
+		if err != nil {
+			return apperr.FromError(err)
+		}
+		tag = &model.Tag{Name: to, Count: count}
+		return nil
+	}))
+	if err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+func tagExists(ctx context.Context, s storage.NoteStorage, name string) (bool, error) {
+	count, err := s.Count(ctx, storage.NoteFilter{}.WithDeleted().WithTags(name))
+	return count > 0, apperr.FromError(err)
+}
@@ -0,0 +1,116 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"github.com/lyquocnam/go-note-learning/apperr"
+	"github.com/lyquocnam/go-note-learning/lib"
+	"github.com/lyquocnam/go-note-learning/model"
+	"github.com/lyquocnam/go-note-learning/storage"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTagRepo_GetList(t *testing.T) {
+	ctx := context.Background()
+	tags := []*model.Tag{{Name: "home", Count: 1}, {Name: "work", Count: 3}}
+
+	mockStorage := newMockStorage()
+	repo := NewTagRepo(NewNoteRepo(mockStorage, storage.NewUserMemoryStorage()))
+	mockStorage.On("Tags", ctx).Return(tags, nil).Once()
+	actual, err := repo.GetList(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, tags, actual)
+
+	failure := errors.New("failure")
+	mockStorage.On("Tags", ctx).Return(nil, failure)
+	_, err = repo.GetList(ctx)
+	assert.Equal(t, apperr.Wrap(failure, apperr.Internal, ""), err)
+}
+
+func TestTagRepo_Rename(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		name   string
+		merge  bool
+		from   string
+		to     string
+		exist  map[string]bool
+		expect *model.Tag
+		err    error
+	}{
+		{
+			name:   "case rename",
+			from:   "Work",
+			to:     "Job",
+			exist:  map[string]bool{"work": true},
+			expect: &model.Tag{Name: "job", Count: 2},
+		},
+		{
+			name:  "case rename missing tag",
+			from:  "work",
+			to:    "job",
+			exist: map[string]bool{},
+			err:   apperr.New(apperr.NotFound, lib.TagNotExistError),
+		},
+		{
+			name:  "case rename to an existing tag",
+			from:  "work",
+			to:    "job",
+			exist: map[string]bool{"work": true, "job": true},
+			err:   apperr.New(apperr.Conflict, lib.TagAlreadyExistError),
+		},
+		{
+			name:   "case merge",
+			merge:  true,
+			from:   "work",
+			to:     "job",
+			exist:  map[string]bool{"work": true, "job": true},
+			expect: &model.Tag{Name: "job", Count: 2},
+		},
+		{
+			name:  "case merge into a missing tag",
+			merge: true,
+			from:  "work",
+			to:    "job",
+			exist: map[string]bool{"work": true},
+			err:   apperr.New(apperr.NotFound, lib.TagNotExistError),
+		},
+		{
+			name:  "case invalid name",
+			from:  "work",
+			to:    "a/b",
+			exist: map[string]bool{"work": true},
+			err:   apperr.NewValidation(lib.TagInvalid, map[string]string{"name": lib.NoteTagInvalid}),
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mockStorage := newMockStorage()
+			repo := NewTagRepo(NewNoteRepo(mockStorage, storage.NewUserMemoryStorage()))
+			for _, name := range []string{"work", "job"} {
+				count := 0
+				if c.exist[name] {
+					count = 1
+				}
+				mockStorage.On("Count", ctx, storage.NoteFilter{}.WithDeleted().WithTags(name)).Return(count, nil)
+			}
+			mockStorage.On("RenameTag", ctx, "work", "job").Return(2, nil)
+			mockStorage.On("Count", ctx, storage.NoteFilter{}.WithTags("job")).Return(2, nil)
+
+			request := &model.TagRequest{Name: &c.to}
+			var actual *model.Tag
+			var err error
+			if c.merge {
+				actual, err = repo.Merge(ctx, c.from, request)
+			} else {
+				actual, err = repo.Rename(ctx, c.from, request)
+			}
+			assert.Equal(t, c.err, err)
+			assert.Equal(t, c.expect, actual)
+			if c.err != nil {
+				mockStorage.AssertNotCalled(t, "RenameTag", ctx, "work", "job")
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/lyquocnam/go-note-learning/model"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+func (b *userBoltStorage) GetComments(ctx context.Context, noteID string) ([]*model.Comment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var comments []*model.Comment
+	err := b.db.View(func(tx *bolt.Tx) error {
+		prefix := []byte(noteID)
+		c := tx.Bucket(commentBucket).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var stored storedComment
+			if err := json.Unmarshal(v, &stored); err != nil {
+				return err
+			}
+			comment := model.Comment(stored)
+			comments = append(comments, &comment)
+		}
+		return nil
+	})
+	return comments, err
+}
+
+func (b *userBoltStorage) InsertComment(ctx context.Context, comment *model.Comment) (*model.Comment, error) {
+	if err := ctx.Err(); err != nil {
+		return comment, err
+	}
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		comments := tx.Bucket(commentBucket)
+		seq, err := comments.NextSequence()
+		if err != nil {
+			return err
+		}
+		comment.ID = uint(seq)
+		comment.CreatedAt = time.Now()
+
+		data, err := json.Marshal(storedComment(*comment))
+		if err != nil {
+			return err
+		}
+		return comments.Put(append([]byte(comment.NoteID), itob(comment.ID)...), data)
+	})
+	return comment, err
+}
+
+func (b *userBoltStorage) DeleteComments(ctx context.Context, noteID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		// the cursor can't be used while deleting
+		var keys [][]byte
+		prefix := []byte(noteID)
+		c := tx.Bucket(commentBucket).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		for _, k := range keys {
+			if err := tx.Bucket(commentBucket).Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
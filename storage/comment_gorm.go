@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"context"
+	"github.com/lyquocnam/go-note-learning/model"
+)
+
+func (u *userGormStorage) GetComments(ctx context.Context, noteID string) ([]*model.Comment, error) {
+	db, err := openGormContext(ctx, u.db, nil, u.logMode)
+	if err != nil {
+		return nil, err
+	}
+
+	var comments []*model.Comment
+	err = db.Where("note_id = ?", noteID).Order("id").Find(&comments).Error
+	return comments, err
+}
+
+func (u *userGormStorage) InsertComment(ctx context.Context, comment *model.Comment) (*model.Comment, error) {
+	db, err := openGormContext(ctx, u.db, nil, u.logMode)
+	if err != nil {
+		return comment, err
+	}
+	return comment, db.Create(comment).Error
+}
+
+func (u *userGormStorage) DeleteComments(ctx context.Context, noteID string) error {
+	db, err := openGormContext(ctx, u.db, nil, u.logMode)
+	if err != nil {
+		return err
+	}
+	return db.Delete(model.Comment{}, "note_id = ?", noteID).Error
+}
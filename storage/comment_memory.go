@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"github.com/lyquocnam/go-note-learning/model"
+	"time"
+)
+
+func (m *userMemoryStorage) GetComments(ctx context.Context, noteID string) ([]*model.Comment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var comments []*model.Comment
+	for _, comment := range m.comments[noteID] {
+		c := *comment
+		comments = append(comments, &c)
+	}
+	return comments, nil
+}
+
+func (m *userMemoryStorage) InsertComment(ctx context.Context, comment *model.Comment) (*model.Comment, error) {
+	if err := ctx.Err(); err != nil {
+		return comment, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	previous, lastCommentID := m.comments[comment.NoteID], m.lastCommentID
+	m.lastCommentID++
+	comment.ID = m.lastCommentID
+	comment.CreatedAt = time.Now()
+	c := *comment
+	// append copies previous, it is put back as it was on failure
+	m.comments[comment.NoteID] = append(previous[:len(previous):len(previous)], &c)
+	if err := m.save(); err != nil {
+		if previous != nil {
+			m.comments[comment.NoteID] = previous
+		} else {
+			delete(m.comments, comment.NoteID)
+		}
+		m.lastCommentID = lastCommentID
+		return comment, err
+	}
+	return comment, nil
+}
+
+func (m *userMemoryStorage) DeleteComments(ctx context.Context, noteID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	comments, ok := m.comments[noteID]
+	if !ok {
+		return nil
+	}
+	delete(m.comments, noteID)
+	if err := m.save(); err != nil {
+		m.comments[noteID] = comments
+		return err
+	}
+	return nil
+}
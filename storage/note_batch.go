@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/lyquocnam/go-note-learning/model"
+)
+
+// ErrBatchAborted is the error of the operations of an atomic batch that
+// were rolled back or not run because another operation failed.
+var ErrBatchAborted = errors.New("storage: batch was rolled back")
+
+type NoteOpKind int
+
+const (
+	NoteOpInsert NoteOpKind = iota
+	NoteOpUpdate
+	NoteOpDelete
+)
+
+// NoteOp is one write of a batch. Insert and Update store Note, Update and
+// Delete act on the note ID, Delete expects Note to be the stored note.
+type NoteOp struct {
+	Kind NoteOpKind
+	ID   uint
+	Note *model.Note
+}
+
+// NoteOpResult is the outcome of the NoteOp at the same index, Note is nil
+// when Err is set.
+type NoteOpResult struct {
+	Note *model.Note
+	Err  error
+}
+
+// batchNotes implements Batch on top of WithTx, a best-effort batch runs
+// its operations one by one: a failed statement aborts the transaction it
+// is part of on postgres.
+func batchNotes(ctx context.Context, s NoteStorage, ops []NoteOp, atomic bool) ([]NoteOpResult, error) {
+	if !atomic {
+		results, _ := runNoteOps(ctx, s, ops, false)
+		return results, nil
+	}
+
+	// returning the error of the failed operation rolls the batch back and
+	// lets WithTx retry it when it is a serialization failure
+	var results []NoteOpResult
+	var failure error
+	err := s.WithTx(ctx, func(tx NoteStorage) error {
+		results, failure = runNoteOps(ctx, tx, ops, true)
+		return failure
+	})
+	if err != nil && failure == nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// runNoteOps runs ops one after the other on s. When atomic, it stops at
+// the first failure and every other operation gets ErrBatchAborted. It
+// returns the error of the first operation that failed.
+func runNoteOps(ctx context.Context, s NoteStorage, ops []NoteOp, atomic bool) ([]NoteOpResult, error) {
+	results := make([]NoteOpResult, len(ops))
+	var failure error
+	for i, op := range ops {
+		if failure != nil && atomic {
+			results[i].Err = ErrBatchAborted
+			continue
+		}
+		note, err := runNoteOp(ctx, s, op)
+		if err != nil {
+			if failure == nil {
+				failure = err
+			}
+			results[i].Err = err
+			if atomic {
+				abortNoteOps(results[:i])
+			}
+			continue
+		}
+		results[i].Note = note
+	}
+	return results, failure
+}
+
+func runNoteOp(ctx context.Context, s NoteStorage, op NoteOp) (*model.Note, error) {
+	switch op.Kind {
+	case NoteOpInsert:
+		return s.Insert(ctx, op.Note)
+	case NoteOpUpdate:
+		return s.Update(ctx, op.ID, op.Note)
+	case NoteOpDelete:
+		op.Note.ID = op.ID
+		return op.Note, s.Delete(ctx, op.Note)
+	}
+	return nil, fmt.Errorf("storage: unknown note operation %d", op.Kind)
+}
+
+func abortNoteOps(results []NoteOpResult) {
+	for i := range results {
+		results[i] = NoteOpResult{Err: ErrBatchAborted}
+	}
+}
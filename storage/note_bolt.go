@@ -0,0 +1,499 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/lyquocnam/go-note-learning/model"
+	"github.com/lyquocnam/go-note-learning/ulid"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+var (
+	noteBucket         = []byte("notes")
+	noteTitleBucket    = []byte("note_owner_titles")
+	notePublicIDBucket = []byte("note_public_ids")
+	// legacyNoteTitleBucket made titles unique across users
+	legacyNoteTitleBucket = []byte("note_titles")
+)
+
+// noteBoltStorage keeps notes as JSON in the "notes" bucket keyed by id,
+// "note_owner_titles" and "note_public_ids" index their titles and public
+// ids.
+type noteBoltStorage struct {
+	db *bolt.DB
+	tx *bolt.Tx
+}
+
+// NewNoteBoltStorage creates the buckets it needs when they are missing,
+// gives the notes stored before public ids and versions existed one and
+// indexes the titles by owner when they were indexed globally.
+func NewNoteBoltStorage(db *bolt.DB) (*noteBoltStorage, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		indexTitles := tx.Bucket(noteTitleBucket) == nil
+		for _, name := range [][]byte{noteBucket, noteTitleBucket, notePublicIDBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		if err := backfillBoltNotes(tx); err != nil {
+			return err
+		}
+		if !indexTitles {
+			return nil
+		}
+		if tx.Bucket(legacyNoteTitleBucket) != nil {
+			if err := tx.DeleteBucket(legacyNoteTitleBucket); err != nil {
+				return err
+			}
+		}
+		return indexBoltTitles(tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &noteBoltStorage{db: db}, nil
+}
+
+func (b *noteBoltStorage) Get(ctx context.Context, id uint) (*model.Note, error) {
+	return b.Find(ctx, NoteFilter{}.WithID(id))
+}
+
+func (b *noteBoltStorage) Find(ctx context.Context, filter NoteFilter) (*model.Note, error) {
+	notes, err := b.GetList(ctx, filter, NoteListOptions{Limit: 1})
+	if err != nil || len(notes) == 0 {
+		return nil, err
+	}
+	return notes[0], nil
+}
+
+func (b *noteBoltStorage) GetList(ctx context.Context, filter NoteFilter, opts NoteListOptions) ([]*model.Note, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	filter, err := scopedFilter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	var notes []*model.Note
+	err = b.view(func(tx *bolt.Tx) error {
+		var err error
+		notes, err = matchBoltNotes(ctx, tx, filter)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pageNotes(notes, opts), nil
+}
+
+func (b *noteBoltStorage) Insert(ctx context.Context, note *model.Note) (*model.Note, error) {
+	if err := ctx.Err(); err != nil {
+		return note, err
+	}
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return note, err
+	}
+	err = b.update(func(tx *bolt.Tx) error {
+		scope.assign(note)
+		if tx.Bucket(noteTitleBucket).Get(boltTitleKey(note)) != nil {
+			return ErrDuplicateTitle
+		}
+
+		seq, err := tx.Bucket(noteBucket).NextSequence()
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		note.ID = uint(seq)
+		assignPublicID(note)
+		note.CreatedAt = now
+		note.UpdatedAt = now
+		note.Version = 1
+		return putBoltNote(tx, note)
+	})
+	return note, err
+}
+
+func (b *noteBoltStorage) Update(ctx context.Context, id uint, note *model.Note) (*model.Note, error) {
+	if err := ctx.Err(); err != nil {
+		return note, err
+	}
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return note, err
+	}
+	err = b.update(func(tx *bolt.Tx) error {
+		current, err := getBoltNote(tx, id)
+		if err != nil {
+			return err
+		}
+		if current == nil || current.DeletedAt != nil || !scope.owns(current) {
+			return fmt.Errorf("storage: note %d does not exist", id)
+		}
+
+		if current.Version != note.Version {
+			return ErrVersionConflict
+		}
+		note.OwnerID = current.OwnerID
+		titles := tx.Bucket(noteTitleBucket)
+		other := titles.Get(boltTitleKey(note))
+		if other != nil && btoi(other) != id {
+			return ErrDuplicateTitle
+		}
+		if err := titles.Delete(boltTitleKey(current)); err != nil {
+			return err
+		}
+
+		note.ID = id
+		note.PublicID = current.PublicID
+		note.Version++
+		note.CreatedAt = current.CreatedAt
+		note.UpdatedAt = time.Now()
+		note.DeletedAt = nil
+		return putBoltNote(tx, note)
+	})
+	return note, err
+}
+
+func (b *noteBoltStorage) Delete(ctx context.Context, note *model.Note) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return err
+	}
+	return b.update(func(tx *bolt.Tx) error {
+		current, err := getBoltNote(tx, note.ID)
+		if err != nil || current == nil || current.DeletedAt != nil || !scope.owns(current) {
+			return err
+		}
+		if err := tx.Bucket(noteTitleBucket).Delete(boltTitleKey(current)); err != nil {
+			return err
+		}
+		now := time.Now()
+		current.DeletedAt = &now
+		note.DeletedAt = &now
+		return putBoltNote(tx, current)
+	})
+}
+
+func (b *noteBoltStorage) Restore(ctx context.Context, note *model.Note) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return err
+	}
+	return b.update(func(tx *bolt.Tx) error {
+		current, err := getBoltNote(tx, note.ID)
+		if err != nil {
+			return err
+		}
+		if current == nil || !scope.owns(current) {
+			return fmt.Errorf("storage: note %d does not exist", note.ID)
+		}
+		if current.DeletedAt == nil {
+			return nil
+		}
+		if tx.Bucket(noteTitleBucket).Get(boltTitleKey(current)) != nil {
+			return ErrDuplicateTitle
+		}
+		current.DeletedAt = nil
+		note.DeletedAt = nil
+		return putBoltNote(tx, current)
+	})
+}
+
+func (b *noteBoltStorage) Purge(ctx context.Context, note *model.Note) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return err
+	}
+	return b.update(func(tx *bolt.Tx) error {
+		current, err := getBoltNote(tx, note.ID)
+		if err != nil || current == nil || !scope.owns(current) {
+			return err
+		}
+		if current.DeletedAt == nil {
+			if err := tx.Bucket(noteTitleBucket).Delete(boltTitleKey(current)); err != nil {
+				return err
+			}
+		}
+		if err := tx.Bucket(notePublicIDBucket).Delete([]byte(current.PublicID)); err != nil {
+			return err
+		}
+		return tx.Bucket(noteBucket).Delete(itob(note.ID))
+	})
+}
+
+func (b *noteBoltStorage) Count(ctx context.Context, filter NoteFilter) (int, error) {
+	notes, err := b.GetList(ctx, filter, NoteListOptions{})
+	return len(notes), err
+}
+
+func (b *noteBoltStorage) Tags(ctx context.Context) ([]*model.Tag, error) {
+	notes, err := b.GetList(ctx, NoteFilter{}, NoteListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return countTags(notes), nil
+}
+
+func (b *noteBoltStorage) RenameTag(ctx context.Context, from, to string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	filter, err := scopedFilter(ctx, NoteFilter{}.WithDeleted().WithTags(from))
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	err = b.update(func(tx *bolt.Tx) error {
+		notes, err := matchBoltNotes(ctx, tx, filter)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, note := range notes {
+			note.Tags, _ = renameTag(note.Tags, from, to)
+			note.Version++
+			note.UpdatedAt = now
+			if err := putBoltNote(tx, note); err != nil {
+				return err
+			}
+		}
+		count = len(notes)
+		return nil
+	})
+	return count, err
+}
+
+func (b *noteBoltStorage) AdoptNotes(ctx context.Context, owner uint) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	count := 0
+	err := b.update(func(tx *bolt.Tx) error {
+		notes, err := matchBoltNotes(ctx, tx, NoteFilter{}.WithDeleted().WithOwner(0))
+		if err != nil {
+			return err
+		}
+		titles := tx.Bucket(noteTitleBucket)
+		for _, note := range notes {
+			if note.DeletedAt == nil {
+				if titles.Get(boltTitleKey(&model.Note{OwnerID: owner, Title: note.Title})) != nil {
+					continue
+				}
+				if err := titles.Delete(boltTitleKey(note)); err != nil {
+					return err
+				}
+			}
+			note.OwnerID = owner
+			if err := putBoltNote(tx, note); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (b *noteBoltStorage) Batch(ctx context.Context, ops []NoteOp, atomic bool) ([]NoteOpResult, error) {
+	return batchNotes(ctx, b, ops, atomic)
+}
+
+// WithTx runs fn in a single bolt transaction, bolt only has one writer at
+// a time.
+func (b *noteBoltStorage) WithTx(ctx context.Context, fn func(s NoteStorage) error) error {
+	if b.tx != nil {
+		return fn(b)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return fn(&noteBoltStorage{db: b.db, tx: tx})
+	})
+}
+
+func (b *noteBoltStorage) update(fn func(tx *bolt.Tx) error) error {
+	if b.tx != nil {
+		return fn(b.tx)
+	}
+	return b.db.Update(fn)
+}
+
+func (b *noteBoltStorage) view(fn func(tx *bolt.Tx) error) error {
+	if b.tx != nil {
+		return fn(b.tx)
+	}
+	return b.db.View(fn)
+}
+
+// matchBoltNotes returns the notes matching filter ordered by id, id,
+// public id and title lookups go through the keys and the indexes instead
+// of a scan.
+func matchBoltNotes(ctx context.Context, tx *bolt.Tx, filter NoteFilter) ([]*model.Note, error) {
+	notes := make([]*model.Note, 0)
+	add := func(note *model.Note) {
+		if note != nil && filter.Match(note) {
+			notes = append(notes, note)
+		}
+	}
+
+	switch {
+	case filter.ID != nil:
+		note, err := getBoltNote(tx, *filter.ID)
+		add(note)
+		return notes, err
+	case filter.PublicID != nil:
+		id := tx.Bucket(notePublicIDBucket).Get([]byte(*filter.PublicID))
+		if id == nil {
+			return notes, nil
+		}
+		note, err := getBoltNote(tx, btoi(id))
+		add(note)
+		return notes, err
+	case filter.Title != nil && filter.OwnerID != nil && filter.liveOnly():
+		id := tx.Bucket(noteTitleBucket).Get(boltTitleKey(&model.Note{OwnerID: *filter.OwnerID, Title: *filter.Title}))
+		if id == nil {
+			return notes, nil
+		}
+		note, err := getBoltNote(tx, btoi(id))
+		add(note)
+		return notes, err
+	}
+
+	err := tx.Bucket(noteBucket).ForEach(func(k, v []byte) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		note, err := decodeBoltNote(v)
+		if err != nil {
+			return err
+		}
+		add(note)
+		return nil
+	})
+	return notes, err
+}
+
+func getBoltNote(tx *bolt.Tx, id uint) (*model.Note, error) {
+	data := tx.Bucket(noteBucket).Get(itob(id))
+	if data == nil {
+		return nil, nil
+	}
+	return decodeBoltNote(data)
+}
+
+// boltNote is the JSON of a stored note, the JSON of model.Note leaves the
+// id and the owner out and names the public id "id".
+type boltNote struct {
+	boltNoteFields
+	ID       uint   `json:"id"`
+	PublicID string `json:"public_id"`
+	OwnerID  uint   `json:"owner_id"`
+}
+
+// boltNoteFields is model.Note without its JSON methods.
+type boltNoteFields model.Note
+
+func decodeBoltNote(data []byte) (*model.Note, error) {
+	var stored boltNote
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+	note := model.Note(stored.boltNoteFields)
+	note.ID, note.PublicID, note.OwnerID = stored.ID, stored.PublicID, stored.OwnerID
+	return &note, nil
+}
+
+// putBoltNote writes the note with its public id index entry and, unless
+// it is in the trash, its title index entry.
+func putBoltNote(tx *bolt.Tx, note *model.Note) error {
+	data, err := json.Marshal(boltNote{boltNoteFields(*note), note.ID, note.PublicID, note.OwnerID})
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(noteBucket).Put(itob(note.ID), data); err != nil {
+		return err
+	}
+	if err := tx.Bucket(notePublicIDBucket).Put([]byte(note.PublicID), itob(note.ID)); err != nil {
+		return err
+	}
+	if note.DeletedAt != nil {
+		return nil
+	}
+	return tx.Bucket(noteTitleBucket).Put(boltTitleKey(note), itob(note.ID))
+}
+
+// boltTitleKey is the key of note in the title index, its owner then its
+// title.
+func boltTitleKey(note *model.Note) []byte {
+	return append(itob(note.OwnerID), note.Title...)
+}
+
+// indexBoltTitles fills the title index from the notes outside of the
+// trash.
+func indexBoltTitles(tx *bolt.Tx) error {
+	titles := tx.Bucket(noteTitleBucket)
+	return tx.Bucket(noteBucket).ForEach(func(k, v []byte) error {
+		note, err := decodeBoltNote(v)
+		if err != nil || note.DeletedAt != nil {
+			return err
+		}
+		return titles.Put(boltTitleKey(note), k)
+	})
+}
+
+// backfillBoltNotes gives the notes without a public id one dated from
+// their creation, and the notes without a version the first one.
+func backfillBoltNotes(tx *bolt.Tx) error {
+	var notes []*model.Note
+	err := tx.Bucket(noteBucket).ForEach(func(k, v []byte) error {
+		note, err := decodeBoltNote(v)
+		if err == nil && (note.PublicID == "" || note.Version == 0) {
+			notes = append(notes, note)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	for _, note := range notes {
+		if note.PublicID == "" {
+			note.PublicID = ulid.NewAt(note.CreatedAt).String()
+		}
+		if note.Version == 0 {
+			note.Version = 1
+		}
+		if err := putBoltNote(tx, note); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// itob encodes ids big endian so bolt keeps them sorted.
+func itob(id uint) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(id))
+	return b
+}
+
+func btoi(b []byte) uint {
+	return uint(binary.BigEndian.Uint64(b))
+}
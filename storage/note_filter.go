@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"github.com/lyquocnam/go-note-learning/model"
+	"strings"
+	"time"
+)
+
+// NoteFilter selects notes in a backend neutral way, every field that is
+// set must match. The zero value matches every note that is not deleted.
+//
+//	filter := storage.NoteFilter{}.WithTitlePrefix("todo").WithCompleted(false)
+type NoteFilter struct {
+	ID             *uint
+	OwnerID        *uint // the storages set it from the context
+	PublicID       *string
+	PublicIDs      []string // one of them, nil skips the check
+	Title          *string
+	TitlePrefix    *string
+	TitleContains  *string // case insensitive
+	IsCompleted    *bool
+	Tags           []string // every tag, or one of them when AnyTag
+	AnyTag         bool
+	CreatedAfter   *time.Time
+	CreatedBefore  *time.Time
+	UpdatedAfter   *time.Time
+	UpdatedBefore  *time.Time
+	DeletedBefore  *time.Time // only matches notes in the trash
+	IncludeDeleted bool
+	Trashed        bool // only notes in the trash
+}
+
+func (f NoteFilter) WithID(id uint) NoteFilter {
+	f.ID = &id
+	return f
+}
+
+// WithOwner keeps the notes of the user id.
+func (f NoteFilter) WithOwner(id uint) NoteFilter {
+	f.OwnerID = &id
+	return f
+}
+
+func (f NoteFilter) WithPublicID(id string) NoteFilter {
+	f.PublicID = &id
+	return f
+}
+
+// WithPublicIDs keeps the notes whose public id is one of ids, no id
+// matches no note.
+func (f NoteFilter) WithPublicIDs(ids ...string) NoteFilter {
+	f.PublicIDs = append([]string{}, ids...)
+	return f
+}
+
+func (f NoteFilter) WithTitle(title string) NoteFilter {
+	f.Title = &title
+	return f
+}
+
+func (f NoteFilter) WithTitlePrefix(prefix string) NoteFilter {
+	f.TitlePrefix = &prefix
+	return f
+}
+
+func (f NoteFilter) WithTitleContains(substring string) NoteFilter {
+	f.TitleContains = &substring
+	return f
+}
+
+func (f NoteFilter) WithCompleted(isCompleted bool) NoteFilter {
+	f.IsCompleted = &isCompleted
+	return f
+}
+
+// WithTags keeps the notes having every tag of tags.
+func (f NoteFilter) WithTags(tags ...string) NoteFilter {
+	f.Tags, f.AnyTag = tags, false
+	return f
+}
+
+// WithAnyTag keeps the notes having at least one tag of tags.
+func (f NoteFilter) WithAnyTag(tags ...string) NoteFilter {
+	f.Tags, f.AnyTag = tags, true
+	return f
+}
+
+// WithCreatedBetween keeps notes created strictly between after and before,
+// a zero time leaves that side open.
+func (f NoteFilter) WithCreatedBetween(after, before time.Time) NoteFilter {
+	f.CreatedAfter, f.CreatedBefore = timeOrNil(after), timeOrNil(before)
+	return f
+}
+
+// WithUpdatedBetween is WithCreatedBetween for UpdatedAt.
+func (f NoteFilter) WithUpdatedBetween(after, before time.Time) NoteFilter {
+	f.UpdatedAfter, f.UpdatedBefore = timeOrNil(after), timeOrNil(before)
+	return f
+}
+
+// WithDeleted also matches soft deleted notes.
+func (f NoteFilter) WithDeleted() NoteFilter {
+	f.IncludeDeleted = true
+	return f
+}
+
+// InTrash only matches soft deleted notes.
+func (f NoteFilter) InTrash() NoteFilter {
+	f.Trashed = true
+	return f
+}
+
+// WithDeletedBefore keeps the notes moved to the trash before before.
+func (f NoteFilter) WithDeletedBefore(before time.Time) NoteFilter {
+	f.Trashed = true
+	f.DeletedBefore = &before
+	return f
+}
+
+func (f NoteFilter) liveOnly() bool {
+	return !f.IncludeDeleted && !f.Trashed
+}
+
+// Match evaluates the filter in Go, it is used by the backends that can't
+// translate it into a query.
+func (f NoteFilter) Match(note *model.Note) bool {
+	switch {
+	case f.liveOnly() && note.DeletedAt != nil:
+		return false
+	case f.Trashed && note.DeletedAt == nil:
+		return false
+	case f.DeletedBefore != nil && (note.DeletedAt == nil || !note.DeletedAt.Before(*f.DeletedBefore)):
+		return false
+	case f.ID != nil && note.ID != *f.ID:
+		return false
+	case f.OwnerID != nil && note.OwnerID != *f.OwnerID:
+		return false
+	case f.PublicID != nil && note.PublicID != *f.PublicID:
+		return false
+	case f.PublicIDs != nil && !containsString(f.PublicIDs, note.PublicID):
+		return false
+	case f.Title != nil && note.Title != *f.Title:
+		return false
+	case f.TitlePrefix != nil && !strings.HasPrefix(note.Title, *f.TitlePrefix):
+		return false
+	case f.TitleContains != nil && !strings.Contains(strings.ToLower(note.Title), strings.ToLower(*f.TitleContains)):
+		return false
+	case f.IsCompleted != nil && note.IsCompleted != *f.IsCompleted:
+		return false
+	case len(f.Tags) > 0 && !f.matchTags(note.Tags):
+		return false
+	case f.CreatedAfter != nil && !note.CreatedAt.After(*f.CreatedAfter):
+		return false
+	case f.CreatedBefore != nil && !note.CreatedAt.Before(*f.CreatedBefore):
+		return false
+	case f.UpdatedAfter != nil && !note.UpdatedAt.After(*f.UpdatedAfter):
+		return false
+	case f.UpdatedBefore != nil && !note.UpdatedAt.Before(*f.UpdatedBefore):
+		return false
+	}
+	return true
+}
+
+func (f NoteFilter) matchTags(tags []string) bool {
+	for _, tag := range f.Tags {
+		if hasTag(tags, tag) == f.AnyTag {
+			return f.AnyTag
+		}
+	}
+	return !f.AnyTag
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
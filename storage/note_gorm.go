@@ -0,0 +1,597 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	"github.com/lyquocnam/go-note-learning/model"
+	"github.com/lyquocnam/go-note-learning/ulid"
+	"math"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+type noteGormStorage struct {
+	db      *gorm.DB
+	logMode bool
+	tx      *gorm.DB
+	// txMu serializes WithTx on sqlite, a transaction that read can't
+	// start to write while another one is writing
+	txMu sync.Mutex
+}
+
+// NewNoteGormStorage works with any gorm dialect, it is used for both
+// postgres and sqlite.
+func NewNoteGormStorage(db *gorm.DB) *noteGormStorage {
+	return &noteGormStorage{db: db}
+}
+
+// MigrateGorm creates or updates the tables used by noteGormStorage and
+// userGormStorage.
+func MigrateGorm(db *gorm.DB) error {
+	if err := db.AutoMigrate(model.Note{}, model.Tag{}, gormNoteTag{}, model.User{}, model.RefreshToken{}, model.Share{}, model.Comment{}).Error; err != nil {
+		return err
+	}
+	if err := dropGormTitleConstraint(db); err != nil {
+		return err
+	}
+	if err := backfillGormPublicIDs(db); err != nil {
+		return err
+	}
+	// the rows stored before versions existed got the column default, 0
+	// would read as "no precondition" once sent back in If-Match
+	if err := db.Exec("UPDATE notes SET version = 1 WHERE version = 0 OR version IS NULL").Error; err != nil {
+		return err
+	}
+	// titles are only unique among the notes of a user outside of the trash,
+	// postgres and sqlite both support partial indexes
+	err := db.Exec("DROP INDEX IF EXISTS idx_notes_live_title").Error
+	if err == nil {
+		err = db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_notes_live_owner_title ON notes (owner_id, title) WHERE deleted_at IS NULL").Error
+	}
+	if err != nil || db.Dialect().GetName() != "postgres" {
+		return err
+	}
+	return migratePostgresSearch(db)
+}
+
+// dropGormTitleConstraint removes the unique constraint older versions put
+// on title, it also covered the notes in the trash.
+func dropGormTitleConstraint(db *gorm.DB) error {
+	switch db.Dialect().GetName() {
+	case "postgres":
+		return db.Exec("ALTER TABLE notes DROP CONSTRAINT IF EXISTS notes_title_key").Error
+	case "sqlite3":
+		var count int
+		err := db.Raw("SELECT count(*) FROM sqlite_master WHERE type = 'index' AND tbl_name = 'notes' AND name LIKE 'sqlite_autoindex_%'").Row().Scan(&count)
+		if err != nil || count == 0 {
+			return err
+		}
+		return rebuildSqliteNotes(db)
+	}
+	return nil
+}
+
+// backfillGormPublicIDs gives the rows stored before public ids existed
+// one dated from their creation.
+func backfillGormPublicIDs(db *gorm.DB) error {
+	var rows []struct {
+		ID        uint
+		CreatedAt *time.Time
+	}
+	err := db.Raw("SELECT id, created_at FROM notes WHERE public_id IS NULL OR public_id = ''").Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		createdAt := time.Now()
+		if row.CreatedAt != nil {
+			createdAt = *row.CreatedAt
+		}
+		err := db.Exec("UPDATE notes SET public_id = ? WHERE id = ?", ulid.NewAt(createdAt).String(), row.ID).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rebuildSqliteNotes recreates the notes table from the model, sqlite
+// can't drop a column constraint.
+func rebuildSqliteNotes(db *gorm.DB) error {
+	tx := db.Begin()
+	if err := tx.Error; err != nil {
+		return err
+	}
+	// the indexes keep their name on notes_old, AutoMigrate would not be
+	// able to create them on the new table
+	err := tx.Exec("DROP INDEX IF EXISTS idx_notes_deleted_at").Error
+	if err == nil {
+		err = tx.Exec("DROP INDEX IF EXISTS uix_notes_public_id").Error
+	}
+	if err == nil {
+		err = tx.Exec("ALTER TABLE notes RENAME TO notes_old").Error
+	}
+	if err == nil {
+		err = tx.AutoMigrate(model.Note{}).Error
+	}
+	if err == nil {
+		err = tx.Exec(`INSERT INTO notes (id, created_at, updated_at, deleted_at, title, is_completed)
+			SELECT id, created_at, updated_at, deleted_at, title, is_completed FROM notes_old`).Error
+	}
+	if err == nil {
+		err = tx.Exec("DROP TABLE notes_old").Error
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// LogMode prints every statement the storage runs, like gorm.DB.LogMode.
+func (n *noteGormStorage) LogMode(enable bool) *noteGormStorage {
+	n.logMode = enable
+	return n
+}
+
+// conn returns a gorm.DB running its statements with ctx, so a cancelled
+// request or an expired deadline also cancels the query.
+func (n *noteGormStorage) conn(ctx context.Context) (*gorm.DB, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if n.tx != nil {
+		return n.tx, nil
+	}
+	return n.open(ctx, nil)
+}
+
+func (n *noteGormStorage) open(ctx context.Context, opts *sql.TxOptions) (*gorm.DB, error) {
+	return openGormContext(ctx, n.db, opts, n.logMode)
+}
+
+// openGormContext returns a gorm.DB on the connections of db whose
+// statements run with ctx and whose transactions start with opts.
+func openGormContext(ctx context.Context, db *gorm.DB, opts *sql.TxOptions, logMode bool) (*gorm.DB, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	conn, err := gorm.Open(db.Dialect().GetName(), &contextDB{ctx: ctx, db: db.DB(), opts: opts})
+	if err != nil {
+		return nil, err
+	}
+	return conn.LogMode(logMode), nil
+}
+
+func (n *noteGormStorage) Get(ctx context.Context, id uint) (*model.Note, error) {
+	return n.Find(ctx, NoteFilter{}.WithID(id))
+}
+
+func (n *noteGormStorage) Find(ctx context.Context, filter NoteFilter) (*model.Note, error) {
+	filter, err := scopedFilter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	db, err := n.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var note model.Note
+	err = applyGormFilter(db, filter).Order("id").First(&note).Error
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, nil
+		}
+		return &note, err
+	}
+	return &note, loadGormTags(db, &note)
+}
+
+func (n *noteGormStorage) GetList(ctx context.Context, filter NoteFilter, opts NoteListOptions) ([]*model.Note, error) {
+	filter, err := scopedFilter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	db, err := n.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var notes []*model.Note
+	err = applyGormListOptions(applyGormFilter(db, filter), opts).Find(&notes).Error
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, nil
+		}
+		return notes, err
+	}
+	return notes, loadGormTags(db, notes...)
+}
+
+func (n *noteGormStorage) Insert(ctx context.Context, note *model.Note) (*model.Note, error) {
+	if n.tx == nil && len(note.Tags) > 0 {
+		id := note.ID
+		err := n.WithTx(ctx, func(s NoteStorage) error {
+			// a retried attempt must not reuse the id of the rolled back one
+			note.ID = id
+			_, err := s.Insert(ctx, note)
+			return err
+		})
+		return note, err
+	}
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return note, err
+	}
+	db, err := n.conn(ctx)
+	if err != nil {
+		return note, err
+	}
+
+	scope.assign(note)
+	assignPublicID(note)
+	note.Version = 1
+	err = db.Create(&note).Error
+	if err == nil && len(note.Tags) > 0 {
+		err = saveGormTags(db, note.ID, note.Tags)
+	}
+	return note, gormError(err)
+}
+
+func (n *noteGormStorage) Update(ctx context.Context, id uint, note *model.Note) (*model.Note, error) {
+	if n.tx == nil {
+		version := note.Version
+		err := n.WithTx(ctx, func(s NoteStorage) error {
+			// a retried attempt starts again from the version of the caller
+			note.Version = version
+			_, err := s.Update(ctx, id, note)
+			return err
+		})
+		return note, err
+	}
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return note, err
+	}
+	db, err := n.conn(ctx)
+	if err != nil {
+		return note, err
+	}
+
+	// Save can't carry the version condition, it falls back to an insert
+	// when no row is updated
+	version := note.Version
+	note.ID = id
+	result := gormScope(db.Model(note), scope).Where("version = ?", version).Updates(map[string]interface{}{
+		"title":        note.Title,
+		"is_completed": note.IsCompleted,
+		"content":      note.Content,
+		"version":      version + 1,
+	})
+	if result.Error != nil || result.RowsAffected == 0 {
+		// Updates already copied the new version into note
+		note.Version = version
+	}
+	if result.Error != nil {
+		return note, gormError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		count := 0
+		if err := gormScope(db.Model(model.Note{}), scope).Where("id = ?", id).Count(&count).Error; err != nil {
+			return note, err
+		}
+		if count == 0 {
+			return note, fmt.Errorf("storage: note %d does not exist", id)
+		}
+		return note, ErrVersionConflict
+	}
+	if err := saveGormTags(db, id, note.Tags); err != nil {
+		return note, err
+	}
+	// the public id and the owner are not updated, the caller may not have
+	// set them
+	var current model.Note
+	if err := db.Select("public_id, owner_id").Where("id = ?", id).First(&current).Error; err != nil {
+		return note, err
+	}
+	note.PublicID, note.OwnerID = current.PublicID, current.OwnerID
+	note.Version = version + 1
+	return note, nil
+}
+
+func (n *noteGormStorage) Delete(ctx context.Context, note *model.Note) error {
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return err
+	}
+	db, err := n.conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = gormScope(db.Model(note), scope).UpdateColumn("deleted_at", now).Error
+	if err == nil {
+		note.DeletedAt = &now
+	}
+	return err
+}
+
+func (n *noteGormStorage) Restore(ctx context.Context, note *model.Note) error {
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return err
+	}
+	db, err := n.conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	result := gormScope(db.Unscoped().Model(note), scope).UpdateColumn("deleted_at", gorm.Expr("NULL"))
+	if result.Error != nil {
+		return gormError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("storage: note %d does not exist", note.ID)
+	}
+	note.DeletedAt = nil
+	return nil
+}
+
+func (n *noteGormStorage) Purge(ctx context.Context, note *model.Note) error {
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return err
+	}
+	return n.inTx(ctx, func(n *noteGormStorage) error {
+		db, err := n.conn(ctx)
+		if err != nil {
+			return err
+		}
+		result := gormScope(db.Unscoped(), scope).Delete(note)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		if err := db.Where("note_id = ?", note.ID).Delete(gormNoteTag{}).Error; err != nil {
+			return err
+		}
+		return deleteOrphanGormTags(db)
+	})
+}
+
+func (n *noteGormStorage) Count(ctx context.Context, filter NoteFilter) (int, error) {
+	filter, err := scopedFilter(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	db, err := n.conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = applyGormFilter(db.Model(model.Note{}), filter).Count(&count).Error
+	return count, err
+}
+
+func (n *noteGormStorage) AdoptNotes(ctx context.Context, owner uint) (int, error) {
+	db, err := n.conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	result := db.Exec(`UPDATE notes SET owner_id = ? WHERE owner_id = 0 AND (deleted_at IS NOT NULL OR NOT EXISTS (
+		SELECT 1 FROM notes AS taken WHERE taken.owner_id = ? AND taken.deleted_at IS NULL AND taken.title = notes.title))`, owner, owner)
+	return int(result.RowsAffected), result.Error
+}
+
+func (n *noteGormStorage) Batch(ctx context.Context, ops []NoteOp, atomic bool) ([]NoteOpResult, error) {
+	return batchNotes(ctx, n, ops, atomic)
+}
+
+const gormTxAttempts = 3
+
+// WithTx runs fn in a serializable transaction, it is retried when the
+// database aborts it to keep the transactions serializable.
+func (n *noteGormStorage) WithTx(ctx context.Context, fn func(s NoteStorage) error) error {
+	if n.tx != nil {
+		return fn(n)
+	}
+	if n.db.Dialect().GetName() == "sqlite3" {
+		n.txMu.Lock()
+		defer n.txMu.Unlock()
+	}
+
+	var err error
+	for attempt := 1; attempt <= gormTxAttempts; attempt++ {
+		err = n.runTx(ctx, fn)
+		if !isGormSerializationFailure(err) {
+			return err
+		}
+		if attempt < gormTxAttempts {
+			// give the conflicting transaction time to finish
+			time.Sleep(time.Duration(attempt) * 10 * time.Millisecond)
+		}
+	}
+	return err
+}
+
+func (n *noteGormStorage) runTx(ctx context.Context, fn func(s NoteStorage) error) error {
+	db, err := n.open(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return err
+	}
+	tx := db.Begin()
+	if err := tx.Error; err != nil {
+		return err
+	}
+	if err := fn(&noteGormStorage{db: n.db, logMode: n.logMode, tx: tx}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// applyGormFilter translates filter into gorm conditions.
+func applyGormFilter(db *gorm.DB, filter NoteFilter) *gorm.DB {
+	if !filter.liveOnly() {
+		db = db.Unscoped()
+	}
+	if filter.Trashed {
+		db = db.Where("deleted_at IS NOT NULL")
+	}
+	if filter.DeletedBefore != nil {
+		db = db.Where("deleted_at < ?", *filter.DeletedBefore)
+	}
+	if filter.ID != nil {
+		db = db.Where("id = ?", *filter.ID)
+	}
+	if filter.OwnerID != nil {
+		db = db.Where("owner_id = ?", *filter.OwnerID)
+	}
+	if filter.PublicID != nil {
+		db = db.Where("public_id = ?", *filter.PublicID)
+	}
+	if filter.PublicIDs != nil {
+		if len(filter.PublicIDs) == 0 {
+			return db.Where("1 = 0")
+		}
+		db = db.Where("public_id IN (?)", filter.PublicIDs)
+	}
+	if filter.Title != nil {
+		db = db.Where("title = ?", *filter.Title)
+	}
+	if filter.TitlePrefix != nil {
+		// LIKE is case insensitive on sqlite, substr behaves the same everywhere
+		prefix := *filter.TitlePrefix
+		db = db.Where("substr(title, 1, ?) = ?", utf8.RuneCountInString(prefix), prefix)
+	}
+	if filter.TitleContains != nil {
+		db = db.Where(`LOWER(title) LIKE ? ESCAPE '\'`, "%"+escapeLike(strings.ToLower(*filter.TitleContains))+"%")
+	}
+	if filter.IsCompleted != nil {
+		db = db.Where("is_completed = ?", *filter.IsCompleted)
+	}
+	if len(filter.Tags) > 0 {
+		query, args := gormTagCondition(filter.Tags, filter.AnyTag)
+		db = db.Where(query, args...)
+	}
+	if filter.CreatedAfter != nil {
+		db = db.Where("created_at > ?", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		db = db.Where("created_at < ?", *filter.CreatedBefore)
+	}
+	if filter.UpdatedAfter != nil {
+		db = db.Where("updated_at > ?", *filter.UpdatedAfter)
+	}
+	if filter.UpdatedBefore != nil {
+		db = db.Where("updated_at < ?", *filter.UpdatedBefore)
+	}
+	return db
+}
+
+// gormScope restricts a statement on notes to the notes of scope.
+func gormScope(db *gorm.DB, scope noteScope) *gorm.DB {
+	if scope.all {
+		return db
+	}
+	return db.Where("owner_id = ?", scope.owner)
+}
+
+// applyGormListOptions orders and pages a query, After becomes a keyset
+// condition on (sort field, public_id).
+func applyGormListOptions(db *gorm.DB, opts NoteListOptions) *gorm.DB {
+	field := opts.sortBy()
+	direction, operator := "ASC", ">"
+	if opts.Desc {
+		direction, operator = "DESC", "<"
+	}
+
+	if opts.After != nil {
+		if field == NoteSortID {
+			db = db.Where("public_id "+operator+" ?", opts.After.PublicID)
+		} else {
+			value := opts.After.Value()
+			db = db.Where(fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND public_id %[2]s ?))", field, operator), value, value, opts.After.PublicID)
+		}
+	}
+	if field != NoteSortID {
+		db = db.Order(field + " " + direction)
+	}
+	db = db.Order("public_id " + direction)
+	if opts.Offset > 0 {
+		db = db.Offset(opts.Offset)
+	}
+	if opts.Limit > 0 {
+		db = db.Limit(opts.Limit)
+	} else if opts.Offset > 0 {
+		// sqlite doesn't accept an OFFSET without a LIMIT
+		db = db.Limit(math.MaxInt32)
+	}
+	return db
+}
+
+// gormError translates the violation of idx_notes_live_owner_title into
+// ErrDuplicateTitle.
+func gormError(err error) error {
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" && pqErr.Constraint == "idx_notes_live_owner_title" {
+		return ErrDuplicateTitle
+	}
+	// the sqlite driver needs cgo, its error is matched on the message
+	if err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed: notes.owner_id, notes.title") {
+		return ErrDuplicateTitle
+	}
+	return err
+}
+
+func isGormSerializationFailure(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// serialization_failure and deadlock_detected
+		return pqErr.Code == "40001" || pqErr.Code == "40P01"
+	}
+	// sqlite refuses a second writer with this message
+	return err != nil && strings.Contains(err.Error(), "database is locked")
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func escapeLike(value string) string {
+	return likeEscaper.Replace(value)
+}
+
+// contextDB is the gorm.SQLCommon gorm runs statements on, gorm v1 has no
+// context support so every call is forwarded to its *Context variant.
+type contextDB struct {
+	ctx  context.Context
+	db   *sql.DB
+	opts *sql.TxOptions
+}
+
+func (c *contextDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.db.ExecContext(c.ctx, query, args...)
+}
+
+func (c *contextDB) Prepare(query string) (*sql.Stmt, error) {
+	return c.db.PrepareContext(c.ctx, query)
+}
+
+func (c *contextDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.db.QueryContext(c.ctx, query, args...)
+}
+
+func (c *contextDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.db.QueryRowContext(c.ctx, query, args...)
+}
+
+// Begin lets gorm.DB.Begin start a transaction bound to ctx.
+func (c *contextDB) Begin() (*sql.Tx, error) {
+	return c.db.BeginTx(c.ctx, c.opts)
+}
@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"context"
+	"github.com/jinzhu/gorm"
+	"github.com/lyquocnam/go-note-learning/model"
+	"sort"
+	"time"
+)
+
+// gormNoteTag links a note to one of its tags, the tags table only holds
+// the tags at least one note has.
+type gormNoteTag struct {
+	NoteID uint `gorm:"primary_key;auto_increment:false"`
+	TagID  uint `gorm:"primary_key;auto_increment:false;index"`
+}
+
+func (gormNoteTag) TableName() string {
+	return "note_tags"
+}
+
+// gormTagsChunk bounds the note ids loadGormTags puts in one query, sqlite
+// accepts 999 parameters.
+const gormTagsChunk = 500
+
+// inTx runs fn in a transaction unless n already is one, the writes
+// spanning notes and note_tags must not be seen half done.
+func (n *noteGormStorage) inTx(ctx context.Context, fn func(n *noteGormStorage) error) error {
+	if n.tx != nil {
+		return fn(n)
+	}
+	return n.WithTx(ctx, func(s NoteStorage) error {
+		return fn(s.(*noteGormStorage))
+	})
+}
+
+func (n *noteGormStorage) Tags(ctx context.Context) ([]*model.Tag, error) {
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	db, err := n.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Count is not a column of tags, gorm would not scan it into model.Tag
+	var rows []struct {
+		Name  string
+		Count int
+	}
+	query := gormScope(db.Table("tags").
+		Select("tags.name, COUNT(*) AS count").
+		Joins("JOIN note_tags ON note_tags.tag_id = tags.id").
+		Joins("JOIN notes ON notes.id = note_tags.note_id").
+		Where("notes.deleted_at IS NULL"), scope)
+	err = query.Group("tags.name").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// sorted here, the collation of the database may not sort like Go does
+	tags := make([]*model.Tag, len(rows))
+	for i, row := range rows {
+		tags[i] = &model.Tag{Name: row.Name, Count: row.Count}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Name < tags[j].Name
+	})
+	return tags, nil
+}
+
+// RenameTag moves the notes of from over to to, the tag rows are shared
+// by every user so from is only deleted once no note has it anymore.
+func (n *noteGormStorage) RenameTag(ctx context.Context, from, to string) (int, error) {
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	err = n.inTx(ctx, func(n *noteGormStorage) error {
+		db := n.tx
+		var source model.Tag
+		if err := db.Where("name = ?", from).First(&source).Error; err != nil {
+			if gorm.IsRecordNotFoundError(err) {
+				return nil
+			}
+			return err
+		}
+
+		// the links to move, the ones of the notes of scope
+		links := "tag_id = ?"
+		args := []interface{}{source.ID}
+		if !scope.all {
+			links += " AND note_id IN (SELECT id FROM notes WHERE owner_id = ?)"
+			args = append(args, scope.owner)
+		}
+
+		result := db.Exec("UPDATE notes SET version = version + 1, updated_at = ? WHERE id IN (SELECT note_id FROM note_tags WHERE "+links+")",
+			append([]interface{}{time.Now()}, args...)...)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		count = int(result.RowsAffected)
+
+		target := model.Tag{Name: to}
+		if err := db.Where(model.Tag{Name: to}).FirstOrCreate(&target).Error; err != nil {
+			return err
+		}
+		err := db.Exec("DELETE FROM note_tags WHERE "+links+" AND note_id IN (SELECT note_id FROM note_tags WHERE tag_id = ?)",
+			append(args, target.ID)...).Error
+		if err == nil {
+			err = db.Exec("UPDATE note_tags SET tag_id = ? WHERE "+links, append([]interface{}{target.ID}, args...)...).Error
+		}
+		if err == nil {
+			err = deleteOrphanGormTags(db)
+		}
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func saveGormTags(db *gorm.DB, id uint, tags []string) error {
+	if err := db.Where("note_id = ?", id).Delete(gormNoteTag{}).Error; err != nil {
+		return err
+	}
+	for _, name := range tags {
+		tag := model.Tag{Name: name}
+		if err := db.Where(model.Tag{Name: name}).FirstOrCreate(&tag).Error; err != nil {
+			return err
+		}
+		if err := db.Create(&gormNoteTag{NoteID: id, TagID: tag.ID}).Error; err != nil {
+			return err
+		}
+	}
+	return deleteOrphanGormTags(db)
+}
+
+func deleteOrphanGormTags(db *gorm.DB) error {
+	return db.Exec("DELETE FROM tags WHERE id NOT IN (SELECT tag_id FROM note_tags)").Error
+}
+
+func loadGormTags(db *gorm.DB, notes ...*model.Note) error {
+	byID := make(map[uint]*model.Note, len(notes))
+	for _, note := range notes {
+		note.Tags = nil
+		byID[note.ID] = note
+	}
+	for start := 0; start < len(notes); start += gormTagsChunk {
+		end := start + gormTagsChunk
+		if end > len(notes) {
+			end = len(notes)
+		}
+		ids := make([]uint, 0, end-start)
+		for _, note := range notes[start:end] {
+			ids = append(ids, note.ID)
+		}
+
+		var rows []struct {
+			NoteID uint
+			Name   string
+		}
+		err := db.Raw(`SELECT note_tags.note_id, tags.name FROM note_tags
+			JOIN tags ON tags.id = note_tags.tag_id
+			WHERE note_tags.note_id IN (?)`, ids).Scan(&rows).Error
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			note := byID[row.NoteID]
+			note.Tags = append(note.Tags, row.Name)
+		}
+	}
+	for _, note := range notes {
+		sort.Strings(note.Tags)
+	}
+	return nil
+}
+
+func gormTagCondition(tags []string, any bool) (string, []interface{}) {
+	query := "id IN (SELECT note_tags.note_id FROM note_tags JOIN tags ON tags.id = note_tags.tag_id WHERE tags.name IN (?)"
+	if any {
+		return query + ")", []interface{}{tags}
+	}
+	distinct := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		distinct[tag] = true
+	}
+	return query + " GROUP BY note_tags.note_id HAVING COUNT(*) = ?)", []interface{}{tags, len(distinct)}
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/lyquocnam/go-note-learning/model"
+	"sync"
+)
+
+// noteJournal emulates a transaction on the storages that have none, it
+// records how to undo every write made through it. Purges are put off
+// until fn succeeded, a purged note can't be brought back.
+type noteJournal struct {
+	NoteStorage
+	undo   []func(ctx context.Context) error
+	purges []*model.Note
+}
+
+// journalTx runs fn on a journal of s and undoes its writes in reverse
+// order when fn fails. mu keeps the units of work of s from interleaving,
+// writes made outside of WithTx or by another process are not isolated
+// from them.
+func journalTx(ctx context.Context, mu *sync.Mutex, s NoteStorage, fn func(s NoteStorage) error) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	j := &noteJournal{NoteStorage: s}
+	err := fn(j)
+	if err == nil {
+		for _, note := range j.purges {
+			if err = s.Purge(ctx, note); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			return nil
+		}
+	}
+
+	// the undo must run even when ctx is the reason fn failed, it only
+	// touches notes fn could write
+	undoCtx := AllOwners(context.Background())
+	for i := len(j.undo) - 1; i >= 0; i-- {
+		if undoErr := j.undo[i](undoCtx); undoErr != nil {
+			return fmt.Errorf("storage: can't undo the unit of work (%v): %w", undoErr, err)
+		}
+	}
+	return err
+}
+
+func (j *noteJournal) Insert(ctx context.Context, note *model.Note) (*model.Note, error) {
+	note, err := j.NoteStorage.Insert(ctx, note)
+	if err == nil {
+		inserted := copyNote(note)
+		j.undo = append(j.undo, func(ctx context.Context) error {
+			return j.NoteStorage.Purge(ctx, inserted)
+		})
+	}
+	return note, err
+}
+
+// Update brings back the fields of the previous note on undo, its version
+// keeps increasing.
+func (j *noteJournal) Update(ctx context.Context, id uint, note *model.Note) (*model.Note, error) {
+	previous, err := j.NoteStorage.Get(ctx, id)
+	if err != nil {
+		return note, err
+	}
+	note, err = j.NoteStorage.Update(ctx, id, note)
+	if err == nil && previous != nil {
+		version := note.Version
+		j.undo = append(j.undo, func(ctx context.Context) error {
+			restored := copyNote(previous)
+			restored.Version = version
+			_, err := j.NoteStorage.Update(ctx, id, restored)
+			return err
+		})
+	}
+	return note, err
+}
+
+func (j *noteJournal) Delete(ctx context.Context, note *model.Note) error {
+	current, err := j.NoteStorage.Get(ctx, note.ID)
+	if err != nil {
+		return err
+	}
+	if err := j.NoteStorage.Delete(ctx, note); err != nil || current == nil {
+		return err
+	}
+	j.undo = append(j.undo, func(ctx context.Context) error {
+		return j.NoteStorage.Restore(ctx, current)
+	})
+	return nil
+}
+
+func (j *noteJournal) Restore(ctx context.Context, note *model.Note) error {
+	current, err := j.NoteStorage.Find(ctx, NoteFilter{}.WithID(note.ID).InTrash())
+	if err != nil {
+		return err
+	}
+	if err := j.NoteStorage.Restore(ctx, note); err != nil || current == nil {
+		return err
+	}
+	j.undo = append(j.undo, func(ctx context.Context) error {
+		return j.NoteStorage.Delete(ctx, current)
+	})
+	return nil
+}
+
+func (j *noteJournal) Purge(ctx context.Context, note *model.Note) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	j.purges = append(j.purges, note)
+	return nil
+}
+
+// noteTagRestorer is implemented by the storages noteJournal wraps, it lets
+// the journal undo a RenameTag note by note.
+type noteTagRestorer interface {
+	restoreTags(ctx context.Context, id uint, tags []string) error
+}
+
+func (j *noteJournal) RenameTag(ctx context.Context, from, to string) (int, error) {
+	restorer, ok := j.NoteStorage.(noteTagRestorer)
+	if !ok {
+		return 0, fmt.Errorf("storage: %T can't undo a tag rename", j.NoteStorage)
+	}
+	previous, err := j.NoteStorage.GetList(ctx, NoteFilter{}.WithDeleted().WithTags(from), NoteListOptions{})
+	if err != nil {
+		return 0, err
+	}
+	count, err := j.NoteStorage.RenameTag(ctx, from, to)
+	if count > 0 {
+		j.undo = append(j.undo, func(ctx context.Context) error {
+			for _, note := range previous {
+				if err := restorer.restoreTags(ctx, note.ID, note.Tags); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	return count, err
+}
+
+func (j *noteJournal) Batch(ctx context.Context, ops []NoteOp, atomic bool) ([]NoteOpResult, error) {
+	return batchNotes(ctx, j, ops, atomic)
+}
+
+func (j *noteJournal) WithTx(ctx context.Context, fn func(s NoteStorage) error) error {
+	return fn(j)
+}
@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/lyquocnam/go-note-learning/model"
+	"github.com/lyquocnam/go-note-learning/ulid"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	NoteSortID        = "id"
+	NoteSortCreatedAt = "created_at"
+	NoteSortUpdatedAt = "updated_at"
+	NoteSortTitle     = "title"
+)
+
+var ErrInvalidCursor = errors.New("storage: invalid cursor")
+
+// NoteListOptions orders and pages the notes returned by GetList. Notes
+// are ordered by SortBy then by public id so the order is always total,
+// NoteSortID is the order of the public ids, the order of creation.
+type NoteListOptions struct {
+	SortBy string // one of the NoteSort constants, id when empty
+	Desc   bool
+	Limit  int // no limit when 0
+	Offset int
+	After  *NoteCursor // keyset pagination, the listing continues after this position
+}
+
+// sortBy falls back to id for unknown fields, SortBy ends up in SQL.
+func (o NoteListOptions) sortBy() string {
+	switch o.SortBy {
+	case NoteSortCreatedAt, NoteSortUpdatedAt, NoteSortTitle:
+		return o.SortBy
+	}
+	return NoteSortID
+}
+
+// NoteCursor is the position of a note in a listing: its sort key and its
+// public id as tie breaker. It is handed to clients as an opaque string,
+// but only holds what the API shows anyway.
+type NoteCursor struct {
+	SortBy   string    `json:"s"`
+	Desc     bool      `json:"d,omitempty"`
+	PublicID string    `json:"i"`
+	Time     time.Time `json:"t,omitempty"`
+	Title    string    `json:"v,omitempty"`
+}
+
+// NewNoteCursor returns the position of note in a listing ordered by opts.
+func NewNoteCursor(note *model.Note, opts NoteListOptions) *NoteCursor {
+	cursor := &NoteCursor{SortBy: opts.sortBy(), Desc: opts.Desc, PublicID: note.PublicID}
+	switch cursor.SortBy {
+	case NoteSortCreatedAt:
+		cursor.Time = note.CreatedAt
+	case NoteSortUpdatedAt:
+		cursor.Time = note.UpdatedAt
+	case NoteSortTitle:
+		cursor.Title = note.Title
+	}
+	return cursor
+}
+
+// DecodeNoteCursor parses a cursor returned by NoteCursor.Encode.
+func DecodeNoteCursor(value string) (*NoteCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor NoteCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	id, err := ulid.Parse(cursor.PublicID)
+	if err != nil || id.String() != cursor.PublicID {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+func (c *NoteCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Value is the sort key stored in the cursor.
+func (c *NoteCursor) Value() interface{} {
+	switch c.SortBy {
+	case NoteSortCreatedAt, NoteSortUpdatedAt:
+		return c.Time
+	case NoteSortTitle:
+		return c.Title
+	}
+	return c.PublicID
+}
+
+// compareNotes orders a before b by field, ties are broken by public id.
+func compareNotes(a, b *model.Note, field string) int {
+	var result int
+	switch field {
+	case NoteSortCreatedAt:
+		result = compareTimes(a.CreatedAt, b.CreatedAt)
+	case NoteSortUpdatedAt:
+		result = compareTimes(a.UpdatedAt, b.UpdatedAt)
+	case NoteSortTitle:
+		result = strings.Compare(a.Title, b.Title)
+	}
+	if result != 0 {
+		return result
+	}
+	return strings.Compare(a.PublicID, b.PublicID)
+}
+
+func compareTimes(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+// pageNotes sorts and pages notes in Go for the backends that can't do it
+// in a query.
+func pageNotes(notes []*model.Note, opts NoteListOptions) []*model.Note {
+	field := opts.sortBy()
+	less := func(a, b *model.Note) bool {
+		if opts.Desc {
+			return compareNotes(a, b, field) > 0
+		}
+		return compareNotes(a, b, field) < 0
+	}
+	sort.Slice(notes, func(i, j int) bool {
+		return less(notes[i], notes[j])
+	})
+
+	if opts.After != nil {
+		position := &model.Note{PublicID: opts.After.PublicID, Title: opts.After.Title, CreatedAt: opts.After.Time, UpdatedAt: opts.After.Time}
+		start := sort.Search(len(notes), func(i int) bool {
+			return less(position, notes[i])
+		})
+		notes = notes[start:]
+	}
+	if opts.Offset > 0 {
+		if opts.Offset >= len(notes) {
+			return notes[:0]
+		}
+		notes = notes[opts.Offset:]
+	}
+	if opts.Limit > 0 && opts.Limit < len(notes) {
+		notes = notes[:opts.Limit]
+	}
+	return notes
+}
@@ -0,0 +1,788 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/lyquocnam/go-note-learning/model"
+	"github.com/lyquocnam/go-note-learning/ulid"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	markdownIndexFile   = ".index.yaml"
+	markdownExt         = ".md"
+	markdownSeparator   = "---\n"
+	markdownTitleLength = 80
+)
+
+var markdownSlugPattern = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+// markdownIndex maps every id to its file so Get doesn't need to scan the
+// directory.
+type markdownIndex struct {
+	NextID uint            `yaml:"next_id"`
+	Files  map[uint]string `yaml:"files"`
+}
+
+type markdownFrontMatter struct {
+	ID          uint       `yaml:"id"`
+	PublicID    string     `yaml:"public_id"`
+	OwnerID     uint       `yaml:"owner_id"`
+	CreatedAt   time.Time  `yaml:"created_at"`
+	UpdatedAt   time.Time  `yaml:"updated_at"`
+	DeletedAt   *time.Time `yaml:"deleted_at,omitempty"`
+	IsCompleted bool       `yaml:"is_completed"`
+	Tags        []string   `yaml:"tags,omitempty"`
+	Version     uint       `yaml:"version"`
+}
+
+// markdownEntry is a parsed note file, modTime and size tell whether the
+// file was edited outside of the storage since it was read.
+type markdownEntry struct {
+	file    string
+	modTime time.Time
+	size    int64
+	note    *model.Note
+}
+
+// noteMarkdownStorage keeps one Markdown file per note in dir:
+//
+//	---
+//	id: 1
+//	public_id: 01D7EX2VQ3AHDK6N2QXKWBS4JZ
+//	owner_id: 1
+//	created_at: 2019-04-01T10:00:00Z
+//	updated_at: 2019-04-01T10:00:00Z
+//	is_completed: false
+//	tags:
+//	- work
+//	---
+//	# Title
+//
+//	The content of the note.
+//
+// Files may be edited, added or removed by hand, the changes are picked up
+// on the next read. A file without front matter becomes a new note, a file
+// whose front matter can't be read is logged and skipped until it changes.
+// A copy of a note file becomes a new note, numbered like "Title (2)" when
+// its title is taken.
+type noteMarkdownStorage struct {
+	mu      sync.Mutex
+	txMu    sync.Mutex // serializes WithTx
+	dir     string
+	index   markdownIndex
+	cache   map[string]*markdownEntry
+	skipped map[string]*markdownEntry
+}
+
+func NewNoteMarkdownStorage(dir string) (*noteMarkdownStorage, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &noteMarkdownStorage{
+		dir:     dir,
+		index:   markdownIndex{Files: make(map[uint]string)},
+		cache:   make(map[string]*markdownEntry),
+		skipped: make(map[string]*markdownEntry),
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, markdownIndexFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := yaml.Unmarshal(data, &m.index); err != nil {
+			return nil, err
+		}
+		if m.index.Files == nil {
+			m.index.Files = make(map[uint]string)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.refresh(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *noteMarkdownStorage) Get(ctx context.Context, id uint) (*model.Note, error) {
+	return m.Find(ctx, NoteFilter{}.WithID(id))
+}
+
+func (m *noteMarkdownStorage) Find(ctx context.Context, filter NoteFilter) (*model.Note, error) {
+	notes, err := m.GetList(ctx, filter, NoteListOptions{Limit: 1})
+	if err != nil || len(notes) == 0 {
+		return nil, err
+	}
+	return notes[0], nil
+}
+
+func (m *noteMarkdownStorage) GetList(ctx context.Context, filter NoteFilter, opts NoteListOptions) ([]*model.Note, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	filter, err := scopedFilter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	notes, err := m.match(filter)
+	if err != nil {
+		return nil, err
+	}
+	return pageNotes(notes, opts), nil
+}
+
+func (m *noteMarkdownStorage) Insert(ctx context.Context, note *model.Note) (*model.Note, error) {
+	if err := ctx.Err(); err != nil {
+		return note, err
+	}
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return note, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.refresh(); err != nil {
+		return note, err
+	}
+	scope.assign(note)
+	if m.findTitle(note.OwnerID, note.Title) != nil {
+		return note, ErrDuplicateTitle
+	}
+
+	now := time.Now()
+	m.index.NextID++
+	note.ID = m.index.NextID
+	assignPublicID(note)
+	note.CreatedAt = now
+	note.UpdatedAt = now
+	note.Version = 1
+
+	entry := &markdownEntry{
+		file: m.fileName(note),
+		note: copyNote(note),
+	}
+	if err := m.write(entry); err != nil {
+		return note, err
+	}
+	m.index.Files[note.ID] = entry.file
+	return note, m.saveIndex()
+}
+
+func (m *noteMarkdownStorage) Update(ctx context.Context, id uint, note *model.Note) (*model.Note, error) {
+	if err := ctx.Err(); err != nil {
+		return note, err
+	}
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return note, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.refresh(); err != nil {
+		return note, err
+	}
+	current, err := m.lookup(id)
+	if err != nil {
+		return note, err
+	}
+	if current == nil || current.note.DeletedAt != nil || !scope.owns(current.note) {
+		return note, fmt.Errorf("storage: note %d does not exist", id)
+	}
+	if current.note.Version != note.Version {
+		return note, ErrVersionConflict
+	}
+	note.OwnerID = current.note.OwnerID
+	if other := m.findTitle(note.OwnerID, note.Title); other != nil && other.note.ID != id {
+		return note, ErrDuplicateTitle
+	}
+
+	note.ID = id
+	note.PublicID = current.note.PublicID
+	note.Version++
+	note.CreatedAt = current.note.CreatedAt
+	note.UpdatedAt = time.Now()
+	note.DeletedAt = nil
+
+	entry := &markdownEntry{
+		file: current.file,
+		note: copyNote(note),
+	}
+	if note.Title != current.note.Title {
+		entry.file = m.fileName(note)
+	}
+	if err := m.write(entry); err != nil {
+		return note, err
+	}
+	if entry.file != current.file {
+		if err := os.Remove(m.path(current.file)); err != nil && !os.IsNotExist(err) {
+			return note, err
+		}
+		delete(m.cache, current.file)
+	}
+	m.index.Files[id] = entry.file
+	return note, m.saveIndex()
+}
+
+func (m *noteMarkdownStorage) Delete(ctx context.Context, note *model.Note) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, err := m.lookup(note.ID)
+	if err != nil || entry == nil || entry.note.DeletedAt != nil || !scope.owns(entry.note) {
+		return err
+	}
+	now := time.Now()
+	note.DeletedAt = &now
+	return m.write(withDeletedAt(entry, note.DeletedAt))
+}
+
+func (m *noteMarkdownStorage) Restore(ctx context.Context, note *model.Note) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.refresh(); err != nil {
+		return err
+	}
+	entry, err := m.lookup(note.ID)
+	if err != nil {
+		return err
+	}
+	if entry == nil || !scope.owns(entry.note) {
+		return fmt.Errorf("storage: note %d does not exist", note.ID)
+	}
+	if entry.note.DeletedAt == nil {
+		return nil
+	}
+	if m.findTitle(entry.note.OwnerID, entry.note.Title) != nil {
+		return ErrDuplicateTitle
+	}
+	note.DeletedAt = nil
+	return m.write(withDeletedAt(entry, nil))
+}
+
+func (m *noteMarkdownStorage) Purge(ctx context.Context, note *model.Note) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, err := m.lookup(note.ID)
+	if err != nil || entry == nil || !scope.owns(entry.note) {
+		return err
+	}
+	if err := os.Remove(m.path(entry.file)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	delete(m.cache, entry.file)
+	delete(m.index.Files, note.ID)
+	return m.saveIndex()
+}
+
+// withDeletedAt leaves the cached entry alone until the file is written.
+func withDeletedAt(entry *markdownEntry, deletedAt *time.Time) *markdownEntry {
+	note := copyNote(entry.note)
+	note.DeletedAt = deletedAt
+	return &markdownEntry{file: entry.file, note: note}
+}
+
+func (m *noteMarkdownStorage) Count(ctx context.Context, filter NoteFilter) (int, error) {
+	notes, err := m.GetList(ctx, filter, NoteListOptions{})
+	return len(notes), err
+}
+
+func (m *noteMarkdownStorage) Tags(ctx context.Context) ([]*model.Tag, error) {
+	notes, err := m.GetList(ctx, NoteFilter{}, NoteListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return countTags(notes), nil
+}
+
+func (m *noteMarkdownStorage) RenameTag(ctx context.Context, from, to string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.refresh(); err != nil {
+		return 0, err
+	}
+	var renamed []*markdownEntry
+	now := time.Now()
+	for _, entry := range m.cache {
+		if !scope.owns(entry.note) {
+			continue
+		}
+		if tags, ok := renameTag(entry.note.Tags, from, to); ok {
+			note := copyNote(entry.note)
+			note.Tags = tags
+			note.Version++
+			note.UpdatedAt = now
+			renamed = append(renamed, &markdownEntry{file: entry.file, note: note})
+		}
+	}
+	for i, entry := range renamed {
+		if err := m.write(entry); err != nil {
+			return i, err
+		}
+	}
+	return len(renamed), nil
+}
+
+func (m *noteMarkdownStorage) AdoptNotes(ctx context.Context, owner uint) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.refresh(); err != nil {
+		return 0, err
+	}
+	var adopted []*markdownEntry
+	for _, entry := range m.cache {
+		if entry.note.OwnerID != 0 {
+			continue
+		}
+		if entry.note.DeletedAt == nil && m.findTitle(owner, entry.note.Title) != nil {
+			continue
+		}
+		note := copyNote(entry.note)
+		note.OwnerID = owner
+		adopted = append(adopted, &markdownEntry{file: entry.file, note: note})
+	}
+	for i, entry := range adopted {
+		if err := m.write(entry); err != nil {
+			return i, err
+		}
+	}
+	return len(adopted), nil
+}
+
+func (m *noteMarkdownStorage) restoreTags(ctx context.Context, id uint, tags []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, err := m.lookup(id)
+	if err != nil || entry == nil {
+		return err
+	}
+	note := copyNote(entry.note)
+	note.Tags = tags
+	note.Version++
+	note.UpdatedAt = time.Now()
+	return m.write(&markdownEntry{file: entry.file, note: note})
+}
+
+func (m *noteMarkdownStorage) Batch(ctx context.Context, ops []NoteOp, atomic bool) ([]NoteOpResult, error) {
+	return batchNotes(ctx, m, ops, atomic)
+}
+
+// WithTx undoes the writes of fn when it fails, the files
+// are written one by one.
+func (m *noteMarkdownStorage) WithTx(ctx context.Context, fn func(s NoteStorage) error) error {
+	return journalTx(ctx, &m.txMu, m, fn)
+}
+
+// match returns copies of the notes matching filter ordered by id, an id
+// lookup goes through the index instead of reading the whole directory.
+func (m *noteMarkdownStorage) match(filter NoteFilter) ([]*model.Note, error) {
+	var entries []*markdownEntry
+	if filter.ID != nil {
+		entry, err := m.lookup(*filter.ID)
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			entries = append(entries, entry)
+		}
+	} else {
+		if err := m.refresh(); err != nil {
+			return nil, err
+		}
+		for _, entry := range m.cache {
+			entries = append(entries, entry)
+		}
+	}
+
+	notes := make([]*model.Note, 0, len(entries))
+	for _, entry := range entries {
+		if filter.Match(entry.note) {
+			notes = append(notes, copyNote(entry.note))
+		}
+	}
+	sort.Slice(notes, func(i, j int) bool {
+		return notes[i].ID < notes[j].ID
+	})
+	return notes, nil
+}
+
+func (m *noteMarkdownStorage) lookup(id uint) (*markdownEntry, error) {
+	if file, ok := m.index.Files[id]; ok {
+		entry, err := m.load(file)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil && entry.note != nil && entry.note.ID == id {
+			return entry, nil
+		}
+	}
+
+	if err := m.refresh(); err != nil {
+		return nil, err
+	}
+	file, ok := m.index.Files[id]
+	if !ok {
+		return nil, nil
+	}
+	return m.cache[file], nil
+}
+
+// refresh syncs the cache and the index with the directory content.
+// Files without an id, or with an id already used by another file, were
+// added by hand and get a new id. The same goes for the public id, files
+// written before public ids existed get one too. Files without a version
+// start at version 1.
+func (m *noteMarkdownStorage) refresh() error {
+	infos, err := ioutil.ReadDir(m.dir)
+	if err != nil {
+		return err
+	}
+
+	files := make(map[uint]string)
+	publicIDs := make(map[string]bool)
+	cache := make(map[string]*markdownEntry)
+	skipped := make(map[string]*markdownEntry)
+	entries := make([]*markdownEntry, 0, len(infos))
+	for _, info := range infos {
+		if info.IsDir() || filepath.Ext(info.Name()) != markdownExt {
+			continue
+		}
+		entry, err := m.load(info.Name())
+		if err != nil {
+			return err
+		}
+		if entry.note == nil {
+			skipped[entry.file] = entry
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	// the file the index knows for an id keeps it, a copy of the file made
+	// by hand is the one renumbered
+	indexed := func(entry *markdownEntry) bool {
+		return m.index.Files[entry.note.ID] == entry.file
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return indexed(entries[i]) && !indexed(entries[j])
+	})
+
+	titles := make(map[markdownTitle]bool)
+	for _, entry := range entries {
+		changed := false
+		if _, taken := files[entry.note.ID]; entry.note.ID == 0 || taken {
+			m.index.NextID++
+			entry.note.ID = m.index.NextID
+			if entry.note.CreatedAt.IsZero() {
+				entry.note.CreatedAt = entry.modTime
+				entry.note.UpdatedAt = entry.modTime
+			}
+			if entry.note.DeletedAt == nil && titles[markdownTitleOf(entry.note)] {
+				title := uniqueMarkdownTitle(entry.note, titles)
+				log.Printf("%s: the title %q is already used, renaming the note to %q", entry.file, entry.note.Title, title)
+				entry.note.Title = title
+			}
+			changed = true
+		}
+		publicID, err := ulid.Parse(entry.note.PublicID)
+		if err != nil || publicIDs[publicID.String()] {
+			publicID = ulid.NewAt(entry.note.CreatedAt)
+		}
+		if publicID.String() != entry.note.PublicID {
+			// a hand written id may also be in lowercase
+			entry.note.PublicID = publicID.String()
+			changed = true
+		}
+		if entry.note.Version == 0 {
+			entry.note.Version = 1
+			changed = true
+		}
+		if changed {
+			if err := m.write(entry); err != nil {
+				return err
+			}
+		}
+		publicIDs[entry.note.PublicID] = true
+		if entry.note.DeletedAt == nil {
+			titles[markdownTitleOf(entry.note)] = true
+		}
+		if entry.note.ID > m.index.NextID {
+			m.index.NextID = entry.note.ID
+		}
+		files[entry.note.ID] = entry.file
+		cache[entry.file] = entry
+	}
+
+	m.cache = cache
+	m.skipped = skipped
+	if reflect.DeepEqual(files, m.index.Files) {
+		return nil
+	}
+	m.index.Files = files
+	return m.saveIndex()
+}
+
+type markdownTitle struct {
+	owner uint
+	title string
+}
+
+func markdownTitleOf(note *model.Note) markdownTitle {
+	return markdownTitle{owner: note.OwnerID, title: note.Title}
+}
+
+func uniqueMarkdownTitle(note *model.Note, titles map[markdownTitle]bool) string {
+	for n := 2; ; n++ {
+		suffix := fmt.Sprintf(" (%d)", n)
+		title := []rune(note.Title)
+		if limit := markdownTitleLength - len(suffix); len(title) > limit {
+			title = title[:limit]
+		}
+		candidate := strings.TrimSpace(string(title)) + suffix
+		if !titles[markdownTitle{owner: note.OwnerID, title: candidate}] {
+			return candidate
+		}
+	}
+}
+
+// load leaves the note of the entry nil when the file can't be parsed, one
+// broken file must not make the whole directory unreadable.
+func (m *noteMarkdownStorage) load(file string) (*markdownEntry, error) {
+	info, err := os.Stat(m.path(file))
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := m.cache[file]
+	if !ok {
+		entry, ok = m.skipped[file]
+	}
+	if ok && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+		return entry, nil
+	}
+
+	data, err := ioutil.ReadFile(m.path(file))
+	if err != nil {
+		return nil, err
+	}
+	entry, err = parseMarkdownNote(file, data)
+	if err != nil {
+		log.Printf("%v, skipping it", err)
+		entry = &markdownEntry{file: file}
+	}
+	entry.modTime = info.ModTime()
+	entry.size = info.Size()
+	if entry.note == nil {
+		delete(m.cache, file)
+		m.skipped[file] = entry
+	} else {
+		delete(m.skipped, file)
+		m.cache[file] = entry
+	}
+	return entry, nil
+}
+
+func (m *noteMarkdownStorage) write(entry *markdownEntry) error {
+	data, err := renderMarkdownNote(entry)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(m.path(entry.file), data, 0644); err != nil {
+		return err
+	}
+	info, err := os.Stat(m.path(entry.file))
+	if err != nil {
+		return err
+	}
+	entry.modTime = info.ModTime()
+	entry.size = info.Size()
+	m.cache[entry.file] = entry
+	return nil
+}
+
+func (m *noteMarkdownStorage) saveIndex() error {
+	data, err := yaml.Marshal(m.index)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(m.path(markdownIndexFile), data, 0644)
+}
+
+// findTitle returns the note of owner outside of the trash using title.
+func (m *noteMarkdownStorage) findTitle(owner uint, title string) *markdownEntry {
+	for _, entry := range m.cache {
+		if entry.note.OwnerID == owner && entry.note.Title == title && entry.note.DeletedAt == nil {
+			return entry
+		}
+	}
+	return nil
+}
+
+func (m *noteMarkdownStorage) fileName(note *model.Note) string {
+	slug := strings.Trim(markdownSlugPattern.ReplaceAllString(strings.ToLower(note.Title), "-"), "-")
+	if slug == "" {
+		slug = "note"
+	}
+	file := slug + markdownExt
+	if _, err := os.Stat(m.path(file)); os.IsNotExist(err) {
+		return file
+	}
+	return fmt.Sprintf("%s-%d%s", slug, note.ID, markdownExt)
+}
+
+func (m *noteMarkdownStorage) path(file string) string {
+	return filepath.Join(m.dir, file)
+}
+
+// parseMarkdownNote reads a note file. A file without front matter, or
+// whose first line is a thematic break never closed, is a plain Markdown
+// file added by hand: all of it is the body of a note without ids.
+func parseMarkdownNote(file string, data []byte) (*markdownEntry, error) {
+	data = bytes.Replace(data, []byte("\r\n"), []byte("\n"), -1)
+	var front markdownFrontMatter
+	body := string(data)
+	if bytes.HasPrefix(data, []byte(markdownSeparator)) {
+		rest := data[len(markdownSeparator):]
+		if end := bytes.Index(rest, []byte("\n"+markdownSeparator)); end >= 0 {
+			if err := yaml.Unmarshal(rest[:end+1], &front); err != nil {
+				return nil, fmt.Errorf("storage: %s: %v", file, err)
+			}
+			body = string(rest[end+1+len(markdownSeparator):])
+		}
+	}
+	body = strings.TrimLeftFunc(body, unicode.IsSpace)
+
+	title := strings.TrimSuffix(file, markdownExt)
+	if strings.HasPrefix(body, "# ") {
+		lines := strings.SplitN(body, "\n", 2)
+		title = strings.TrimSpace(strings.TrimPrefix(lines[0], "# "))
+		body = ""
+		if len(lines) == 2 {
+			// the blank line after the title isn't part of the content
+			body = strings.TrimPrefix(lines[1], "\n")
+		}
+	}
+
+	return &markdownEntry{
+		file: file,
+		note: &model.Note{
+			ID:          front.ID,
+			PublicID:    front.PublicID,
+			OwnerID:     front.OwnerID,
+			CreatedAt:   front.CreatedAt,
+			UpdatedAt:   front.UpdatedAt,
+			DeletedAt:   front.DeletedAt,
+			Title:       title,
+			IsCompleted: front.IsCompleted,
+			Tags:        front.Tags,
+			Content:     body,
+			Version:     front.Version,
+		},
+	}, nil
+}
+
+func renderMarkdownNote(entry *markdownEntry) ([]byte, error) {
+	front, err := yaml.Marshal(markdownFrontMatter{
+		ID:          entry.note.ID,
+		PublicID:    entry.note.PublicID,
+		OwnerID:     entry.note.OwnerID,
+		CreatedAt:   entry.note.CreatedAt,
+		UpdatedAt:   entry.note.UpdatedAt,
+		DeletedAt:   entry.note.DeletedAt,
+		IsCompleted: entry.note.IsCompleted,
+		Tags:        entry.note.Tags,
+		Version:     entry.note.Version,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(markdownSeparator)
+	buf.Write(front)
+	buf.WriteString(markdownSeparator)
+	buf.WriteString("# " + entry.note.Title + "\n")
+	if entry.note.Content != "" {
+		buf.WriteString("\n" + entry.note.Content)
+	}
+	return buf.Bytes(), nil
+}
+
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,360 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/lyquocnam/go-note-learning/model"
+	"sort"
+	"sync"
+	"time"
+)
+
+type noteMemoryStorage struct {
+	mu     sync.RWMutex
+	lastID uint
+	notes  map[uint]*model.Note
+	titles map[noteTitleKey]uint // notes that are not in the trash
+	// publicIDs maps the public id of every note to its id
+	publicIDs map[string]uint
+	// locked is set on the view WithTx runs fn on, it already holds the
+	// lock of the storage
+	locked bool
+}
+
+// NewNoteMemoryStorage keeps notes in process memory, everything is lost
+// when the process exits. Meant for tests and local development.
+func NewNoteMemoryStorage() *noteMemoryStorage {
+	return &noteMemoryStorage{
+		notes:     make(map[uint]*model.Note),
+		titles:    make(map[noteTitleKey]uint),
+		publicIDs: make(map[string]uint),
+	}
+}
+
+func (m *noteMemoryStorage) Get(ctx context.Context, id uint) (*model.Note, error) {
+	return m.Find(ctx, NoteFilter{}.WithID(id))
+}
+
+func (m *noteMemoryStorage) Find(ctx context.Context, filter NoteFilter) (*model.Note, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	filter, err := scopedFilter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer m.rlock()()
+
+	notes := m.match(filter)
+	if len(notes) == 0 {
+		return nil, nil
+	}
+	return notes[0], nil
+}
+
+func (m *noteMemoryStorage) GetList(ctx context.Context, filter NoteFilter, opts NoteListOptions) ([]*model.Note, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	filter, err := scopedFilter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer m.rlock()()
+
+	return pageNotes(m.match(filter), opts), nil
+}
+
+func (m *noteMemoryStorage) Insert(ctx context.Context, note *model.Note) (*model.Note, error) {
+	if err := ctx.Err(); err != nil {
+		return note, err
+	}
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return note, err
+	}
+	defer m.lock()()
+
+	scope.assign(note)
+	if _, ok := m.titles[titleKey(note)]; ok {
+		return note, ErrDuplicateTitle
+	}
+
+	now := time.Now()
+	m.lastID++
+	note.ID = m.lastID
+	assignPublicID(note)
+	note.CreatedAt = now
+	note.UpdatedAt = now
+	note.Version = 1
+	m.notes[note.ID] = copyNote(note)
+	m.titles[titleKey(note)] = note.ID
+	m.publicIDs[note.PublicID] = note.ID
+	return note, nil
+}
+
+func (m *noteMemoryStorage) Update(ctx context.Context, id uint, note *model.Note) (*model.Note, error) {
+	if err := ctx.Err(); err != nil {
+		return note, err
+	}
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return note, err
+	}
+	defer m.lock()()
+
+	current, ok := m.notes[id]
+	if !ok || current.DeletedAt != nil || !scope.owns(current) {
+		return note, fmt.Errorf("storage: note %d does not exist", id)
+	}
+	if current.Version != note.Version {
+		return note, ErrVersionConflict
+	}
+	note.OwnerID = current.OwnerID
+	if other, ok := m.titles[titleKey(note)]; ok && other != id {
+		return note, ErrDuplicateTitle
+	}
+
+	note.ID = id
+	note.PublicID = current.PublicID
+	note.Version++
+	note.CreatedAt = current.CreatedAt
+	note.UpdatedAt = time.Now()
+	note.DeletedAt = nil
+	delete(m.titles, titleKey(current))
+	m.notes[id] = copyNote(note)
+	m.titles[titleKey(note)] = id
+	return note, nil
+}
+
+func (m *noteMemoryStorage) Delete(ctx context.Context, note *model.Note) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return err
+	}
+	defer m.lock()()
+
+	current, ok := m.notes[note.ID]
+	if !ok || current.DeletedAt != nil || !scope.owns(current) {
+		return nil
+	}
+	now := time.Now()
+	current.DeletedAt = &now
+	note.DeletedAt = &now
+	delete(m.titles, titleKey(current))
+	return nil
+}
+
+func (m *noteMemoryStorage) Restore(ctx context.Context, note *model.Note) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return err
+	}
+	defer m.lock()()
+
+	current, ok := m.notes[note.ID]
+	if !ok || !scope.owns(current) {
+		return fmt.Errorf("storage: note %d does not exist", note.ID)
+	}
+	if current.DeletedAt == nil {
+		return nil
+	}
+	if _, ok := m.titles[titleKey(current)]; ok {
+		return ErrDuplicateTitle
+	}
+	current.DeletedAt = nil
+	note.DeletedAt = nil
+	m.titles[titleKey(current)] = current.ID
+	return nil
+}
+
+func (m *noteMemoryStorage) Purge(ctx context.Context, note *model.Note) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return err
+	}
+	defer m.lock()()
+
+	current, ok := m.notes[note.ID]
+	if !ok || !scope.owns(current) {
+		return nil
+	}
+	if current.DeletedAt == nil {
+		delete(m.titles, titleKey(current))
+	}
+	delete(m.publicIDs, current.PublicID)
+	delete(m.notes, note.ID)
+	return nil
+}
+
+func (m *noteMemoryStorage) Count(ctx context.Context, filter NoteFilter) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	filter, err := scopedFilter(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	defer m.rlock()()
+
+	return len(m.match(filter)), nil
+}
+
+func (m *noteMemoryStorage) Tags(ctx context.Context) ([]*model.Tag, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	filter, err := scopedFilter(ctx, NoteFilter{})
+	if err != nil {
+		return nil, err
+	}
+	defer m.rlock()()
+
+	return countTags(m.match(filter)), nil
+}
+
+func (m *noteMemoryStorage) RenameTag(ctx context.Context, from, to string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer m.lock()()
+
+	count := 0
+	now := time.Now()
+	for _, note := range m.notes {
+		if !scope.owns(note) {
+			continue
+		}
+		if tags, ok := renameTag(note.Tags, from, to); ok {
+			note.Tags = tags
+			note.Version++
+			note.UpdatedAt = now
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *noteMemoryStorage) Batch(ctx context.Context, ops []NoteOp, atomic bool) ([]NoteOpResult, error) {
+	return batchNotes(ctx, m, ops, atomic)
+}
+
+// WithTx holds the lock while fn runs on a view sharing the maps of m, the
+// maps are put back to a copy taken before fn when it fails.
+func (m *noteMemoryStorage) WithTx(ctx context.Context, fn func(s NoteStorage) error) error {
+	if m.locked {
+		return fn(m)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	defer m.lock()()
+
+	notes := make(map[uint]*model.Note, len(m.notes))
+	for id, note := range m.notes {
+		notes[id] = copyNote(note)
+	}
+	titles := make(map[noteTitleKey]uint, len(m.titles))
+	for title, id := range m.titles {
+		titles[title] = id
+	}
+	publicIDs := make(map[string]uint, len(m.publicIDs))
+	for publicID, id := range m.publicIDs {
+		publicIDs[publicID] = id
+	}
+
+	view := &noteMemoryStorage{lastID: m.lastID, notes: m.notes, titles: m.titles, publicIDs: m.publicIDs, locked: true}
+	if err := fn(view); err != nil {
+		m.notes, m.titles, m.publicIDs = notes, titles, publicIDs
+		return err
+	}
+	m.lastID = view.lastID
+	return nil
+}
+
+func (m *noteMemoryStorage) lock() func() {
+	if m.locked {
+		return func() {}
+	}
+	m.mu.Lock()
+	return m.mu.Unlock
+}
+
+func (m *noteMemoryStorage) rlock() func() {
+	if m.locked {
+		return func() {}
+	}
+	m.mu.RLock()
+	return m.mu.RUnlock
+}
+
+// match returns copies of the notes matching filter ordered by id, id,
+// public id and title lookups don't scan every note.
+func (m *noteMemoryStorage) match(filter NoteFilter) []*model.Note {
+	var candidates []*model.Note
+	switch {
+	case filter.ID != nil:
+		if note, ok := m.notes[*filter.ID]; ok {
+			candidates = append(candidates, note)
+		}
+	case filter.PublicID != nil:
+		if id, ok := m.publicIDs[*filter.PublicID]; ok {
+			candidates = append(candidates, m.notes[id])
+		}
+	case filter.Title != nil && filter.OwnerID != nil && filter.liveOnly():
+		if id, ok := m.titles[noteTitleKey{*filter.OwnerID, *filter.Title}]; ok {
+			candidates = append(candidates, m.notes[id])
+		}
+	default:
+		for _, note := range m.notes {
+			candidates = append(candidates, note)
+		}
+	}
+
+	notes := make([]*model.Note, 0, len(candidates))
+	for _, note := range candidates {
+		if filter.Match(note) {
+			notes = append(notes, copyNote(note))
+		}
+	}
+	sort.Slice(notes, func(i, j int) bool {
+		return notes[i].ID < notes[j].ID
+	})
+	return notes
+}
+
+// noteTitleKey identifies the titles that must be unique, the ones of a
+// user outside of the trash.
+type noteTitleKey struct {
+	owner uint
+	title string
+}
+
+func titleKey(note *model.Note) noteTitleKey {
+	return noteTitleKey{note.OwnerID, note.Title}
+}
+
+func copyNote(note *model.Note) *model.Note {
+	clone := *note
+	if note.DeletedAt != nil {
+		deletedAt := *note.DeletedAt
+		clone.DeletedAt = &deletedAt
+	}
+	if note.Tags != nil {
+		clone.Tags = append([]string(nil), note.Tags...)
+	}
+	return &clone
+}
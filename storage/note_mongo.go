@@ -1,40 +1,564 @@
 package storage
 
 import (
+	"context"
+	"fmt"
 	"github.com/lyquocnam/go-note-learning/model"
+	"github.com/lyquocnam/go-note-learning/ulid"
 	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"regexp"
+	"sync"
+	"time"
 )
 
+const noteCounterID = "notes"
+
 type noteMongo struct {
-	noteCollection *mgo.Collection
+	noteCollection    *mgo.Collection
+	counterCollection *mgo.Collection
+	txMu              sync.Mutex // serializes WithTx
+}
+
+// NewNoteMongoStorage stores notes in the "notes" collection of db and keeps
+// the id sequence in the "counters" collection.
+func NewNoteMongoStorage(db *mgo.Database) (*noteMongo, error) {
+	m := &noteMongo{
+		noteCollection:    db.C("notes"),
+		counterCollection: db.C("counters"),
+	}
+	// mgo can't create partial indexes, every note outside of the trash has
+	// a null deleted_at so the triple keeps the titles of a user unique
+	err := m.noteCollection.EnsureIndex(mgo.Index{
+		Key:    []string{"owner_id", "title", "deleted_at"},
+		Unique: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// older versions made title unique across the trash, then across users
+	indexes, err := m.noteCollection.Indexes()
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range indexes {
+		if index.Name == "title_1" || index.Name == "title_1_deleted_at_1" {
+			if err := m.noteCollection.DropIndexName(index.Name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// the notes stored before public ids existed get one before the index
+	// requires it to be unique
+	if err := m.backfillPublicIDs(); err != nil {
+		return nil, err
+	}
+	// the notes stored before versions existed start at version 1
+	_, err = m.noteCollection.UpdateAll(bson.M{"version": bson.M{"$in": []interface{}{nil, 0}}}, bson.M{"$set": bson.M{"version": 1}})
+	if err != nil {
+		return nil, err
+	}
+	err = m.noteCollection.EnsureIndex(mgo.Index{
+		Key:    []string{"public_id"},
+		Unique: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// backfillPublicIDs gives the documents without a public id one dated from
+// their creation.
+func (m *noteMongo) backfillPublicIDs() error {
+	var notes []*model.Note
+	err := m.noteCollection.Find(bson.M{"public_id": bson.M{"$in": []interface{}{nil, ""}}}).All(&notes)
+	if err != nil {
+		return err
+	}
+	for _, note := range notes {
+		update := bson.M{"$set": bson.M{"public_id": ulid.NewAt(note.CreatedAt).String()}}
+		if err := m.noteCollection.UpdateId(note.ID, update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// session returns the collections bound to a copied session, mgo sessions
+// are not meant to be shared between concurrent requests.
+// mgo has no context support, the ctx deadline becomes the socket timeout.
+func (m *noteMongo) session(ctx context.Context) (notes *mgo.Collection, counters *mgo.Collection, closeFn func(), err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, nil, err
+	}
+	session := m.noteCollection.Database.Session.Copy()
+	if deadline, ok := ctx.Deadline(); ok {
+		session.SetSocketTimeout(time.Until(deadline))
+	}
+	return m.noteCollection.With(session), m.counterCollection.With(session), session.Close, nil
+}
+
+func (m *noteMongo) Get(ctx context.Context, id uint) (*model.Note, error) {
+	return m.Find(ctx, NoteFilter{}.WithID(id))
+}
+
+func (m *noteMongo) Find(ctx context.Context, filter NoteFilter) (*model.Note, error) {
+	filter, err := scopedFilter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	notes, _, closeFn, err := m.session(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFn()
+
+	var note model.Note
+	err = notes.Find(mongoQuery(filter)).Sort("_id").One(&note)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+	return &note, err
 }
 
-func (m *noteMongo) Get(id uint) (*model.Note, error) {
-	var result model.Note
-	err := m.noteCollection.FindId(id).One(&result)
-	return &result, err
+func (m *noteMongo) GetList(ctx context.Context, filter NoteFilter, opts NoteListOptions) ([]*model.Note, error) {
+	filter, err := scopedFilter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	notes, _, closeFn, err := m.session(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFn()
+
+	query := mongoQuery(filter)
+	if opts.After != nil {
+		query = bson.M{"$and": []bson.M{query, mongoAfter(opts)}}
+	}
+	q := notes.Find(query).Sort(mongoSort(opts)...)
+	if opts.Offset > 0 {
+		q = q.Skip(opts.Offset)
+	}
+	if opts.Limit > 0 {
+		q = q.Limit(opts.Limit)
+	}
+
+	var result []*model.Note
+	err = q.All(&result)
+	return result, err
+}
+
+func (m *noteMongo) Insert(ctx context.Context, note *model.Note) (*model.Note, error) {
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return note, err
+	}
+	notes, counters, closeFn, err := m.session(ctx)
+	if err != nil {
+		return note, err
+	}
+	defer closeFn()
+
+	id, err := m.nextID(counters)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	scope.assign(note)
+	note.ID = id
+	assignPublicID(note)
+	note.CreatedAt = now
+	note.UpdatedAt = now
+	note.Version = 1
+	err = notes.Insert(note)
+	if mgo.IsDup(err) {
+		// ids come from the counter and public ids are random, only the
+		// title index can be violated
+		return note, ErrDuplicateTitle
+	}
+	return note, err
+}
+
+func (m *noteMongo) Update(ctx context.Context, id uint, note *model.Note) (*model.Note, error) {
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return note, err
+	}
+	notes, _, closeFn, err := m.session(ctx)
+	if err != nil {
+		return note, err
+	}
+	defer closeFn()
+
+	// the document is replaced, it keeps the public id and the owner it has
+	// even when the caller did not set them
+	var current model.Note
+	err = notes.Find(mongoScope(bson.M{"_id": id}, scope)).Select(bson.M{"public_id": 1, "owner_id": 1}).One(&current)
+	if err != nil && err != mgo.ErrNotFound {
+		return note, err
+	}
+	note.PublicID, note.OwnerID = current.PublicID, current.OwnerID
+
+	version := note.Version
+	note.ID = id
+	note.Version++
+	note.UpdatedAt = time.Now()
+	err = notes.Update(mongoScope(bson.M{"_id": id, "deleted_at": nil, "version": mongoVersion(version)}, scope), note)
+	if err != nil {
+		note.Version = version
+	}
+	if mgo.IsDup(err) {
+		return note, ErrDuplicateTitle
+	}
+	if err == mgo.ErrNotFound {
+		count, err := notes.Find(mongoScope(bson.M{"_id": id, "deleted_at": nil}, scope)).Count()
+		if err != nil {
+			return note, err
+		}
+		if count == 0 {
+			return note, fmt.Errorf("storage: note %d does not exist", id)
+		}
+		return note, ErrVersionConflict
+	}
+	return note, err
 }
 
-func (m *noteMongo) GetByTitle(title string) (*model.Note, error) {
-	panic("implement me")
+// mongoVersion matches version, documents written before notes were
+// versioned have no version field.
+func mongoVersion(version uint) interface{} {
+	if version == 0 {
+		return bson.M{"$in": []interface{}{0, nil}}
+	}
+	return version
+}
+
+func (m *noteMongo) Delete(ctx context.Context, note *model.Note) error {
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return err
+	}
+	notes, _, closeFn, err := m.session(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	now := time.Now()
+	err = notes.Update(mongoScope(bson.M{"_id": note.ID, "deleted_at": nil}, scope), bson.M{"$set": bson.M{"deleted_at": now}})
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	if err == nil {
+		note.DeletedAt = &now
+	}
+	return err
+}
+
+func (m *noteMongo) Restore(ctx context.Context, note *model.Note) error {
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return err
+	}
+	notes, _, closeFn, err := m.session(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	err = notes.Update(mongoScope(bson.M{"_id": note.ID}, scope), bson.M{"$set": bson.M{"deleted_at": nil}})
+	if err == mgo.ErrNotFound {
+		return fmt.Errorf("storage: note %d does not exist", note.ID)
+	}
+	if mgo.IsDup(err) {
+		return ErrDuplicateTitle
+	}
+	if err == nil {
+		note.DeletedAt = nil
+	}
+	return err
+}
+
+func (m *noteMongo) Purge(ctx context.Context, note *model.Note) error {
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return err
+	}
+	notes, _, closeFn, err := m.session(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	err = notes.Remove(mongoScope(bson.M{"_id": note.ID}, scope))
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+func (m *noteMongo) Count(ctx context.Context, filter NoteFilter) (int, error) {
+	filter, err := scopedFilter(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	notes, _, closeFn, err := m.session(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer closeFn()
+	return notes.Find(mongoQuery(filter)).Count()
+}
+
+func (m *noteMongo) Tags(ctx context.Context) ([]*model.Tag, error) {
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	notes, _, closeFn, err := m.session(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFn()
+
+	tags := make([]*model.Tag, 0)
+	err = notes.Pipe([]bson.M{
+		{"$match": mongoScope(bson.M{"deleted_at": nil}, scope)},
+		{"$unwind": "$tags"},
+		{"$group": bson.M{"_id": "$tags", "count": bson.M{"$sum": 1}}},
+		{"$sort": bson.M{"_id": 1}},
+	}).All(&tags)
+	return tags, err
+}
+
+// RenameTag writes the new tags of each note in one update, guarded by the
+// version read so a note changed meanwhile is read again.
+func (m *noteMongo) RenameTag(ctx context.Context, from, to string) (int, error) {
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return 0, err
+	}
+	notes, _, closeFn, err := m.session(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer closeFn()
+
+	selector := mongoScope(bson.M{"tags": from}, scope)
+	fields := bson.M{"tags": 1, "version": 1}
+	var found []*model.Note
+	if err := notes.Find(selector).Select(fields).All(&found); err != nil {
+		return 0, err
+	}
+	count := 0
+	now := time.Now()
+	for _, note := range found {
+		for {
+			tags, _ := renameTag(note.Tags, from, to)
+			err := notes.Update(bson.M{"_id": note.ID, "version": mongoVersion(note.Version)}, bson.M{
+				"$set": bson.M{"tags": tags, "updated_at": now},
+				"$inc": bson.M{"version": 1},
+			})
+			if err == nil {
+				count++
+				break
+			}
+			if err != mgo.ErrNotFound {
+				return count, err
+			}
+			err = notes.Find(mongoScope(bson.M{"_id": note.ID, "tags": from}, scope)).Select(fields).One(note)
+			if err == mgo.ErrNotFound {
+				break
+			}
+			if err != nil {
+				return count, err
+			}
+		}
+	}
+	return count, nil
+}
+
+// AdoptNotes also covers the documents stored without an owner_id.
+func (m *noteMongo) AdoptNotes(ctx context.Context, owner uint) (int, error) {
+	notes, _, closeFn, err := m.session(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer closeFn()
+
+	var taken []string
+	err = notes.Find(bson.M{"owner_id": owner, "deleted_at": nil}).Distinct("title", &taken)
+	if err != nil {
+		return 0, err
+	}
+	if taken == nil {
+		// $nin needs an array, not null
+		taken = []string{}
+	}
+	ownerless := bson.M{"$in": []interface{}{nil, 0}}
+	info, err := notes.UpdateAll(bson.M{
+		"owner_id": ownerless,
+		"$or":      []bson.M{{"deleted_at": bson.M{"$ne": nil}}, {"title": bson.M{"$nin": taken}}},
+	}, bson.M{"$set": bson.M{"owner_id": owner}})
+	if err != nil {
+		return 0, err
+	}
+	return info.Updated, nil
+}
+
+func (m *noteMongo) restoreTags(ctx context.Context, id uint, tags []string) error {
+	notes, _, closeFn, err := m.session(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	return notes.UpdateId(id, bson.M{
+		"$set": bson.M{"tags": tags, "updated_at": time.Now()},
+		"$inc": bson.M{"version": 1},
+	})
+}
+
+func (m *noteMongo) Batch(ctx context.Context, ops []NoteOp, atomic bool) ([]NoteOpResult, error) {
+	return batchNotes(ctx, m, ops, atomic)
+}
+
+// WithTx undoes the writes of fn when it fails, mongo has no transactions
+// across documents.
+func (m *noteMongo) WithTx(ctx context.Context, fn func(s NoteStorage) error) error {
+	return journalTx(ctx, &m.txMu, m, fn)
+}
+
+// mongoQuery translates filter into a bson query.
+func mongoQuery(filter NoteFilter) bson.M {
+	query := bson.M{}
+	switch {
+	case filter.liveOnly():
+		// also matches documents without the field
+		query["deleted_at"] = nil
+	case filter.Trashed:
+		deletedAt := bson.M{"$ne": nil}
+		if filter.DeletedBefore != nil {
+			deletedAt["$lt"] = *filter.DeletedBefore
+		}
+		query["deleted_at"] = deletedAt
+	}
+	if filter.ID != nil {
+		query["_id"] = *filter.ID
+	}
+	if filter.OwnerID != nil {
+		query["owner_id"] = *filter.OwnerID
+	}
+	publicID := bson.M{}
+	if filter.PublicID != nil {
+		publicID["$eq"] = *filter.PublicID
+	}
+	if filter.PublicIDs != nil {
+		publicID["$in"] = filter.PublicIDs
+	}
+	if len(publicID) > 0 {
+		query["public_id"] = publicID
+	}
+
+	title := bson.M{}
+	if filter.Title != nil {
+		title["$eq"] = *filter.Title
+	}
+	if filter.TitlePrefix != nil {
+		title["$regex"] = "^" + regexp.QuoteMeta(*filter.TitlePrefix)
+	}
+	if len(title) > 0 {
+		query["title"] = title
+	}
+	if filter.TitleContains != nil {
+		// title may already hold a prefix $regex
+		query["$and"] = []bson.M{{"title": bson.M{"$regex": regexp.QuoteMeta(*filter.TitleContains), "$options": "i"}}}
+	}
+
+	if filter.IsCompleted != nil {
+		query["is_completed"] = *filter.IsCompleted
+	}
+	if len(filter.Tags) > 0 {
+		operator := "$all"
+		if filter.AnyTag {
+			operator = "$in"
+		}
+		query["tags"] = bson.M{operator: filter.Tags}
+	}
+	if createdAt := mongoRange(filter.CreatedAfter, filter.CreatedBefore); createdAt != nil {
+		query["created_at"] = createdAt
+	}
+	if updatedAt := mongoRange(filter.UpdatedAfter, filter.UpdatedBefore); updatedAt != nil {
+		query["updated_at"] = updatedAt
+	}
+	return query
 }
 
-func (m *noteMongo) GetList() ([]*model.Note, error) {
-	panic("implement me")
+// mongoScope restricts selector to the notes of scope.
+func mongoScope(selector bson.M, scope noteScope) bson.M {
+	if !scope.all {
+		selector["owner_id"] = scope.owner
+	}
+	return selector
 }
 
-func (m *noteMongo) Insert(note *model.Note) (*model.Note, error) {
-	panic("implement me")
+func mongoSort(opts NoteListOptions) []string {
+	prefix := ""
+	if opts.Desc {
+		prefix = "-"
+	}
+	fields := []string{prefix + "public_id"}
+	if field := opts.sortBy(); field != NoteSortID {
+		fields = append([]string{prefix + field}, fields...)
+	}
+	return fields
 }
 
-func (m *noteMongo) Update(id uint, note *model.Note) (*model.Note, error) {
-	panic("implement me")
+// mongoAfter is the keyset condition selecting the notes after opts.After.
+func mongoAfter(opts NoteListOptions) bson.M {
+	operator := "$gt"
+	if opts.Desc {
+		operator = "$lt"
+	}
+	field := opts.sortBy()
+	if field == NoteSortID {
+		return bson.M{"public_id": bson.M{operator: opts.After.PublicID}}
+	}
+	value := opts.After.Value()
+	return bson.M{"$or": []bson.M{
+		{field: bson.M{operator: value}},
+		{field: value, "public_id": bson.M{operator: opts.After.PublicID}},
+	}}
 }
 
-func (m *noteMongo) Delete(note *model.Note) error {
-	panic("implement me")
+func mongoRange(after, before *time.Time) bson.M {
+	if after == nil && before == nil {
+		return nil
+	}
+	r := bson.M{}
+	if after != nil {
+		r["$gt"] = *after
+	}
+	if before != nil {
+		r["$lt"] = *before
+	}
+	return r
 }
 
-func (m *noteMongo) Count(where interface{}, args ...interface{}) (int, error) {
-	panic("implement me")
+// nextID atomically increments the note sequence, mongo has no
+// auto increment and model.Note.ID is a uint.
+func (m *noteMongo) nextID(counters *mgo.Collection) (uint, error) {
+	var counter struct {
+		Seq uint `bson:"seq"`
+	}
+	_, err := counters.FindId(noteCounterID).Apply(mgo.Change{
+		Update:    bson.M{"$inc": bson.M{"seq": 1}},
+		Upsert:    true,
+		ReturnNew: true,
+	}, &counter)
+	return counter.Seq, err
 }
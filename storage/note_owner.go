@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/lyquocnam/go-note-learning/auth"
+	"github.com/lyquocnam/go-note-learning/model"
+)
+
+// ErrNoOwner is returned by the note storages when the context has
+// neither the identity of a user nor AllOwners, every note belongs to a
+// user and a call can't be scoped to nobody.
+var ErrNoOwner = errors.New("storage: no user to scope the notes to")
+
+// NoteAdopter is implemented by the storages that may hold notes stored
+// before accounts existed, those notes belong to no user.
+type NoteAdopter interface {
+	// AdoptNotes gives owner the notes that belong to no user, except the
+	// ones outside of the trash whose title owner already uses. It returns
+	// how many notes it gave.
+	AdoptNotes(ctx context.Context, owner uint) (int, error)
+}
+
+type scopeKey struct{}
+
+// AllOwners returns a copy of ctx whose note storage calls see the notes
+// of every user, it is meant for the maintenance jobs that don't run on
+// behalf of a user and for the callers restricting the notes themselves.
+func AllOwners(ctx context.Context) context.Context {
+	return context.WithValue(ctx, scopeKey{}, noteScope{all: true})
+}
+
+// AsOwner returns a copy of ctx whose note storage calls see the notes of
+// owner whatever the identity of ctx is, the caller must have checked it
+// may act on them, like the notes shared with it.
+func AsOwner(ctx context.Context, owner uint) context.Context {
+	return context.WithValue(ctx, scopeKey{}, noteScope{owner: owner})
+}
+
+// noteScope is the set of notes a call may see: the notes of owner, or
+// every note when all.
+type noteScope struct {
+	owner uint
+	all   bool
+}
+
+// scopeOf returns the scope of the calls made with ctx, AllOwners and
+// AsOwner win over the identity of auth.IdentityFrom.
+func scopeOf(ctx context.Context) (noteScope, error) {
+	if scope, ok := ctx.Value(scopeKey{}).(noteScope); ok {
+		return scope, nil
+	}
+	if identity, ok := auth.IdentityFrom(ctx); ok {
+		return noteScope{owner: identity.UserID}, nil
+	}
+	return noteScope{}, ErrNoOwner
+}
+
+// filter restricts filter to the notes of the scope.
+func (s noteScope) filter(filter NoteFilter) NoteFilter {
+	if s.all {
+		return filter
+	}
+	return filter.WithOwner(s.owner)
+}
+
+// owns tells whether note is in the scope, the notes of other users are
+// treated as if they did not exist.
+func (s noteScope) owns(note *model.Note) bool {
+	return s.all || note.OwnerID == s.owner
+}
+
+// assign gives a new note the owner of the scope, a note inserted for
+// every owner keeps the one it has.
+func (s noteScope) assign(note *model.Note) {
+	if !s.all {
+		note.OwnerID = s.owner
+	}
+}
+
+// scopedFilter is scopeOf(ctx).filter(filter).
+func scopedFilter(ctx context.Context, filter NoteFilter) (NoteFilter, error) {
+	scope, err := scopeOf(ctx)
+	if err != nil {
+		return filter, err
+	}
+	return scope.filter(filter), nil
+}
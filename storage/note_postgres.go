@@ -1,67 +1,140 @@
 package storage
 
 import (
+	"context"
+	"fmt"
 	"github.com/jinzhu/gorm"
 	"github.com/lyquocnam/go-note-learning/model"
+	"html"
+	"math"
+	"strings"
 )
 
+// postgresSearchText is the text of a note searches look into and
+// postgresSearchDocument its tsvector, idx_notes_search_content is an
+// index on this very expression so the searches can use it.
+const (
+	postgresSearchText     = "title || ' ' || content"
+	postgresSearchDocument = "to_tsvector('notes_search', " + postgresSearchText + ")"
+)
+
+// ts_headline delimits the matches with these control characters rather
+// than with <mark>, the headline is raw note text that must be escaped
+// before the marks are put in, see postgresSnippet. They are removed from
+// the text given to ts_headline so a note can't forge them.
+const (
+	postgresMarkStart    = "\x02"
+	postgresMarkStop     = "\x03"
+	postgresHeadlineText = "translate(" + postgresSearchText + ", chr(2) || chr(3), '')"
+)
+
+var postgresHeadlineOptions = fmt.Sprintf("StartSel=%s, StopSel=%s, MaxWords=35, MinWords=15", postgresMarkStart, postgresMarkStop)
+
+var postgresMarks = strings.NewReplacer(postgresMarkStart, "<mark>", postgresMarkStop, "</mark>")
+
+// postgresSnippet turns a headline of ts_headline into a snippet like the
+// ones of noteSnippet: HTML-escaped with the matches between <mark> and
+// </mark>.
+func postgresSnippet(headline string) string {
+	return postgresMarks.Replace(html.EscapeString(headline))
+}
+
+// notePostgresStorage is noteGormStorage on postgres, notes are searched
+// with the full-text search of postgres.
 type notePostgresStorage struct {
-	db *gorm.DB
+	*noteGormStorage
 }
 
 func NewNotePostgresStorage(db *gorm.DB) *notePostgresStorage {
-	return &notePostgresStorage{db: db}
+	return &notePostgresStorage{noteGormStorage: NewNoteGormStorage(db)}
 }
 
-func (n *notePostgresStorage) Get(id uint) (*model.Note, error) {
-	var note model.Note
-	err := n.db.New().First(&note, "id = ?", id).Error
-	if err != nil {
-		if gorm.IsRecordNotFoundError(err) {
-			return nil, nil
-		}
+// migratePostgresSearch creates the notes_search text search configuration,
+// the simple one that also removes accents, and the index searches use.
+func migratePostgresSearch(db *gorm.DB) error {
+	err := db.Exec("CREATE EXTENSION IF NOT EXISTS unaccent").Error
+	if err == nil {
+		err = db.Exec(`DO $$ BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = 'notes_search') THEN
+				CREATE TEXT SEARCH CONFIGURATION notes_search (COPY = simple);
+				ALTER TEXT SEARCH CONFIGURATION notes_search
+					ALTER MAPPING FOR hword, hword_part, word WITH unaccent, simple;
+			END IF;
+		END $$`).Error
+	}
+	if err == nil {
+		// the index of the titles only, before notes had a content
+		err = db.Exec("DROP INDEX IF EXISTS idx_notes_search").Error
 	}
-	return &note, err
+	if err == nil {
+		err = db.Exec("CREATE INDEX IF NOT EXISTS idx_notes_search_content ON notes USING GIN (" + postgresSearchDocument + ")").Error
+	}
+	return err
 }
 
-func (n *notePostgresStorage) GetByTitle(title string) (*model.Note, error) {
-	var note model.Note
-	err := n.db.New().First(&note, "title = ?", title).Error
+// Search ranks the matches with ts_rank and highlights them with
+// ts_headline.
+func (p *notePostgresStorage) Search(ctx context.Context, query string, opts NoteSearchOptions) ([]*model.NoteSearchResult, int, error) {
+	scope, err := scopeOf(ctx)
 	if err != nil {
-		if gorm.IsRecordNotFoundError(err) {
-			return nil, nil
-		}
+		return nil, 0, err
 	}
-	return &note, err
-}
-
-func (n *notePostgresStorage) GetList() ([]*model.Note, error) {
-	var notes []*model.Note
-	err := n.db.New().Find(&notes).Error
+	tsquery := prefixQuery(query)
+	if tsquery == "" {
+		return []*model.NoteSearchResult{}, 0, nil
+	}
+	db, err := p.conn(ctx)
 	if err != nil {
-		if gorm.IsRecordNotFoundError(err) {
-			return nil, nil
-		}
+		return nil, 0, err
 	}
-	return notes, err
-}
 
-func (n *notePostgresStorage) Insert(note *model.Note) (*model.Note, error) {
-	err := n.db.New().Create(&note).Error
-	return note, err
-}
+	match := postgresSearchDocument + " @@ to_tsquery('notes_search', ?)"
+	total := 0
+	err = gormScope(db.Model(model.Note{}), scope).Where(match, tsquery).Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
 
-func (n *notePostgresStorage) Update(id uint, note *model.Note) (*model.Note, error) {
-	err := n.db.New().Save(&note).Error
-	return note, err
-}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = math.MaxInt32
+	}
+	owned, args := "", []interface{}{tsquery}
+	if !scope.all {
+		owned = " AND owner_id = ?"
+		args = append(args, scope.owner)
+	}
+	var rows []struct {
+		model.Note
+		Rank    float64
+		Snippet string
+	}
+	err = db.Raw(`SELECT notes.*,
+			ts_rank(`+postgresSearchDocument+`, q) AS rank,
+			ts_headline('notes_search', `+postgresHeadlineText+`, q, ?) AS snippet
+		FROM notes, to_tsquery('notes_search', ?) q
+		WHERE deleted_at IS NULL AND `+postgresSearchDocument+` @@ q`+owned+`
+		ORDER BY rank DESC, id
+		LIMIT ? OFFSET ?`, append(append([]interface{}{postgresHeadlineOptions}, args...), limit, opts.Offset)...).Scan(&rows).Error
+	if err != nil {
+		return nil, 0, err
+	}
 
-func (n *notePostgresStorage) Delete(note *model.Note) error {
-	return n.db.New().Unscoped().Delete(note).Error
+	results := make([]*model.NoteSearchResult, len(rows))
+	notes := make([]*model.Note, len(rows))
+	for i := range rows {
+		notes[i] = &rows[i].Note
+		results[i] = &model.NoteSearchResult{Note: notes[i], Rank: rows[i].Rank, Snippet: postgresSnippet(rows[i].Snippet)}
+	}
+	return results, total, loadGormTags(db, notes...)
 }
 
-func (n *notePostgresStorage) Count(where interface{}, args ...interface{}) (int, error) {
-	count := 0
-	err := n.db.New().Model(model.Note{}).Where(where, args...).Count(&count).Error
-	return count, err
+// prefixQuery is the to_tsquery of query, every term matches as a prefix.
+// The terms only hold letters and digits, none of the tsquery operators.
+func prefixQuery(query string) string {
+	terms := searchTerms(query)
+	for i, term := range terms {
+		terms[i] = term + ":*"
+	}
+	return strings.Join(terms, " & ")
 }
@@ -0,0 +1,316 @@
+package storage
+
+import (
+	"context"
+	"github.com/lyquocnam/go-note-learning/model"
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// NoteSearchOptions pages the results of a search, they are ordered by
+// rank then by id.
+type NoteSearchOptions struct {
+	Limit  int // 0 means no limit
+	Offset int
+}
+
+// NoteSearcher is implemented by the storages searching notes themselves,
+// the others are searched through NewNoteSearchIndex.
+type NoteSearcher interface {
+	// Search returns the notes outside of the trash containing a word
+	// starting with every term of query, accents and case aside, and how
+	// many notes match in total.
+	Search(ctx context.Context, query string, opts NoteSearchOptions) ([]*model.NoteSearchResult, int, error)
+}
+
+// snippetWords is how many words a snippet keeps around the first match,
+// like the default MaxWords of ts_headline.
+const snippetWords = 35
+
+// searchText is the text of a note searches look into, the title then the
+// Markdown source of the content.
+func searchText(note *model.Note) string {
+	if note.Content == "" {
+		return note.Title
+	}
+	return note.Title + "\n" + note.Content
+}
+
+// searchWord is a word of a text, start and end are byte offsets.
+type searchWord struct {
+	start, end int
+	folded     string
+}
+
+// splitSearchWords splits text into words of letters and digits, the
+// combining marks of decomposed accents belong to their letter.
+func splitSearchWords(text string) []searchWord {
+	var words []searchWord
+	start := -1
+	for i, r := range text {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+		switch {
+		case inWord && start < 0:
+			start = i
+		case !inWord && start >= 0:
+			words = append(words, searchWord{start: start, end: i, folded: foldText(text[start:i])})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, searchWord{start: start, end: len(text), folded: foldText(text[start:])})
+	}
+	return words
+}
+
+// searchTerms returns the distinct folded words of query.
+func searchTerms(query string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, word := range splitSearchWords(query) {
+		if word.folded != "" && !seen[word.folded] {
+			seen[word.folded] = true
+			terms = append(terms, word.folded)
+		}
+	}
+	return terms
+}
+
+// foldRunes maps the accented letters, Vietnamese ones included, to their
+// base letter.
+var foldRunes = make(map[rune]rune)
+
+func init() {
+	for base, letters := range map[rune]string{
+		'a': "àáâãäåāăąạảấầẩẫậắằẳẵặ",
+		'c': "çćč",
+		'd': "đď",
+		'e': "èéêëēęěẹẻẽếềểễệ",
+		'i': "ìíîïīįỉị",
+		'n': "ñńň",
+		'o': "òóôõöøōơọỏốồổỗộớờởỡợ",
+		'u': "ùúûüūůưụủũứừửữự",
+		'y': "ýÿỳỵỷỹ",
+		's': "śš",
+		'z': "źżž",
+	} {
+		for _, letter := range letters {
+			foldRunes[letter] = base
+		}
+	}
+}
+
+// foldText lower cases text and removes its accents, like unaccent does.
+func foldText(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		r = unicode.ToLower(r)
+		if base, ok := foldRunes[r]; ok {
+			r = base
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// matchesTerm tells whether a folded word starts with one of terms.
+func matchesTerm(word string, terms []string) bool {
+	for _, term := range terms {
+		if strings.HasPrefix(word, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// noteSnippet returns up to snippetWords words of the text of note around
+// the first match, the matches are put between <mark> and </mark>. The
+// text is HTML-escaped, the marks are the only markup of a snippet.
+func noteSnippet(note *model.Note, terms []string) string {
+	text := searchText(note)
+	words := splitSearchWords(text)
+	if len(words) == 0 {
+		return ""
+	}
+
+	first := 0
+	for i, word := range words {
+		if matchesTerm(word.folded, terms) {
+			first = i
+			break
+		}
+	}
+	from := first - snippetWords/4
+	if from < 0 {
+		from = 0
+	}
+	to := from + snippetWords
+	if to > len(words) {
+		to = len(words)
+	}
+
+	var b strings.Builder
+	last := words[from].start
+	for _, word := range words[from:to] {
+		b.WriteString(html.EscapeString(text[last:word.start]))
+		if matchesTerm(word.folded, terms) {
+			b.WriteString("<mark>" + html.EscapeString(text[word.start:word.end]) + "</mark>")
+		} else {
+			b.WriteString(html.EscapeString(text[word.start:word.end]))
+		}
+		last = word.end
+	}
+	return b.String()
+}
+
+// noteTextIndex is an inverted index of the words of the notes, prefixes
+// are looked up in the sorted list of the words.
+type noteTextIndex struct {
+	postings map[string]map[uint]int // word → note id → occurrences
+	words    []string
+	notes    map[uint]*indexedNote
+}
+
+type indexedNote struct {
+	note  *model.Note
+	words []string
+}
+
+func newNoteTextIndex() *noteTextIndex {
+	return &noteTextIndex{
+		postings: make(map[string]map[uint]int),
+		notes:    make(map[uint]*indexedNote),
+	}
+}
+
+// put indexes note in place of its previous version.
+func (x *noteTextIndex) put(note *model.Note) {
+	x.remove(note.ID)
+	indexed := &indexedNote{note: copyNote(note)}
+	for _, word := range splitSearchWords(searchText(note)) {
+		if word.folded == "" {
+			continue
+		}
+		indexed.words = append(indexed.words, word.folded)
+		notes, ok := x.postings[word.folded]
+		if !ok {
+			notes = make(map[uint]int)
+			x.postings[word.folded] = notes
+			i := sort.SearchStrings(x.words, word.folded)
+			x.words = append(x.words, "")
+			copy(x.words[i+1:], x.words[i:])
+			x.words[i] = word.folded
+		}
+		notes[note.ID]++
+	}
+	x.notes[note.ID] = indexed
+}
+
+func (x *noteTextIndex) remove(id uint) {
+	indexed, ok := x.notes[id]
+	if !ok {
+		return
+	}
+	delete(x.notes, id)
+	for _, word := range indexed.words {
+		notes, ok := x.postings[word]
+		if !ok {
+			// a word the note repeats
+			continue
+		}
+		delete(notes, id)
+		if len(notes) == 0 {
+			delete(x.postings, word)
+			i := sort.SearchStrings(x.words, word)
+			x.words = append(x.words[:i], x.words[i+1:]...)
+		}
+	}
+}
+
+// search ranks the notes matching filter and every term by the tf-idf of
+// the words they matched, normalized by the length of the note. The notes
+// filter leaves out don't count in the idf either, the ranks don't depend
+// on notes the caller can't see.
+func (x *noteTextIndex) search(query string, filter NoteFilter, opts NoteSearchOptions) ([]*model.NoteSearchResult, int) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return []*model.NoteSearchResult{}, 0
+	}
+
+	matching := 0
+	for _, indexed := range x.notes {
+		if filter.Match(indexed.note) {
+			matching++
+		}
+	}
+
+	var ranks map[uint]float64
+	for _, term := range terms {
+		termRanks := make(map[uint]float64)
+		for i := sort.SearchStrings(x.words, term); i < len(x.words) && strings.HasPrefix(x.words[i], term); i++ {
+			counts := make(map[uint]int)
+			for id, count := range x.postings[x.words[i]] {
+				if filter.Match(x.notes[id].note) {
+					counts[id] = count
+				}
+			}
+			if len(counts) == 0 {
+				continue
+			}
+			idf := math.Log(1 + float64(matching)/float64(len(counts)))
+			for id, count := range counts {
+				termRanks[id] += float64(count) * idf
+			}
+		}
+		if ranks == nil {
+			ranks = termRanks
+			continue
+		}
+		for id := range ranks {
+			if rank, ok := termRanks[id]; ok {
+				ranks[id] += rank
+			} else {
+				delete(ranks, id)
+			}
+		}
+	}
+
+	results := make([]*model.NoteSearchResult, 0, len(ranks))
+	for id, rank := range ranks {
+		indexed := x.notes[id]
+		results = append(results, &model.NoteSearchResult{
+			Note: indexed.note,
+			Rank: rank / math.Sqrt(float64(len(indexed.words))),
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].Note.ID < results[j].Note.ID
+	})
+
+	total := len(results)
+	if opts.Offset >= len(results) {
+		results = results[:0]
+	} else {
+		results = results[opts.Offset:]
+	}
+	if opts.Limit > 0 && len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+	for i, result := range results {
+		results[i] = &model.NoteSearchResult{
+			Note:    copyNote(result.Note),
+			Rank:    result.Rank,
+			Snippet: noteSnippet(result.Note, terms),
+		}
+	}
+	return results, total
+}
@@ -0,0 +1,213 @@
+package storage
+
+import (
+	"context"
+	"github.com/lyquocnam/go-note-learning/model"
+	"sync"
+)
+
+// noteSearchIndex searches the notes of a storage without full-text search
+// in an in-memory inverted index. The index is built from the storage on
+// the first search and kept up to date by the writes made through it,
+// changes made behind its back, like Markdown files edited by hand, are
+// not seen until the process restarts.
+type noteSearchIndex struct {
+	NoteStorage
+	mu    sync.RWMutex
+	index *noteTextIndex // nil until it is built
+}
+
+// NewNoteSearchIndex adds a pure Go full-text search to s.
+func NewNoteSearchIndex(s NoteStorage) *noteSearchIndex {
+	return &noteSearchIndex{NoteStorage: s}
+}
+
+func (i *noteSearchIndex) Search(ctx context.Context, query string, opts NoteSearchOptions) ([]*model.NoteSearchResult, int, error) {
+	filter, err := scopedFilter(ctx, NoteFilter{})
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := i.build(ctx); err != nil {
+		return nil, 0, err
+	}
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	results, total := i.index.search(query, filter, opts)
+	return results, total, nil
+}
+
+// build reads every note outside of the trash, whoever owns it, into the
+// index when it is not built yet.
+func (i *noteSearchIndex) build(ctx context.Context) error {
+	i.mu.RLock()
+	built := i.index != nil
+	i.mu.RUnlock()
+	if built {
+		return nil
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.index != nil {
+		return nil
+	}
+	notes, err := i.NoteStorage.GetList(AllOwners(ctx), NoteFilter{}, NoteListOptions{})
+	if err != nil {
+		return err
+	}
+	index := newNoteTextIndex()
+	for _, note := range notes {
+		index.put(note)
+	}
+	i.index = index
+	return nil
+}
+
+// refresh indexes the stored version of the notes ids. The index is
+// dropped, to be built again, when a note can't be read.
+func (i *noteSearchIndex) refresh(ids ...uint) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.index == nil {
+		return
+	}
+	for _, id := range ids {
+		// the write is done, it must be indexed even if ctx expired
+		note, err := i.NoteStorage.Get(AllOwners(context.Background()), id)
+		if err != nil {
+			i.index = nil
+			return
+		}
+		if note == nil {
+			i.index.remove(id)
+		} else {
+			i.index.put(note)
+		}
+	}
+}
+
+func (i *noteSearchIndex) drop() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.index = nil
+}
+
+func (i *noteSearchIndex) Insert(ctx context.Context, note *model.Note) (*model.Note, error) {
+	note, err := i.NoteStorage.Insert(ctx, note)
+	if err == nil {
+		i.refresh(note.ID)
+	}
+	return note, err
+}
+
+func (i *noteSearchIndex) Update(ctx context.Context, id uint, note *model.Note) (*model.Note, error) {
+	note, err := i.NoteStorage.Update(ctx, id, note)
+	if err == nil {
+		i.refresh(id)
+	}
+	return note, err
+}
+
+func (i *noteSearchIndex) Delete(ctx context.Context, note *model.Note) error {
+	err := i.NoteStorage.Delete(ctx, note)
+	if err == nil {
+		i.refresh(note.ID)
+	}
+	return err
+}
+
+func (i *noteSearchIndex) Restore(ctx context.Context, note *model.Note) error {
+	err := i.NoteStorage.Restore(ctx, note)
+	if err == nil {
+		i.refresh(note.ID)
+	}
+	return err
+}
+
+func (i *noteSearchIndex) Purge(ctx context.Context, note *model.Note) error {
+	err := i.NoteStorage.Purge(ctx, note)
+	if err == nil {
+		i.refresh(note.ID)
+	}
+	return err
+}
+
+// RenameTag drops the index, the notes it changed are not known.
+func (i *noteSearchIndex) RenameTag(ctx context.Context, from, to string) (int, error) {
+	count, err := i.NoteStorage.RenameTag(ctx, from, to)
+	if count > 0 {
+		i.drop()
+	}
+	return count, err
+}
+
+func (i *noteSearchIndex) Batch(ctx context.Context, ops []NoteOp, atomic bool) ([]NoteOpResult, error) {
+	return batchNotes(ctx, i, ops, atomic)
+}
+
+// WithTx indexes the notes fn wrote once the unit of work succeeded.
+func (i *noteSearchIndex) WithTx(ctx context.Context, fn func(s NoteStorage) error) error {
+	var tx *noteSearchTx
+	err := i.NoteStorage.WithTx(ctx, func(s NoteStorage) error {
+		// fn runs again when the unit of work is retried
+		tx = &noteSearchTx{NoteStorage: s}
+		return fn(tx)
+	})
+	switch {
+	case err != nil:
+	case tx.renamed:
+		i.drop()
+	default:
+		i.refresh(tx.written...)
+	}
+	return err
+}
+
+// noteSearchTx records the notes written in a unit of work.
+type noteSearchTx struct {
+	NoteStorage
+	written []uint
+	renamed bool // a tag was renamed, the notes written are unknown
+}
+
+func (t *noteSearchTx) Insert(ctx context.Context, note *model.Note) (*model.Note, error) {
+	note, err := t.NoteStorage.Insert(ctx, note)
+	if err == nil {
+		t.written = append(t.written, note.ID)
+	}
+	return note, err
+}
+
+func (t *noteSearchTx) Update(ctx context.Context, id uint, note *model.Note) (*model.Note, error) {
+	t.written = append(t.written, id)
+	return t.NoteStorage.Update(ctx, id, note)
+}
+
+func (t *noteSearchTx) Delete(ctx context.Context, note *model.Note) error {
+	t.written = append(t.written, note.ID)
+	return t.NoteStorage.Delete(ctx, note)
+}
+
+func (t *noteSearchTx) Restore(ctx context.Context, note *model.Note) error {
+	t.written = append(t.written, note.ID)
+	return t.NoteStorage.Restore(ctx, note)
+}
+
+func (t *noteSearchTx) Purge(ctx context.Context, note *model.Note) error {
+	t.written = append(t.written, note.ID)
+	return t.NoteStorage.Purge(ctx, note)
+}
+
+func (t *noteSearchTx) RenameTag(ctx context.Context, from, to string) (int, error) {
+	t.renamed = true
+	return t.NoteStorage.RenameTag(ctx, from, to)
+}
+
+func (t *noteSearchTx) Batch(ctx context.Context, ops []NoteOp, atomic bool) ([]NoteOpResult, error) {
+	return batchNotes(ctx, t, ops, atomic)
+}
+
+func (t *noteSearchTx) WithTx(ctx context.Context, fn func(s NoteStorage) error) error {
+	return fn(t)
+}
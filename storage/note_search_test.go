@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"context"
+	"github.com/lyquocnam/go-note-learning/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+// testNoteSearch is the behaviour every NoteSearcher must share, newStorage
+// returns an empty storage implementing NoteSearcher.
+func testNoteSearch(t *testing.T, newStorage func(t *testing.T) (NoteStorage, func())) {
+	ctx := ownerContext(1)
+	s, closeFn := newStorage(t)
+	defer closeFn()
+	searcher := s.(NoteSearcher)
+
+	insert := func(title string) *model.Note {
+		note, err := s.Insert(ctx, &model.Note{Title: title})
+		require.NoError(t, err)
+		return note
+	}
+	search := func(query string, opts NoteSearchOptions) ([]string, int) {
+		results, total, err := searcher.Search(ctx, query, opts)
+		require.NoError(t, err)
+		titles := make([]string, len(results))
+		for i, result := range results {
+			titles[i] = result.Note.Title
+		}
+		return titles, total
+	}
+
+	hanoi := insert("Du lịch Hà Nội mùa thu")
+	insert("Mua sữa")
+	insert("Đi chợ mua rau, mua thịt")
+	trashed := insert("Ghé Hà Nội")
+	require.NoError(t, s.Delete(ctx, trashed))
+
+	titles, total := search("ha noi", NoteSearchOptions{})
+	assert.Equal(t, []string{"Du lịch Hà Nội mùa thu"}, titles)
+	assert.Equal(t, 1, total)
+
+	results, _, err := searcher.Search(ctx, "HÀ nội", NoteSearchOptions{})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, hanoi.ID, results[0].Note.ID)
+	assert.Equal(t, "Du lịch <mark>Hà</mark> <mark>Nội</mark> mùa thu", results[0].Snippet)
+	assert.True(t, results[0].Rank > 0)
+
+	// mua matches mùa too, the note repeating it ranks first
+	titles, total = search("mua", NoteSearchOptions{})
+	assert.Equal(t, 3, total)
+	assert.Equal(t, "Đi chợ mua rau, mua thịt", titles[0])
+	titles, total = search("mua", NoteSearchOptions{Limit: 1, Offset: 1})
+	assert.Len(t, titles, 1)
+	assert.Equal(t, 3, total)
+
+	titles, _ = search("di cho", NoteSearchOptions{})
+	assert.Equal(t, []string{"Đi chợ mua rau, mua thịt"}, titles)
+	titles, _ = search("sữ", NoteSearchOptions{})
+	assert.Equal(t, []string{"Mua sữa"}, titles)
+	titles, total = search("hà nội paris", NoteSearchOptions{})
+	assert.Empty(t, titles)
+	assert.Zero(t, total)
+	titles, _ = search("?!", NoteSearchOptions{})
+	assert.Empty(t, titles)
+
+	// the content is searched too
+	recipe, err := s.Insert(ctx, &model.Note{Title: "Công thức", Content: "Phở bò: *bánh phở*, thịt bò, hành"})
+	require.NoError(t, err)
+	results, _, err = searcher.Search(ctx, "pho bo", NoteSearchOptions{})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, recipe.ID, results[0].Note.ID)
+	assert.Contains(t, results[0].Snippet, "<mark>Phở</mark> <mark>bò</mark>")
+	require.NoError(t, s.Purge(ctx, recipe))
+
+	// the markup of the notes is escaped, only the marks are HTML
+	script, err := s.Insert(ctx, &model.Note{Title: "Trap", Content: `<script>alert("xss")</script> & <b>bold</b>`})
+	require.NoError(t, err)
+	results, _, err = searcher.Search(ctx, "alert", NoteSearchOptions{})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Contains(t, results[0].Snippet, "<mark>alert</mark>")
+	assert.NotContains(t, results[0].Snippet, "<script>")
+	assert.NotContains(t, results[0].Snippet, "<b>")
+	assert.Contains(t, results[0].Snippet, "&lt;")
+	require.NoError(t, s.Purge(ctx, script))
+
+	// nor can the bytes postgres delimits the matches with
+	forged, err := s.Insert(ctx, &model.Note{Title: "Forged", Content: "\x02fake\x03 \x03 milk \x02"})
+	require.NoError(t, err)
+	results, _, err = searcher.Search(ctx, "milk", NoteSearchOptions{})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Contains(t, results[0].Snippet, "<mark>milk</mark>")
+	assert.Equal(t, 1, strings.Count(results[0].Snippet, "<mark>"), results[0].Snippet)
+	assert.Equal(t, 1, strings.Count(results[0].Snippet, "</mark>"), results[0].Snippet)
+	require.NoError(t, s.Purge(ctx, forged))
+
+	// the writes are searched right away
+	note, err := s.Get(ctx, hanoi.ID)
+	require.NoError(t, err)
+	note.Title = "Du lịch Đà Lạt"
+	_, err = s.Update(ctx, note.ID, note)
+	require.NoError(t, err)
+	require.NoError(t, s.Restore(ctx, trashed))
+	titles, _ = search("ha noi", NoteSearchOptions{})
+	assert.Equal(t, []string{"Ghé Hà Nội"}, titles)
+	titles, _ = search("da lat", NoteSearchOptions{})
+	assert.Equal(t, []string{"Du lịch Đà Lạt"}, titles)
+
+	err = s.WithTx(ctx, func(tx NoteStorage) error {
+		_, err := tx.Insert(ctx, &model.Note{Title: "Hà Nội phố"})
+		return err
+	})
+	require.NoError(t, err)
+	_, total = search("ha noi", NoteSearchOptions{})
+	assert.Equal(t, 2, total)
+
+	// the results carry the renamed tags
+	note, err = s.Get(ctx, hanoi.ID)
+	require.NoError(t, err)
+	note.Tags = []string{"travel"}
+	_, err = s.Update(ctx, note.ID, note)
+	require.NoError(t, err)
+	_, err = s.RenameTag(ctx, "travel", "trips")
+	require.NoError(t, err)
+	results, _, err = searcher.Search(ctx, "da lat", NoteSearchOptions{})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, []string{"trips"}, results[0].Note.Tags)
+
+	// the notes of other users are never found
+	bobCtx := ownerContext(2)
+	bob, err := s.Insert(bobCtx, &model.Note{Title: "Hà Nội của Bob"})
+	require.NoError(t, err)
+	_, total = search("ha noi", NoteSearchOptions{})
+	assert.Equal(t, 2, total)
+	results, total, err = searcher.Search(bobCtx, "ha noi", NoteSearchOptions{})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, bob.ID, results[0].Note.ID)
+	_, _, err = searcher.Search(context.Background(), "ha noi", NoteSearchOptions{})
+	assert.Equal(t, ErrNoOwner, err)
+}
+
+func TestNoteSearchIndex(t *testing.T) {
+	testNoteSearch(t, func(t *testing.T) (NoteStorage, func()) {
+		return NewNoteSearchIndex(NewNoteMemoryStorage()), func() {}
+	})
+}
+
+func TestFoldText(t *testing.T) {
+	cases := map[string]string{
+		"Hà Nội":                "ha noi",
+		"ĐƯỜNG PHỐ":             "duong pho",
+		"Nguyễn Thị Ánh":        "nguyen thi anh",
+		"Tiếng Việt có dấu":     "tieng viet co dau",
+		"Cafe\u0301 decomposed": "cafe decomposed",
+		"日本語":                   "日本語",
+	}
+	for text, folded := range cases {
+		assert.Equal(t, folded, foldText(text), text)
+	}
+}
+
+func TestNoteSnippet(t *testing.T) {
+	note := &model.Note{Title: "Mua sữa, mua bánh mì!"}
+	assert.Equal(t, "<mark>Mua</mark> sữa, <mark>mua</mark> bánh mì", noteSnippet(note, []string{"mua"}))
+	assert.Equal(t, "Mua <mark>sữa</mark>, mua <mark>bánh</mark> mì", noteSnippet(note, searchTerms("SUA ban")))
+	assert.Equal(t, "", noteSnippet(&model.Note{Title: "!!"}, []string{"mua"}))
+
+	note = &model.Note{Title: "Tags", Content: `<i>1 < 2</i> & <mark>sữa`}
+	assert.Equal(t, "Tags\n&lt;i&gt;1 &lt; 2&lt;/i&gt; &amp; &lt;mark&gt;<mark>sữa</mark>", noteSnippet(note, []string{"sua"}))
+}
+
+func TestPostgresSnippet(t *testing.T) {
+	headline := "1 < 2 & " + postgresMarkStart + "<b>Hà</b>" + postgresMarkStop + " Nội"
+	assert.Equal(t, "1 &lt; 2 &amp; <mark>&lt;b&gt;Hà&lt;/b&gt;</mark> Nội", postgresSnippet(headline))
+}
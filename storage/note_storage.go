@@ -1,13 +1,59 @@
 package storage
 
-import "github.com/lyquocnam/go-note-learning/model"
+import (
+	"context"
+	"errors"
+	"github.com/lyquocnam/go-note-learning/model"
+	"github.com/lyquocnam/go-note-learning/ulid"
+)
+
+var (
+	ErrVersionConflict = errors.New("storage: note was modified concurrently")
+	// ErrDuplicateTitle is returned when a write would give two notes
+	// outside of the trash the same title.
+	ErrDuplicateTitle = errors.New("storage: note title already exists")
+)
 
 type NoteStorage interface {
-	Get(id uint) (*model.Note, error)
-	GetByTitle(title string) (*model.Note, error)
-	GetList() ([]*model.Note, error)
-	Insert(note *model.Note) (*model.Note, error)
-	Update(id uint, note *model.Note) (*model.Note, error)
-	Delete(note *model.Note) error
-	Count(where interface{}, args ...interface{}) (int, error)
+	Get(ctx context.Context, id uint) (*model.Note, error)
+	Find(ctx context.Context, filter NoteFilter) (*model.Note, error)
+	GetList(ctx context.Context, filter NoteFilter, opts NoteListOptions) ([]*model.Note, error)
+	// Insert gives the note its ID and, unless it has one, a PublicID.
+	Insert(ctx context.Context, note *model.Note) (*model.Note, error)
+	// Update only succeeds when note.Version is still the stored version, it
+	// returns ErrVersionConflict otherwise and increments the version.
+	Update(ctx context.Context, id uint, note *model.Note) (*model.Note, error)
+	// Delete moves the note to the trash, Restore brings it back and Purge
+	// removes it for good. Titles only have to be unique among the notes
+	// that are not in the trash.
+	Delete(ctx context.Context, note *model.Note) error
+	Restore(ctx context.Context, note *model.Note) error
+	Purge(ctx context.Context, note *model.Note) error
+	Count(ctx context.Context, filter NoteFilter) (int, error)
+	// Tags returns the tags of the notes outside of the trash with how many
+	// notes have them, sorted by name.
+	Tags(ctx context.Context) ([]*model.Tag, error)
+	// RenameTag replaces the tag from by to on every note, the trash
+	// included, a note having both keeps to once. The version of the notes
+	// it changes is incremented, it returns how many there are.
+	RenameTag(ctx context.Context, from, to string) (int, error)
+	// Batch runs ops in order and returns one result per operation. When
+	// atomic, either every operation is applied or none is, the failing
+	// one keeps its error and the others get ErrBatchAborted. The error is
+	// only set when the batch itself could not run. Inside WithTx, fn must
+	// return an error for a failed atomic batch to be rolled back.
+	Batch(ctx context.Context, ops []NoteOp, atomic bool) ([]NoteOpResult, error)
+	// WithTx runs fn with a storage whose writes form a unit of work, they
+	// are kept when fn returns nil and undone when it returns an error,
+	// which WithTx returns. fn may run more than once when the unit of work
+	// conflicts with another one, so it must have no other side effects.
+	// WithTx on the storage given to fn joins the running unit of work.
+	WithTx(ctx context.Context, fn func(s NoteStorage) error) error
+}
+
+// assignPublicID gives note a new ULID unless it already has a public id.
+func assignPublicID(note *model.Note) {
+	if note.PublicID == "" {
+		note.PublicID = ulid.New().String()
+	}
 }
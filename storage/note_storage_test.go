@@ -0,0 +1,1438 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/lyquocnam/go-note-learning/auth"
+	"github.com/lyquocnam/go-note-learning/model"
+	"github.com/lyquocnam/go-note-learning/ulid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+	"gopkg.in/mgo.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ownerContext is the context of the calls made on behalf of the user id.
+func ownerContext(id uint) context.Context {
+	return auth.WithIdentity(context.Background(), &auth.Identity{UserID: id})
+}
+
+// testNoteStorage is the behaviour every NoteStorage backend must share.
+// newStorage must return an empty storage and a func releasing it.
+func testNoteStorage(t *testing.T, newStorage func(t *testing.T) (NoteStorage, func())) {
+	ctx := ownerContext(1)
+
+	t.Run("insert and get", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+
+		inserted, err := s.Insert(ctx, &model.Note{Title: "Hello", IsCompleted: true, Content: "# Hi\n\n- *one*\n"})
+		require.NoError(t, err)
+		require.NotZero(t, inserted.ID)
+		assert.False(t, inserted.CreatedAt.IsZero())
+		assert.False(t, inserted.UpdatedAt.IsZero())
+
+		note, err := s.Get(ctx, inserted.ID)
+		require.NoError(t, err)
+		require.NotNil(t, note)
+		assert.Equal(t, inserted.ID, note.ID)
+		assert.Equal(t, "Hello", note.Title)
+		assert.True(t, note.IsCompleted)
+		assert.Equal(t, "# Hi\n\n- *one*\n", note.Content)
+	})
+
+	t.Run("ids are unique", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+
+		first, err := s.Insert(ctx, &model.Note{Title: "first"})
+		require.NoError(t, err)
+		second, err := s.Insert(ctx, &model.Note{Title: "second"})
+		require.NoError(t, err)
+		assert.NotEqual(t, first.ID, second.ID)
+	})
+
+	t.Run("public ids", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+
+		first, err := s.Insert(ctx, &model.Note{Title: "first"})
+		require.NoError(t, err)
+		second, err := s.Insert(ctx, &model.Note{Title: "second"})
+		require.NoError(t, err)
+		_, err = ulid.Parse(first.PublicID)
+		require.NoError(t, err)
+		assert.True(t, first.PublicID < second.PublicID, "public ids sort in insertion order")
+
+		note, err := s.Find(ctx, NoteFilter{}.WithPublicID(second.PublicID))
+		require.NoError(t, err)
+		require.NotNil(t, note)
+		assert.Equal(t, second.ID, note.ID)
+
+		// the public id can't be changed nor lost by an update
+		note.PublicID = ""
+		note.Title = "renamed"
+		_, err = s.Update(ctx, note.ID, note)
+		require.NoError(t, err)
+		assert.Equal(t, second.PublicID, note.PublicID)
+		note, err = s.Get(ctx, second.ID)
+		require.NoError(t, err)
+		assert.Equal(t, second.PublicID, note.PublicID)
+
+		require.NoError(t, s.Delete(ctx, note))
+		note, err = s.Find(ctx, NoteFilter{}.WithPublicID(second.PublicID))
+		require.NoError(t, err)
+		assert.Nil(t, note)
+		note, err = s.Find(ctx, NoteFilter{}.WithPublicID(second.PublicID).InTrash())
+		require.NoError(t, err)
+		require.NotNil(t, note)
+
+		require.NoError(t, s.Purge(ctx, note))
+		count, err := s.Count(ctx, NoteFilter{}.WithPublicID(second.PublicID).WithDeleted())
+		require.NoError(t, err)
+		assert.Zero(t, count)
+	})
+
+	t.Run("get missing note", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+
+		note, err := s.Get(ctx, 12345)
+		assert.NoError(t, err)
+		assert.Nil(t, note)
+	})
+
+	t.Run("find by title", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+
+		inserted, err := s.Insert(ctx, &model.Note{Title: "Hello"})
+		require.NoError(t, err)
+
+		note, err := s.Find(ctx, NoteFilter{}.WithTitle("Hello"))
+		require.NoError(t, err)
+		require.NotNil(t, note)
+		assert.Equal(t, inserted.ID, note.ID)
+
+		note, err = s.Find(ctx, NoteFilter{}.WithTitle("missing"))
+		assert.NoError(t, err)
+		assert.Nil(t, note)
+	})
+
+	t.Run("title is unique", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+
+		_, err := s.Insert(ctx, &model.Note{Title: "Hello"})
+		require.NoError(t, err)
+		_, err = s.Insert(ctx, &model.Note{Title: "Hello"})
+		assert.Equal(t, ErrDuplicateTitle, err)
+
+		other, err := s.Insert(ctx, &model.Note{Title: "World"})
+		require.NoError(t, err)
+		other.Title = "Hello"
+		_, err = s.Update(ctx, other.ID, other)
+		assert.Equal(t, ErrDuplicateTitle, err)
+	})
+
+	t.Run("title is unique per user", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+
+		ann, err := s.Insert(ctx, &model.Note{Title: "Hello"})
+		require.NoError(t, err)
+		assert.Equal(t, uint(1), ann.OwnerID)
+		bob, err := s.Insert(ownerContext(2), &model.Note{Title: "Hello"})
+		require.NoError(t, err)
+		assert.Equal(t, uint(2), bob.OwnerID)
+		_, err = s.Insert(ownerContext(2), &model.Note{Title: "Hello"})
+		assert.Equal(t, ErrDuplicateTitle, err)
+
+		note, err := s.Find(ownerContext(2), NoteFilter{}.WithTitle("Hello"))
+		require.NoError(t, err)
+		require.NotNil(t, note)
+		assert.Equal(t, bob.ID, note.ID)
+
+		// bob's note moves to the trash and back while ann keeps hers
+		require.NoError(t, s.Delete(ownerContext(2), bob))
+		require.NoError(t, s.Restore(ownerContext(2), bob))
+	})
+
+	t.Run("adopt notes", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+		adopter, ok := s.(NoteAdopter)
+		if !ok {
+			t.Skip("the storage holds no note stored before accounts")
+		}
+
+		// inserted for every owner, the notes keep the owner they have: none
+		all := AllOwners(context.Background())
+		legacy, err := s.Insert(all, &model.Note{Title: "Legacy", Tags: []string{"old"}})
+		require.NoError(t, err)
+		taken, err := s.Insert(all, &model.Note{Title: "Taken"})
+		require.NoError(t, err)
+		trashed, err := s.Insert(all, &model.Note{Title: "Taken again"})
+		require.NoError(t, err)
+		require.NoError(t, s.Delete(all, trashed))
+		_, err = s.Insert(ctx, &model.Note{Title: "Taken"})
+		require.NoError(t, err)
+		_, err = s.Insert(ctx, &model.Note{Title: "Taken again"})
+		require.NoError(t, err)
+		other, err := s.Insert(ownerContext(2), &model.Note{Title: "Other"})
+		require.NoError(t, err)
+
+		count, err := adopter.AdoptNotes(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+		got, err := s.Get(ctx, legacy.ID)
+		require.NoError(t, err)
+		require.NotNil(t, got)
+		assert.Equal(t, uint(1), got.OwnerID)
+		assert.Equal(t, legacy.Version, got.Version)
+		got, err = s.Find(ctx, NoteFilter{}.WithID(trashed.ID).InTrash())
+		require.NoError(t, err)
+		assert.NotNil(t, got, "a note in the trash has no title to clash with")
+		got, err = s.Get(ctx, taken.ID)
+		require.NoError(t, err)
+		assert.Nil(t, got, "the title is used by a note of the owner")
+		got, err = s.Get(all, taken.ID)
+		require.NoError(t, err)
+		require.NotNil(t, got)
+		assert.Equal(t, uint(0), got.OwnerID)
+		got, err = s.Get(ownerContext(2), other.ID)
+		require.NoError(t, err)
+		assert.NotNil(t, got)
+
+		// the adopted title is taken now
+		_, err = s.Insert(ctx, &model.Note{Title: "Legacy"})
+		assert.Equal(t, ErrDuplicateTitle, err)
+		count, err = adopter.AdoptNotes(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+
+	t.Run("notes of other users", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+		bobCtx := ownerContext(2)
+
+		ann, err := s.Insert(ctx, &model.Note{Title: "Ann", Tags: []string{"work"}})
+		require.NoError(t, err)
+		trashed, err := s.Insert(ctx, &model.Note{Title: "Trashed", Tags: []string{"work"}})
+		require.NoError(t, err)
+		require.NoError(t, s.Delete(ctx, trashed))
+		bob, err := s.Insert(bobCtx, &model.Note{Title: "Bob", Tags: []string{"home"}})
+		require.NoError(t, err)
+
+		// an owner set by the caller is replaced
+		forged, err := s.Insert(bobCtx, &model.Note{Title: "Forged", OwnerID: 1})
+		require.NoError(t, err)
+		assert.Equal(t, uint(2), forged.OwnerID)
+
+		for name, filter := range map[string]NoteFilter{
+			"id":        NoteFilter{}.WithID(ann.ID),
+			"public id": NoteFilter{}.WithPublicID(ann.PublicID),
+			"title":     NoteFilter{}.WithTitle("Ann"),
+			"trash":     NoteFilter{}.WithID(trashed.ID).InTrash(),
+		} {
+			note, err := s.Find(bobCtx, filter)
+			assert.NoError(t, err, name)
+			assert.Nil(t, note, name)
+			count, err := s.Count(bobCtx, filter)
+			assert.NoError(t, err, name)
+			assert.Zero(t, count, name)
+		}
+		note, err := s.Get(bobCtx, ann.ID)
+		assert.NoError(t, err)
+		assert.Nil(t, note)
+
+		// the owner of a filter is replaced too
+		notes, err := s.GetList(bobCtx, NoteFilter{}.WithDeleted().WithOwner(1), NoteListOptions{})
+		require.NoError(t, err)
+		require.Len(t, notes, 2)
+		assert.Equal(t, bob.ID, notes[0].ID)
+		assert.Equal(t, forged.ID, notes[1].ID)
+		tags, err := s.Tags(bobCtx)
+		require.NoError(t, err)
+		assert.Equal(t, []*model.Tag{{Name: "home", Count: 1}}, tags)
+
+		// the writes behave as if ann's notes did not exist
+		stolen := *ann
+		stolen.Title = "Stolen"
+		_, err = s.Update(bobCtx, ann.ID, &stolen)
+		assert.Error(t, err)
+		assert.NotEqual(t, ErrVersionConflict, err)
+		assert.NoError(t, s.Delete(bobCtx, ann))
+		assert.Error(t, s.Restore(bobCtx, trashed))
+		assert.NoError(t, s.Purge(bobCtx, trashed))
+		count, err := s.RenameTag(bobCtx, "work", "stolen")
+		require.NoError(t, err)
+		assert.Zero(t, count)
+
+		note, err = s.Get(ctx, ann.ID)
+		require.NoError(t, err)
+		require.NotNil(t, note)
+		assert.Equal(t, "Ann", note.Title)
+		assert.Equal(t, ann.Version, note.Version)
+		assert.Equal(t, []string{"work"}, note.Tags)
+		note, err = s.Find(ctx, NoteFilter{}.WithID(trashed.ID).InTrash())
+		require.NoError(t, err)
+		assert.NotNil(t, note)
+
+		// renaming a tag both users have only changes the notes of the caller
+		_, err = s.Insert(bobCtx, &model.Note{Title: "Bob at work", Tags: []string{"work"}})
+		require.NoError(t, err)
+		count, err = s.RenameTag(bobCtx, "work", "job")
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+		tags, err = s.Tags(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []*model.Tag{{Name: "work", Count: 1}}, tags)
+		tags, err = s.Tags(bobCtx)
+		require.NoError(t, err)
+		assert.Equal(t, []*model.Tag{{Name: "home", Count: 1}, {Name: "job", Count: 1}}, tags)
+
+		// the maintenance jobs see every note
+		count, err = s.Count(AllOwners(context.Background()), NoteFilter{}.WithDeleted())
+		require.NoError(t, err)
+		assert.Equal(t, 5, count)
+
+		// AsOwner and AllOwners win over the identity, like for the notes
+		// shared with the caller
+		asAnn := AsOwner(bobCtx, 1)
+		note, err = s.Find(asAnn, NoteFilter{}.WithPublicID(ann.PublicID))
+		require.NoError(t, err)
+		require.NotNil(t, note)
+		note.Title = "Shared"
+		updated, err := s.Update(asAnn, ann.ID, note)
+		require.NoError(t, err)
+		assert.Equal(t, uint(1), updated.OwnerID)
+		note, err = s.Find(asAnn, NoteFilter{}.WithPublicID(bob.PublicID))
+		require.NoError(t, err)
+		assert.Nil(t, note)
+
+		notes, err = s.GetList(AllOwners(bobCtx), NoteFilter{}.WithPublicIDs(ann.PublicID, bob.PublicID, trashed.PublicID), NoteListOptions{})
+		require.NoError(t, err)
+		require.Len(t, notes, 2)
+		assert.Equal(t, "Shared", notes[0].Title)
+		assert.Equal(t, bob.ID, notes[1].ID)
+		count, err = s.Count(AllOwners(bobCtx), NoteFilter{}.WithPublicIDs())
+		require.NoError(t, err)
+		assert.Zero(t, count)
+	})
+
+	t.Run("no owner", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+
+		note, err := s.Insert(ctx, &model.Note{Title: "Hello"})
+		require.NoError(t, err)
+
+		anonymous := context.Background()
+		_, err = s.Insert(anonymous, &model.Note{Title: "World"})
+		assert.Equal(t, ErrNoOwner, err)
+		_, err = s.Get(anonymous, note.ID)
+		assert.Equal(t, ErrNoOwner, err)
+		_, err = s.GetList(anonymous, NoteFilter{}, NoteListOptions{})
+		assert.Equal(t, ErrNoOwner, err)
+		_, err = s.Count(anonymous, NoteFilter{})
+		assert.Equal(t, ErrNoOwner, err)
+		_, err = s.Update(anonymous, note.ID, note)
+		assert.Equal(t, ErrNoOwner, err)
+		assert.Equal(t, ErrNoOwner, s.Delete(anonymous, note))
+		assert.Equal(t, ErrNoOwner, s.Purge(anonymous, note))
+		_, err = s.Tags(anonymous)
+		assert.Equal(t, ErrNoOwner, err)
+		_, err = s.RenameTag(anonymous, "work", "job")
+		assert.Equal(t, ErrNoOwner, err)
+
+		note, err = s.Get(ctx, note.ID)
+		require.NoError(t, err)
+		assert.NotNil(t, note)
+	})
+
+	t.Run("concurrent inserts with the same title", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 20)
+		for i := 0; i < cap(errs); i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := s.Insert(ctx, &model.Note{Title: "Hello"})
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		inserted := 0
+		for err := range errs {
+			if err == nil {
+				inserted++
+				continue
+			}
+			assert.Equal(t, ErrDuplicateTitle, err)
+		}
+		assert.Equal(t, 1, inserted)
+		count, err := s.Count(ctx, NoteFilter{}.WithTitle("Hello"))
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("get list", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+
+		notes, err := s.GetList(ctx, NoteFilter{}, NoteListOptions{})
+		require.NoError(t, err)
+		assert.Empty(t, notes)
+
+		for _, title := range []string{"a", "b", "c"} {
+			_, err := s.Insert(ctx, &model.Note{Title: title})
+			require.NoError(t, err)
+		}
+
+		notes, err = s.GetList(ctx, NoteFilter{}, NoteListOptions{})
+		require.NoError(t, err)
+		titles := make([]string, 0, len(notes))
+		for _, note := range notes {
+			titles = append(titles, note.Title)
+		}
+		assert.ElementsMatch(t, []string{"a", "b", "c"}, titles)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+
+		inserted, err := s.Insert(ctx, &model.Note{Title: "Hello"})
+		require.NoError(t, err)
+
+		inserted.Title = "World"
+		inserted.IsCompleted = true
+		inserted.Content = "    indented code"
+		updated, err := s.Update(ctx, inserted.ID, inserted)
+		require.NoError(t, err)
+		assert.Equal(t, "World", updated.Title)
+
+		note, err := s.Get(ctx, inserted.ID)
+		require.NoError(t, err)
+		require.NotNil(t, note)
+		assert.Equal(t, "World", note.Title)
+		assert.True(t, note.IsCompleted)
+		assert.Equal(t, "    indented code", note.Content)
+
+		note, err = s.Find(ctx, NoteFilter{}.WithTitle("Hello"))
+		assert.NoError(t, err)
+		assert.Nil(t, note)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+
+		inserted, err := s.Insert(ctx, &model.Note{Title: "Hello"})
+		require.NoError(t, err)
+		require.NoError(t, s.Delete(ctx, inserted))
+
+		note, err := s.Get(ctx, inserted.ID)
+		assert.NoError(t, err)
+		assert.Nil(t, note)
+
+		_, err = s.Insert(ctx, &model.Note{Title: "Hello"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("update checks the version", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+
+		inserted, err := s.Insert(ctx, &model.Note{Title: "Hello"})
+		require.NoError(t, err)
+		assert.Equal(t, uint(1), inserted.Version)
+
+		first := *inserted
+		second := *inserted
+		first.Title = "first"
+		updated, err := s.Update(ctx, first.ID, &first)
+		require.NoError(t, err)
+		assert.Equal(t, uint(2), updated.Version)
+
+		second.Title = "second"
+		_, err = s.Update(ctx, second.ID, &second)
+		assert.Equal(t, ErrVersionConflict, err)
+		assert.Equal(t, uint(1), second.Version)
+
+		note, err := s.Get(ctx, inserted.ID)
+		require.NoError(t, err)
+		require.NotNil(t, note)
+		assert.Equal(t, "first", note.Title)
+		assert.Equal(t, uint(2), note.Version)
+
+		note.IsCompleted = true
+		_, err = s.Update(ctx, note.ID, note)
+		require.NoError(t, err)
+		assert.Equal(t, uint(3), note.Version)
+
+		_, err = s.Update(ctx, 12345, &model.Note{Title: "missing", Version: 1})
+		assert.Error(t, err)
+		assert.NotEqual(t, ErrVersionConflict, err)
+	})
+
+	t.Run("trash", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+
+		trashed, err := s.Insert(ctx, &model.Note{Title: "Hello"})
+		require.NoError(t, err)
+		require.NoError(t, s.Delete(ctx, trashed))
+		require.NotNil(t, trashed.DeletedAt)
+
+		count, err := s.Count(ctx, NoteFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+		note, err := s.Find(ctx, NoteFilter{}.WithTitle("Hello"))
+		require.NoError(t, err)
+		assert.Nil(t, note)
+
+		note, err = s.Find(ctx, NoteFilter{}.WithTitle("Hello").InTrash())
+		require.NoError(t, err)
+		require.NotNil(t, note)
+		assert.Equal(t, trashed.ID, note.ID)
+		assert.NotNil(t, note.DeletedAt)
+
+		live, err := s.Insert(ctx, &model.Note{Title: "Hello"})
+		require.NoError(t, err)
+		notes, err := s.GetList(ctx, NoteFilter{}.InTrash(), NoteListOptions{})
+		require.NoError(t, err)
+		require.Len(t, notes, 1)
+		assert.Equal(t, trashed.ID, notes[0].ID)
+		count, err = s.Count(ctx, NoteFilter{}.WithDeleted())
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+
+		// the live note owns the title
+		assert.Equal(t, ErrDuplicateTitle, s.Restore(ctx, trashed))
+
+		// several notes in the trash may share a title
+		require.NoError(t, s.Delete(ctx, live))
+		count, err = s.Count(ctx, NoteFilter{}.WithTitle("Hello").InTrash())
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+
+		require.NoError(t, s.Purge(ctx, live))
+		require.NoError(t, s.Restore(ctx, trashed))
+		assert.Nil(t, trashed.DeletedAt)
+		note, err = s.Get(ctx, trashed.ID)
+		require.NoError(t, err)
+		require.NotNil(t, note)
+		assert.Nil(t, note.DeletedAt)
+
+		note, err = s.Find(ctx, NoteFilter{}.WithID(live.ID).WithDeleted())
+		require.NoError(t, err)
+		assert.Nil(t, note)
+		_, err = s.Insert(ctx, &model.Note{Title: "Hello"})
+		assert.Error(t, err)
+	})
+
+	t.Run("deleted before", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+
+		old, err := s.Insert(ctx, &model.Note{Title: "old"})
+		require.NoError(t, err)
+		require.NoError(t, s.Delete(ctx, old))
+		time.Sleep(10 * time.Millisecond)
+		before := time.Now()
+		time.Sleep(10 * time.Millisecond)
+		recent, err := s.Insert(ctx, &model.Note{Title: "recent"})
+		require.NoError(t, err)
+		require.NoError(t, s.Delete(ctx, recent))
+		_, err = s.Insert(ctx, &model.Note{Title: "live"})
+		require.NoError(t, err)
+
+		notes, err := s.GetList(ctx, NoteFilter{}.WithDeletedBefore(before), NoteListOptions{})
+		require.NoError(t, err)
+		require.Len(t, notes, 1)
+		assert.Equal(t, old.ID, notes[0].ID)
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err := s.Insert(cancelled, &model.Note{Title: "Hello"})
+		assert.Equal(t, context.Canceled, err)
+		_, err = s.GetList(cancelled, NoteFilter{}, NoteListOptions{})
+		assert.Equal(t, context.Canceled, err)
+
+		notes, err := s.GetList(ctx, NoteFilter{}, NoteListOptions{})
+		require.NoError(t, err)
+		assert.Empty(t, notes)
+	})
+
+	t.Run("count", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+
+		inserted, err := s.Insert(ctx, &model.Note{Title: "Hello"})
+		require.NoError(t, err)
+		_, err = s.Insert(ctx, &model.Note{Title: "World"})
+		require.NoError(t, err)
+
+		count, err := s.Count(ctx, NoteFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+
+		count, err = s.Count(ctx, NoteFilter{}.WithTitle("Hello"))
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		count, err = s.Count(ctx, NoteFilter{}.WithID(inserted.ID))
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		count, err = s.Count(ctx, NoteFilter{}.WithTitle("World").WithID(inserted.ID))
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+
+		count, err = s.Count(ctx, NoteFilter{}.WithTitle("missing"))
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+
+		first, err := s.Insert(ctx, &model.Note{Title: "todo: a", IsCompleted: true})
+		require.NoError(t, err)
+		time.Sleep(10 * time.Millisecond)
+		middle := time.Now()
+		time.Sleep(10 * time.Millisecond)
+		_, err = s.Insert(ctx, &model.Note{Title: "todo: b"})
+		require.NoError(t, err)
+		_, err = s.Insert(ctx, &model.Note{Title: "TODO%_ c"})
+		require.NoError(t, err)
+
+		titles := func(filter NoteFilter) []string {
+			notes, err := s.GetList(ctx, filter, NoteListOptions{})
+			require.NoError(t, err)
+			result := make([]string, 0, len(notes))
+			for _, note := range notes {
+				result = append(result, note.Title)
+			}
+			return result
+		}
+
+		assert.Equal(t, []string{"todo: a", "todo: b"}, titles(NoteFilter{}.WithTitlePrefix("todo")))
+		assert.Equal(t, []string{"TODO%_ c"}, titles(NoteFilter{}.WithTitlePrefix("TODO%_")))
+		assert.Equal(t, []string{"todo: b", "TODO%_ c"}, titles(NoteFilter{}.WithCompleted(false)))
+		assert.Equal(t, []string{"todo: b"}, titles(NoteFilter{}.WithCompleted(false).WithTitlePrefix("todo")))
+		assert.Equal(t, []string{"todo: a", "todo: b", "TODO%_ c"}, titles(NoteFilter{}.WithTitleContains("Do")))
+		assert.Equal(t, []string{"TODO%_ c"}, titles(NoteFilter{}.WithTitleContains("%_")))
+		assert.Equal(t, []string{"todo: b"}, titles(NoteFilter{}.WithTitleContains(": B").WithTitlePrefix("todo")))
+		assert.Equal(t, []string{"todo: a"}, titles(NoteFilter{}.WithCreatedBetween(time.Time{}, middle)))
+		assert.Equal(t, []string{"todo: b", "TODO%_ c"}, titles(NoteFilter{}.WithCreatedBetween(middle, time.Time{})))
+		assert.Equal(t, []string{"todo: b", "TODO%_ c"}, titles(NoteFilter{}.WithUpdatedBetween(middle, time.Now().Add(time.Minute))))
+
+		note, err := s.Find(ctx, NoteFilter{}.WithTitlePrefix("todo"))
+		require.NoError(t, err)
+		require.NotNil(t, note)
+		assert.Equal(t, first.ID, note.ID)
+
+		note, err = s.Find(ctx, NoteFilter{}.WithTitlePrefix("missing"))
+		assert.NoError(t, err)
+		assert.Nil(t, note)
+	})
+
+	t.Run("sort and page", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+
+		for _, title := range []string{"c", "a", "d", "b"} {
+			_, err := s.Insert(ctx, &model.Note{Title: title})
+			require.NoError(t, err)
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		titles := func(opts NoteListOptions) []string {
+			notes, err := s.GetList(ctx, NoteFilter{}, opts)
+			require.NoError(t, err)
+			result := make([]string, 0, len(notes))
+			for _, note := range notes {
+				result = append(result, note.Title)
+			}
+			return result
+		}
+
+		assert.Equal(t, []string{"c", "a", "d", "b"}, titles(NoteListOptions{}))
+		assert.Equal(t, []string{"b", "d", "a", "c"}, titles(NoteListOptions{Desc: true}))
+		assert.Equal(t, []string{"a", "b", "c", "d"}, titles(NoteListOptions{SortBy: NoteSortTitle}))
+		assert.Equal(t, []string{"d", "c", "b", "a"}, titles(NoteListOptions{SortBy: NoteSortTitle, Desc: true}))
+		assert.Equal(t, []string{"b", "d", "a", "c"}, titles(NoteListOptions{SortBy: NoteSortCreatedAt, Desc: true}))
+		assert.Equal(t, []string{"c", "a", "d", "b"}, titles(NoteListOptions{SortBy: NoteSortUpdatedAt}))
+		assert.Equal(t, []string{"b", "c"}, titles(NoteListOptions{SortBy: NoteSortTitle, Offset: 1, Limit: 2}))
+		assert.Equal(t, []string{}, titles(NoteListOptions{Offset: 10}))
+	})
+
+	t.Run("keyset pagination", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+
+		for _, title := range []string{"c", "a", "e", "b", "d"} {
+			_, err := s.Insert(ctx, &model.Note{Title: title})
+			require.NoError(t, err)
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		for _, opts := range []NoteListOptions{
+			{SortBy: NoteSortID},
+			{SortBy: NoteSortTitle},
+			{SortBy: NoteSortTitle, Desc: true},
+			{SortBy: NoteSortCreatedAt, Desc: true},
+			{SortBy: NoteSortUpdatedAt},
+		} {
+			all, err := s.GetList(ctx, NoteFilter{}, opts)
+			require.NoError(t, err)
+
+			var paged []*model.Note
+			opts.Limit = 2
+			for {
+				page, err := s.GetList(ctx, NoteFilter{}, opts)
+				require.NoError(t, err)
+				paged = append(paged, page...)
+				if len(page) < opts.Limit {
+					break
+				}
+				cursor, err := DecodeNoteCursor(NewNoteCursor(page[len(page)-1], opts).Encode())
+				require.NoError(t, err)
+				opts.After = cursor
+			}
+			assert.Equal(t, all, paged, "sort by %s desc %v", opts.SortBy, opts.Desc)
+		}
+	})
+	t.Run("tags", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+
+		both, err := s.Insert(ctx, &model.Note{Title: "both", Tags: []string{"home", "work"}})
+		require.NoError(t, err)
+		work, err := s.Insert(ctx, &model.Note{Title: "work", Tags: []string{"work"}})
+		require.NoError(t, err)
+		_, err = s.Insert(ctx, &model.Note{Title: "none"})
+		require.NoError(t, err)
+		trashed, err := s.Insert(ctx, &model.Note{Title: "trashed", Tags: []string{"home"}})
+		require.NoError(t, err)
+		require.NoError(t, s.Delete(ctx, trashed))
+
+		note, err := s.Get(ctx, both.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"home", "work"}, note.Tags)
+
+		titles := func(filter NoteFilter) []string {
+			notes, err := s.GetList(ctx, filter, NoteListOptions{})
+			require.NoError(t, err)
+			var titles []string
+			for _, note := range notes {
+				titles = append(titles, note.Title)
+			}
+			return titles
+		}
+		assert.Equal(t, []string{"both"}, titles(NoteFilter{}.WithTags("home", "work")))
+		assert.Equal(t, []string{"both", "work"}, titles(NoteFilter{}.WithAnyTag("home", "work")))
+		assert.Equal(t, []string{"both", "trashed"}, titles(NoteFilter{}.WithDeleted().WithTags("home")))
+		assert.Empty(t, titles(NoteFilter{}.WithTags("work", "missing")))
+
+		tags, err := s.Tags(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []*model.Tag{{Name: "home", Count: 1}, {Name: "work", Count: 2}}, tags)
+
+		work.Tags = []string{"urgent"}
+		_, err = s.Update(ctx, work.ID, work)
+		require.NoError(t, err)
+		note, err = s.Get(ctx, work.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"urgent"}, note.Tags)
+
+		// both keeps work once, the note in the trash is renamed too
+		count, err := s.RenameTag(ctx, "home", "work")
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+		note, err = s.Get(ctx, both.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"work"}, note.Tags)
+		assert.Equal(t, both.Version+1, note.Version)
+		note, err = s.Find(ctx, NoteFilter{}.WithID(trashed.ID).InTrash())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"work"}, note.Tags)
+
+		count, err = s.RenameTag(ctx, "missing", "other")
+		require.NoError(t, err)
+		assert.Zero(t, count)
+
+		failure := errors.New("failure")
+		err = s.WithTx(ctx, func(tx NoteStorage) error {
+			if _, err := tx.RenameTag(ctx, "urgent", "later"); err != nil {
+				return err
+			}
+			return failure
+		})
+		assert.Equal(t, failure, err)
+
+		tags, err = s.Tags(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []*model.Tag{{Name: "urgent", Count: 1}, {Name: "work", Count: 1}}, tags)
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+
+		existing, err := s.Insert(ctx, &model.Note{Title: "existing"})
+		require.NoError(t, err)
+		trashed, err := s.Insert(ctx, &model.Note{Title: "trashed"})
+		require.NoError(t, err)
+
+		results, err := s.Batch(ctx, []NoteOp{
+			{Kind: NoteOpInsert, Note: &model.Note{Title: "new"}},
+			{Kind: NoteOpUpdate, ID: existing.ID, Note: &model.Note{Title: "renamed", IsCompleted: true, Version: existing.Version}},
+			{Kind: NoteOpDelete, ID: trashed.ID, Note: trashed},
+			{Kind: NoteOpInsert, Note: &model.Note{Title: "renamed"}},
+		}, false)
+		require.NoError(t, err)
+		require.Len(t, results, 4)
+		for _, result := range results[:3] {
+			assert.NoError(t, result.Err)
+		}
+		assert.Equal(t, ErrDuplicateTitle, results[3].Err)
+		assert.Nil(t, results[3].Note)
+		assert.Equal(t, "new", results[0].Note.Title)
+		assert.Equal(t, existing.Version+1, results[1].Note.Version)
+
+		count, err := s.Count(ctx, NoteFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+		note, err := s.Find(ctx, NoteFilter{}.WithTitle("renamed"))
+		require.NoError(t, err)
+		require.NotNil(t, note)
+		assert.True(t, note.IsCompleted)
+	})
+
+	t.Run("atomic batch", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+
+		existing, err := s.Insert(ctx, &model.Note{Title: "existing"})
+		require.NoError(t, err)
+		other, err := s.Insert(ctx, &model.Note{Title: "other"})
+		require.NoError(t, err)
+
+		results, err := s.Batch(ctx, []NoteOp{
+			{Kind: NoteOpInsert, Note: &model.Note{Title: "new"}},
+			{Kind: NoteOpUpdate, ID: existing.ID, Note: &model.Note{Title: "renamed", Version: existing.Version}},
+			{Kind: NoteOpDelete, ID: other.ID, Note: other},
+			{Kind: NoteOpUpdate, ID: existing.ID, Note: &model.Note{Title: "stale", Version: existing.Version}},
+			{Kind: NoteOpInsert, Note: &model.Note{Title: "never"}},
+		}, true)
+		require.NoError(t, err)
+		require.Len(t, results, 5)
+		assert.Equal(t, ErrVersionConflict, results[3].Err)
+		for _, i := range []int{0, 1, 2, 4} {
+			assert.Equal(t, ErrBatchAborted, results[i].Err, "operation %d", i)
+			assert.Nil(t, results[i].Note)
+		}
+
+		notes, err := s.GetList(ctx, NoteFilter{}, NoteListOptions{})
+		require.NoError(t, err)
+		require.Len(t, notes, 2)
+		assert.Equal(t, "existing", notes[0].Title)
+		assert.Equal(t, "other", notes[1].Title)
+		trash, err := s.Count(ctx, NoteFilter{}.InTrash())
+		require.NoError(t, err)
+		assert.Zero(t, trash)
+
+		results, err = s.Batch(ctx, []NoteOp{
+			{Kind: NoteOpInsert, Note: &model.Note{Title: "new"}},
+			{Kind: NoteOpDelete, ID: other.ID, Note: other},
+		}, true)
+		require.NoError(t, err)
+		assert.NoError(t, results[0].Err)
+		assert.NoError(t, results[1].Err)
+		count, err := s.Count(ctx, NoteFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+	})
+	t.Run("unit of work", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+
+		kept, err := s.Insert(ctx, &model.Note{Title: "kept"})
+		require.NoError(t, err)
+		trashed, err := s.Insert(ctx, &model.Note{Title: "trashed"})
+		require.NoError(t, err)
+		require.NoError(t, s.Delete(ctx, trashed))
+		purged, err := s.Insert(ctx, &model.Note{Title: "purged"})
+		require.NoError(t, err)
+		require.NoError(t, s.Delete(ctx, purged))
+
+		failure := errors.New("failure")
+		err = s.WithTx(ctx, func(tx NoteStorage) error {
+			if _, err := tx.Insert(ctx, &model.Note{Title: "new"}); err != nil {
+				return err
+			}
+			note, err := tx.Get(ctx, kept.ID)
+			if err != nil {
+				return err
+			}
+			note.Title = "renamed"
+			if _, err := tx.Update(ctx, kept.ID, note); err != nil {
+				return err
+			}
+			if err := tx.Restore(ctx, trashed); err != nil {
+				return err
+			}
+			if err := tx.Purge(ctx, purged); err != nil {
+				return err
+			}
+			// a nested unit of work joins this one
+			return tx.WithTx(ctx, func(tx NoteStorage) error {
+				note, err := tx.Find(ctx, NoteFilter{}.WithTitle("renamed"))
+				if err != nil || note == nil {
+					return fmt.Errorf("renamed note not found: %v", err)
+				}
+				return failure
+			})
+		})
+		assert.Equal(t, failure, err)
+
+		notes, err := s.GetList(ctx, NoteFilter{}, NoteListOptions{})
+		require.NoError(t, err)
+		require.Len(t, notes, 1)
+		assert.Equal(t, "kept", notes[0].Title)
+		trash, err := s.GetList(ctx, NoteFilter{}.InTrash(), NoteListOptions{})
+		require.NoError(t, err)
+		require.Len(t, trash, 2)
+
+		err = s.WithTx(ctx, func(tx NoteStorage) error {
+			note, err := tx.Get(ctx, kept.ID)
+			if err != nil {
+				return err
+			}
+			note.Title = "renamed"
+			if _, err := tx.Update(ctx, kept.ID, note); err != nil {
+				return err
+			}
+			return tx.Purge(ctx, trash[1])
+		})
+		require.NoError(t, err)
+		note, err := s.Get(ctx, kept.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "renamed", note.Title)
+		count, err := s.Count(ctx, NoteFilter{}.InTrash())
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("concurrent units of work", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+
+		inserted, err := s.Insert(ctx, &model.Note{Title: "0"})
+		require.NoError(t, err)
+
+		// every unit of work reads the title and writes it back incremented,
+		// none of them may be lost
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := s.WithTx(ctx, func(tx NoteStorage) error {
+					note, err := tx.Get(ctx, inserted.ID)
+					if err != nil {
+						return err
+					}
+					var n int
+					fmt.Sscan(note.Title, &n)
+					note.Title = fmt.Sprint(n + 1)
+					_, err = tx.Update(ctx, inserted.ID, note)
+					return err
+				})
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+
+		note, err := s.Get(ctx, inserted.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "10", note.Title)
+	})
+}
+
+func TestNoteMemoryStorage(t *testing.T) {
+	ctx := ownerContext(1)
+	testNoteStorage(t, func(t *testing.T) (NoteStorage, func()) {
+		return NewNoteMemoryStorage(), func() {}
+	})
+
+	t.Run("concurrent inserts", func(t *testing.T) {
+		s := NewNoteMemoryStorage()
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, err := s.Insert(ctx, &model.Note{Title: fmt.Sprintf("note %d", i)})
+				assert.NoError(t, err)
+			}(i)
+		}
+		wg.Wait()
+
+		notes, err := s.GetList(ctx, NoteFilter{}, NoteListOptions{})
+		require.NoError(t, err)
+		require.Len(t, notes, 50)
+		for i, note := range notes {
+			assert.Equal(t, uint(i+1), note.ID)
+		}
+	})
+}
+
+func TestNoteSqliteStorage(t *testing.T) {
+	testNoteStorage(t, func(t *testing.T) (NoteStorage, func()) {
+		dir, err := ioutil.TempDir("", "notes")
+		require.NoError(t, err)
+		db, err := gorm.Open("sqlite3", filepath.Join(dir, "notes.db"))
+		require.NoError(t, err)
+		require.NoError(t, MigrateGorm(db))
+		return NewNoteGormStorage(db), func() {
+			db.Close()
+			os.RemoveAll(dir)
+		}
+	})
+}
+
+func TestMigrateGormSqlite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notes")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	db, err := gorm.Open("sqlite3", filepath.Join(dir, "notes.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	// the schema created by versions keeping titles unique across the trash
+	require.NoError(t, db.Exec(`CREATE TABLE notes (id integer primary key autoincrement, created_at datetime, updated_at datetime,
+		deleted_at datetime, title varchar(255) UNIQUE, is_completed bool)`).Error)
+	require.NoError(t, db.Exec("CREATE INDEX idx_notes_deleted_at ON notes(deleted_at)").Error)
+	require.NoError(t, db.Exec("INSERT INTO notes (title, is_completed, created_at, deleted_at) VALUES ('Hello', 1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)").Error)
+	require.NoError(t, db.Exec("INSERT INTO notes (title, is_completed, created_at) VALUES ('World', 0, CURRENT_TIMESTAMP)").Error)
+
+	require.NoError(t, MigrateGorm(db))
+	require.NoError(t, MigrateGorm(db))
+
+	s := NewNoteGormStorage(db)
+	// the notes stored before users existed belong to none of them until
+	// they are adopted
+	ctx := AllOwners(context.Background())
+	note, err := s.Find(ctx, NoteFilter{}.WithTitle("Hello").InTrash())
+	require.NoError(t, err)
+	require.NotNil(t, note)
+	assert.True(t, note.IsCompleted)
+	assert.Equal(t, "", note.Content)
+	// backfilled from the creation time
+	publicID, err := ulid.Parse(note.PublicID)
+	require.NoError(t, err)
+	assert.WithinDuration(t, note.CreatedAt, publicID.Time(), time.Millisecond)
+	// rows stored before versions existed start at 1, the ETag they are
+	// served with can be sent back in If-Match
+	assert.Equal(t, uint(1), note.Version)
+	live, err := s.Find(ctx, NoteFilter{}.WithTitle("World"))
+	require.NoError(t, err)
+	require.NotNil(t, live)
+	assert.Equal(t, uint(1), live.Version)
+	live.IsCompleted = true
+	stale := *live
+	updated, err := s.Update(ctx, live.ID, live)
+	require.NoError(t, err)
+	assert.Equal(t, uint(2), updated.Version)
+	_, err = s.Update(ctx, live.ID, &stale)
+	assert.Equal(t, ErrVersionConflict, err)
+
+	_, err = s.Insert(ctx, &model.Note{Title: "Hello"})
+	assert.NoError(t, err)
+	_, err = s.Insert(ctx, &model.Note{Title: "Hello"})
+	assert.Equal(t, ErrDuplicateTitle, err)
+	_, err = s.Insert(ownerContext(1), &model.Note{Title: "Hello"})
+	assert.NoError(t, err)
+
+	// the first user gets the notes whose title it doesn't use
+	user, err := NewUserGormStorage(db).InsertUser(context.Background(), &model.User{Email: "ann@example.com"})
+	require.NoError(t, err)
+	require.Equal(t, uint(1), user.ID)
+	count, err := s.AdoptNotes(context.Background(), user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	annCtx := ownerContext(user.ID)
+	adopted, err := s.Find(annCtx, NoteFilter{}.WithID(note.ID).InTrash())
+	require.NoError(t, err)
+	assert.NotNil(t, adopted)
+	adopted, err = s.Find(annCtx, NoteFilter{}.WithTitle("World"))
+	require.NoError(t, err)
+	require.NotNil(t, adopted)
+	assert.Equal(t, uint(2), adopted.Version)
+	ownerless, err := s.Count(ctx, NoteFilter{}.WithOwner(0))
+	require.NoError(t, err)
+	assert.Equal(t, 1, ownerless, "ann already has a note titled Hello")
+}
+
+func TestNoteBoltStorage(t *testing.T) {
+	testNoteStorage(t, func(t *testing.T) (NoteStorage, func()) {
+		dir, err := ioutil.TempDir("", "notes")
+		require.NoError(t, err)
+		db, err := bolt.Open(filepath.Join(dir, "notes.bolt"), 0600, nil)
+		require.NoError(t, err)
+		s, err := NewNoteBoltStorage(db)
+		require.NoError(t, err)
+		return s, func() {
+			db.Close()
+			os.RemoveAll(dir)
+		}
+	})
+}
+
+func TestNoteBoltStorage_Backfill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notes")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	db, err := bolt.Open(filepath.Join(dir, "notes.bolt"), 0600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	// a note stored before public ids, versions and users existed, its
+	// title was indexed for every user
+	createdAt := time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC)
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucket(noteBucket)
+		if err != nil {
+			return err
+		}
+		data := fmt.Sprintf(`{"id":1,"title":"Hello","created_at":%q}`, createdAt.Format(time.RFC3339))
+		if err := bucket.Put(itob(1), []byte(data)); err != nil {
+			return err
+		}
+		titles, err := tx.CreateBucket(legacyNoteTitleBucket)
+		if err != nil {
+			return err
+		}
+		return titles.Put([]byte("Hello"), itob(1))
+	})
+	require.NoError(t, err)
+
+	s, err := NewNoteBoltStorage(db)
+	require.NoError(t, err)
+	ctx := AllOwners(context.Background())
+	note, err := s.Get(ctx, 1)
+	require.NoError(t, err)
+	require.NotNil(t, note)
+	assert.Equal(t, "Hello", note.Title)
+	publicID, err := ulid.Parse(note.PublicID)
+	require.NoError(t, err)
+	assert.True(t, createdAt.Equal(publicID.Time()))
+	assert.Equal(t, uint(1), note.Version)
+
+	found, err := s.Find(ctx, NoteFilter{}.WithPublicID(note.PublicID))
+	require.NoError(t, err)
+	assert.Equal(t, note, found)
+
+	err = db.View(func(tx *bolt.Tx) error {
+		assert.Nil(t, tx.Bucket(legacyNoteTitleBucket))
+		return nil
+	})
+	require.NoError(t, err)
+	_, err = s.Insert(ctx, &model.Note{Title: "Hello"})
+	assert.Equal(t, ErrDuplicateTitle, err)
+	_, err = s.Insert(ownerContext(1), &model.Note{Title: "Hello"})
+	assert.NoError(t, err)
+}
+
+func TestNoteMarkdownStorage(t *testing.T) {
+	ctx := ownerContext(1)
+	newStorage := func(t *testing.T) (NoteStorage, func()) {
+		dir, err := ioutil.TempDir("", "notes")
+		require.NoError(t, err)
+		s, err := NewNoteMarkdownStorage(dir)
+		require.NoError(t, err)
+		return s, func() { os.RemoveAll(dir) }
+	}
+	testNoteStorage(t, newStorage)
+
+	t.Run("file layout", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "notes")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		s, err := NewNoteMarkdownStorage(dir)
+		require.NoError(t, err)
+
+		note, err := s.Insert(ctx, &model.Note{Title: "Hello World!", IsCompleted: true, Content: "Some *text*\n", Tags: []string{"home", "work"}})
+		require.NoError(t, err)
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, "hello-world.md"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "id: 1\n")
+		assert.Contains(t, string(data), "public_id: "+note.PublicID+"\n")
+		assert.Contains(t, string(data), "owner_id: 1\n")
+		assert.Contains(t, string(data), "is_completed: true\n")
+		assert.Contains(t, string(data), "tags:\n- home\n- work\n")
+		assert.Contains(t, string(data), "---\n# Hello World!\n\nSome *text*\n")
+
+		note.Title = "Renamed"
+		_, err = s.Update(ctx, note.ID, note)
+		require.NoError(t, err)
+		_, err = os.Stat(filepath.Join(dir, "hello-world.md"))
+		assert.True(t, os.IsNotExist(err))
+		_, err = os.Stat(filepath.Join(dir, "renamed.md"))
+		assert.NoError(t, err)
+
+		reopened, err := NewNoteMarkdownStorage(dir)
+		require.NoError(t, err)
+		got, err := reopened.Get(ctx, note.ID)
+		require.NoError(t, err)
+		require.NotNil(t, got)
+		assert.Equal(t, "Renamed", got.Title)
+		assert.Equal(t, []string{"home", "work"}, got.Tags)
+	})
+
+	t.Run("external edits", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "notes")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		s, err := NewNoteMarkdownStorage(dir)
+		require.NoError(t, err)
+
+		note, err := s.Insert(ctx, &model.Note{Title: "Hello"})
+		require.NoError(t, err)
+
+		path := filepath.Join(dir, "hello.md")
+		data, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		data = bytes.Replace(data, []byte("is_completed: false"), []byte("is_completed: true"), 1)
+		data = bytes.Replace(data, []byte("# Hello"), []byte("# Edited by hand\n\nsome text"), 1)
+		require.NoError(t, ioutil.WriteFile(path, data, 0644))
+
+		got, err := s.Get(ctx, note.ID)
+		require.NoError(t, err)
+		require.NotNil(t, got)
+		assert.Equal(t, "Edited by hand", got.Title)
+		assert.Equal(t, "some text\n", got.Content)
+		assert.True(t, got.IsCompleted)
+
+		got.IsCompleted = false
+		_, err = s.Update(ctx, got.ID, got)
+		require.NoError(t, err)
+		data, err = ioutil.ReadFile(path)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "some text")
+
+		require.NoError(t, os.Rename(path, filepath.Join(dir, "moved.md")))
+		got, err = s.Get(ctx, note.ID)
+		require.NoError(t, err)
+		require.NotNil(t, got)
+		assert.Equal(t, "Edited by hand", got.Title)
+
+		// a file added by hand names its owner, without one it belongs to no
+		// user
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "new.md"), []byte("---\nowner_id: 1\nis_completed: true\n---\n# Added by hand\n"), 0644))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "orphan.md"), []byte("---\nis_completed: false\n---\n# Orphan\n"), 0644))
+		orphan, err := s.Find(ctx, NoteFilter{}.WithTitle("Orphan"))
+		require.NoError(t, err)
+		assert.Nil(t, orphan)
+		require.NoError(t, os.Remove(filepath.Join(dir, "orphan.md")))
+		added, err := s.Find(ctx, NoteFilter{}.WithTitle("Added by hand"))
+		require.NoError(t, err)
+		require.NotNil(t, added)
+		assert.Equal(t, uint(2), added.ID)
+		assert.True(t, added.IsCompleted)
+		assert.Equal(t, uint(1), added.Version)
+		_, err = ulid.Parse(added.PublicID)
+		assert.NoError(t, err)
+
+		// a copied file gets its own ids and title, even when its name comes
+		// first, a lowercase public id is fixed
+		copied := bytes.Replace(data, []byte("public_id: "+note.PublicID), []byte("public_id: "+strings.ToLower(note.PublicID)), 1)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "copy.md"), copied, 0644))
+		require.NoError(t, os.Remove(filepath.Join(dir, "moved.md")))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "moved.md"), data, 0644))
+		got, err = s.Find(ctx, NoteFilter{}.WithTitle("Edited by hand"))
+		require.NoError(t, err)
+		require.NotNil(t, got)
+		assert.Equal(t, note.ID, got.ID)
+		assert.Equal(t, note.PublicID, got.PublicID)
+		duplicate, err := s.Find(ctx, NoteFilter{}.WithTitle("Edited by hand (2)"))
+		require.NoError(t, err)
+		require.NotNil(t, duplicate)
+		assert.NotEqual(t, note.ID, duplicate.ID)
+		assert.NotEqual(t, note.PublicID, duplicate.PublicID)
+		data, err = ioutil.ReadFile(filepath.Join(dir, "copy.md"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "# Edited by hand (2)\n")
+		require.NoError(t, os.Remove(filepath.Join(dir, "copy.md")))
+
+		require.NoError(t, os.Remove(filepath.Join(dir, "moved.md")))
+		got, err = s.Get(ctx, note.ID)
+		assert.NoError(t, err)
+		assert.Nil(t, got)
+	})
+
+	t.Run("files without front matter", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "notes")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		s, err := NewNoteMarkdownStorage(dir)
+		require.NoError(t, err)
+		note, err := s.Insert(ctx, &model.Note{Title: "Hello"})
+		require.NoError(t, err)
+
+		// plain Markdown files are imported, a broken front matter only hides
+		// its own file
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "plain.md"), []byte("# Plain\n\nsome text\n"), 0644))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "unclosed.md"), []byte("---\nnot front matter\n"), 0644))
+		broken := filepath.Join(dir, "broken.md")
+		require.NoError(t, ioutil.WriteFile(broken, []byte("---\nid: [\n---\n# Broken\n"), 0644))
+
+		all := AllOwners(context.Background())
+		notes, err := s.GetList(all, NoteFilter{}, NoteListOptions{SortBy: NoteSortTitle})
+		require.NoError(t, err)
+		require.Len(t, notes, 3)
+		assert.Equal(t, "Hello", notes[0].Title)
+		assert.Equal(t, "Plain", notes[1].Title)
+		assert.Equal(t, "some text\n", notes[1].Content)
+		assert.Equal(t, uint(1), notes[1].Version)
+		assert.Equal(t, "unclosed", notes[2].Title)
+		assert.Equal(t, "---\nnot front matter\n", notes[2].Content)
+		data, err := ioutil.ReadFile(filepath.Join(dir, "plain.md"))
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(string(data), "---\nid: 2\n"), "the file got its front matter")
+
+		got, err := s.Get(ctx, note.ID)
+		require.NoError(t, err)
+		require.NotNil(t, got)
+		note.Title = "Still writable"
+		_, err = s.Update(ctx, note.ID, note)
+		assert.NoError(t, err)
+
+		require.NoError(t, ioutil.WriteFile(broken, []byte("---\nis_completed: true\n---\n# Fixed\n"), 0644))
+		fixed, err := s.Find(all, NoteFilter{}.WithTitle("Fixed"))
+		require.NoError(t, err)
+		require.NotNil(t, fixed)
+		assert.True(t, fixed.IsCompleted)
+	})
+}
+
+func TestNoteCursor(t *testing.T) {
+	note := &model.Note{ID: 48213, PublicID: "01D7EX2VQ3AHDK6N2QXKWBS4JZ", Title: "Hello"}
+	encoded := NewNoteCursor(note, NoteListOptions{SortBy: NoteSortTitle, Desc: true}).Encode()
+
+	// the cursor is only encoded, it must not hold the internal id
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"s":"title","d":true,"i":"01D7EX2VQ3AHDK6N2QXKWBS4JZ","t":"0001-01-01T00:00:00Z","v":"Hello"}`, string(data))
+	assert.NotContains(t, string(data), "48213")
+
+	cursor, err := DecodeNoteCursor(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, note.PublicID, cursor.PublicID)
+	assert.Equal(t, note.PublicID, NewNoteCursor(note, NoteListOptions{}).Value())
+
+	for _, value := range []string{
+		`{"s":"id","i":3}`,
+		`{"s":"id","i":"3"}`,
+		`{"s":"id","i":""}`,
+		`{"s":"id"}`,
+		`{"s":"id","i":"01d7ex2vq3ahdk6n2qxkwbs4jz"}`,
+		`{"s":"id","i":"01D7EX2VQ3AHDK6N2QXKWBS4J"}`,
+	} {
+		_, err := DecodeNoteCursor(base64.RawURLEncoding.EncodeToString([]byte(value)))
+		assert.Equal(t, ErrInvalidCursor, err, value)
+	}
+}
+
+func TestUniqueMarkdownTitle(t *testing.T) {
+	titles := map[markdownTitle]bool{
+		{owner: 1, title: "Hello"}:     true,
+		{owner: 1, title: "Hello (2)"}: true,
+	}
+	assert.Equal(t, "Hello (3)", uniqueMarkdownTitle(&model.Note{OwnerID: 1, Title: "Hello"}, titles))
+	assert.Equal(t, "Hello (2)", uniqueMarkdownTitle(&model.Note{OwnerID: 2, Title: "Hello"}, titles))
+	long := strings.Repeat("ữ", 75) + " " + strings.Repeat("a", 4)
+	assert.Equal(t, strings.Repeat("ữ", 75)+" (2)", uniqueMarkdownTitle(&model.Note{Title: long}, titles))
+}
+
+func TestNotePostgresStorage(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	newStorage := func(t *testing.T) (NoteStorage, func()) {
+		db, err := gorm.Open("postgres", url)
+		require.NoError(t, err)
+		require.NoError(t, db.DropTableIfExists(model.Note{}).Error)
+		require.NoError(t, MigrateGorm(db))
+		return NewNotePostgresStorage(db), func() {
+			db.DropTableIfExists(model.Note{})
+			db.Close()
+		}
+	}
+	testNoteStorage(t, newStorage)
+	t.Run("search", func(t *testing.T) {
+		testNoteSearch(t, newStorage)
+	})
+}
+
+func TestNoteMongoStorage(t *testing.T) {
+	url := os.Getenv("TEST_MONGO_URL")
+	if url == "" {
+		t.Skip("TEST_MONGO_URL is not set")
+	}
+
+	testNoteStorage(t, func(t *testing.T) (NoteStorage, func()) {
+		session, err := mgo.Dial(url)
+		require.NoError(t, err)
+		db := session.DB("notes_test")
+		require.NoError(t, dropMongoDatabase(db))
+		s, err := NewNoteMongoStorage(db)
+		require.NoError(t, err)
+		return s, func() {
+			dropMongoDatabase(db)
+			session.Close()
+		}
+	})
+}
+
+func dropMongoDatabase(db *mgo.Database) error {
+	err := db.DropDatabase()
+	if err != nil && err.Error() == "ns not found" {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"github.com/lyquocnam/go-note-learning/model"
+	"sort"
+)
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// renameTag returns tags with from replaced by to, sorted and without
+// duplicates, ok tells whether tags had from.
+func renameTag(tags []string, from, to string) (renamed []string, ok bool) {
+	if !hasTag(tags, from) {
+		return tags, false
+	}
+	renamed = make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if tag != from && tag != to {
+			renamed = append(renamed, tag)
+		}
+	}
+	renamed = append(renamed, to)
+	sort.Strings(renamed)
+	return renamed, true
+}
+
+func countTags(notes []*model.Note) []*model.Tag {
+	counts := make(map[string]int)
+	for _, note := range notes {
+		if note.DeletedAt != nil {
+			continue
+		}
+		for _, tag := range note.Tags {
+			counts[tag]++
+		}
+	}
+	tags := make([]*model.Tag, 0, len(counts))
+	for name, count := range counts {
+		tags = append(tags, &model.Tag{Name: name, Count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Name < tags[j].Name
+	})
+	return tags
+}
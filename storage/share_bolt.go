@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/lyquocnam/go-note-learning/model"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+func (b *userBoltStorage) FindShare(ctx context.Context, noteID string, userID uint) (*model.Share, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var share *model.Share
+	err := b.db.View(func(tx *bolt.Tx) error {
+		var err error
+		share, err = getBoltShare(tx, boltShareKey(noteID, userID))
+		return err
+	})
+	return share, err
+}
+
+func (b *userBoltStorage) GetShares(ctx context.Context, noteID string) ([]*model.Share, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var shares []*model.Share
+	err := b.db.View(func(tx *bolt.Tx) error {
+		prefix := []byte(noteID)
+		c := tx.Bucket(shareBucket).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			share, err := decodeBoltShare(v)
+			if err != nil {
+				return err
+			}
+			shares = append(shares, share)
+		}
+		return nil
+	})
+	sortShares(shares)
+	return shares, err
+}
+
+func (b *userBoltStorage) GetSharesWith(ctx context.Context, userID uint) ([]*model.Share, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var shares []*model.Share
+	err := b.db.View(func(tx *bolt.Tx) error {
+		prefix := itob(userID)
+		c := tx.Bucket(userShareBucket).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			share, err := getBoltShare(tx, boltShareKey(string(k[len(prefix):]), userID))
+			if err != nil {
+				return err
+			}
+			if share != nil {
+				shares = append(shares, share)
+			}
+		}
+		return nil
+	})
+	sortShares(shares)
+	return shares, err
+}
+
+func (b *userBoltStorage) PutShare(ctx context.Context, share *model.Share) (*model.Share, error) {
+	if err := ctx.Err(); err != nil {
+		return share, err
+	}
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		var user *model.User
+		if id := tx.Bucket(userPublicIDBucket).Get([]byte(share.UserPublicID)); id != nil {
+			var stored storedUser
+			if err := json.Unmarshal(tx.Bucket(userBucket).Get(id), &stored); err != nil {
+				return err
+			}
+			found := model.User(stored)
+			user = &found
+		}
+		if err := shareUser(share, user); err != nil {
+			return err
+		}
+
+		key := boltShareKey(share.NoteID, share.UserID)
+		previous, err := getBoltShare(tx, key)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if previous != nil {
+			share.ID = previous.ID
+			share.CreatedAt = previous.CreatedAt
+		} else {
+			seq, err := tx.Bucket(shareBucket).NextSequence()
+			if err != nil {
+				return err
+			}
+			share.ID = uint(seq)
+			share.CreatedAt = now
+		}
+		share.UpdatedAt = now
+
+		data, err := json.Marshal(storedShare(*share))
+		if err != nil {
+			return err
+		}
+		if err := tx.Bucket(shareBucket).Put(key, data); err != nil {
+			return err
+		}
+		return tx.Bucket(userShareBucket).Put(boltUserShareKey(share.UserID, share.NoteID), []byte{})
+	})
+	return share, err
+}
+
+func (b *userBoltStorage) DeleteShare(ctx context.Context, noteID string, userID uint) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	deleted := false
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		key := boltShareKey(noteID, userID)
+		if tx.Bucket(shareBucket).Get(key) == nil {
+			return nil
+		}
+		deleted = true
+		return deleteBoltShare(tx, noteID, userID)
+	})
+	return deleted && err == nil, err
+}
+
+func (b *userBoltStorage) DeleteShares(ctx context.Context, noteID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		// the cursor can't be used while deleting
+		var userIDs []uint
+		prefix := []byte(noteID)
+		c := tx.Bucket(shareBucket).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			share, err := decodeBoltShare(v)
+			if err != nil {
+				return err
+			}
+			userIDs = append(userIDs, share.UserID)
+		}
+		for _, userID := range userIDs {
+			if err := deleteBoltShare(tx, noteID, userID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func boltShareKey(noteID string, userID uint) []byte {
+	return append([]byte(noteID), itob(userID)...)
+}
+
+func boltUserShareKey(userID uint, noteID string) []byte {
+	return append(itob(userID), noteID...)
+}
+
+func getBoltShare(tx *bolt.Tx, key []byte) (*model.Share, error) {
+	data := tx.Bucket(shareBucket).Get(key)
+	if data == nil {
+		return nil, nil
+	}
+	return decodeBoltShare(data)
+}
+
+func decodeBoltShare(data []byte) (*model.Share, error) {
+	var stored storedShare
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+	share := model.Share(stored)
+	return &share, nil
+}
+
+func deleteBoltShare(tx *bolt.Tx, noteID string, userID uint) error {
+	if err := tx.Bucket(shareBucket).Delete(boltShareKey(noteID, userID)); err != nil {
+		return err
+	}
+	return tx.Bucket(userShareBucket).Delete(boltUserShareKey(userID, noteID))
+}
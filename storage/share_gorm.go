@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"github.com/jinzhu/gorm"
+	"github.com/lyquocnam/go-note-learning/model"
+)
+
+func (u *userGormStorage) FindShare(ctx context.Context, noteID string, userID uint) (*model.Share, error) {
+	db, err := openGormContext(ctx, u.db, nil, u.logMode)
+	if err != nil {
+		return nil, err
+	}
+
+	var share model.Share
+	err = db.First(&share, "note_id = ? AND user_id = ?", noteID, userID).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+func (u *userGormStorage) GetShares(ctx context.Context, noteID string) ([]*model.Share, error) {
+	return u.findShares(ctx, "note_id = ?", noteID)
+}
+
+func (u *userGormStorage) GetSharesWith(ctx context.Context, userID uint) ([]*model.Share, error) {
+	return u.findShares(ctx, "user_id = ?", userID)
+}
+
+func (u *userGormStorage) findShares(ctx context.Context, where string, value interface{}) ([]*model.Share, error) {
+	db, err := openGormContext(ctx, u.db, nil, u.logMode)
+	if err != nil {
+		return nil, err
+	}
+
+	var shares []*model.Share
+	err = db.Where(where, value).Order("id").Find(&shares).Error
+	return shares, err
+}
+
+func (u *userGormStorage) PutShare(ctx context.Context, share *model.Share) (*model.Share, error) {
+	user, err := u.FindUserByPublicID(ctx, share.UserPublicID)
+	if err != nil {
+		return share, err
+	}
+	if err := shareUser(share, user); err != nil {
+		return share, err
+	}
+	db, err := openGormContext(ctx, u.db, nil, u.logMode)
+	if err != nil {
+		return share, err
+	}
+
+	err = db.Where(model.Share{NoteID: share.NoteID, UserID: share.UserID}).
+		Assign(model.Share{Role: share.Role}).
+		FirstOrCreate(share).Error
+	return share, err
+}
+
+func (u *userGormStorage) DeleteShare(ctx context.Context, noteID string, userID uint) (bool, error) {
+	db, err := openGormContext(ctx, u.db, nil, u.logMode)
+	if err != nil {
+		return false, err
+	}
+
+	result := db.Delete(model.Share{}, "note_id = ? AND user_id = ?", noteID, userID)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (u *userGormStorage) DeleteShares(ctx context.Context, noteID string) error {
+	db, err := openGormContext(ctx, u.db, nil, u.logMode)
+	if err != nil {
+		return err
+	}
+	return db.Delete(model.Share{}, "note_id = ?", noteID).Error
+}
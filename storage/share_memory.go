@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"context"
+	"github.com/lyquocnam/go-note-learning/model"
+	"time"
+)
+
+type shareKey struct {
+	noteID string
+	userID uint
+}
+
+func (m *userMemoryStorage) FindShare(ctx context.Context, noteID string, userID uint) (*model.Share, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return copyShare(m.shares[shareKey{noteID, userID}]), nil
+}
+
+func (m *userMemoryStorage) GetShares(ctx context.Context, noteID string) ([]*model.Share, error) {
+	return m.findShares(ctx, func(share *model.Share) bool {
+		return share.NoteID == noteID
+	})
+}
+
+func (m *userMemoryStorage) GetSharesWith(ctx context.Context, userID uint) ([]*model.Share, error) {
+	return m.findShares(ctx, func(share *model.Share) bool {
+		return share.UserID == userID
+	})
+}
+
+func (m *userMemoryStorage) findShares(ctx context.Context, match func(share *model.Share) bool) ([]*model.Share, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var shares []*model.Share
+	for _, share := range m.shares {
+		if match(share) {
+			shares = append(shares, copyShare(share))
+		}
+	}
+	sortShares(shares)
+	return shares, nil
+}
+
+func (m *userMemoryStorage) PutShare(ctx context.Context, share *model.Share) (*model.Share, error) {
+	if err := ctx.Err(); err != nil {
+		return share, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var user *model.User
+	if id, ok := m.publicIDs[share.UserPublicID]; ok {
+		user = m.users[id]
+	}
+	if err := shareUser(share, user); err != nil {
+		return share, err
+	}
+
+	key := shareKey{share.NoteID, share.UserID}
+	previous, lastShareID := m.shares[key], m.lastShareID
+	now := time.Now()
+	if previous != nil {
+		share.ID = previous.ID
+		share.CreatedAt = previous.CreatedAt
+	} else {
+		m.lastShareID++
+		share.ID = m.lastShareID
+		share.CreatedAt = now
+	}
+	share.UpdatedAt = now
+	m.shares[key] = copyShare(share)
+	if err := m.save(); err != nil {
+		if previous != nil {
+			m.shares[key] = previous
+		} else {
+			delete(m.shares, key)
+		}
+		m.lastShareID = lastShareID
+		return share, err
+	}
+	return share, nil
+}
+
+func (m *userMemoryStorage) DeleteShare(ctx context.Context, noteID string, userID uint) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := shareKey{noteID, userID}
+	share, ok := m.shares[key]
+	if !ok {
+		return false, nil
+	}
+	delete(m.shares, key)
+	if err := m.save(); err != nil {
+		m.shares[key] = share
+		return false, err
+	}
+	return true, nil
+}
+
+func (m *userMemoryStorage) DeleteShares(ctx context.Context, noteID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deleted := make(map[shareKey]*model.Share)
+	for key, share := range m.shares {
+		if key.noteID == noteID {
+			deleted[key] = share
+			delete(m.shares, key)
+		}
+	}
+	if len(deleted) == 0 {
+		return nil
+	}
+	if err := m.save(); err != nil {
+		for key, share := range deleted {
+			m.shares[key] = share
+		}
+		return err
+	}
+	return nil
+}
+
+func copyShare(share *model.Share) *model.Share {
+	if share == nil {
+		return nil
+	}
+	c := *share
+	return &c
+}
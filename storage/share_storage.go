@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/lyquocnam/go-note-learning/acl"
+	"github.com/lyquocnam/go-note-learning/model"
+	"sort"
+	"time"
+)
+
+var (
+	// ErrUserNotExist is returned when a note is shared with a user that
+	// does not exist.
+	ErrUserNotExist = errors.New("storage: user does not exist")
+	// ErrShareWithOwner is returned when a note is shared with its owner.
+	ErrShareWithOwner = errors.New("storage: note shared with its owner")
+)
+
+// ShareStorage keeps who the notes are shared with and the comments left
+// on them, the user storages implement it. Notes are identified by their
+// public id and a share that does not exist is nil rather than an error.
+type ShareStorage interface {
+	FindShare(ctx context.Context, noteID string, userID uint) (*model.Share, error)
+	// GetShares returns the shares of the note, the oldest first.
+	GetShares(ctx context.Context, noteID string) ([]*model.Share, error)
+	// GetSharesWith returns the shares of the notes shared with the user,
+	// the oldest first.
+	GetSharesWith(ctx context.Context, userID uint) ([]*model.Share, error)
+	// PutShare shares the note with the user share.UserPublicID, or gives
+	// its share the role of share when the note is already shared with it.
+	// It fills in UserID, and returns ErrUserNotExist when there is no such
+	// user and ErrShareWithOwner when it owns the note.
+	PutShare(ctx context.Context, share *model.Share) (*model.Share, error)
+	// DeleteShare returns false when the note was not shared with the
+	// user.
+	DeleteShare(ctx context.Context, noteID string, userID uint) (bool, error)
+	DeleteShares(ctx context.Context, noteID string) error
+	// GetComments returns the comments of the note, the oldest first.
+	GetComments(ctx context.Context, noteID string) ([]*model.Comment, error)
+	InsertComment(ctx context.Context, comment *model.Comment) (*model.Comment, error)
+	DeleteComments(ctx context.Context, noteID string) error
+}
+
+// storedShare is a share as the file and bolt storages encode it, the JSON
+// of model.Share leaves the ids of the users out.
+type storedShare struct {
+	ID           uint      `yaml:"id" json:"id"`
+	NoteID       string    `yaml:"note_id" json:"note_id"`
+	OwnerID      uint      `yaml:"owner_id" json:"owner_id"`
+	UserID       uint      `yaml:"user_id" json:"user_id"`
+	UserPublicID string    `yaml:"user_public_id" json:"user_public_id"`
+	Role         acl.Role  `yaml:"role" json:"role"`
+	CreatedAt    time.Time `yaml:"created_at" json:"created_at"`
+	UpdatedAt    time.Time `yaml:"updated_at" json:"updated_at"`
+}
+
+// storedComment is a comment as the file and bolt storages encode it.
+type storedComment struct {
+	ID             uint      `yaml:"id" json:"id"`
+	NoteID         string    `yaml:"note_id" json:"note_id"`
+	AuthorID       uint      `yaml:"author_id" json:"author_id"`
+	AuthorPublicID string    `yaml:"author_public_id" json:"author_public_id"`
+	Body           string    `yaml:"body" json:"body"`
+	CreatedAt      time.Time `yaml:"created_at" json:"created_at"`
+}
+
+// shareUser checks user can be given share, user is nil when it does not
+// exist.
+func shareUser(share *model.Share, user *model.User) error {
+	if user == nil {
+		return ErrUserNotExist
+	}
+	if user.ID == share.OwnerID {
+		return ErrShareWithOwner
+	}
+	share.UserID = user.ID
+	return nil
+}
+
+// sortShares orders shares by id, the order they were created in.
+func sortShares(shares []*model.Share) {
+	sort.Slice(shares, func(i, j int) bool {
+		return shares[i].ID < shares[j].ID
+	})
+}
@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/lyquocnam/go-note-learning/model"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+var (
+	userBucket         = []byte("users")
+	userEmailBucket    = []byte("user_emails")
+	userPublicIDBucket = []byte("user_public_ids")
+	refreshTokenBucket = []byte("refresh_tokens")
+	shareBucket        = []byte("shares")
+	userShareBucket    = []byte("user_shares")
+	commentBucket      = []byte("comments")
+)
+
+// userBoltStorage keeps users as JSON in the "users" bucket keyed by id,
+// "user_emails" and "user_public_ids" map the email and the public id of
+// every user to its id. "refresh_tokens" is keyed by the jti. "shares" is
+// keyed by the public id of the note followed by the id of the user, the
+// keys of "user_shares" are the other way around. "comments" is keyed by
+// the public id of the note followed by the id of the comment.
+type userBoltStorage struct {
+	db *bolt.DB
+}
+
+// NewUserBoltStorage creates the buckets it needs when they are missing,
+// it can share db with NewNoteBoltStorage.
+func NewUserBoltStorage(db *bolt.DB) (*userBoltStorage, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{userBucket, userEmailBucket, userPublicIDBucket, refreshTokenBucket, shareBucket, userShareBucket, commentBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &userBoltStorage{db: db}, nil
+}
+
+func (b *userBoltStorage) GetUser(ctx context.Context, id uint) (*model.User, error) {
+	return b.findUser(ctx, func(tx *bolt.Tx) []byte {
+		return itob(id)
+	})
+}
+
+func (b *userBoltStorage) FindUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	return b.findUser(ctx, func(tx *bolt.Tx) []byte {
+		return tx.Bucket(userEmailBucket).Get([]byte(email))
+	})
+}
+
+func (b *userBoltStorage) FindUserByPublicID(ctx context.Context, publicID string) (*model.User, error) {
+	return b.findUser(ctx, func(tx *bolt.Tx) []byte {
+		return tx.Bucket(userPublicIDBucket).Get([]byte(publicID))
+	})
+}
+
+func (b *userBoltStorage) FirstUser(ctx context.Context) (*model.User, error) {
+	return b.findUser(ctx, func(tx *bolt.Tx) []byte {
+		// the keys are the ids in big endian
+		key, _ := tx.Bucket(userBucket).Cursor().First()
+		return key
+	})
+}
+
+// findUser returns the user whose key lookup returns, nil when it returns
+// nil.
+func (b *userBoltStorage) findUser(ctx context.Context, lookup func(tx *bolt.Tx) []byte) (*model.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var user *model.User
+	err := b.db.View(func(tx *bolt.Tx) error {
+		key := lookup(tx)
+		if key == nil {
+			return nil
+		}
+		data := tx.Bucket(userBucket).Get(key)
+		if data == nil {
+			return nil
+		}
+		var stored storedUser
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return err
+		}
+		found := model.User(stored)
+		user = &found
+		return nil
+	})
+	return user, err
+}
+
+func (b *userBoltStorage) InsertUser(ctx context.Context, user *model.User) (*model.User, error) {
+	if err := ctx.Err(); err != nil {
+		return user, err
+	}
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		emails := tx.Bucket(userEmailBucket)
+		if emails.Get([]byte(user.Email)) != nil {
+			return ErrDuplicateEmail
+		}
+
+		users := tx.Bucket(userBucket)
+		seq, err := users.NextSequence()
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		user.ID = uint(seq)
+		assignUserPublicID(user)
+		user.CreatedAt = now
+		user.UpdatedAt = now
+
+		data, err := json.Marshal(storedUser(*user))
+		if err != nil {
+			return err
+		}
+		if err := users.Put(itob(user.ID), data); err != nil {
+			return err
+		}
+		if err := emails.Put([]byte(user.Email), itob(user.ID)); err != nil {
+			return err
+		}
+		return tx.Bucket(userPublicIDBucket).Put([]byte(user.PublicID), itob(user.ID))
+	})
+	return user, err
+}
+
+// InsertRefreshToken also removes the expired tokens, they are refused
+// without being looked up.
+func (b *userBoltStorage) InsertRefreshToken(ctx context.Context, token *model.RefreshToken) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		now := time.Now()
+		tokens := tx.Bucket(refreshTokenBucket)
+		var expired [][]byte
+		err := tokens.ForEach(func(k, v []byte) error {
+			var stored storedRefreshToken
+			if err := json.Unmarshal(v, &stored); err != nil {
+				return err
+			}
+			if !now.Before(stored.ExpiresAt) {
+				expired = append(expired, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := tokens.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		if token.CreatedAt.IsZero() {
+			token.CreatedAt = now
+		}
+		return putBoltRefreshToken(tx, token)
+	})
+}
+
+func (b *userBoltStorage) GetRefreshToken(ctx context.Context, id string) (*model.RefreshToken, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var token *model.RefreshToken
+	err := b.db.View(func(tx *bolt.Tx) error {
+		var err error
+		token, err = getBoltRefreshToken(tx, id)
+		return err
+	})
+	return token, err
+}
+
+func (b *userBoltStorage) RevokeRefreshToken(ctx context.Context, id string, at time.Time) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	revoked := false
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		token, err := getBoltRefreshToken(tx, id)
+		if err != nil || token == nil || token.Revoked() {
+			return err
+		}
+		token.RevokedAt = &at
+		revoked = true
+		return putBoltRefreshToken(tx, token)
+	})
+	return revoked && err == nil, err
+}
+
+func (b *userBoltStorage) RevokeRefreshTokenFamily(ctx context.Context, familyID string, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		var family []*model.RefreshToken
+		err := tx.Bucket(refreshTokenBucket).ForEach(func(k, v []byte) error {
+			var stored storedRefreshToken
+			if err := json.Unmarshal(v, &stored); err != nil {
+				return err
+			}
+			if stored.FamilyID == familyID && stored.RevokedAt == nil {
+				token := model.RefreshToken(stored)
+				family = append(family, &token)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, token := range family {
+			token.RevokedAt = &at
+			if err := putBoltRefreshToken(tx, token); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func getBoltRefreshToken(tx *bolt.Tx, id string) (*model.RefreshToken, error) {
+	data := tx.Bucket(refreshTokenBucket).Get([]byte(id))
+	if data == nil {
+		return nil, nil
+	}
+	var stored storedRefreshToken
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+	token := model.RefreshToken(stored)
+	return &token, nil
+}
+
+func putBoltRefreshToken(tx *bolt.Tx, token *model.RefreshToken) error {
+	data, err := json.Marshal(storedRefreshToken(*token))
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(refreshTokenBucket).Put([]byte(token.ID), data)
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"context"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	"github.com/lyquocnam/go-note-learning/model"
+	"strings"
+	"time"
+)
+
+type userGormStorage struct {
+	db      *gorm.DB
+	logMode bool
+}
+
+// NewUserGormStorage keeps users in the users and refresh_tokens tables
+// MigrateGorm creates, the shares of the notes in the shares table and
+// their comments in the comments table, it works with any gorm dialect.
+func NewUserGormStorage(db *gorm.DB) *userGormStorage {
+	return &userGormStorage{db: db}
+}
+
+// LogMode prints every statement the storage runs, like gorm.DB.LogMode.
+func (u *userGormStorage) LogMode(enable bool) *userGormStorage {
+	u.logMode = enable
+	return u
+}
+
+func (u *userGormStorage) GetUser(ctx context.Context, id uint) (*model.User, error) {
+	return u.findUser(ctx, "id = ?", id)
+}
+
+func (u *userGormStorage) FindUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	return u.findUser(ctx, "email = ?", email)
+}
+
+func (u *userGormStorage) FindUserByPublicID(ctx context.Context, publicID string) (*model.User, error) {
+	return u.findUser(ctx, "public_id = ?", publicID)
+}
+
+func (u *userGormStorage) FirstUser(ctx context.Context) (*model.User, error) {
+	return u.findUser(ctx)
+}
+
+// findUser returns the user matching where with the lowest id, First
+// orders by the primary key, nil when there is none.
+func (u *userGormStorage) findUser(ctx context.Context, where ...interface{}) (*model.User, error) {
+	db, err := openGormContext(ctx, u.db, nil, u.logMode)
+	if err != nil {
+		return nil, err
+	}
+
+	var user model.User
+	err = db.First(&user, where...).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (u *userGormStorage) InsertUser(ctx context.Context, user *model.User) (*model.User, error) {
+	db, err := openGormContext(ctx, u.db, nil, u.logMode)
+	if err != nil {
+		return user, err
+	}
+
+	assignUserPublicID(user)
+	err = db.Create(user).Error
+	if isGormEmailViolation(err) {
+		return user, ErrDuplicateEmail
+	}
+	return user, err
+}
+
+func (u *userGormStorage) InsertRefreshToken(ctx context.Context, token *model.RefreshToken) error {
+	db, err := openGormContext(ctx, u.db, nil, u.logMode)
+	if err != nil {
+		return err
+	}
+	return db.Create(token).Error
+}
+
+func (u *userGormStorage) GetRefreshToken(ctx context.Context, id string) (*model.RefreshToken, error) {
+	db, err := openGormContext(ctx, u.db, nil, u.logMode)
+	if err != nil {
+		return nil, err
+	}
+
+	var token model.RefreshToken
+	err = db.First(&token, "id = ?", id).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// RevokeRefreshToken relies on the single UPDATE matching the token only
+// while it is not revoked, the database lets one caller win.
+func (u *userGormStorage) RevokeRefreshToken(ctx context.Context, id string, at time.Time) (bool, error) {
+	db, err := openGormContext(ctx, u.db, nil, u.logMode)
+	if err != nil {
+		return false, err
+	}
+
+	result := db.Model(&model.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		UpdateColumn("revoked_at", at)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (u *userGormStorage) RevokeRefreshTokenFamily(ctx context.Context, familyID string, at time.Time) error {
+	db, err := openGormContext(ctx, u.db, nil, u.logMode)
+	if err != nil {
+		return err
+	}
+
+	return db.Model(&model.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		UpdateColumn("revoked_at", at).Error
+}
+
+// isGormEmailViolation tells whether err violates the unique index on the
+// email of the users.
+func isGormEmailViolation(err error) bool {
+	if pqErr, ok := err.(*pq.Error); ok {
+		return pqErr.Code == "23505" && pqErr.Constraint == "uix_users_email"
+	}
+	// the sqlite driver needs cgo, its error is matched on the message
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed: users.email")
+}
@@ -0,0 +1,312 @@
+package storage
+
+import (
+	"context"
+	"github.com/lyquocnam/go-note-learning/model"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+type userMemoryStorage struct {
+	mu        sync.RWMutex
+	lastID    uint
+	users     map[uint]*model.User
+	emails    map[string]uint
+	publicIDs map[string]uint
+	tokens    map[string]*model.RefreshToken
+	// shares are keyed by the note and the user they give a role to
+	lastShareID uint
+	shares      map[shareKey]*model.Share
+	// comments are keyed by the public id of their note, the oldest first
+	lastCommentID uint
+	comments      map[string][]*model.Comment
+	// path is the file every write is saved to, empty keeps everything in
+	// memory
+	path string
+}
+
+// NewUserMemoryStorage keeps users, shares and comments in process memory,
+// everything is lost when the process exits. Meant for tests and local
+// development.
+func NewUserMemoryStorage() *userMemoryStorage {
+	return &userMemoryStorage{
+		users:     make(map[uint]*model.User),
+		emails:    make(map[string]uint),
+		publicIDs: make(map[string]uint),
+		tokens:    make(map[string]*model.RefreshToken),
+		shares:    make(map[shareKey]*model.Share),
+		comments:  make(map[string][]*model.Comment),
+	}
+}
+
+// userFile is the content of the file of NewUserFileStorage.
+type userFile struct {
+	LastID        uint                 `yaml:"last_id"`
+	Users         []storedUser         `yaml:"users"`
+	RefreshTokens []storedRefreshToken `yaml:"refresh_tokens"`
+	LastShareID   uint                 `yaml:"last_share_id"`
+	Shares        []storedShare        `yaml:"shares"`
+	LastCommentID uint                 `yaml:"last_comment_id"`
+	Comments      []storedComment      `yaml:"comments"`
+}
+
+// NewUserFileStorage keeps users, shares and comments in memory and saves
+// them to the YAML file at path after every write, it is the user storage
+// of the markdown driver. Only the owner may read the file, it holds the
+// password hashes.
+func NewUserFileStorage(path string) (*userMemoryStorage, error) {
+	m := NewUserMemoryStorage()
+	m.path = path
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file userFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	m.lastID = file.LastID
+	for _, u := range file.Users {
+		user := model.User(u)
+		m.users[user.ID] = &user
+		m.emails[user.Email] = user.ID
+		m.publicIDs[user.PublicID] = user.ID
+	}
+	for _, t := range file.RefreshTokens {
+		token := model.RefreshToken(t)
+		m.tokens[token.ID] = &token
+	}
+	m.lastShareID = file.LastShareID
+	for _, s := range file.Shares {
+		share := model.Share(s)
+		m.shares[shareKey{share.NoteID, share.UserID}] = &share
+	}
+	m.lastCommentID = file.LastCommentID
+	for _, c := range file.Comments {
+		comment := model.Comment(c)
+		m.comments[comment.NoteID] = append(m.comments[comment.NoteID], &comment)
+	}
+	return m, nil
+}
+
+func (m *userMemoryStorage) GetUser(ctx context.Context, id uint) (*model.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return copyUser(m.users[id]), nil
+}
+
+func (m *userMemoryStorage) FindUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, ok := m.emails[email]
+	if !ok {
+		return nil, nil
+	}
+	return copyUser(m.users[id]), nil
+}
+
+func (m *userMemoryStorage) FirstUser(ctx context.Context) (*model.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var first *model.User
+	for _, user := range m.users {
+		if first == nil || user.ID < first.ID {
+			first = user
+		}
+	}
+	return copyUser(first), nil
+}
+
+func (m *userMemoryStorage) FindUserByPublicID(ctx context.Context, publicID string) (*model.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, ok := m.publicIDs[publicID]
+	if !ok {
+		return nil, nil
+	}
+	return copyUser(m.users[id]), nil
+}
+
+func (m *userMemoryStorage) InsertUser(ctx context.Context, user *model.User) (*model.User, error) {
+	if err := ctx.Err(); err != nil {
+		return user, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.emails[user.Email]; ok {
+		return user, ErrDuplicateEmail
+	}
+
+	now := time.Now()
+	m.lastID++
+	user.ID = m.lastID
+	assignUserPublicID(user)
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	m.users[user.ID] = copyUser(user)
+	m.emails[user.Email] = user.ID
+	m.publicIDs[user.PublicID] = user.ID
+	if err := m.save(); err != nil {
+		delete(m.users, user.ID)
+		delete(m.emails, user.Email)
+		delete(m.publicIDs, user.PublicID)
+		return user, err
+	}
+	return user, nil
+}
+
+// InsertRefreshToken also forgets the expired tokens, they are refused
+// without being looked up.
+func (m *userMemoryStorage) InsertRefreshToken(ctx context.Context, token *model.RefreshToken) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for id, t := range m.tokens {
+		if !now.Before(t.ExpiresAt) {
+			delete(m.tokens, id)
+		}
+	}
+	if token.CreatedAt.IsZero() {
+		token.CreatedAt = now
+	}
+	stored := *token
+	m.tokens[token.ID] = &stored
+	if err := m.save(); err != nil {
+		delete(m.tokens, token.ID)
+		return err
+	}
+	return nil
+}
+
+func (m *userMemoryStorage) GetRefreshToken(ctx context.Context, id string) (*model.RefreshToken, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	token, ok := m.tokens[id]
+	if !ok {
+		return nil, nil
+	}
+	found := *token
+	return &found, nil
+}
+
+func (m *userMemoryStorage) RevokeRefreshToken(ctx context.Context, id string, at time.Time) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.tokens[id]
+	if !ok || token.RevokedAt != nil {
+		return false, nil
+	}
+	token.RevokedAt = &at
+	if err := m.save(); err != nil {
+		token.RevokedAt = nil
+		return false, err
+	}
+	return true, nil
+}
+
+func (m *userMemoryStorage) RevokeRefreshTokenFamily(ctx context.Context, familyID string, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var revoked []*model.RefreshToken
+	for _, token := range m.tokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			token.RevokedAt = &at
+			revoked = append(revoked, token)
+		}
+	}
+	if err := m.save(); err != nil {
+		for _, token := range revoked {
+			token.RevokedAt = nil
+		}
+		return err
+	}
+	return nil
+}
+
+// save writes the users, the tokens, the shares and the comments to the
+// file of the storage, the lock must be held.
+func (m *userMemoryStorage) save() error {
+	if m.path == "" {
+		return nil
+	}
+	file := userFile{LastID: m.lastID, LastShareID: m.lastShareID, LastCommentID: m.lastCommentID}
+	for id := uint(1); id <= m.lastID; id++ {
+		if user, ok := m.users[id]; ok {
+			file.Users = append(file.Users, storedUser(*user))
+		}
+	}
+	for _, token := range m.tokens {
+		file.RefreshTokens = append(file.RefreshTokens, storedRefreshToken(*token))
+	}
+	sort.Slice(file.RefreshTokens, func(i, j int) bool {
+		return file.RefreshTokens[i].ID < file.RefreshTokens[j].ID
+	})
+	for _, share := range m.shares {
+		file.Shares = append(file.Shares, storedShare(*share))
+	}
+	sort.Slice(file.Shares, func(i, j int) bool {
+		return file.Shares[i].ID < file.Shares[j].ID
+	})
+	for _, comments := range m.comments {
+		for _, comment := range comments {
+			file.Comments = append(file.Comments, storedComment(*comment))
+		}
+	}
+	sort.Slice(file.Comments, func(i, j int) bool {
+		return file.Comments[i].ID < file.Comments[j].ID
+	})
+	data, err := yaml.Marshal(file)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(m.path, data, 0600)
+}
+
+func copyUser(user *model.User) *model.User {
+	if user == nil {
+		return nil
+	}
+	c := *user
+	return &c
+}
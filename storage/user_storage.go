@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/lyquocnam/go-note-learning/model"
+	"github.com/lyquocnam/go-note-learning/ulid"
+	"time"
+)
+
+// ErrDuplicateEmail is returned when a user is inserted with the email of
+// another user.
+var ErrDuplicateEmail = errors.New("storage: user email already exists")
+
+// UserStorage keeps the users and the refresh tokens issued to them. A
+// user or a token that does not exist is nil rather than an error.
+type UserStorage interface {
+	GetUser(ctx context.Context, id uint) (*model.User, error)
+	FindUserByEmail(ctx context.Context, email string) (*model.User, error)
+	FindUserByPublicID(ctx context.Context, publicID string) (*model.User, error)
+	// FirstUser returns the user registered first, nil when there is none.
+	FirstUser(ctx context.Context) (*model.User, error)
+	// InsertUser gives the user its ID and a PublicID, it returns
+	// ErrDuplicateEmail when another user has its email.
+	InsertUser(ctx context.Context, user *model.User) (*model.User, error)
+	InsertRefreshToken(ctx context.Context, token *model.RefreshToken) error
+	GetRefreshToken(ctx context.Context, id string) (*model.RefreshToken, error)
+	// RevokeRefreshToken revokes the token at at, it returns false when the
+	// token does not exist or was already revoked. When it is called for the
+	// same token concurrently, only one call returns true.
+	RevokeRefreshToken(ctx context.Context, id string, at time.Time) (bool, error)
+	// RevokeRefreshTokenFamily revokes every token of the family that is
+	// not revoked yet.
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string, at time.Time) error
+}
+
+// storedUser is a user as the file and bolt storages encode it, the JSON
+// of model.User leaves the id and the password hash out.
+type storedUser struct {
+	ID           uint      `yaml:"id" json:"id"`
+	PublicID     string    `yaml:"public_id" json:"public_id"`
+	CreatedAt    time.Time `yaml:"created_at" json:"created_at"`
+	UpdatedAt    time.Time `yaml:"updated_at" json:"updated_at"`
+	Email        string    `yaml:"email" json:"email"`
+	PasswordHash string    `yaml:"password_hash" json:"password_hash"`
+}
+
+type storedRefreshToken struct {
+	ID        string     `yaml:"id" json:"id"`
+	UserID    uint       `yaml:"user_id" json:"user_id"`
+	FamilyID  string     `yaml:"family_id" json:"family_id"`
+	CreatedAt time.Time  `yaml:"created_at" json:"created_at"`
+	ExpiresAt time.Time  `yaml:"expires_at" json:"expires_at"`
+	RevokedAt *time.Time `yaml:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+}
+
+// assignUserPublicID gives user a new ULID unless it already has a public
+// id.
+func assignUserPublicID(user *model.User) {
+	if user.PublicID == "" {
+		user.PublicID = ulid.New().String()
+	}
+}
@@ -0,0 +1,421 @@
+package storage
+
+import (
+	"context"
+	"github.com/jinzhu/gorm"
+	"github.com/lyquocnam/go-note-learning/acl"
+	"github.com/lyquocnam/go-note-learning/model"
+	"github.com/lyquocnam/go-note-learning/ulid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testUserStorage is the behaviour every UserStorage backend must share.
+// newStorage must return an empty storage and a func releasing it.
+func testUserStorage(t *testing.T, newStorage func(t *testing.T) (UserStorage, func())) {
+	ctx := context.Background()
+
+	t.Run("insert and find", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+
+		first, err := s.FirstUser(ctx)
+		require.NoError(t, err)
+		assert.Nil(t, first)
+
+		inserted, err := s.InsertUser(ctx, &model.User{Email: "ann@example.com", PasswordHash: "hash"})
+		require.NoError(t, err)
+		require.NotZero(t, inserted.ID)
+		_, err = ulid.Parse(inserted.PublicID)
+		assert.NoError(t, err)
+		assert.False(t, inserted.CreatedAt.IsZero())
+
+		for name, find := range map[string]func() (*model.User, error){
+			"id":        func() (*model.User, error) { return s.GetUser(ctx, inserted.ID) },
+			"email":     func() (*model.User, error) { return s.FindUserByEmail(ctx, "ann@example.com") },
+			"public id": func() (*model.User, error) { return s.FindUserByPublicID(ctx, inserted.PublicID) },
+			"first":     func() (*model.User, error) { return s.FirstUser(ctx) },
+		} {
+			user, err := find()
+			require.NoError(t, err, name)
+			require.NotNil(t, user, name)
+			assert.Equal(t, inserted.ID, user.ID, name)
+			assert.Equal(t, inserted.PublicID, user.PublicID, name)
+			assert.Equal(t, "ann@example.com", user.Email, name)
+			assert.Equal(t, "hash", user.PasswordHash, name)
+		}
+
+		_, err = s.InsertUser(ctx, &model.User{Email: "abe@example.com", PasswordHash: "hash"})
+		require.NoError(t, err)
+		first, err = s.FirstUser(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, first)
+		assert.Equal(t, inserted.ID, first.ID, "the first user is the oldest, not the first email")
+
+		user, err := s.GetUser(ctx, inserted.ID+2)
+		assert.NoError(t, err)
+		assert.Nil(t, user)
+		user, err = s.FindUserByEmail(ctx, "bob@example.com")
+		assert.NoError(t, err)
+		assert.Nil(t, user)
+		user, err = s.FindUserByPublicID(ctx, ulid.New().String())
+		assert.NoError(t, err)
+		assert.Nil(t, user)
+	})
+
+	t.Run("emails are unique", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+
+		first, err := s.InsertUser(ctx, &model.User{Email: "ann@example.com", PasswordHash: "hash"})
+		require.NoError(t, err)
+		_, err = s.InsertUser(ctx, &model.User{Email: "ann@example.com", PasswordHash: "other"})
+		assert.Equal(t, ErrDuplicateEmail, err)
+
+		second, err := s.InsertUser(ctx, &model.User{Email: "bob@example.com", PasswordHash: "hash"})
+		require.NoError(t, err)
+		assert.NotEqual(t, first.ID, second.ID)
+		assert.NotEqual(t, first.PublicID, second.PublicID)
+	})
+
+	t.Run("refresh tokens", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+
+		user, err := s.InsertUser(ctx, &model.User{Email: "ann@example.com", PasswordHash: "hash"})
+		require.NoError(t, err)
+		expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+		family := ulid.New().String()
+		first := &model.RefreshToken{ID: family, UserID: user.ID, FamilyID: family, ExpiresAt: expiresAt}
+		second := &model.RefreshToken{ID: ulid.New().String(), UserID: user.ID, FamilyID: family, ExpiresAt: expiresAt}
+		other := &model.RefreshToken{ID: ulid.New().String(), UserID: user.ID, FamilyID: ulid.New().String(), ExpiresAt: expiresAt}
+		for _, token := range []*model.RefreshToken{first, second, other} {
+			require.NoError(t, s.InsertRefreshToken(ctx, token))
+		}
+
+		token, err := s.GetRefreshToken(ctx, first.ID)
+		require.NoError(t, err)
+		require.NotNil(t, token)
+		assert.Equal(t, user.ID, token.UserID)
+		assert.Equal(t, family, token.FamilyID)
+		assert.True(t, expiresAt.Equal(token.ExpiresAt))
+		assert.False(t, token.Revoked())
+
+		token, err = s.GetRefreshToken(ctx, ulid.New().String())
+		assert.NoError(t, err)
+		assert.Nil(t, token)
+
+		revoked, err := s.RevokeRefreshToken(ctx, first.ID, time.Now())
+		require.NoError(t, err)
+		assert.True(t, revoked)
+		revoked, err = s.RevokeRefreshToken(ctx, first.ID, time.Now())
+		require.NoError(t, err)
+		assert.False(t, revoked, "already revoked")
+		revoked, err = s.RevokeRefreshToken(ctx, ulid.New().String(), time.Now())
+		require.NoError(t, err)
+		assert.False(t, revoked, "missing")
+
+		token, err = s.GetRefreshToken(ctx, first.ID)
+		require.NoError(t, err)
+		assert.True(t, token.Revoked())
+
+		require.NoError(t, s.RevokeRefreshTokenFamily(ctx, family, time.Now()))
+		token, err = s.GetRefreshToken(ctx, second.ID)
+		require.NoError(t, err)
+		assert.True(t, token.Revoked())
+		token, err = s.GetRefreshToken(ctx, other.ID)
+		require.NoError(t, err)
+		assert.False(t, token.Revoked(), "another family")
+	})
+
+	t.Run("concurrent revocations", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+
+		user, err := s.InsertUser(ctx, &model.User{Email: "ann@example.com", PasswordHash: "hash"})
+		require.NoError(t, err)
+		id := ulid.New().String()
+		require.NoError(t, s.InsertRefreshToken(ctx, &model.RefreshToken{ID: id, UserID: user.ID, FamilyID: id, ExpiresAt: time.Now().Add(time.Hour)}))
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		wins := 0
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				revoked, err := s.RevokeRefreshToken(ctx, id, time.Now())
+				assert.NoError(t, err)
+				if revoked {
+					mu.Lock()
+					wins++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 1, wins)
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err := s.InsertUser(cancelled, &model.User{Email: "ann@example.com", PasswordHash: "hash"})
+		assert.Equal(t, context.Canceled, err)
+		user, err := s.FindUserByEmail(ctx, "ann@example.com")
+		assert.NoError(t, err)
+		assert.Nil(t, user)
+	})
+
+	t.Run("shares", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+		shares := s.(ShareStorage)
+
+		ann, err := s.InsertUser(ctx, &model.User{Email: "ann@example.com", PasswordHash: "hash"})
+		require.NoError(t, err)
+		bob, err := s.InsertUser(ctx, &model.User{Email: "bob@example.com", PasswordHash: "hash"})
+		require.NoError(t, err)
+		cat, err := s.InsertUser(ctx, &model.User{Email: "cat@example.com", PasswordHash: "hash"})
+		require.NoError(t, err)
+		note, other := ulid.New().String(), ulid.New().String()
+
+		share, err := shares.PutShare(ctx, &model.Share{NoteID: note, OwnerID: ann.ID, UserPublicID: bob.PublicID, Role: acl.Viewer})
+		require.NoError(t, err)
+		assert.NotZero(t, share.ID)
+		assert.Equal(t, bob.ID, share.UserID)
+		assert.False(t, share.CreatedAt.IsZero())
+		_, err = shares.PutShare(ctx, &model.Share{NoteID: note, OwnerID: ann.ID, UserPublicID: cat.PublicID, Role: acl.Editor})
+		require.NoError(t, err)
+		_, err = shares.PutShare(ctx, &model.Share{NoteID: other, OwnerID: ann.ID, UserPublicID: bob.PublicID, Role: acl.Commenter})
+		require.NoError(t, err)
+
+		_, err = shares.PutShare(ctx, &model.Share{NoteID: note, OwnerID: ann.ID, UserPublicID: ann.PublicID, Role: acl.Viewer})
+		assert.Equal(t, ErrShareWithOwner, err)
+		_, err = shares.PutShare(ctx, &model.Share{NoteID: note, OwnerID: ann.ID, UserPublicID: ulid.New().String(), Role: acl.Viewer})
+		assert.Equal(t, ErrUserNotExist, err)
+
+		// sharing again changes the role
+		updated, err := shares.PutShare(ctx, &model.Share{NoteID: note, OwnerID: ann.ID, UserPublicID: bob.PublicID, Role: acl.Editor})
+		require.NoError(t, err)
+		assert.Equal(t, share.ID, updated.ID)
+		found, err := shares.FindShare(ctx, note, bob.ID)
+		require.NoError(t, err)
+		require.NotNil(t, found)
+		assert.Equal(t, acl.Editor, found.Role)
+		assert.Equal(t, ann.ID, found.OwnerID)
+		assert.Equal(t, bob.PublicID, found.UserPublicID)
+		assert.Equal(t, share.CreatedAt.Unix(), found.CreatedAt.Unix())
+
+		found, err = shares.FindShare(ctx, note, ann.ID)
+		assert.NoError(t, err)
+		assert.Nil(t, found)
+
+		noteShares, err := shares.GetShares(ctx, note)
+		require.NoError(t, err)
+		require.Len(t, noteShares, 2)
+		assert.Equal(t, bob.ID, noteShares[0].UserID)
+		assert.Equal(t, cat.ID, noteShares[1].UserID)
+		withBob, err := shares.GetSharesWith(ctx, bob.ID)
+		require.NoError(t, err)
+		require.Len(t, withBob, 2)
+		assert.Equal(t, note, withBob[0].NoteID)
+		assert.Equal(t, other, withBob[1].NoteID)
+
+		deleted, err := shares.DeleteShare(ctx, note, bob.ID)
+		require.NoError(t, err)
+		assert.True(t, deleted)
+		deleted, err = shares.DeleteShare(ctx, note, bob.ID)
+		require.NoError(t, err)
+		assert.False(t, deleted)
+		withBob, err = shares.GetSharesWith(ctx, bob.ID)
+		require.NoError(t, err)
+		assert.Len(t, withBob, 1)
+
+		require.NoError(t, shares.DeleteShares(ctx, note))
+		noteShares, err = shares.GetShares(ctx, note)
+		require.NoError(t, err)
+		assert.Empty(t, noteShares)
+		withCat, err := shares.GetSharesWith(ctx, cat.ID)
+		require.NoError(t, err)
+		assert.Empty(t, withCat)
+		noteShares, err = shares.GetShares(ctx, other)
+		require.NoError(t, err)
+		assert.Len(t, noteShares, 1)
+	})
+
+	t.Run("comments", func(t *testing.T) {
+		s, closeFn := newStorage(t)
+		defer closeFn()
+		comments := s.(ShareStorage)
+
+		ann, err := s.InsertUser(ctx, &model.User{Email: "ann@example.com", PasswordHash: "hash"})
+		require.NoError(t, err)
+		note, other := ulid.New().String(), ulid.New().String()
+		insert := func(noteID, body string) *model.Comment {
+			comment, err := comments.InsertComment(ctx, &model.Comment{NoteID: noteID, AuthorID: ann.ID, AuthorPublicID: ann.PublicID, Body: body})
+			require.NoError(t, err)
+			return comment
+		}
+
+		first := insert(note, "first")
+		assert.NotZero(t, first.ID)
+		assert.False(t, first.CreatedAt.IsZero())
+		insert(other, "elsewhere")
+		insert(note, "second")
+
+		found, err := comments.GetComments(ctx, note)
+		require.NoError(t, err)
+		require.Len(t, found, 2)
+		assert.Equal(t, "first", found[0].Body)
+		assert.Equal(t, "second", found[1].Body)
+		assert.Equal(t, ann.ID, found[0].AuthorID)
+		assert.Equal(t, ann.PublicID, found[0].AuthorPublicID)
+
+		require.NoError(t, comments.DeleteComments(ctx, note))
+		found, err = comments.GetComments(ctx, note)
+		require.NoError(t, err)
+		assert.Empty(t, found)
+		found, err = comments.GetComments(ctx, other)
+		require.NoError(t, err)
+		assert.Len(t, found, 1)
+	})
+}
+
+func TestUserMemoryStorage(t *testing.T) {
+	testUserStorage(t, func(t *testing.T) (UserStorage, func()) {
+		return NewUserMemoryStorage(), func() {}
+	})
+}
+
+func TestUserFileStorage(t *testing.T) {
+	testUserStorage(t, func(t *testing.T) (UserStorage, func()) {
+		dir, err := ioutil.TempDir("", "users")
+		require.NoError(t, err)
+		s, err := NewUserFileStorage(filepath.Join(dir, ".users.yaml"))
+		require.NoError(t, err)
+		return s, func() { os.RemoveAll(dir) }
+	})
+
+	t.Run("reopen", func(t *testing.T) {
+		ctx := context.Background()
+		dir, err := ioutil.TempDir("", "users")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, ".users.yaml")
+
+		s, err := NewUserFileStorage(path)
+		require.NoError(t, err)
+		ann, err := s.InsertUser(ctx, &model.User{Email: "ann@example.com", PasswordHash: "hash"})
+		require.NoError(t, err)
+		id := ulid.New().String()
+		require.NoError(t, s.InsertRefreshToken(ctx, &model.RefreshToken{ID: id, UserID: ann.ID, FamilyID: id, ExpiresAt: time.Now().Add(time.Hour)}))
+		_, err = s.RevokeRefreshToken(ctx, id, time.Now())
+		require.NoError(t, err)
+
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "the file holds password hashes")
+
+		s, err = NewUserFileStorage(path)
+		require.NoError(t, err)
+		user, err := s.FindUserByPublicID(ctx, ann.PublicID)
+		require.NoError(t, err)
+		require.NotNil(t, user)
+		assert.Equal(t, ann.ID, user.ID)
+		assert.Equal(t, "hash", user.PasswordHash)
+		token, err := s.GetRefreshToken(ctx, id)
+		require.NoError(t, err)
+		require.NotNil(t, token)
+		assert.True(t, token.Revoked())
+
+		bob, err := s.InsertUser(ctx, &model.User{Email: "bob@example.com", PasswordHash: "hash"})
+		require.NoError(t, err)
+		assert.Equal(t, ann.ID+1, bob.ID, "ids are not reused")
+
+		note := ulid.New().String()
+		_, err = s.PutShare(ctx, &model.Share{NoteID: note, OwnerID: ann.ID, UserPublicID: bob.PublicID, Role: acl.Commenter})
+		require.NoError(t, err)
+		s, err = NewUserFileStorage(path)
+		require.NoError(t, err)
+		found, err := s.FindShare(ctx, note, bob.ID)
+		require.NoError(t, err)
+		require.NotNil(t, found)
+		assert.Equal(t, acl.Commenter, found.Role)
+		assert.Equal(t, bob.PublicID, found.UserPublicID)
+		share, err := s.PutShare(ctx, &model.Share{NoteID: ulid.New().String(), OwnerID: bob.ID, UserPublicID: ann.PublicID, Role: acl.Viewer})
+		require.NoError(t, err)
+		assert.Equal(t, found.ID+1, share.ID, "share ids are not reused")
+
+		_, err = s.InsertComment(ctx, &model.Comment{NoteID: note, AuthorID: bob.ID, AuthorPublicID: bob.PublicID, Body: "nice"})
+		require.NoError(t, err)
+		s, err = NewUserFileStorage(path)
+		require.NoError(t, err)
+		comments, err := s.GetComments(ctx, note)
+		require.NoError(t, err)
+		require.Len(t, comments, 1)
+		assert.Equal(t, "nice", comments[0].Body)
+		assert.Equal(t, bob.ID, comments[0].AuthorID)
+	})
+}
+
+func TestUserSqliteStorage(t *testing.T) {
+	testUserStorage(t, func(t *testing.T) (UserStorage, func()) {
+		dir, err := ioutil.TempDir("", "users")
+		require.NoError(t, err)
+		// the concurrent revocations each open a connection
+		db, err := gorm.Open("sqlite3", filepath.Join(dir, "users.db")+"?_busy_timeout=5000")
+		require.NoError(t, err)
+		require.NoError(t, MigrateGorm(db))
+		return NewUserGormStorage(db), func() {
+			db.Close()
+			os.RemoveAll(dir)
+		}
+	})
+}
+
+func TestUserBoltStorage(t *testing.T) {
+	testUserStorage(t, func(t *testing.T) (UserStorage, func()) {
+		dir, err := ioutil.TempDir("", "users")
+		require.NoError(t, err)
+		db, err := bolt.Open(filepath.Join(dir, "notes.bolt"), 0600, nil)
+		require.NoError(t, err)
+		// users share the database of the notes
+		_, err = NewNoteBoltStorage(db)
+		require.NoError(t, err)
+		s, err := NewUserBoltStorage(db)
+		require.NoError(t, err)
+		return s, func() {
+			db.Close()
+			os.RemoveAll(dir)
+		}
+	})
+}
+
+func TestUserPostgresStorage(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	testUserStorage(t, func(t *testing.T) (UserStorage, func()) {
+		db, err := gorm.Open("postgres", url)
+		require.NoError(t, err)
+		require.NoError(t, db.DropTableIfExists(model.Comment{}, model.Share{}, model.RefreshToken{}, model.User{}).Error)
+		require.NoError(t, MigrateGorm(db))
+		return NewUserGormStorage(db), func() {
+			db.DropTableIfExists(model.Comment{}, model.Share{}, model.RefreshToken{}, model.User{})
+			db.Close()
+		}
+	})
+}
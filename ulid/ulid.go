@@ -0,0 +1,171 @@
+// Package ulid generates and parses ULIDs: 128 bit identifiers made of a
+// millisecond timestamp and 80 random bits, written as 26 characters of
+// Crockford's base32 so they sort by creation time.
+package ulid
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrInvalid is returned by Parse for a string that is not a ULID.
+var ErrInvalid = errors.New("ulid: invalid ULID")
+
+// Length is the length of the string form of a ULID.
+const Length = 26
+
+const alphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID is the binary form, the timestamp in the first 6 bytes big endian.
+type ULID [16]byte
+
+var (
+	mu       sync.Mutex
+	last     ULID
+	lastTime uint64
+	entropy  io.Reader = rand.Reader
+)
+
+// New returns a ULID for the current time. ULIDs made in the same
+// millisecond increment the random part of the previous one, so they still
+// sort in the order they were made.
+func New() ULID {
+	mu.Lock()
+	defer mu.Unlock()
+
+	ms := timestamp(time.Now())
+	if ms <= lastTime {
+		next := last
+		if increment(&next) {
+			last = next
+			return last
+		}
+		// the random part overflowed, move on to the next millisecond
+		ms = lastTime + 1
+	}
+	last = newAt(ms)
+	lastTime = ms
+	return last
+}
+
+// NewAt returns a ULID for t with a random part, it is not ordered with
+// the other ULIDs of the same millisecond.
+func NewAt(t time.Time) ULID {
+	return newAt(timestamp(t))
+}
+
+// Parse reads the string form of a ULID, either case. It rejects anything
+// but exactly 26 characters of the alphabet and timestamps above 48 bits.
+func Parse(s string) (ULID, error) {
+	var id ULID
+	if len(s) != Length {
+		return id, ErrInvalid
+	}
+	var digits [Length]byte
+	for i := 0; i < Length; i++ {
+		c := s[i]
+		if c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		d := decoding[c]
+		if d == 0xFF {
+			return id, ErrInvalid
+		}
+		digits[i] = d
+	}
+	// 26 digits are 130 bits, the 2 highest must be zero
+	if digits[0] > 7 {
+		return id, ErrInvalid
+	}
+
+	// big endian base32 to bytes, the first digit holds 3 bits
+	var acc uint
+	bits := uint(0)
+	n := 0
+	for i, d := range digits {
+		if i == 0 {
+			acc, bits = uint(d), 3
+			continue
+		}
+		acc = acc<<5 | uint(d)
+		bits += 5
+		if bits >= 8 {
+			bits -= 8
+			id[n] = byte(acc >> bits)
+			n++
+			acc &= 1<<bits - 1
+		}
+	}
+	return id, nil
+}
+
+// String returns the 26 uppercase characters of id.
+func (id ULID) String() string {
+	var out [Length]byte
+	// walk the 128 bits from the lowest, 5 at a time
+	var acc uint
+	bits := uint(0)
+	n := Length - 1
+	for i := len(id) - 1; i >= 0; i-- {
+		acc |= uint(id[i]) << bits
+		bits += 8
+		for bits >= 5 {
+			out[n] = alphabet[acc&31]
+			n--
+			acc >>= 5
+			bits -= 5
+		}
+	}
+	out[0] = alphabet[acc&31]
+	return string(out[:])
+}
+
+// Time returns the timestamp of id.
+func (id ULID) Time() time.Time {
+	var ms uint64
+	for _, b := range id[:6] {
+		ms = ms<<8 | uint64(b)
+	}
+	return time.Unix(int64(ms/1000), int64(ms%1000)*int64(time.Millisecond))
+}
+
+var decoding = func() [256]byte {
+	var table [256]byte
+	for i := range table {
+		table[i] = 0xFF
+	}
+	for i := 0; i < len(alphabet); i++ {
+		table[alphabet[i]] = byte(i)
+	}
+	return table
+}()
+
+func timestamp(t time.Time) uint64 {
+	return uint64(t.UnixNano() / int64(time.Millisecond))
+}
+
+func newAt(ms uint64) ULID {
+	var id ULID
+	for i := 5; i >= 0; i-- {
+		id[i] = byte(ms)
+		ms >>= 8
+	}
+	if _, err := io.ReadFull(entropy, id[6:]); err != nil {
+		panic("ulid: reading random bytes: " + err.Error())
+	}
+	return id
+}
+
+// increment adds one to the random part of id, false when it overflows.
+func increment(id *ULID) bool {
+	for i := len(id) - 1; i >= 6; i-- {
+		id[i]++
+		if id[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
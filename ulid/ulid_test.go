@@ -0,0 +1,58 @@
+package ulid
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		valid bool
+	}{
+		{"canonical", "01ARZ3NDEKTSV4RRFFQ69G5FAV", true},
+		{"lowercase", "01arz3ndektsv4rrffq69g5fav", true},
+		{"largest", "7ZZZZZZZZZZZZZZZZZZZZZZZZZ", true},
+		{"zero", "00000000000000000000000000", true},
+		{"too short", "01ARZ3NDEKTSV4RRFFQ69G5FA", false},
+		{"too long", "01ARZ3NDEKTSV4RRFFQ69G5FAVV", false},
+		{"empty", "", false},
+		{"number", "1", false},
+		{"overflow", "80000000000000000000000000", false},
+		{"excluded letter", "01ARZ3NDEKTSV4RRFFQ69G5FAU", false},
+		{"ambiguous letter", "01ARZ3NDEKTSV4RRFFQ69G5FAI", false},
+		{"symbol", "01ARZ3NDEKTSV4RRFFQ69G5FA-", false},
+		{"multi byte", "01ARZ3NDEKTSV4RRFFQ69G5Fé", false},
+	}
+	for _, c := range cases {
+		id, err := Parse(c.input)
+		if !c.valid {
+			assert.Equal(t, ErrInvalid, err, c.name)
+			continue
+		}
+		require.NoError(t, err, c.name)
+		assert.Equal(t, bytes.ToUpper([]byte(c.input)), []byte(id.String()), c.name)
+	}
+}
+
+func TestULID_Time(t *testing.T) {
+	id, err := Parse("01ARZ3NDEKTSV4RRFFQ69G5FAV")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1469922850259), id.Time().UnixNano()/int64(time.Millisecond))
+
+	at := time.Date(2019, 3, 1, 10, 20, 30, 456000000, time.UTC)
+	assert.True(t, at.Equal(NewAt(at).Time()))
+}
+
+func TestNew(t *testing.T) {
+	previous := New()
+	for i := 0; i < 10000; i++ {
+		id := New()
+		assert.True(t, previous.String() < id.String(), "%s after %s", id, previous)
+		previous = id
+	}
+}